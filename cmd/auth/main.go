@@ -15,6 +15,7 @@ import (
 	"authmicro/internal/repository/postgres"
 	"authmicro/internal/service"
 	"authmicro/pkg/logger"
+	"authmicro/pkg/mailaddr"
 )
 
 func main() {
@@ -46,14 +47,47 @@ func main() {
 	userRepo := postgres.NewUserRepository(db)
 	sessionRepo := postgres.NewSessionRepository(db)
 	roleRepo := postgres.NewRoleRepository(db)
+	permissionRepo := postgres.NewPermissionRepository(db)
+	policyRepo := postgres.NewPolicyRepository(db)
+	orgRepo := postgres.NewOrganizationRepository(db)
+	invitationRepo := postgres.NewInvitationRepository(db)
+	institutionRepo := postgres.NewInstitutionRepository(db)
+	nicknameRepo := postgres.NewNicknameRepository(db)
+	dataExportRepo := postgres.NewDataExportRepository(db)
+	consentRepo := postgres.NewConsentRepository(db)
+	scimClientRepo := postgres.NewSCIMClientRepository(db)
+	uow := postgres.NewUnitOfWork(db)
 
 	// Initialize services
-	tokenService := service.NewTokenService(cfg.JWT, sessionRepo)
+	tokenService := service.NewTokenService(cfg.JWT, sessionRepo, userRepo)
 	emailService := service.NewEmailService(cfg.SMTP)
-	authService := service.NewAuthService(userRepo, roleRepo, sessionRepo, tokenService, emailService, l)
+	emailPolicy := service.NewEmailPolicy(nil, cfg.EmailPolicy, l)
+	emailNorm := mailaddr.Normalizer{ProviderRules: cfg.Registration.EmailProviderRules}
+	authService := service.NewAuthService(userRepo, roleRepo, permissionRepo, orgRepo, institutionRepo, emailPolicy, sessionRepo, consentRepo, uow, tokenService, emailService, emailNorm, cfg.Registration, cfg.EmailChange, l)
+	roleService := service.NewRoleService(roleRepo, userRepo, uow, tokenService, cfg.JWT.RevokeRefreshOnRoleChange, l)
+	orgService := service.NewOrganizationService(orgRepo, roleRepo, userRepo, uow, tokenService, cfg.JWT.RevokeRefreshOnRoleChange, l)
+	institutionService := service.NewInstitutionService(institutionRepo, l)
+	userAdminService := service.NewUserAdminService(userRepo, roleRepo, nicknameRepo, sessionRepo, uow, emailService, cfg.Nickname, l)
+	userBulkService := service.NewUserBulkService(authService, userRepo, roleRepo, uow, emailService, emailNorm, l)
+	profileService := service.NewProfileService(userRepo, nicknameRepo, uow, cfg.Nickname, l)
+	invitationService := service.NewInvitationService(invitationRepo, orgRepo, roleRepo, userRepo, authService, emailService, emailNorm, uow, tokenService, cfg.JWT, cfg.Invitation, l)
+	accountDeletionService := service.NewAccountDeletionService(userRepo, sessionRepo, uow, emailService, cfg.AccountDeletion, l)
+	dataExportService := service.NewDataExportService(dataExportRepo, userRepo, roleRepo, orgRepo, nicknameRepo, consentRepo, sessionRepo, emailService, cfg.DataExport, l)
+	consentService := service.NewConsentService(consentRepo, l)
+	policyService := service.NewPolicyService(policyRepo, userRepo, roleRepo, permissionRepo, orgRepo, cfg.Policy, l)
+	scimService := service.NewSCIMService(scimClientRepo, userRepo, roleRepo, sessionRepo, nicknameRepo, authService, roleService, uow, cfg.SCIM, cfg.Nickname, cfg.AccountDeletion, l)
+
+	// Start background jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	go roleService.RunExpiredGrantsCleanup(jobsCtx, cfg.Jobs.RoleGrantCleanupInterval)
+	go emailPolicy.RunBlocklistReload(jobsCtx, cfg.EmailPolicy.BlocklistReloadInterval)
+	go accountDeletionService.RunDeletionCleanup(jobsCtx, cfg.Jobs.AccountDeletionCleanupInterval)
+	go dataExportService.RunExportProcessing(jobsCtx, cfg.Jobs.DataExportInterval)
 
 	// Initialize REST router
-	r := router.NewRouter(authService, tokenService, l)
+	r := router.NewRouter(authService, tokenService, roleService, orgService, invitationService, institutionService, userAdminService, userBulkService, profileService, accountDeletionService, cfg.AccountDeletion.ReauthMaxAge, dataExportService, consentService, policyService, scimService, l)
 
 	// Start REST server
 	go func() {
@@ -64,7 +98,7 @@ func main() {
 	}()
 
 	// Initialize and start gRPC server
-	grpcServer := server.NewGRPCServer(cfg.GRPCServerAddress, authService, tokenService, l)
+	grpcServer := server.NewGRPCServer(cfg.GRPCServerAddress, authService, tokenService, roleService, policyService, invitationService, profileService, userAdminService, l)
 	go func() {
 		l.Infof("Starting gRPC server on %s", cfg.GRPCServerAddress)
 		if err := grpcServer.Start(); err != nil {
@@ -79,6 +113,8 @@ func main() {
 	<-quit
 	l.Info("Shutting down servers...")
 
+	stopJobs()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
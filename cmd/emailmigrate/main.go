@@ -0,0 +1,123 @@
+// Command emailmigrate normalizes the emails of existing users and reports addresses that collide once normalized.
+//
+// By default it only prints a report. With -apply it rewrites every email that normalizes without a collision and,
+// once no collisions remain, creates the case-insensitive unique index on users.email.
+// Colliding accounts have to be merged or renamed by hand before the index can be created.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/joho/godotenv"
+
+	"authmicro/configs"
+	"authmicro/internal/repository/postgres"
+	"authmicro/pkg/logger"
+	"authmicro/pkg/mailaddr"
+)
+
+type userEmail struct {
+	ID    int64  `db:"id"`
+	Email string `db:"email"`
+}
+
+func main() {
+	_ = godotenv.Load()
+
+	l := logger.NewLogger()
+	cfg := configs.NewConfig()
+
+	providerRules := flag.Bool("provider-rules", cfg.Registration.EmailProviderRules, "apply provider-specific rules (Gmail dots, +tag suffixes)")
+	apply := flag.Bool("apply", false, "rewrite non-colliding emails and create the unique index when no collisions remain")
+	flag.Parse()
+
+	db, err := postgres.NewPostgresDB(cfg.DB)
+	if err != nil {
+		l.Fatalf("Failed to initialize database connection: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	normalizer := mailaddr.Normalizer{ProviderRules: *providerRules}
+
+	var users []userEmail
+	if err := db.SelectContext(ctx, &users, `SELECT id, email FROM users ORDER BY id`); err != nil {
+		l.Fatalf("Failed to load users: %v", err)
+	}
+
+	groups := make(map[string][]userEmail)
+	normalized := make(map[int64]string, len(users))
+	invalid := 0
+	for _, user := range users {
+		email, err := normalizer.Normalize(user.Email)
+		if err != nil {
+			fmt.Printf("invalid: user %d %q: %v\n", user.ID, user.Email, err)
+			invalid++
+			continue
+		}
+		normalized[user.ID] = email
+		groups[email] = append(groups[email], user)
+	}
+
+	keys := make([]string, 0, len(groups))
+	for email, group := range groups {
+		if len(group) > 1 {
+			keys = append(keys, email)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, email := range keys {
+		fmt.Printf("collision: %s\n", email)
+		for _, user := range groups[email] {
+			fmt.Printf("  user %d %q\n", user.ID, user.Email)
+		}
+	}
+
+	var changes []userEmail
+	for _, user := range users {
+		email, ok := normalized[user.ID]
+		if !ok || email == user.Email || len(groups[email]) > 1 {
+			continue
+		}
+		changes = append(changes, userEmail{ID: user.ID, Email: email})
+	}
+
+	fmt.Printf("%d users, %d to normalize, %d collisions, %d invalid\n", len(users), len(changes), len(keys), invalid)
+
+	if !*apply {
+		return
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		l.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, change := range changes {
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET email = $1 WHERE id = $2`, change.Email, change.ID); err != nil {
+			l.Fatalf("Failed to update user %d: %v", change.ID, err)
+		}
+	}
+
+	if len(keys) == 0 {
+		if _, err := tx.ExecContext(ctx, `CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (LOWER(email))`); err != nil {
+			l.Fatalf("Failed to create unique index: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		l.Fatalf("Failed to commit: %v", err)
+	}
+
+	fmt.Printf("normalized %d emails\n", len(changes))
+	if len(keys) > 0 {
+		fmt.Println("unique index not created: resolve the collisions above and run again")
+		os.Exit(1)
+	}
+}
@@ -3,6 +3,7 @@ package configs
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -11,6 +12,16 @@ type Config struct {
 	DB                DBConfig
 	JWT               JWTConfig
 	SMTP              SMTPConfig
+	Jobs              JobsConfig
+	Policy            PolicyConfig
+	Invitation        InvitationConfig
+	Registration      RegistrationConfig
+	EmailPolicy       EmailPolicyConfig
+	EmailChange       EmailChangeConfig
+	Nickname          NicknameConfig
+	AccountDeletion   AccountDeletionConfig
+	DataExport        DataExportConfig
+	SCIM              SCIMConfig
 	HTTPServerAddress string
 	GRPCServerAddress string
 }
@@ -30,6 +41,9 @@ type JWTConfig struct {
 	AccessTokenExpiration  time.Duration
 	RefreshTokenExpiration time.Duration
 	Secret                 string
+	EmbedPermissions       bool
+	// RevokeRefreshOnRoleChange revokes a user's refresh tokens when their roles change
+	RevokeRefreshOnRoleChange bool
 }
 
 // SMTPConfig holds email configuration
@@ -41,6 +55,93 @@ type SMTPConfig struct {
 	From     string
 }
 
+// JobsConfig holds background job configuration
+type JobsConfig struct {
+	RoleGrantCleanupInterval       time.Duration
+	AccountDeletionCleanupInterval time.Duration
+	DataExportInterval             time.Duration
+}
+
+// PolicyConfig holds authorization policy configuration
+type PolicyConfig struct {
+	CacheTTL time.Duration
+	Timezone string
+}
+
+// InvitationConfig holds organization invitation configuration
+type InvitationConfig struct {
+	TTL time.Duration
+	// AcceptURL is the page the invitation link points to; the token is appended as the "token" query parameter
+	AcceptURL string
+}
+
+// RegistrationConfig holds self-service registration configuration
+type RegistrationConfig struct {
+	// RequireInstitution only lets addresses at the email domains of registered institutions register
+	RequireInstitution bool
+	// EmailProviderRules applies provider-specific address rules (Gmail dots, "+tag" suffixes, ...) when normalizing emails
+	EmailProviderRules bool
+	// LoginURL is the login page linked from the notice sent when someone registers with an already registered email
+	LoginURL string
+}
+
+// EmailPolicyConfig holds the registration email policy configuration
+type EmailPolicyConfig struct {
+	// BlocklistFile lists disposable domains in addition to the bundled list, one per line
+	BlocklistFile           string
+	BlocklistReloadInterval time.Duration
+	CheckMX                 bool
+	MXTimeout               time.Duration
+	// Action is "reject" to refuse failing addresses or "flag" to register them for review
+	Action string
+}
+
+// EmailChangeConfig holds the email change configuration
+type EmailChangeConfig struct {
+	// CancelURL is the page the cancel link sent to the current address points to; the token is appended as the "token" query parameter
+	CancelURL string
+	// RevokeSessions revokes all refresh tokens of the user once the email has changed
+	RevokeSessions bool
+}
+
+// NicknameConfig holds the nickname change configuration
+type NicknameConfig struct {
+	// ReservationPeriod keeps a previous nickname from being taken by other users after a change
+	ReservationPeriod time.Duration
+	// ChangeInterval is the minimum time between two nickname changes by the user; admins are not limited
+	ChangeInterval time.Duration
+}
+
+// AccountDeletionConfig holds the account deletion configuration
+type AccountDeletionConfig struct {
+	// GracePeriod is how long a deleted account can still be restored by cancelling the deletion
+	GracePeriod time.Duration
+	// Anonymize replaces the personal data of deleted accounts instead of removing their rows
+	Anonymize bool
+	// ReauthMaxAge is how recently the user must have re-authenticated with an email code to delete the account
+	ReauthMaxAge time.Duration
+}
+
+// DataExportConfig holds the personal data export configuration
+type DataExportConfig struct {
+	// TTL is how long a built export can be downloaded
+	TTL time.Duration
+	// DownloadURL is the address the emailed download link points to; the token is appended as the "token" query parameter
+	DownloadURL string
+	// ProcessingTimeout is how long an export may stay processing before its worker is presumed dead and the export is
+	// claimed again
+	ProcessingTimeout time.Duration
+}
+
+// SCIMConfig holds the SCIM provisioning configuration
+type SCIMConfig struct {
+	// BaseURL is the public address of the SCIM API, used for the locations of the resources
+	BaseURL string
+	// Groups lists the roles provisioning clients may manage as groups. Default roles and roles implying the admin
+	// role are never provisionable, even when listed.
+	Groups []string
+}
+
 // NewConfig initializes and returns a new Config
 func NewConfig() *Config {
 	return &Config{
@@ -53,9 +154,11 @@ func NewConfig() *Config {
 			SSLMode:  getEnv("PGSSLMODE", "require"),
 		},
 		JWT: JWTConfig{
-			AccessTokenExpiration:  time.Duration(getEnvAsInt("JWT_ACCESS_EXPIRATION", 15)) * time.Minute,
-			RefreshTokenExpiration: time.Duration(getEnvAsInt("JWT_REFRESH_EXPIRATION", 24*7)) * time.Hour,
-			Secret:                 getEnv("JWT_SECRET", "my-super-secret-key"),
+			AccessTokenExpiration:     time.Duration(getEnvAsInt("JWT_ACCESS_EXPIRATION", 15)) * time.Minute,
+			RefreshTokenExpiration:    time.Duration(getEnvAsInt("JWT_REFRESH_EXPIRATION", 24*7)) * time.Hour,
+			Secret:                    getEnv("JWT_SECRET", "my-super-secret-key"),
+			EmbedPermissions:          getEnvAsBool("JWT_EMBED_PERMISSIONS", false),
+			RevokeRefreshOnRoleChange: getEnvAsBool("JWT_REVOKE_REFRESH_ON_ROLE_CHANGE", false),
 		},
 		SMTP: SMTPConfig{
 			Host:     getEnv("SMTP_HOST", "smtp.gmail.com"),
@@ -64,6 +167,53 @@ func NewConfig() *Config {
 			Password: getEnv("SMTP_PASSWORD", ""),
 			From:     getEnv("SMTP_FROM", "no-reply@example.com"),
 		},
+		Jobs: JobsConfig{
+			RoleGrantCleanupInterval:       time.Duration(getEnvAsInt("ROLE_GRANT_CLEANUP_INTERVAL", 60)) * time.Second,
+			AccountDeletionCleanupInterval: time.Duration(getEnvAsInt("ACCOUNT_DELETION_CLEANUP_INTERVAL", 3600)) * time.Second,
+			DataExportInterval:             time.Duration(getEnvAsInt("DATA_EXPORT_INTERVAL", 30)) * time.Second,
+		},
+		Policy: PolicyConfig{
+			CacheTTL: time.Duration(getEnvAsInt("POLICY_CACHE_TTL", 30)) * time.Second,
+			Timezone: getEnv("POLICY_TIMEZONE", "UTC"),
+		},
+		Invitation: InvitationConfig{
+			TTL:       time.Duration(getEnvAsInt("INVITATION_TTL", 72)) * time.Hour,
+			AcceptURL: getEnv("INVITATION_ACCEPT_URL", "http://localhost:3000/invitations/accept"),
+		},
+		Registration: RegistrationConfig{
+			RequireInstitution: getEnvAsBool("REGISTRATION_REQUIRE_INSTITUTION", true),
+			EmailProviderRules: getEnvAsBool("EMAIL_PROVIDER_RULES", false),
+			LoginURL:           getEnv("REGISTRATION_LOGIN_URL", "http://localhost:3000/login"),
+		},
+		EmailPolicy: EmailPolicyConfig{
+			BlocklistFile:           getEnv("EMAIL_BLOCKLIST_FILE", ""),
+			BlocklistReloadInterval: time.Duration(getEnvAsInt("EMAIL_BLOCKLIST_RELOAD_INTERVAL", 3600)) * time.Second,
+			CheckMX:                 getEnvAsBool("EMAIL_CHECK_MX", false),
+			MXTimeout:               time.Duration(getEnvAsInt("EMAIL_MX_TIMEOUT", 3)) * time.Second,
+			Action:                  getEnv("EMAIL_POLICY_ACTION", "reject"),
+		},
+		EmailChange: EmailChangeConfig{
+			CancelURL:      getEnv("EMAIL_CHANGE_CANCEL_URL", "http://localhost:3000/email-change/cancel"),
+			RevokeSessions: getEnvAsBool("EMAIL_CHANGE_REVOKE_SESSIONS", true),
+		},
+		Nickname: NicknameConfig{
+			ReservationPeriod: time.Duration(getEnvAsInt("NICKNAME_RESERVATION_DAYS", 90)) * 24 * time.Hour,
+			ChangeInterval:    time.Duration(getEnvAsInt("NICKNAME_CHANGE_INTERVAL_DAYS", 30)) * 24 * time.Hour,
+		},
+		AccountDeletion: AccountDeletionConfig{
+			GracePeriod:  time.Duration(getEnvAsInt("ACCOUNT_DELETION_GRACE_DAYS", 30)) * 24 * time.Hour,
+			Anonymize:    getEnvAsBool("ACCOUNT_DELETION_ANONYMIZE", false),
+			ReauthMaxAge: time.Duration(getEnvAsInt("ACCOUNT_DELETION_REAUTH_MAX_AGE", 5)) * time.Minute,
+		},
+		DataExport: DataExportConfig{
+			TTL:               time.Duration(getEnvAsInt("DATA_EXPORT_TTL", 48)) * time.Hour,
+			DownloadURL:       getEnv("DATA_EXPORT_DOWNLOAD_URL", "http://localhost:8000/auth/v1/dataExports/download"),
+			ProcessingTimeout: time.Duration(getEnvAsInt("DATA_EXPORT_PROCESSING_TIMEOUT", 30)) * time.Minute,
+		},
+		SCIM: SCIMConfig{
+			BaseURL: getEnv("SCIM_BASE_URL", "http://localhost:8000/scim/v2"),
+			Groups:  getEnvAsList("SCIM_GROUPS"),
+		},
 		HTTPServerAddress: getEnv("HTTP_SERVER_ADDRESS", "0.0.0.0:8000"),
 		GRPCServerAddress: getEnv("GRPC_SERVER_ADDRESS", "0.0.0.0:9000"),
 	}
@@ -87,3 +237,25 @@ func getEnvAsInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+// getEnvAsBool retrieves the value of the environment variable named by the key as a bool
+// If the variable is not present or cannot be parsed as a bool, it returns the fallback value
+func getEnvAsBool(key string, fallback bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return fallback
+}
+
+// getEnvAsList retrieves the value of the environment variable named by the key as a comma-separated list,
+// leaving out empty items
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,4 @@
+package proto
+
+// auth.pb.go and auth_grpc.pb.go are generated from auth.proto; regenerate them after changing it
+//go:generate protoc -I . --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative auth.proto
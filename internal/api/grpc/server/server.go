@@ -7,25 +7,52 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 
+	pb "authmicro/internal/api/grpc/proto"
+	grpcservice "authmicro/internal/api/grpc/service"
+	"authmicro/internal/service"
 	"authmicro/pkg/logger"
 )
 
 // AuthServer represents the gRPC server for auth service
 type AuthServer struct {
-	address      string
-	server       *grpc.Server
-	logger       logger.Logger
-	authService  interface{}
-	tokenService interface{}
+	address string
+	server  *grpc.Server
+	logger  logger.Logger
 }
 
-// NewGRPCServer creates a new instance of the gRPC server
-func NewGRPCServer(address string, authService interface{}, tokenService interface{}, logger logger.Logger) *AuthServer {
+// NewGRPCServer creates a new instance of the gRPC server with the auth service registered
+func NewGRPCServer(
+	address string,
+	authService *service.AuthService,
+	tokenService *service.TokenService,
+	roleService *service.RoleService,
+	policyService *service.PolicyService,
+	invitationService *service.InvitationService,
+	profileService *service.ProfileService,
+	userAdminService *service.UserAdminService,
+	logger logger.Logger,
+) *AuthServer {
+	server := grpc.NewServer()
+
+	// Enable reflection for grpcurl and other tools
+	reflection.Register(server)
+
+	authGRPCService := grpcservice.NewAuthGRPCService(
+		authService,
+		tokenService,
+		roleService,
+		policyService,
+		invitationService,
+		profileService,
+		userAdminService,
+		logger,
+	)
+	pb.RegisterAuthServiceServer(server, authGRPCService)
+
 	return &AuthServer{
-		address:      address,
-		logger:       logger,
-		authService:  authService,
-		tokenService: tokenService,
+		address: address,
+		server:  server,
+		logger:  logger,
 	}
 }
 
@@ -36,28 +63,14 @@ func (s *AuthServer) Start() error {
 		return err
 	}
 
-	// Create a new gRPC server
-	server := grpc.NewServer()
-
-	// Enable reflection for grpcurl and other tools
-	reflection.Register(server)
-
-	// Store the server instance
-	s.server = server
-
-	// Register gRPC services here
-	// proto.RegisterAuthServiceServer(server, NewAuthServiceGRPC(s.authService, s.logger))
-
 	s.logger.Infof("gRPC server is listening on %s", s.address)
-	return server.Serve(lis)
+	return s.server.Serve(lis)
 }
 
 // Stop gracefully stops the gRPC server
 func (s *AuthServer) Stop() {
-	if s.server != nil {
-		s.logger.Info("Gracefully stopping gRPC server")
-		s.server.GracefulStop()
-	}
+	s.logger.Info("Gracefully stopping gRPC server")
+	s.server.GracefulStop()
 }
 
 // RegisterService registers a gRPC service with the server
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "authmicro/internal/api/grpc/proto"
+	"authmicro/internal/domain"
+)
+
+type roleService interface {
+	ListRoles(ctx context.Context) ([]domain.Role, error)
+	CreateRole(ctx context.Context, actorID int64, req domain.CreateRoleRequest) (domain.Role, []domain.FieldError, error)
+	RenameRole(ctx context.Context, actorID, roleID int64, req domain.RenameRoleRequest) (domain.Role, []domain.FieldError, error)
+	DeleteRole(ctx context.Context, actorID, roleID int64) error
+	AssignRole(ctx context.Context, actorID, userID, roleID int64, req domain.AssignRoleRequest) error
+	UnassignRole(ctx context.Context, actorID, userID, roleID int64) error
+	ListUsersByRole(ctx context.Context, roleID int64) ([]domain.User, error)
+	GetUserRoles(ctx context.Context, userID int64) (domain.UserRolesView, error)
+	AddRoleChild(ctx context.Context, actorID, parentID, childID int64) error
+	RemoveRoleChild(ctx context.Context, actorID, parentID, childID int64) error
+}
+
+// ListRoles lists all roles
+func (s *AuthGRPCService) ListRoles(ctx context.Context, req *pb.ListRolesRequest) (*pb.ListRolesResponse, error) {
+	if _, err := s.requireRole(ctx, domain.DefaultRoles.Admin); err != nil {
+		return nil, err
+	}
+
+	roles, err := s.roleService.ListRoles(ctx)
+	if err != nil {
+		s.logger.Errorf("Error listing roles: %v", err)
+		return nil, status.Errorf(codes.Internal, "Сервер не отвечает")
+	}
+
+	res := &pb.ListRolesResponse{}
+	for _, role := range roles {
+		res.Roles = append(res.Roles, toPBRole(role))
+	}
+
+	return res, nil
+}
+
+// CreateRole creates a new role
+func (s *AuthGRPCService) CreateRole(ctx context.Context, req *pb.CreateRoleRequest) (*pb.Role, error) {
+	actorID, err := s.requireRoleManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	role, fieldErrors, err := s.roleService.CreateRole(ctx, actorID, domain.CreateRoleRequest{Name: req.Name})
+	if err != nil {
+		return nil, s.roleStatusError("Error creating role", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Ошибка валидации")
+	}
+
+	return toPBRole(role), nil
+}
+
+// RenameRole renames an existing role
+func (s *AuthGRPCService) RenameRole(ctx context.Context, req *pb.RenameRoleRequest) (*pb.Role, error) {
+	actorID, err := s.requireRoleManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	role, fieldErrors, err := s.roleService.RenameRole(ctx, actorID, req.RoleId, domain.RenameRoleRequest{Name: req.Name})
+	if err != nil {
+		return nil, s.roleStatusError("Error renaming role", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Ошибка валидации")
+	}
+
+	return toPBRole(role), nil
+}
+
+// DeleteRole deletes a role
+func (s *AuthGRPCService) DeleteRole(ctx context.Context, req *pb.DeleteRoleRequest) (*pb.EmptyResponse, error) {
+	actorID, err := s.requireRoleManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.roleService.DeleteRole(ctx, actorID, req.RoleId); err != nil {
+		return nil, s.roleStatusError("Error deleting role", err)
+	}
+
+	return &pb.EmptyResponse{}, nil
+}
+
+// AssignRole assigns a role to a user
+func (s *AuthGRPCService) AssignRole(ctx context.Context, req *pb.UserRoleRequest) (*pb.EmptyResponse, error) {
+	actorID, err := s.requireRoleManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var assignReq domain.AssignRoleRequest
+	if req.ExpiresAt > 0 {
+		expiresAt := time.Unix(req.ExpiresAt, 0).UTC()
+		assignReq.ExpiresAt = &expiresAt
+	}
+
+	if err := s.roleService.AssignRole(ctx, actorID, req.UserId, req.RoleId, assignReq); err != nil {
+		return nil, s.roleStatusError("Error assigning role", err)
+	}
+
+	return &pb.EmptyResponse{}, nil
+}
+
+// UnassignRole removes a role from a user
+func (s *AuthGRPCService) UnassignRole(ctx context.Context, req *pb.UserRoleRequest) (*pb.EmptyResponse, error) {
+	actorID, err := s.requireRoleManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.roleService.UnassignRole(ctx, actorID, req.UserId, req.RoleId); err != nil {
+		return nil, s.roleStatusError("Error unassigning role", err)
+	}
+
+	return &pb.EmptyResponse{}, nil
+}
+
+// ListUsersByRole lists all users that have a role assigned
+func (s *AuthGRPCService) ListUsersByRole(ctx context.Context, req *pb.ListUsersByRoleRequest) (*pb.ListUsersResponse, error) {
+	if _, err := s.requireRole(ctx, domain.DefaultRoles.Admin); err != nil {
+		return nil, err
+	}
+
+	users, err := s.roleService.ListUsersByRole(ctx, req.RoleId)
+	if err != nil {
+		return nil, s.roleStatusError("Error listing users by role", err)
+	}
+
+	res := &pb.ListUsersResponse{}
+	for _, user := range users {
+		res.Users = append(res.Users, toPBUser(user))
+	}
+
+	return res, nil
+}
+
+// GetUserRoles returns the direct and effective roles of a user
+func (s *AuthGRPCService) GetUserRoles(ctx context.Context, req *pb.GetUserRolesRequest) (*pb.GetUserRolesResponse, error) {
+	if _, err := s.requireRole(ctx, domain.DefaultRoles.Admin); err != nil {
+		return nil, err
+	}
+
+	view, err := s.roleService.GetUserRoles(ctx, req.UserId)
+	if err != nil {
+		return nil, s.roleStatusError("Error getting user roles", err)
+	}
+
+	res := &pb.GetUserRolesResponse{
+		Effective: view.Effective,
+	}
+	for _, grant := range view.Direct {
+		res.Direct = append(res.Direct, toPBRoleGrant(grant))
+	}
+
+	return res, nil
+}
+
+// AddRoleChild makes a role imply another role
+func (s *AuthGRPCService) AddRoleChild(ctx context.Context, req *pb.RoleChildRequest) (*pb.EmptyResponse, error) {
+	actorID, err := s.requireRoleManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.roleService.AddRoleChild(ctx, actorID, req.ParentRoleId, req.ChildRoleId); err != nil {
+		return nil, s.roleStatusError("Error adding child role", err)
+	}
+
+	return &pb.EmptyResponse{}, nil
+}
+
+// RemoveRoleChild removes a parent-child relationship between roles
+func (s *AuthGRPCService) RemoveRoleChild(ctx context.Context, req *pb.RoleChildRequest) (*pb.EmptyResponse, error) {
+	actorID, err := s.requireRoleManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.roleService.RemoveRoleChild(ctx, actorID, req.ParentRoleId, req.ChildRoleId); err != nil {
+		return nil, s.roleStatusError("Error removing child role", err)
+	}
+
+	return &pb.EmptyResponse{}, nil
+}
+
+// authenticate validates the bearer token from the request metadata
+func (s *AuthGRPCService) authenticate(ctx context.Context) (*domain.TokenClaims, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "Unauthorized")
+	}
+
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Errorf(codes.Unauthenticated, "Unauthorized")
+	}
+
+	token := strings.TrimPrefix(values[0], "Bearer ")
+	claims, err := s.tokenService.ValidateToken(ctx, token)
+	if err != nil {
+		s.logger.Errorf("Error validating token: %v", err)
+		return nil, status.Errorf(codes.Unauthenticated, "Invalid or expired token")
+	}
+
+	return claims, nil
+}
+
+// requireRole validates the bearer token from the request metadata and checks that its user has the role.
+// It returns the ID of the authenticated user.
+func (s *AuthGRPCService) requireRole(ctx context.Context, role string) (int64, error) {
+	claims, err := s.authenticate(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, r := range claims.Roles {
+		if r == role {
+			return claims.UserID, nil
+		}
+	}
+
+	// If not in token, double-check with database (token might be outdated)
+	hasRole, err := s.authService.HasRole(ctx, claims.UserID, role)
+	if err != nil {
+		s.logger.Errorf("Error checking role: %v", err)
+		return 0, status.Errorf(codes.Internal, "Сервер не отвечает")
+	}
+
+	if !hasRole {
+		return 0, status.Errorf(codes.PermissionDenied, "Insufficient permissions")
+	}
+
+	return claims.UserID, nil
+}
+
+// requireRoleManager checks that the user of the request is an admin holding the roles:manage permission,
+// which changes to roles and grants require. It returns the ID of the authenticated user.
+func (s *AuthGRPCService) requireRoleManager(ctx context.Context) (int64, error) {
+	userID, err := s.requireRole(ctx, domain.DefaultRoles.Admin)
+	if err != nil {
+		return 0, err
+	}
+
+	hasPermission, err := s.authService.HasPermission(ctx, userID, domain.DefaultPermissions.RolesManage)
+	if err != nil {
+		s.logger.Errorf("Error checking permission: %v", err)
+		return 0, status.Errorf(codes.Internal, "Сервер не отвечает")
+	}
+
+	if !hasPermission {
+		return 0, status.Errorf(codes.PermissionDenied, "Insufficient permissions")
+	}
+
+	return userID, nil
+}
+
+// roleStatusError maps role management errors to gRPC status errors
+func (s *AuthGRPCService) roleStatusError(msg string, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrRoleNotFound),
+		errors.Is(err, domain.ErrUserNotFound),
+		errors.Is(err, domain.ErrRoleNotGranted),
+		errors.Is(err, domain.ErrRoleChildNotFound):
+		return status.Errorf(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrRoleExists),
+		errors.Is(err, domain.ErrRoleAlreadyGranted):
+		return status.Errorf(codes.AlreadyExists, err.Error())
+	case errors.Is(err, domain.ErrProtectedRole),
+		errors.Is(err, domain.ErrRoleCycle):
+		return status.Errorf(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, domain.ErrGrantExpiryInPast):
+		return status.Errorf(codes.InvalidArgument, err.Error())
+	}
+
+	s.logger.Errorf("%s: %v", msg, err)
+	return status.Errorf(codes.Internal, "Сервер не отвечает")
+}
+
+// toPBRole converts a domain role to its protobuf representation
+func toPBRole(role domain.Role) *pb.Role {
+	return &pb.Role{
+		Id:        role.ID,
+		Name:      role.Name,
+		CreatedAt: role.CreatedAt.Unix(),
+		UpdatedAt: role.UpdatedAt.Unix(),
+	}
+}
+
+// toPBRoleGrant converts a domain role grant to its protobuf representation
+func toPBRoleGrant(grant domain.RoleGrant) *pb.RoleGrant {
+	res := &pb.RoleGrant{
+		Role:      toPBRole(grant.Role),
+		GrantedAt: grant.GrantedAt.Unix(),
+	}
+	if grant.ExpiresAt != nil {
+		res.ExpiresAt = grant.ExpiresAt.Unix()
+	}
+	if grant.GrantedBy != nil {
+		res.GrantedBy = *grant.GrantedBy
+	}
+
+	return res
+}
+
+// toPBUser converts a domain user to its protobuf representation
+func toPBUser(user domain.User) *pb.User {
+	res := &pb.User{
+		Id:            user.ID,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		Nickname:      user.Nickname,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		CreatedAt:     user.CreatedAt.Unix(),
+		Status:        user.Status,
+	}
+	if user.SuspendedUntil != nil {
+		res.SuspendedUntil = user.SuspendedUntil.Unix()
+	}
+
+	return res
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -15,28 +16,42 @@ type authService interface {
 	CreateRegistrationSession(ctx context.Context, req domain.RegistrationRequest) (*domain.RegistrationSessionResponse, []domain.FieldError, error)
 	ConfirmEmail(ctx context.Context, req domain.ConfirmEmailRequest) error
 	ResendVerificationCode(ctx context.Context, req domain.ResendCodeRequest) (*domain.RegistrationSessionResponse, error)
-	SendLoginCode(ctx context.Context, req domain.LoginRequest) (*domain.RegistrationSessionResponse, error)
+	SendLoginCode(ctx context.Context, req domain.LoginRequest) (*domain.LoginSessionResponse, error)
 	ConfirmLogin(ctx context.Context, req domain.LoginConfirmRequest, userAgent, ip string) (*domain.TokenResponse, error)
 	RefreshToken(ctx context.Context, req domain.RefreshTokenRequest, userAgent, ip string) (*domain.TokenResponse, error)
+	AcceptConsent(ctx context.Context, req domain.AcceptConsentRequest, userAgent, ip string) (*domain.TokenResponse, error)
 	HasRole(ctx context.Context, userID int64, roleName string) (bool, error)
+	HasOrgRole(ctx context.Context, userID, orgID int64, roleName string) (bool, error)
+	HasPermission(ctx context.Context, userID int64, permissionName string) (bool, error)
+	CheckBatch(ctx context.Context, checks []domain.AuthorizationCheck) ([]domain.AuthorizationCheckResult, error)
 }
 
 type tokenService interface {
-	ValidateToken(token string) (*domain.TokenClaims, error)
+	ValidateToken(ctx context.Context, token string) (*domain.TokenClaims, error)
 }
 
 type AuthGRPCService struct {
 	pb.UnimplementedAuthServiceServer
-	authService  authService
-	tokenService tokenService
-	logger       logger.Logger
+	authService   authService
+	tokenService  tokenService
+	roleService   roleService
+	policyService policyService
+	invitationSvc invitationService
+	profileSvc    profileService
+	userAdminSvc  userAdminService
+	logger        logger.Logger
 }
 
-func NewAuthGRPCService(authService authService, tokenService tokenService, logger logger.Logger) *AuthGRPCService {
+func NewAuthGRPCService(authService authService, tokenService tokenService, roleService roleService, policyService policyService, invitationSvc invitationService, profileSvc profileService, userAdminSvc userAdminService, logger logger.Logger) *AuthGRPCService {
 	return &AuthGRPCService{
-		authService:  authService,
-		tokenService: tokenService,
-		logger:       logger,
+		authService:   authService,
+		tokenService:  tokenService,
+		roleService:   roleService,
+		policyService: policyService,
+		invitationSvc: invitationSvc,
+		profileSvc:    profileSvc,
+		userAdminSvc:  userAdminSvc,
+		logger:        logger,
 	}
 }
 
@@ -138,13 +153,13 @@ func (s *AuthGRPCService) ConfirmLogin(ctx context.Context, req *pb.LoginConfirm
 	res, err := s.authService.ConfirmLogin(ctx, domainReq, req.UserAgent, req.Ip)
 	if err != nil {
 		s.logger.Errorf("Error confirming login: %v", err)
+		if isAccountBlocked(err) {
+			return nil, status.Errorf(codes.PermissionDenied, err.Error())
+		}
 		return nil, status.Errorf(codes.InvalidArgument, err.Error())
 	}
 
-	return &pb.TokenResponse{
-		AccessToken:  res.AccessToken,
-		RefreshToken: res.RefreshToken,
-	}, nil
+	return toPBTokenResponse(res), nil
 }
 
 // RefreshToken refreshes an access token
@@ -157,6 +172,10 @@ func (s *AuthGRPCService) RefreshToken(ctx context.Context, req *pb.RefreshToken
 	if err != nil {
 		s.logger.Errorf("Error refreshing token: %v", err)
 
+		if isAccountBlocked(err) {
+			return nil, status.Errorf(codes.PermissionDenied, err.Error())
+		}
+
 		// Return specific error message based on the error
 		if err.Error() == "token expires" {
 			return nil, status.Errorf(codes.InvalidArgument, "token expires")
@@ -167,15 +186,37 @@ func (s *AuthGRPCService) RefreshToken(ctx context.Context, req *pb.RefreshToken
 		return nil, status.Errorf(codes.Internal, "Сервер не отвечает")
 	}
 
-	return &pb.TokenResponse{
-		AccessToken:  res.AccessToken,
-		RefreshToken: res.RefreshToken,
-	}, nil
+	return toPBTokenResponse(res), nil
+}
+
+// AcceptConsent accepts the required legal documents and completes a login or token refresh held back for it
+func (s *AuthGRPCService) AcceptConsent(ctx context.Context, req *pb.AcceptConsentRequest) (*pb.TokenResponse, error) {
+	domainReq := domain.AcceptConsentRequest{
+		ConsentToken: req.ConsentToken,
+		DocumentIDs:  req.DocumentIds,
+	}
+
+	res, err := s.authService.AcceptConsent(ctx, domainReq, req.UserAgent, req.Ip)
+	if err != nil {
+		if isAccountBlocked(err) {
+			return nil, status.Errorf(codes.PermissionDenied, err.Error())
+		}
+		if errors.Is(err, domain.ErrConsentSessionNotFound) ||
+			errors.Is(err, domain.ErrConsentIncomplete) ||
+			errors.Is(err, domain.ErrUserNotFound) {
+			return nil, status.Errorf(codes.InvalidArgument, err.Error())
+		}
+
+		s.logger.Errorf("Error accepting consent: %v", err)
+		return nil, status.Errorf(codes.Internal, "Сервер не отвечает")
+	}
+
+	return toPBTokenResponse(res), nil
 }
 
 // ValidateToken validates a token
 func (s *AuthGRPCService) ValidateToken(ctx context.Context, req *pb.ValidateTokenRequest) (*pb.ValidateTokenResponse, error) {
-	claims, err := s.tokenService.ValidateToken(req.Token)
+	claims, err := s.tokenService.ValidateToken(ctx, req.Token)
 	if err != nil {
 		s.logger.Errorf("Error validating token: %v", err)
 		return &pb.ValidateTokenResponse{
@@ -184,11 +225,16 @@ func (s *AuthGRPCService) ValidateToken(ctx context.Context, req *pb.ValidateTok
 	}
 
 	return &pb.ValidateTokenResponse{
-		Valid:    true,
-		UserId:   claims.UserID,
-		Email:    claims.Email,
-		Nickname: claims.Nickname,
-		Roles:    claims.Roles,
+		Valid:       true,
+		UserId:      claims.UserID,
+		Email:       claims.Email,
+		Nickname:    claims.Nickname,
+		Roles:       claims.Roles,
+		Amr:         claims.AMR,
+		Acr:         claims.ACR,
+		AuthTime:    claims.AuthTime,
+		Permissions: claims.Permissions,
+		Memberships: toPBMemberships(claims.Memberships),
 	}, nil
 }
 
@@ -204,3 +250,97 @@ func (s *AuthGRPCService) HasRole(ctx context.Context, req *pb.HasRoleRequest) (
 		HasRole: hasRole,
 	}, nil
 }
+
+// HasOrgRole checks if a user has a specific role within an organization
+func (s *AuthGRPCService) HasOrgRole(ctx context.Context, req *pb.HasOrgRoleRequest) (*pb.HasRoleResponse, error) {
+	hasRole, err := s.authService.HasOrgRole(ctx, req.UserId, req.OrganizationId, req.RoleName)
+	if err != nil {
+		s.logger.Errorf("Error checking organization role: %v", err)
+		return nil, status.Errorf(codes.Internal, "Сервер не отвечает")
+	}
+
+	return &pb.HasRoleResponse{
+		HasRole: hasRole,
+	}, nil
+}
+
+// HasPermission checks if a user has a specific permission
+func (s *AuthGRPCService) HasPermission(ctx context.Context, req *pb.HasPermissionRequest) (*pb.HasPermissionResponse, error) {
+	hasPermission, err := s.authService.HasPermission(ctx, req.UserId, req.Permission)
+	if err != nil {
+		s.logger.Errorf("Error checking permission: %v", err)
+		return nil, status.Errorf(codes.Internal, "Сервер не отвечает")
+	}
+
+	return &pb.HasPermissionResponse{
+		HasPermission: hasPermission,
+	}, nil
+}
+
+// CheckBatch checks many (user, role or permission) pairs in a single call
+func (s *AuthGRPCService) CheckBatch(ctx context.Context, req *pb.CheckBatchRequest) (*pb.CheckBatchResponse, error) {
+	checks := make([]domain.AuthorizationCheck, 0, len(req.Checks))
+	for _, check := range req.Checks {
+		checks = append(checks, domain.AuthorizationCheck{
+			UserID:     check.UserId,
+			Role:       check.Role,
+			Permission: check.Permission,
+		})
+	}
+
+	results, err := s.authService.CheckBatch(ctx, checks)
+	if err != nil {
+		if errors.Is(err, domain.ErrBatchTooLarge) {
+			return nil, status.Errorf(codes.InvalidArgument, err.Error())
+		}
+		s.logger.Errorf("Error checking batch: %v", err)
+		return nil, status.Errorf(codes.Internal, "Сервер не отвечает")
+	}
+
+	res := &pb.CheckBatchResponse{}
+	for _, result := range results {
+		res.Results = append(res.Results, &pb.AuthorizationCheckResult{
+			Allowed: result.Allowed,
+			Error:   result.Error,
+		})
+	}
+
+	return res, nil
+}
+
+// isAccountBlocked reports whether the error is due to a suspended or banned account
+func isAccountBlocked(err error) bool {
+	return errors.Is(err, domain.ErrAccountSuspended) || errors.Is(err, domain.ErrAccountBanned)
+}
+
+func toPBMemberships(memberships []domain.Membership) []*pb.Membership {
+	pbMemberships := make([]*pb.Membership, 0, len(memberships))
+	for _, membership := range memberships {
+		pbMemberships = append(pbMemberships, &pb.Membership{
+			OrganizationId: membership.OrganizationID,
+			Roles:          membership.Roles,
+		})
+	}
+	return pbMemberships
+}
+
+func toPBTokenResponse(res *domain.TokenResponse) *pb.TokenResponse {
+	documents := make([]*pb.LegalDocument, 0, len(res.RequiredDocuments))
+	for _, document := range res.RequiredDocuments {
+		documents = append(documents, &pb.LegalDocument{
+			Id:          document.ID,
+			Kind:        document.Kind,
+			Version:     document.Version,
+			Url:         document.URL,
+			PublishedAt: document.PublishedAt.Unix(),
+		})
+	}
+
+	return &pb.TokenResponse{
+		AccessToken:       res.AccessToken,
+		RefreshToken:      res.RefreshToken,
+		Status:            res.Status,
+		ConsentToken:      res.ConsentToken,
+		RequiredDocuments: documents,
+	}
+}
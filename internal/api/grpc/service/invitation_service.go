@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "authmicro/internal/api/grpc/proto"
+	"authmicro/internal/domain"
+)
+
+type invitationService interface {
+	AcceptInvitation(ctx context.Context, req domain.AcceptInvitationRequest) (*domain.AcceptInvitationResponse, []domain.FieldError, error)
+}
+
+// AcceptInvitation accepts an invitation into an organization, registering a new account when needed
+func (s *AuthGRPCService) AcceptInvitation(ctx context.Context, req *pb.AcceptInvitationRequest) (*pb.AcceptInvitationResponse, error) {
+	domainReq := domain.AcceptInvitationRequest{
+		Token:                 req.Token,
+		FirstName:             req.FirstName,
+		LastName:              req.LastName,
+		Nickname:              req.Nickname,
+		AcceptedPrivacyPolicy: req.AcceptedPrivacyPolicy,
+	}
+
+	res, fieldErrors, err := s.invitationSvc.AcceptInvitation(ctx, domainReq)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvitationInvalid) {
+			return nil, status.Errorf(codes.InvalidArgument, "Приглашение недействительно или истекло")
+		}
+		s.logger.Errorf("Error accepting invitation: %v", err)
+		return nil, status.Errorf(codes.Internal, "Сервер не отвечает")
+	}
+
+	if len(fieldErrors) > 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Ошибка регистрации")
+	}
+
+	return &pb.AcceptInvitationResponse{
+		OrganizationId: res.OrganizationID,
+		UserId:         res.UserID,
+		Registered:     res.Registered,
+	}, nil
+}
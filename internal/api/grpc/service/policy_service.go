@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "authmicro/internal/api/grpc/proto"
+	"authmicro/internal/domain"
+)
+
+type policyService interface {
+	Authorize(ctx context.Context, req domain.AuthorizeRequest) (domain.AuthorizationDecision, []domain.FieldError, error)
+}
+
+// Authorize decides whether a subject may perform an action on a resource according to the policies
+func (s *AuthGRPCService) Authorize(ctx context.Context, req *pb.AuthorizeRequest) (*pb.AuthorizeResponse, error) {
+	domainReq := domain.AuthorizeRequest{
+		SubjectID: req.SubjectId,
+		Action:    req.Action,
+		Context:   req.Context.AsMap(),
+		Explain:   req.Explain,
+	}
+	if req.Resource != nil {
+		domainReq.Resource = domain.AuthorizationResource{
+			Type:       req.Resource.Type,
+			ID:         req.Resource.Id,
+			Attributes: req.Resource.Attributes.AsMap(),
+		}
+	}
+
+	decision, fieldErrors, err := s.policyService.Authorize(ctx, domainReq)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, status.Errorf(codes.NotFound, err.Error())
+		}
+		s.logger.Errorf("Error authorizing request: %v", err)
+		return nil, status.Errorf(codes.Internal, "Сервер не отвечает")
+	}
+
+	if len(fieldErrors) > 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Ошибка валидации")
+	}
+
+	res := &pb.AuthorizeResponse{
+		Allowed: decision.Allowed,
+		Reason:  decision.Reason,
+		Policy:  decision.Policy,
+	}
+	for _, evaluation := range decision.Evaluations {
+		res.Evaluations = append(res.Evaluations, &pb.PolicyEvaluation{
+			PolicyId:     evaluation.PolicyID,
+			Policy:       evaluation.Policy,
+			Effect:       evaluation.Effect,
+			Applicable:   evaluation.Applicable,
+			ConditionMet: evaluation.ConditionMet,
+			Error:        evaluation.Error,
+		})
+	}
+
+	return res, nil
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "authmicro/internal/api/grpc/proto"
+	"authmicro/internal/domain"
+)
+
+type profileService interface {
+	GetMe(ctx context.Context, userID int64) (domain.User, error)
+	UpdateProfile(ctx context.Context, userID int64, req domain.UpdateProfileRequest) (domain.User, []domain.FieldError, error)
+}
+
+// GetMe retrieves the profile of the authenticated user
+func (s *AuthGRPCService) GetMe(ctx context.Context, req *pb.GetMeRequest) (*pb.User, error) {
+	claims, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.profileSvc.GetMe(ctx, claims.UserID)
+	if err != nil {
+		return nil, s.profileStatusError("Error getting profile", err)
+	}
+
+	return toPBUser(user), nil
+}
+
+// UpdateProfile changes the profile of the authenticated user
+func (s *AuthGRPCService) UpdateProfile(ctx context.Context, req *pb.UpdateProfileRequest) (*pb.UpdateProfileResponse, error) {
+	claims, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var domainReq domain.UpdateProfileRequest
+	if req.FirstName != "" {
+		domainReq.FirstName = &req.FirstName
+	}
+	if req.LastName != "" {
+		domainReq.LastName = &req.LastName
+	}
+	if req.Nickname != "" {
+		domainReq.Nickname = &req.Nickname
+	}
+
+	user, fieldErrors, err := s.profileSvc.UpdateProfile(ctx, claims.UserID, domainReq)
+	if err != nil {
+		return nil, s.profileStatusError("Error updating profile", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		res := &pb.UpdateProfileResponse{}
+		for _, fe := range fieldErrors {
+			res.FieldErrors = append(res.FieldErrors, &pb.FieldError{
+				Field:   fe.Field,
+				Message: fe.Message,
+			})
+		}
+		return res, nil
+	}
+
+	return &pb.UpdateProfileResponse{
+		User: toPBUser(user),
+	}, nil
+}
+
+// profileStatusError maps profile errors to gRPC status errors
+func (s *AuthGRPCService) profileStatusError(msg string, err error) error {
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return status.Errorf(codes.Unauthenticated, "Unauthorized")
+	}
+
+	s.logger.Errorf("%s: %v", msg, err)
+	return status.Errorf(codes.Internal, "Сервер не отвечает")
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "authmicro/internal/api/grpc/proto"
+	"authmicro/internal/domain"
+)
+
+type userAdminService interface {
+	ListUsers(ctx context.Context, req domain.ListUsersRequest) (domain.UserPage, []domain.FieldError, error)
+}
+
+// ListUsers lists a page of the user directory
+func (s *AuthGRPCService) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	if _, err := s.requireRole(ctx, domain.DefaultRoles.Admin); err != nil {
+		return nil, err
+	}
+
+	listReq := domain.ListUsersRequest{
+		Email:    req.Email,
+		Nickname: req.Nickname,
+		Role:     req.Role,
+		Status:   req.Status,
+		Sort:     req.Sort,
+		Order:    req.Order,
+		Cursor:   req.Cursor,
+		Limit:    int(req.Limit),
+	}
+	if req.Verified != "" {
+		verified, err := strconv.ParseBool(req.Verified)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Ошибка валидации")
+		}
+		listReq.Verified = &verified
+	}
+	if req.CreatedFrom > 0 {
+		createdFrom := time.Unix(req.CreatedFrom, 0).UTC()
+		listReq.CreatedFrom = &createdFrom
+	}
+	if req.CreatedTo > 0 {
+		createdTo := time.Unix(req.CreatedTo, 0).UTC()
+		listReq.CreatedTo = &createdTo
+	}
+
+	page, fieldErrors, err := s.userAdminSvc.ListUsers(ctx, listReq)
+	if err != nil {
+		s.logger.Errorf("Error listing users: %v", err)
+		return nil, status.Errorf(codes.Internal, "Сервер не отвечает")
+	}
+
+	if len(fieldErrors) > 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Ошибка валидации")
+	}
+
+	res := &pb.ListUsersResponse{NextCursor: page.NextCursor}
+	for _, user := range page.Users {
+		res.Users = append(res.Users, toPBUser(user))
+	}
+
+	return res, nil
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type AccountDeletionService interface {
+	RequestDeletion(ctx context.Context, userID int64) (*domain.AccountDeletionResponse, error)
+	CancelDeletion(ctx context.Context, userID int64) error
+}
+
+type AccountDeletionHandler struct {
+	accountDeletionService AccountDeletionService
+	logger                 logger.Logger
+}
+
+func NewAccountDeletionHandler(accountDeletionService AccountDeletionService, logger logger.Logger) *AccountDeletionHandler {
+	return &AccountDeletionHandler{
+		accountDeletionService: accountDeletionService,
+		logger:                 logger,
+	}
+}
+
+// RequestDeletion handles scheduling the deletion of the authenticated user's account
+// @Summary Delete my account
+// @Description Schedule the deletion of the authenticated user's account after the grace period and revoke all its sessions.
+// @Description Requires a token from a recent re-authentication with an email code (/api/v1/reauth).
+// @Description The deletion can be cancelled by logging in again before it is due.
+// @Tags profile
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} domain.AccountDeletionResponse
+// @Failure 401 {object} domain.ErrorResponse
+// @Router /api/v1/me [delete]
+func (h *AccountDeletionHandler) RequestDeletion(c echo.Context) error {
+	resp, err := h.accountDeletionService.RequestDeletion(c.Request().Context(), actorID(c))
+	if err != nil {
+		return h.accountDeletionError(c, "Error requesting account deletion", err)
+	}
+
+	return c.JSON(http.StatusAccepted, resp)
+}
+
+// CancelDeletion handles cancelling the pending deletion of the authenticated user's account
+// @Summary Cancel my account deletion
+// @Description Cancel the pending deletion of the authenticated user's account
+// @Tags profile
+// @Produce json
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 401 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/me/deletion [delete]
+func (h *AccountDeletionHandler) CancelDeletion(c echo.Context) error {
+	if err := h.accountDeletionService.CancelDeletion(c.Request().Context(), actorID(c)); err != nil {
+		return h.accountDeletionError(c, "Error cancelling account deletion", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// accountDeletionError maps account deletion errors to HTTP responses
+func (h *AccountDeletionHandler) accountDeletionError(c echo.Context, msg string, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		// The account was removed after the token had been issued
+		return c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
+			Error: "Unauthorized",
+		})
+	case errors.Is(err, domain.ErrDeletionNotScheduled):
+		return c.JSON(http.StatusNotFound, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	h.logger.Errorf("%s: %v", msg, err)
+	return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+		Error: "Сервер не отвечает",
+	})
+}
@@ -0,0 +1,458 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type RoleService interface {
+	ListRoles(ctx context.Context) ([]domain.Role, error)
+	CreateRole(ctx context.Context, actorID int64, req domain.CreateRoleRequest) (domain.Role, []domain.FieldError, error)
+	RenameRole(ctx context.Context, actorID, roleID int64, req domain.RenameRoleRequest) (domain.Role, []domain.FieldError, error)
+	DeleteRole(ctx context.Context, actorID, roleID int64) error
+	AssignRole(ctx context.Context, actorID, userID, roleID int64, req domain.AssignRoleRequest) error
+	UnassignRole(ctx context.Context, actorID, userID, roleID int64) error
+	ListUsersByRole(ctx context.Context, roleID int64) ([]domain.User, error)
+	ListRoleChanges(ctx context.Context, limit int) ([]domain.RoleChange, error)
+	GetUserRoles(ctx context.Context, userID int64) (domain.UserRolesView, error)
+	ListRoleChildren(ctx context.Context, roleID int64) ([]domain.Role, error)
+	AddRoleChild(ctx context.Context, actorID, parentID, childID int64) error
+	RemoveRoleChild(ctx context.Context, actorID, parentID, childID int64) error
+}
+
+type AdminHandler struct {
+	roleService RoleService
+	logger      logger.Logger
+}
+
+func NewAdminHandler(roleService RoleService, logger logger.Logger) *AdminHandler {
+	return &AdminHandler{
+		roleService: roleService,
+		logger:      logger,
+	}
+}
+
+// ListRoles handles listing all roles
+// @Summary List roles
+// @Description List all roles
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} domain.Role
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /api/v1/admin/roles [get]
+func (h *AdminHandler) ListRoles(c echo.Context) error {
+	roles, err := h.roleService.ListRoles(c.Request().Context())
+	if err != nil {
+		h.logger.Errorf("Error listing roles: %v", err)
+		return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Сервер не отвечает",
+		})
+	}
+
+	return c.JSON(http.StatusOK, roles)
+}
+
+// CreateRole handles creating a role
+// @Summary Create role
+// @Description Create a new role
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body domain.CreateRoleRequest true "Create role request"
+// @Success 201 {object} domain.Role
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 409 {object} domain.ErrorResponse
+// @Router /api/v1/admin/roles [post]
+func (h *AdminHandler) CreateRole(c echo.Context) error {
+	var req domain.CreateRoleRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	role, fieldErrors, err := h.roleService.CreateRole(c.Request().Context(), actorID(c), req)
+	if err != nil {
+		return h.roleError(c, "Error creating role", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusCreated, role)
+}
+
+// RenameRole handles renaming a role
+// @Summary Rename role
+// @Description Rename an existing role
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param roleId path int true "Role ID"
+// @Param request body domain.RenameRoleRequest true "Rename role request"
+// @Success 200 {object} domain.Role
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Failure 409 {object} domain.ErrorResponse
+// @Router /api/v1/admin/roles/{roleId} [patch]
+func (h *AdminHandler) RenameRole(c echo.Context) error {
+	roleID, err := strconv.ParseInt(c.Param("roleId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор роли",
+		})
+	}
+
+	var req domain.RenameRoleRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	role, fieldErrors, err := h.roleService.RenameRole(c.Request().Context(), actorID(c), roleID, req)
+	if err != nil {
+		return h.roleError(c, "Error renaming role", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole handles deleting a role
+// @Summary Delete role
+// @Description Delete a role and all its assignments
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param roleId path int true "Role ID"
+// @Success 204
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/roles/{roleId} [delete]
+func (h *AdminHandler) DeleteRole(c echo.Context) error {
+	roleID, err := strconv.ParseInt(c.Param("roleId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор роли",
+		})
+	}
+
+	if err := h.roleService.DeleteRole(c.Request().Context(), actorID(c), roleID); err != nil {
+		return h.roleError(c, "Error deleting role", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListUsersByRole handles listing the users that have a role
+// @Summary List users by role
+// @Description List all users that have a role assigned
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param roleId path int true "Role ID"
+// @Success 200 {array} domain.User
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/roles/{roleId}/users [get]
+func (h *AdminHandler) ListUsersByRole(c echo.Context) error {
+	roleID, err := strconv.ParseInt(c.Param("roleId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор роли",
+		})
+	}
+
+	users, err := h.roleService.ListUsersByRole(c.Request().Context(), roleID)
+	if err != nil {
+		return h.roleError(c, "Error listing users by role", err)
+	}
+
+	return c.JSON(http.StatusOK, users)
+}
+
+// ListRoleChanges handles listing the role change history
+// @Summary List role change history
+// @Description List the most recent role changes with actor and timestamp
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Maximum number of entries (default 100)"
+// @Success 200 {array} domain.RoleChange
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /api/v1/admin/roles/history [get]
+func (h *AdminHandler) ListRoleChanges(c echo.Context) error {
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	changes, err := h.roleService.ListRoleChanges(c.Request().Context(), limit)
+	if err != nil {
+		h.logger.Errorf("Error listing role changes: %v", err)
+		return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Сервер не отвечает",
+		})
+	}
+
+	return c.JSON(http.StatusOK, changes)
+}
+
+// AssignRole handles assigning a role to a user
+// @Summary Assign role
+// @Description Assign a role to a user, optionally until the given time
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Param roleId path int true "Role ID"
+// @Param request body domain.AssignRoleRequest false "Assign role request"
+// @Success 204
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Failure 409 {object} domain.ErrorResponse
+// @Router /api/v1/admin/users/{userId}/roles/{roleId} [put]
+func (h *AdminHandler) AssignRole(c echo.Context) error {
+	userID, roleID, ok := userRoleParams(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор пользователя или роли",
+		})
+	}
+
+	var req domain.AssignRoleRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	if err := h.roleService.AssignRole(c.Request().Context(), actorID(c), userID, roleID, req); err != nil {
+		return h.roleError(c, "Error assigning role", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// UnassignRole handles removing a role from a user
+// @Summary Unassign role
+// @Description Remove a role from a user
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Param roleId path int true "Role ID"
+// @Success 204
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/users/{userId}/roles/{roleId} [delete]
+func (h *AdminHandler) UnassignRole(c echo.Context) error {
+	userID, roleID, ok := userRoleParams(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор пользователя или роли",
+		})
+	}
+
+	if err := h.roleService.UnassignRole(c.Request().Context(), actorID(c), userID, roleID); err != nil {
+		return h.roleError(c, "Error unassigning role", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetUserRoles handles showing the direct and effective roles of a user
+// @Summary Get user roles
+// @Description Get the roles assigned directly to a user and the roles they hold through the role hierarchy
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Success 200 {object} domain.UserRolesView
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/users/{userId}/roles [get]
+func (h *AdminHandler) GetUserRoles(c echo.Context) error {
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор пользователя",
+		})
+	}
+
+	view, err := h.roleService.GetUserRoles(c.Request().Context(), userID)
+	if err != nil {
+		return h.roleError(c, "Error getting user roles", err)
+	}
+
+	return c.JSON(http.StatusOK, view)
+}
+
+// ListRoleChildren handles listing the roles implied by a role
+// @Summary List child roles
+// @Description List the roles directly implied by a role
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param roleId path int true "Role ID"
+// @Success 200 {array} domain.Role
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/roles/{roleId}/children [get]
+func (h *AdminHandler) ListRoleChildren(c echo.Context) error {
+	roleID, err := strconv.ParseInt(c.Param("roleId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор роли",
+		})
+	}
+
+	children, err := h.roleService.ListRoleChildren(c.Request().Context(), roleID)
+	if err != nil {
+		return h.roleError(c, "Error listing child roles", err)
+	}
+
+	return c.JSON(http.StatusOK, children)
+}
+
+// AddRoleChild handles making a role imply another role
+// @Summary Add child role
+// @Description Make every holder of the parent role effectively hold the child role
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param roleId path int true "Parent role ID"
+// @Param childId path int true "Child role ID"
+// @Success 204
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Failure 409 {object} domain.ErrorResponse
+// @Router /api/v1/admin/roles/{roleId}/children/{childId} [put]
+func (h *AdminHandler) AddRoleChild(c echo.Context) error {
+	parentID, childID, ok := roleChildParams(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор роли",
+		})
+	}
+
+	if err := h.roleService.AddRoleChild(c.Request().Context(), actorID(c), parentID, childID); err != nil {
+		return h.roleError(c, "Error adding child role", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RemoveRoleChild handles removing a parent-child relationship between roles
+// @Summary Remove child role
+// @Description Remove a parent-child relationship between roles
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param roleId path int true "Parent role ID"
+// @Param childId path int true "Child role ID"
+// @Success 204
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/roles/{roleId}/children/{childId} [delete]
+func (h *AdminHandler) RemoveRoleChild(c echo.Context) error {
+	parentID, childID, ok := roleChildParams(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор роли",
+		})
+	}
+
+	if err := h.roleService.RemoveRoleChild(c.Request().Context(), actorID(c), parentID, childID); err != nil {
+		return h.roleError(c, "Error removing child role", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// roleError maps role management errors to HTTP responses
+func (h *AdminHandler) roleError(c echo.Context, msg string, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrRoleNotFound),
+		errors.Is(err, domain.ErrUserNotFound),
+		errors.Is(err, domain.ErrRoleNotGranted),
+		errors.Is(err, domain.ErrRoleChildNotFound):
+		return c.JSON(http.StatusNotFound, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	case errors.Is(err, domain.ErrRoleExists),
+		errors.Is(err, domain.ErrRoleAlreadyGranted),
+		errors.Is(err, domain.ErrRoleCycle):
+		return c.JSON(http.StatusConflict, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	case errors.Is(err, domain.ErrProtectedRole),
+		errors.Is(err, domain.ErrGrantExpiryInPast):
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	h.logger.Errorf("%s: %v", msg, err)
+	return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+		Error: "Сервер не отвечает",
+	})
+}
+
+// actorID returns the ID of the authenticated user performing the request
+func actorID(c echo.Context) int64 {
+	id, _ := c.Get("userID").(int64)
+	return id
+}
+
+// userRoleParams parses the userId and roleId path parameters
+func userRoleParams(c echo.Context) (int64, int64, bool) {
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	roleID, err := strconv.ParseInt(c.Param("roleId"), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return userID, roleID, true
+}
+
+// roleChildParams parses the roleId and childId path parameters
+func roleChildParams(c echo.Context) (int64, int64, bool) {
+	parentID, err := strconv.ParseInt(c.Param("roleId"), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	childID, err := strconv.ParseInt(c.Param("childId"), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return parentID, childID, true
+}
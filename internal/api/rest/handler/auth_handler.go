@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 
@@ -18,6 +19,12 @@ type AuthService interface {
 	SendLoginCode(ctx context.Context, req domain.LoginRequest) (*domain.LoginSessionResponse, error)
 	ConfirmLogin(ctx context.Context, req domain.LoginConfirmRequest, userAgent, ip string) (*domain.TokenResponse, error)
 	RefreshToken(ctx context.Context, req domain.RefreshTokenRequest, userAgent, ip string) (*domain.TokenResponse, error)
+	AcceptConsent(ctx context.Context, req domain.AcceptConsentRequest, userAgent, ip string) (*domain.TokenResponse, error)
+	SendReauthCode(ctx context.Context, userID int64) (*domain.ReauthCodeResponse, error)
+	ConfirmReauth(ctx context.Context, userID int64, req domain.ReauthConfirmRequest) (*domain.StepUpTokenResponse, error)
+	RequestEmailChange(ctx context.Context, userID int64, req domain.ChangeEmailRequest) (*domain.ChangeEmailResponse, []domain.FieldError, error)
+	ConfirmEmailChange(ctx context.Context, userID int64, req domain.ConfirmEmailChangeRequest) error
+	CancelEmailChange(ctx context.Context, req domain.CancelEmailChangeRequest) error
 	HasRole(ctx context.Context, userID int64, roleName string) (bool, error)
 }
 
@@ -165,7 +172,9 @@ func (h *AuthHandler) SendLoginCode(c echo.Context) error {
 
 // ConfirmLogin handles confirming login with a code
 // @Summary Confirm login
-// @Description Confirm login using a verification code sent to email
+// @Description Confirm login using a verification code sent to email. When the user has not accepted the current
+// @Description privacy policy or terms, no tokens are issued; the status is consent_required and the consent token
+// @Description completes the login at /auth/v1/consents/accept.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -189,6 +198,11 @@ func (h *AuthHandler) ConfirmLogin(c echo.Context) error {
 	res, err := h.authService.ConfirmLogin(c.Request().Context(), req, userAgent, ip)
 	if err != nil {
 		h.logger.Errorf("Error confirming login: %v", err)
+		if isAccountBlocked(err) {
+			return c.JSON(http.StatusForbidden, domain.ErrorResponse{
+				Error: err.Error(),
+			})
+		}
 		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
 			Error: err.Error(),
 		})
@@ -199,7 +213,8 @@ func (h *AuthHandler) ConfirmLogin(c echo.Context) error {
 
 // RefreshToken handles refreshing tokens
 // @Summary Refresh tokens
-// @Description Refresh access token using a valid refresh token
+// @Description Refresh access token using a valid refresh token. Like login, it returns the consent_required status
+// @Description until the user accepts new versions of the privacy policy or terms.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -224,6 +239,12 @@ func (h *AuthHandler) RefreshToken(c echo.Context) error {
 	if err != nil {
 		h.logger.Errorf("Error refreshing token: %v", err)
 
+		if isAccountBlocked(err) {
+			return c.JSON(http.StatusForbidden, domain.ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+
 		// Return specific error message based on the error
 		if strings.Contains(err.Error(), "token expires") {
 			return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
@@ -242,3 +263,236 @@ func (h *AuthHandler) RefreshToken(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, res)
 }
+
+// SendReauthCode handles sending a re-authentication code to the current user's email
+// @Summary Send re-authentication code
+// @Description Send a verification code to the authenticated user's email to obtain an elevated token
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} domain.ReauthCodeResponse
+// @Failure 401 {object} domain.ErrorResponse
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /api/v1/reauth/sendCodeEmail [post]
+func (h *AuthHandler) SendReauthCode(c echo.Context) error {
+	userID, ok := c.Get("userID").(int64)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
+			Error: "Unauthorized",
+		})
+	}
+
+	res, err := h.authService.SendReauthCode(c.Request().Context(), userID)
+	if err != nil {
+		h.logger.Errorf("Error sending re-authentication code: %v", err)
+		return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Сервер не отвечает",
+		})
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// ConfirmReauth handles confirming a re-authentication code
+// @Summary Confirm re-authentication
+// @Description Confirm a re-authentication code and receive an elevated access token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body domain.ReauthConfirmRequest true "Re-authentication confirmation request"
+// @Success 200 {object} domain.StepUpTokenResponse
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 401 {object} domain.ErrorResponse
+// @Router /api/v1/reauth/confirmEmail [post]
+func (h *AuthHandler) ConfirmReauth(c echo.Context) error {
+	userID, ok := c.Get("userID").(int64)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
+			Error: "Unauthorized",
+		})
+	}
+
+	var req domain.ReauthConfirmRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	res, err := h.authService.ConfirmReauth(c.Request().Context(), userID, req)
+	if err != nil {
+		h.logger.Errorf("Error confirming re-authentication: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// RequestEmailChange handles requesting a change of the current user's email
+// @Summary Request email change
+// @Description Send a confirmation code to the new email and a notice with a cancel link to the current one
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body domain.ChangeEmailRequest true "Change email request"
+// @Success 200 {object} domain.ChangeEmailResponse
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 401 {object} domain.ErrorResponse
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /api/v1/me/email [post]
+func (h *AuthHandler) RequestEmailChange(c echo.Context) error {
+	userID, ok := c.Get("userID").(int64)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
+			Error: "Unauthorized",
+		})
+	}
+
+	var req domain.ChangeEmailRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	res, fieldErrors, err := h.authService.RequestEmailChange(c.Request().Context(), userID, req)
+	if err != nil {
+		h.logger.Errorf("Error requesting email change: %v", err)
+		return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Сервер не отвечает",
+		})
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// ConfirmEmailChange handles confirming an email change
+// @Summary Confirm email change
+// @Description Confirm an email change with the code sent to the new email
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body domain.ConfirmEmailChangeRequest true "Confirm email change request"
+// @Success 200 {object} interface{}
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 401 {object} domain.ErrorResponse
+// @Router /api/v1/me/email/confirm [post]
+func (h *AuthHandler) ConfirmEmailChange(c echo.Context) error {
+	userID, ok := c.Get("userID").(int64)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
+			Error: "Unauthorized",
+		})
+	}
+
+	var req domain.ConfirmEmailChangeRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	if err := h.authService.ConfirmEmailChange(c.Request().Context(), userID, req); err != nil {
+		h.logger.Errorf("Error confirming email change: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, struct{}{})
+}
+
+// CancelEmailChange handles cancelling an email change
+// @Summary Cancel email change
+// @Description Cancel a pending email change with the token from the link sent to the current email
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Param request body domain.CancelEmailChangeRequest true "Cancel email change request"
+// @Success 204
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /auth/v1/emailChange/cancel [post]
+func (h *AuthHandler) CancelEmailChange(c echo.Context) error {
+	var req domain.CancelEmailChangeRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	if err := h.authService.CancelEmailChange(c.Request().Context(), req); err != nil {
+		h.logger.Errorf("Error cancelling email change: %v", err)
+		return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Сервер не отвечает",
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// AcceptConsent handles accepting the current legal documents
+// @Summary Accept legal documents
+// @Description Accept the required versions of the privacy policy and terms with the consent token of a login or token
+// @Description refresh that returned the consent_required status, completing it. All required documents must be accepted.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body domain.AcceptConsentRequest true "Accept consent request"
+// @Success 200 {object} domain.TokenResponse
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /auth/v1/consents/accept [post]
+func (h *AuthHandler) AcceptConsent(c echo.Context) error {
+	var req domain.AcceptConsentRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	res, err := h.authService.AcceptConsent(c.Request().Context(), req, c.Request().UserAgent(), c.RealIP())
+	if err != nil {
+		if isAccountBlocked(err) {
+			return c.JSON(http.StatusForbidden, domain.ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+		if errors.Is(err, domain.ErrConsentSessionNotFound) ||
+			errors.Is(err, domain.ErrConsentIncomplete) ||
+			errors.Is(err, domain.ErrUserNotFound) {
+			return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+
+		h.logger.Errorf("Error accepting consent: %v", err)
+		return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Сервер не отвечает",
+		})
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// isAccountBlocked reports whether the error is due to a suspended or banned account
+func isAccountBlocked(err error) bool {
+	return errors.Is(err, domain.ErrAccountSuspended) || errors.Is(err, domain.ErrAccountBanned)
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type ConsentService interface {
+	ListDocuments(ctx context.Context) ([]domain.LegalDocument, error)
+	ListCurrentDocuments(ctx context.Context) ([]domain.LegalDocument, error)
+	ListUserConsents(ctx context.Context, userID int64) ([]domain.Consent, error)
+	PublishDocument(ctx context.Context, actorID int64, req domain.PublishLegalDocumentRequest) (domain.LegalDocument, []domain.FieldError, error)
+}
+
+type ConsentHandler struct {
+	consentService ConsentService
+	logger         logger.Logger
+}
+
+func NewConsentHandler(consentService ConsentService, logger logger.Logger) *ConsentHandler {
+	return &ConsentHandler{
+		consentService: consentService,
+		logger:         logger,
+	}
+}
+
+// ListCurrentDocuments handles listing the current legal documents
+// @Summary List current legal documents
+// @Description List the current version of the privacy policy and terms
+// @Tags auth
+// @Produce json
+// @Success 200 {array} domain.LegalDocument
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /auth/v1/legalDocuments [get]
+func (h *ConsentHandler) ListCurrentDocuments(c echo.Context) error {
+	documents, err := h.consentService.ListCurrentDocuments(c.Request().Context())
+	if err != nil {
+		return h.consentError(c, "Error listing current legal documents", err)
+	}
+
+	return c.JSON(http.StatusOK, documents)
+}
+
+// ListMyConsents handles listing the legal documents accepted by the authenticated user
+// @Summary List my consents
+// @Description List the versions of the privacy policy and terms accepted by the authenticated user, with when and from where
+// @Tags profile
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} domain.Consent
+// @Failure 401 {object} domain.ErrorResponse
+// @Router /api/v1/me/consents [get]
+func (h *ConsentHandler) ListMyConsents(c echo.Context) error {
+	consents, err := h.consentService.ListUserConsents(c.Request().Context(), actorID(c))
+	if err != nil {
+		return h.consentError(c, "Error listing consents", err)
+	}
+
+	return c.JSON(http.StatusOK, consents)
+}
+
+// ListDocuments handles listing all versions of the legal documents
+// @Summary List legal documents
+// @Description List all published and scheduled versions of the privacy policy and terms
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} domain.LegalDocument
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /api/v1/admin/legalDocuments [get]
+func (h *ConsentHandler) ListDocuments(c echo.Context) error {
+	documents, err := h.consentService.ListDocuments(c.Request().Context())
+	if err != nil {
+		return h.consentError(c, "Error listing legal documents", err)
+	}
+
+	return c.JSON(http.StatusOK, documents)
+}
+
+// PublishDocument handles publishing a new version of a legal document
+// @Summary Publish legal document
+// @Description Publish a new version of the privacy policy or terms, now or at a future time. From then on users must
+// @Description accept it before they are issued tokens again.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body domain.PublishLegalDocumentRequest true "Publish legal document request"
+// @Success 201 {object} domain.LegalDocument
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 409 {object} domain.ErrorResponse
+// @Router /api/v1/admin/legalDocuments [post]
+func (h *ConsentHandler) PublishDocument(c echo.Context) error {
+	var req domain.PublishLegalDocumentRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	document, fieldErrors, err := h.consentService.PublishDocument(c.Request().Context(), actorID(c), req)
+	if err != nil {
+		return h.consentError(c, "Error publishing legal document", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusCreated, document)
+}
+
+// consentError maps legal document errors to HTTP responses
+func (h *ConsentHandler) consentError(c echo.Context, msg string, err error) error {
+	if errors.Is(err, domain.ErrLegalDocumentExists) {
+		return c.JSON(http.StatusConflict, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	h.logger.Errorf("%s: %v", msg, err)
+	return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+		Error: "Сервер не отвечает",
+	})
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type DataExportService interface {
+	RequestExport(ctx context.Context, userID int64, req domain.DataExportRequest) (domain.DataExport, error)
+	GetExport(ctx context.Context, userID int64, id string) (domain.DataExport, error)
+	Download(ctx context.Context, token string) (domain.DataExport, error)
+}
+
+type DataExportHandler struct {
+	dataExportService DataExportService
+	logger            logger.Logger
+}
+
+func NewDataExportHandler(dataExportService DataExportService, logger logger.Logger) *DataExportHandler {
+	return &DataExportHandler{
+		dataExportService: dataExportService,
+		logger:            logger,
+	}
+}
+
+// RequestExport handles requesting an export of the authenticated user's personal data
+// @Summary Export my data
+// @Description Request an export of everything the service holds on the authenticated user, as JSON or as a ZIP archive.
+// @Description The export is built in the background and an expiring download link is emailed to the user.
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body domain.DataExportRequest false "Data export request"
+// @Success 202 {object} domain.DataExport
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 401 {object} domain.ErrorResponse
+// @Router /api/v1/me/exports [post]
+func (h *DataExportHandler) RequestExport(c echo.Context) error {
+	var req domain.DataExportRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	export, err := h.dataExportService.RequestExport(c.Request().Context(), actorID(c), req)
+	if err != nil {
+		return h.dataExportError(c, "Error requesting data export", err)
+	}
+
+	return c.JSON(http.StatusAccepted, export)
+}
+
+// GetExport handles retrieving the status of an export of the authenticated user's personal data
+// @Summary Get my data export
+// @Description Get the status of an export of the authenticated user's personal data
+// @Tags profile
+// @Produce json
+// @Security BearerAuth
+// @Param exportId path string true "Export ID"
+// @Success 200 {object} domain.DataExport
+// @Failure 401 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/me/exports/{exportId} [get]
+func (h *DataExportHandler) GetExport(c echo.Context) error {
+	export, err := h.dataExportService.GetExport(c.Request().Context(), actorID(c), c.Param("exportId"))
+	if err != nil {
+		return h.dataExportError(c, "Error getting data export", err)
+	}
+
+	return c.JSON(http.StatusOK, export)
+}
+
+// Download handles downloading a personal data export with the emailed link
+// @Summary Download a data export
+// @Description Download a ready personal data export with the token from the emailed link, until the link expires
+// @Tags profile
+// @Produce json,application/zip
+// @Param token query string true "Download token"
+// @Success 200 {file} file
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /auth/v1/dataExports/download [get]
+func (h *DataExportHandler) Download(c echo.Context) error {
+	export, err := h.dataExportService.Download(c.Request().Context(), c.QueryParam("token"))
+	if err != nil {
+		return h.dataExportError(c, "Error downloading data export", err)
+	}
+
+	contentType := echo.MIMEApplicationJSON
+	if export.Format == domain.DataExportFormats.ZIP {
+		contentType = "application/zip"
+	}
+
+	filename := fmt.Sprintf("personal-data-%s.%s", export.CreatedAt.Format("2006-01-02"), export.Format)
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	return c.Blob(http.StatusOK, contentType, export.Data)
+}
+
+// dataExportError maps data export errors to HTTP responses
+func (h *DataExportHandler) dataExportError(c echo.Context, msg string, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		// The account was removed after the token had been issued
+		return c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
+			Error: "Unauthorized",
+		})
+	case errors.Is(err, domain.ErrDataExportNotFound):
+		return c.JSON(http.StatusNotFound, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvalidExportFormat):
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	h.logger.Errorf("%s: %v", msg, err)
+	return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+		Error: "Сервер не отвечает",
+	})
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type InstitutionService interface {
+	ListInstitutions(ctx context.Context) ([]domain.Institution, error)
+	GetInstitution(ctx context.Context, id int64) (domain.Institution, error)
+	CreateInstitution(ctx context.Context, req domain.InstitutionRequest) (domain.Institution, []domain.FieldError, error)
+	UpdateInstitution(ctx context.Context, id int64, req domain.InstitutionRequest) (domain.Institution, []domain.FieldError, error)
+	DeleteInstitution(ctx context.Context, id int64) error
+}
+
+type InstitutionHandler struct {
+	institutionService InstitutionService
+	logger             logger.Logger
+}
+
+func NewInstitutionHandler(institutionService InstitutionService, logger logger.Logger) *InstitutionHandler {
+	return &InstitutionHandler{
+		institutionService: institutionService,
+		logger:             logger,
+	}
+}
+
+// ListInstitutions handles listing all institutions
+// @Summary List institutions
+// @Description List the institutions allowed to register with their email domains
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} domain.Institution
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /api/v1/admin/institutions [get]
+func (h *InstitutionHandler) ListInstitutions(c echo.Context) error {
+	institutions, err := h.institutionService.ListInstitutions(c.Request().Context())
+	if err != nil {
+		h.logger.Errorf("Error listing institutions: %v", err)
+		return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Сервер не отвечает",
+		})
+	}
+
+	return c.JSON(http.StatusOK, institutions)
+}
+
+// GetInstitution handles retrieving an institution
+// @Summary Get institution
+// @Description Get an institution with its email domains
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param institutionId path int true "Institution ID"
+// @Success 200 {object} domain.Institution
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/institutions/{institutionId} [get]
+func (h *InstitutionHandler) GetInstitution(c echo.Context) error {
+	institutionID, err := strconv.ParseInt(c.Param("institutionId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор учебного заведения",
+		})
+	}
+
+	institution, err := h.institutionService.GetInstitution(c.Request().Context(), institutionID)
+	if err != nil {
+		return h.institutionError(c, "Error getting institution", err)
+	}
+
+	return c.JSON(http.StatusOK, institution)
+}
+
+// CreateInstitution handles creating an institution
+// @Summary Create institution
+// @Description Register an institution with its email domains; "*.example.com" matches any subdomain of example.com
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body domain.InstitutionRequest true "Institution request"
+// @Success 201 {object} domain.Institution
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 409 {object} domain.ErrorResponse
+// @Router /api/v1/admin/institutions [post]
+func (h *InstitutionHandler) CreateInstitution(c echo.Context) error {
+	var req domain.InstitutionRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	institution, fieldErrors, err := h.institutionService.CreateInstitution(c.Request().Context(), req)
+	if err != nil {
+		return h.institutionError(c, "Error creating institution", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusCreated, institution)
+}
+
+// UpdateInstitution handles replacing an institution
+// @Summary Update institution
+// @Description Rename an institution and replace its email domains
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param institutionId path int true "Institution ID"
+// @Param request body domain.InstitutionRequest true "Institution request"
+// @Success 200 {object} domain.Institution
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Failure 409 {object} domain.ErrorResponse
+// @Router /api/v1/admin/institutions/{institutionId} [put]
+func (h *InstitutionHandler) UpdateInstitution(c echo.Context) error {
+	institutionID, err := strconv.ParseInt(c.Param("institutionId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор учебного заведения",
+		})
+	}
+
+	var req domain.InstitutionRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	institution, fieldErrors, err := h.institutionService.UpdateInstitution(c.Request().Context(), institutionID, req)
+	if err != nil {
+		return h.institutionError(c, "Error updating institution", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusOK, institution)
+}
+
+// DeleteInstitution handles deleting an institution
+// @Summary Delete institution
+// @Description Delete an institution; its users keep their accounts
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param institutionId path int true "Institution ID"
+// @Success 204
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/institutions/{institutionId} [delete]
+func (h *InstitutionHandler) DeleteInstitution(c echo.Context) error {
+	institutionID, err := strconv.ParseInt(c.Param("institutionId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор учебного заведения",
+		})
+	}
+
+	if err := h.institutionService.DeleteInstitution(c.Request().Context(), institutionID); err != nil {
+		return h.institutionError(c, "Error deleting institution", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// institutionError maps institution errors to HTTP responses
+func (h *InstitutionHandler) institutionError(c echo.Context, msg string, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrInstitutionNotFound):
+		return c.JSON(http.StatusNotFound, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	case errors.Is(err, domain.ErrInstitutionExists),
+		errors.Is(err, domain.ErrDomainTaken):
+		return c.JSON(http.StatusConflict, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	h.logger.Errorf("%s: %v", msg, err)
+	return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+		Error: "Сервер не отвечает",
+	})
+}
@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type InvitationService interface {
+	CreateInvitation(ctx context.Context, orgID, inviterID int64, req domain.CreateInvitationRequest) (domain.InvitationView, []domain.FieldError, error)
+	ListInvitations(ctx context.Context, orgID int64) ([]domain.InvitationView, error)
+	ResendInvitation(ctx context.Context, orgID, id int64) (domain.InvitationView, error)
+	RevokeInvitation(ctx context.Context, orgID, id int64) error
+	AcceptInvitation(ctx context.Context, req domain.AcceptInvitationRequest) (*domain.AcceptInvitationResponse, []domain.FieldError, error)
+}
+
+type InvitationHandler struct {
+	invitationService InvitationService
+	logger            logger.Logger
+}
+
+func NewInvitationHandler(invitationService InvitationService, logger logger.Logger) *InvitationHandler {
+	return &InvitationHandler{
+		invitationService: invitationService,
+		logger:            logger,
+	}
+}
+
+// AcceptInvitation handles accepting an invitation
+// @Summary Accept invitation
+// @Description Accept an invitation into an organization. An existing account with the invited email joins the organization;
+// @Description otherwise a new account is registered from the request with the email already verified.
+// @Tags invitations
+// @Accept json
+// @Produce json
+// @Param request body domain.AcceptInvitationRequest true "Accept invitation request"
+// @Success 200 {object} domain.AcceptInvitationResponse
+// @Failure 400 {object} domain.ErrorResponse
+// @Router /auth/v1/invitations/accept [post]
+func (h *InvitationHandler) AcceptInvitation(c echo.Context) error {
+	var req domain.AcceptInvitationRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	if req.Token == "" {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Ошибка валидации",
+			DetailedErrors: []domain.FieldError{
+				{
+					Field:   "token",
+					Message: "Поле пустое",
+				},
+			},
+		})
+	}
+
+	resp, fieldErrors, err := h.invitationService.AcceptInvitation(c.Request().Context(), req)
+	if err != nil {
+		return h.invitationError(c, "Error accepting invitation", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// ListInvitations handles listing the invitations of an organization
+// @Summary List invitations
+// @Description List the invitations of an organization with their status
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param orgId path int true "Organization ID"
+// @Success 200 {array} domain.InvitationView
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/organizations/{orgId}/invitations [get]
+func (h *InvitationHandler) ListInvitations(c echo.Context) error {
+	orgID, err := strconv.ParseInt(c.Param("orgId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор организации",
+		})
+	}
+
+	invitations, err := h.invitationService.ListInvitations(c.Request().Context(), orgID)
+	if err != nil {
+		return h.invitationError(c, "Error listing invitations", err)
+	}
+
+	return c.JSON(http.StatusOK, invitations)
+}
+
+// CreateInvitation handles inviting a person into an organization
+// @Summary Create invitation
+// @Description Invite a person into an organization by email, optionally with roles granted within it on acceptance
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param orgId path int true "Organization ID"
+// @Param request body domain.CreateInvitationRequest true "Create invitation request"
+// @Success 201 {object} domain.InvitationView
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Failure 409 {object} domain.ErrorResponse
+// @Router /api/v1/admin/organizations/{orgId}/invitations [post]
+func (h *InvitationHandler) CreateInvitation(c echo.Context) error {
+	orgID, err := strconv.ParseInt(c.Param("orgId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор организации",
+		})
+	}
+
+	var req domain.CreateInvitationRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	invitation, fieldErrors, err := h.invitationService.CreateInvitation(c.Request().Context(), orgID, actorID(c), req)
+	if err != nil {
+		return h.invitationError(c, "Error creating invitation", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusCreated, invitation)
+}
+
+// ResendInvitation handles resending an invitation
+// @Summary Resend invitation
+// @Description Send an open invitation again with a new token and a fresh expiry; previously sent links stop working
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param orgId path int true "Organization ID"
+// @Param invitationId path int true "Invitation ID"
+// @Success 200 {object} domain.InvitationView
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Failure 409 {object} domain.ErrorResponse
+// @Router /api/v1/admin/organizations/{orgId}/invitations/{invitationId}/resend [post]
+func (h *InvitationHandler) ResendInvitation(c echo.Context) error {
+	orgID, invitationID, ok := invitationParams(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор организации или приглашения",
+		})
+	}
+
+	invitation, err := h.invitationService.ResendInvitation(c.Request().Context(), orgID, invitationID)
+	if err != nil {
+		return h.invitationError(c, "Error resending invitation", err)
+	}
+
+	return c.JSON(http.StatusOK, invitation)
+}
+
+// RevokeInvitation handles revoking an invitation
+// @Summary Revoke invitation
+// @Description Revoke an open invitation
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param orgId path int true "Organization ID"
+// @Param invitationId path int true "Invitation ID"
+// @Success 204
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Failure 409 {object} domain.ErrorResponse
+// @Router /api/v1/admin/organizations/{orgId}/invitations/{invitationId} [delete]
+func (h *InvitationHandler) RevokeInvitation(c echo.Context) error {
+	orgID, invitationID, ok := invitationParams(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор организации или приглашения",
+		})
+	}
+
+	if err := h.invitationService.RevokeInvitation(c.Request().Context(), orgID, invitationID); err != nil {
+		return h.invitationError(c, "Error revoking invitation", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// invitationError maps invitation errors to HTTP responses
+func (h *InvitationHandler) invitationError(c echo.Context, msg string, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrInvitationInvalid):
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Приглашение недействительно или истекло",
+		})
+	case errors.Is(err, domain.ErrOrgNotFound),
+		errors.Is(err, domain.ErrInvitationNotFound):
+		return c.JSON(http.StatusNotFound, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvitationExists),
+		errors.Is(err, domain.ErrInvitationClosed):
+		return c.JSON(http.StatusConflict, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	h.logger.Errorf("%s: %v", msg, err)
+	return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+		Error: "Сервер не отвечает",
+	})
+}
+
+// invitationParams parses the orgId and invitationId path parameters
+func invitationParams(c echo.Context) (int64, int64, bool) {
+	orgID, err := strconv.ParseInt(c.Param("orgId"), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	invitationID, err := strconv.ParseInt(c.Param("invitationId"), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return orgID, invitationID, true
+}
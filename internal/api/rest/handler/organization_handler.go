@@ -0,0 +1,337 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type OrganizationService interface {
+	ListOrganizations(ctx context.Context) ([]domain.Organization, error)
+	CreateOrganization(ctx context.Context, req domain.CreateOrganizationRequest) (domain.Organization, []domain.FieldError, error)
+	DeleteOrganization(ctx context.Context, orgID int64) error
+	AddMember(ctx context.Context, orgID, userID int64) error
+	RemoveMember(ctx context.Context, orgID, userID int64) error
+	ListMembers(ctx context.Context, orgID int64) ([]domain.OrganizationMember, error)
+	GrantOrgRole(ctx context.Context, orgID, userID, roleID int64) error
+	RevokeOrgRole(ctx context.Context, orgID, userID, roleID int64) error
+	GetUserMemberships(ctx context.Context, userID int64) ([]domain.Membership, error)
+}
+
+type OrganizationHandler struct {
+	orgService OrganizationService
+	logger     logger.Logger
+}
+
+func NewOrganizationHandler(orgService OrganizationService, logger logger.Logger) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgService: orgService,
+		logger:     logger,
+	}
+}
+
+// ListMyMemberships handles listing the organizations of the authenticated user
+// @Summary List my organizations
+// @Description List the organizations the authenticated user is a member of, with their roles in each
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} domain.Membership
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /api/v1/organizations [get]
+func (h *OrganizationHandler) ListMyMemberships(c echo.Context) error {
+	memberships, err := h.orgService.GetUserMemberships(c.Request().Context(), actorID(c))
+	if err != nil {
+		h.logger.Errorf("Error listing memberships: %v", err)
+		return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Сервер не отвечает",
+		})
+	}
+
+	return c.JSON(http.StatusOK, memberships)
+}
+
+// ListOrganizations handles listing all organizations
+// @Summary List organizations
+// @Description List all organizations
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} domain.Organization
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /api/v1/admin/organizations [get]
+func (h *OrganizationHandler) ListOrganizations(c echo.Context) error {
+	orgs, err := h.orgService.ListOrganizations(c.Request().Context())
+	if err != nil {
+		h.logger.Errorf("Error listing organizations: %v", err)
+		return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Сервер не отвечает",
+		})
+	}
+
+	return c.JSON(http.StatusOK, orgs)
+}
+
+// CreateOrganization handles creating an organization
+// @Summary Create organization
+// @Description Create a new organization
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body domain.CreateOrganizationRequest true "Create organization request"
+// @Success 201 {object} domain.Organization
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 409 {object} domain.ErrorResponse
+// @Router /api/v1/admin/organizations [post]
+func (h *OrganizationHandler) CreateOrganization(c echo.Context) error {
+	var req domain.CreateOrganizationRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	org, fieldErrors, err := h.orgService.CreateOrganization(c.Request().Context(), req)
+	if err != nil {
+		return h.orgError(c, "Error creating organization", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusCreated, org)
+}
+
+// DeleteOrganization handles deleting an organization
+// @Summary Delete organization
+// @Description Delete an organization together with its memberships
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param orgId path int true "Organization ID"
+// @Success 204
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/organizations/{orgId} [delete]
+func (h *OrganizationHandler) DeleteOrganization(c echo.Context) error {
+	orgID, err := strconv.ParseInt(c.Param("orgId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор организации",
+		})
+	}
+
+	if err := h.orgService.DeleteOrganization(c.Request().Context(), orgID); err != nil {
+		return h.orgError(c, "Error deleting organization", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// AddMember handles adding a user to an organization
+// @Summary Add member
+// @Description Add a user to an organization
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param orgId path int true "Organization ID"
+// @Param userId path int true "User ID"
+// @Success 204
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Failure 409 {object} domain.ErrorResponse
+// @Router /api/v1/admin/organizations/{orgId}/members/{userId} [put]
+func (h *OrganizationHandler) AddMember(c echo.Context) error {
+	orgID, userID, ok := orgMemberParams(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор организации или пользователя",
+		})
+	}
+
+	if err := h.orgService.AddMember(c.Request().Context(), orgID, userID); err != nil {
+		return h.orgError(c, "Error adding member", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RemoveMember handles removing a user from an organization
+// @Summary Remove member
+// @Description Remove a user and their roles from an organization
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param orgId path int true "Organization ID"
+// @Param userId path int true "User ID"
+// @Success 204
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/organizations/{orgId}/members/{userId} [delete]
+func (h *OrganizationHandler) RemoveMember(c echo.Context) error {
+	orgID, userID, ok := orgMemberParams(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор организации или пользователя",
+		})
+	}
+
+	if err := h.orgService.RemoveMember(c.Request().Context(), orgID, userID); err != nil {
+		return h.orgError(c, "Error removing member", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListMembers handles listing the members of an organization
+// @Summary List members
+// @Description List the members of an organization with their roles in it; requires membership
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param orgId path int true "Organization ID"
+// @Success 200 {array} domain.OrganizationMember
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 403 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/organizations/{orgId}/members [get]
+func (h *OrganizationHandler) ListMembers(c echo.Context) error {
+	orgID, err := strconv.ParseInt(c.Param("orgId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор организации",
+		})
+	}
+
+	members, err := h.orgService.ListMembers(c.Request().Context(), orgID)
+	if err != nil {
+		return h.orgError(c, "Error listing members", err)
+	}
+
+	return c.JSON(http.StatusOK, members)
+}
+
+// GrantOrgRole handles granting a role to a member within an organization
+// @Summary Grant organization role
+// @Description Grant a role to a member within an organization; requires the admin role in the organization
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param orgId path int true "Organization ID"
+// @Param userId path int true "User ID"
+// @Param roleId path int true "Role ID"
+// @Success 204
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 403 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Failure 409 {object} domain.ErrorResponse
+// @Router /api/v1/organizations/{orgId}/members/{userId}/roles/{roleId} [put]
+func (h *OrganizationHandler) GrantOrgRole(c echo.Context) error {
+	orgID, userID, ok := orgMemberParams(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор организации или пользователя",
+		})
+	}
+
+	roleID, err := strconv.ParseInt(c.Param("roleId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор роли",
+		})
+	}
+
+	if err := h.orgService.GrantOrgRole(c.Request().Context(), orgID, userID, roleID); err != nil {
+		return h.orgError(c, "Error granting organization role", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RevokeOrgRole handles revoking a role from a member within an organization
+// @Summary Revoke organization role
+// @Description Revoke a role from a member within an organization; requires the admin role in the organization
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param orgId path int true "Organization ID"
+// @Param userId path int true "User ID"
+// @Param roleId path int true "Role ID"
+// @Success 204
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 403 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/organizations/{orgId}/members/{userId}/roles/{roleId} [delete]
+func (h *OrganizationHandler) RevokeOrgRole(c echo.Context) error {
+	orgID, userID, ok := orgMemberParams(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор организации или пользователя",
+		})
+	}
+
+	roleID, err := strconv.ParseInt(c.Param("roleId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор роли",
+		})
+	}
+
+	if err := h.orgService.RevokeOrgRole(c.Request().Context(), orgID, userID, roleID); err != nil {
+		return h.orgError(c, "Error revoking organization role", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// orgError maps organization errors to HTTP responses
+func (h *OrganizationHandler) orgError(c echo.Context, msg string, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrOrgNotFound),
+		errors.Is(err, domain.ErrUserNotFound),
+		errors.Is(err, domain.ErrRoleNotFound),
+		errors.Is(err, domain.ErrNotMember),
+		errors.Is(err, domain.ErrRoleNotGranted):
+		return c.JSON(http.StatusNotFound, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	case errors.Is(err, domain.ErrOrgExists),
+		errors.Is(err, domain.ErrAlreadyMember),
+		errors.Is(err, domain.ErrRoleAlreadyGranted):
+		return c.JSON(http.StatusConflict, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	h.logger.Errorf("%s: %v", msg, err)
+	return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+		Error: "Сервер не отвечает",
+	})
+}
+
+// orgMemberParams parses the orgId and userId path parameters
+func orgMemberParams(c echo.Context) (int64, int64, bool) {
+	orgID, err := strconv.ParseInt(c.Param("orgId"), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return orgID, userID, true
+}
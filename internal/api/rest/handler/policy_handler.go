@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type PolicyService interface {
+	ListPolicies(ctx context.Context) ([]domain.Policy, error)
+	GetPolicy(ctx context.Context, id int64) (domain.Policy, error)
+	CreatePolicy(ctx context.Context, req domain.PolicyRequest) (domain.Policy, []domain.FieldError, error)
+	UpdatePolicy(ctx context.Context, id int64, req domain.PolicyRequest) (domain.Policy, []domain.FieldError, error)
+	DeletePolicy(ctx context.Context, id int64) error
+	Authorize(ctx context.Context, req domain.AuthorizeRequest) (domain.AuthorizationDecision, []domain.FieldError, error)
+	DryRun(ctx context.Context, req domain.PolicyDryRunRequest) (domain.AuthorizationDecision, []domain.FieldError, error)
+}
+
+type PolicyHandler struct {
+	policyService PolicyService
+	logger        logger.Logger
+}
+
+func NewPolicyHandler(policyService PolicyService, logger logger.Logger) *PolicyHandler {
+	return &PolicyHandler{
+		policyService: policyService,
+		logger:        logger,
+	}
+}
+
+// Authorize handles an authorization decision for the authenticated user
+// @Summary Authorize action
+// @Description Decide whether the authenticated user may perform an action on a resource according to the policies
+// @Tags authorization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body domain.AuthorizeRequest true "Authorize request; subjectId is taken from the token and explain is ignored"
+// @Success 200 {object} domain.AuthorizationDecision
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /api/v1/authorize [post]
+func (h *PolicyHandler) Authorize(c echo.Context) error {
+	var req domain.AuthorizeRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	// The subject is always the caller. Explanations name every policy and how it evaluated, so they are only
+	// served to admins by the dry-run endpoint.
+	req.SubjectID = actorID(c)
+	req.Explain = false
+
+	decision, fieldErrors, err := h.policyService.Authorize(c.Request().Context(), req)
+	if err != nil {
+		return h.policyError(c, "Error authorizing request", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusOK, decision)
+}
+
+// ListPolicies handles listing all policies
+// @Summary List policies
+// @Description List all authorization policies
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} domain.Policy
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /api/v1/admin/policies [get]
+func (h *PolicyHandler) ListPolicies(c echo.Context) error {
+	policies, err := h.policyService.ListPolicies(c.Request().Context())
+	if err != nil {
+		h.logger.Errorf("Error listing policies: %v", err)
+		return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Сервер не отвечает",
+		})
+	}
+
+	return c.JSON(http.StatusOK, policies)
+}
+
+// GetPolicy handles retrieving a policy
+// @Summary Get policy
+// @Description Get an authorization policy
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param policyId path int true "Policy ID"
+// @Success 200 {object} domain.Policy
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/policies/{policyId} [get]
+func (h *PolicyHandler) GetPolicy(c echo.Context) error {
+	policyID, err := strconv.ParseInt(c.Param("policyId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор политики",
+		})
+	}
+
+	policy, err := h.policyService.GetPolicy(c.Request().Context(), policyID)
+	if err != nil {
+		return h.policyError(c, "Error getting policy", err)
+	}
+
+	return c.JSON(http.StatusOK, policy)
+}
+
+// CreatePolicy handles creating a policy
+// @Summary Create policy
+// @Description Create a new authorization policy
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body domain.PolicyRequest true "Policy request"
+// @Success 201 {object} domain.Policy
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 409 {object} domain.ErrorResponse
+// @Router /api/v1/admin/policies [post]
+func (h *PolicyHandler) CreatePolicy(c echo.Context) error {
+	var req domain.PolicyRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	policy, fieldErrors, err := h.policyService.CreatePolicy(c.Request().Context(), req)
+	if err != nil {
+		return h.policyError(c, "Error creating policy", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusCreated, policy)
+}
+
+// UpdatePolicy handles replacing a policy
+// @Summary Update policy
+// @Description Replace the definition of an authorization policy
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param policyId path int true "Policy ID"
+// @Param request body domain.PolicyRequest true "Policy request"
+// @Success 200 {object} domain.Policy
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Failure 409 {object} domain.ErrorResponse
+// @Router /api/v1/admin/policies/{policyId} [put]
+func (h *PolicyHandler) UpdatePolicy(c echo.Context) error {
+	policyID, err := strconv.ParseInt(c.Param("policyId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор политики",
+		})
+	}
+
+	var req domain.PolicyRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	policy, fieldErrors, err := h.policyService.UpdatePolicy(c.Request().Context(), policyID, req)
+	if err != nil {
+		return h.policyError(c, "Error updating policy", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusOK, policy)
+}
+
+// DeletePolicy handles deleting a policy
+// @Summary Delete policy
+// @Description Delete an authorization policy
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param policyId path int true "Policy ID"
+// @Success 204
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/policies/{policyId} [delete]
+func (h *PolicyHandler) DeletePolicy(c echo.Context) error {
+	policyID, err := strconv.ParseInt(c.Param("policyId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор политики",
+		})
+	}
+
+	if err := h.policyService.DeletePolicy(c.Request().Context(), policyID); err != nil {
+		return h.policyError(c, "Error deleting policy", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// DryRunPolicies handles evaluating candidate policies without storing them
+// @Summary Dry-run policies
+// @Description Evaluate candidate policies, optionally together with the stored ones, and explain the decision
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body domain.PolicyDryRunRequest true "Dry-run request"
+// @Success 200 {object} domain.AuthorizationDecision
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/policies/dry-run [post]
+func (h *PolicyHandler) DryRunPolicies(c echo.Context) error {
+	var req domain.PolicyDryRunRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	decision, fieldErrors, err := h.policyService.DryRun(c.Request().Context(), req)
+	if err != nil {
+		return h.policyError(c, "Error evaluating policies", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusOK, decision)
+}
+
+// policyError maps policy errors to HTTP responses
+func (h *PolicyHandler) policyError(c echo.Context, msg string, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrPolicyNotFound),
+		errors.Is(err, domain.ErrUserNotFound):
+		return c.JSON(http.StatusNotFound, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	case errors.Is(err, domain.ErrPolicyExists):
+		return c.JSON(http.StatusConflict, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	h.logger.Errorf("%s: %v", msg, err)
+	return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+		Error: "Сервер не отвечает",
+	})
+}
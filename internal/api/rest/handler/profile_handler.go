@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type ProfileService interface {
+	GetMe(ctx context.Context, userID int64) (domain.User, error)
+	UpdateProfile(ctx context.Context, userID int64, req domain.UpdateProfileRequest) (domain.User, []domain.FieldError, error)
+}
+
+type ProfileHandler struct {
+	profileService ProfileService
+	logger         logger.Logger
+}
+
+func NewProfileHandler(profileService ProfileService, logger logger.Logger) *ProfileHandler {
+	return &ProfileHandler{
+		profileService: profileService,
+		logger:         logger,
+	}
+}
+
+// GetMe handles retrieving the profile of the authenticated user
+// @Summary Get my profile
+// @Description Get the profile of the authenticated user
+// @Tags profile
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} domain.User
+// @Failure 401 {object} domain.ErrorResponse
+// @Router /api/v1/me [get]
+func (h *ProfileHandler) GetMe(c echo.Context) error {
+	user, err := h.profileService.GetMe(c.Request().Context(), actorID(c))
+	if err != nil {
+		return h.profileError(c, "Error getting profile", err)
+	}
+
+	return c.JSON(http.StatusOK, user)
+}
+
+// UpdateProfile handles changing the profile of the authenticated user
+// @Summary Update my profile
+// @Description Change the first name, last name or nickname of the authenticated user; omitted fields are left unchanged.
+// @Description The values are validated like at registration.
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body domain.UpdateProfileRequest true "Update profile request"
+// @Success 200 {object} domain.User
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 401 {object} domain.ErrorResponse
+// @Router /api/v1/me [patch]
+func (h *ProfileHandler) UpdateProfile(c echo.Context) error {
+	var req domain.UpdateProfileRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	user, fieldErrors, err := h.profileService.UpdateProfile(c.Request().Context(), actorID(c), req)
+	if err != nil {
+		return h.profileError(c, "Error updating profile", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusOK, user)
+}
+
+// profileError maps profile errors to HTTP responses
+func (h *ProfileHandler) profileError(c echo.Context, msg string, err error) error {
+	if errors.Is(err, domain.ErrUserNotFound) {
+		// The account was removed after the token had been issued
+		return c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
+			Error: "Unauthorized",
+		})
+	}
+
+	h.logger.Errorf("%s: %v", msg, err)
+	return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+		Error: "Сервер не отвечает",
+	})
+}
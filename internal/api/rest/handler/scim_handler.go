@@ -0,0 +1,577 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type SCIMService interface {
+	CreateClient(ctx context.Context, actorID int64, req domain.CreateSCIMClientRequest) (domain.CreateSCIMClientResponse, []domain.FieldError, error)
+	ListClients(ctx context.Context) ([]domain.SCIMClient, error)
+	DeleteClient(ctx context.Context, clientID int64) error
+	ListUsers(ctx context.Context, req domain.SCIMListRequest) (domain.SCIMListResponse, error)
+	GetUser(ctx context.Context, id string) (domain.SCIMUser, error)
+	CreateUser(ctx context.Context, client domain.SCIMClient, user domain.SCIMUser) (domain.SCIMUser, error)
+	ReplaceUser(ctx context.Context, client domain.SCIMClient, id string, user domain.SCIMUser) (domain.SCIMUser, error)
+	PatchUser(ctx context.Context, client domain.SCIMClient, id string, req domain.SCIMPatchRequest) (domain.SCIMUser, error)
+	DeleteUser(ctx context.Context, client domain.SCIMClient, id string) error
+	ListGroups(ctx context.Context, req domain.SCIMListRequest) (domain.SCIMListResponse, error)
+	GetGroup(ctx context.Context, id string, excludeMembers bool) (domain.SCIMGroup, error)
+	CreateGroup(ctx context.Context, client domain.SCIMClient, group domain.SCIMGroup) (domain.SCIMGroup, error)
+	ReplaceGroup(ctx context.Context, client domain.SCIMClient, id string, group domain.SCIMGroup) (domain.SCIMGroup, error)
+	PatchGroup(ctx context.Context, client domain.SCIMClient, id string, req domain.SCIMPatchRequest) (domain.SCIMGroup, error)
+	DeleteGroup(ctx context.Context, client domain.SCIMClient, id string) error
+}
+
+// scimContentType is the media type of SCIM requests and responses
+const scimContentType = "application/scim+json"
+
+type SCIMHandler struct {
+	scimService SCIMService
+	logger      logger.Logger
+}
+
+func NewSCIMHandler(scimService SCIMService, logger logger.Logger) *SCIMHandler {
+	return &SCIMHandler{
+		scimService: scimService,
+		logger:      logger,
+	}
+}
+
+// ListClients handles listing the SCIM provisioning clients
+// @Summary List SCIM clients
+// @Description Get the provisioning clients allowed to call the SCIM API
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} domain.SCIMClient
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /api/v1/admin/scimClients [get]
+func (h *SCIMHandler) ListClients(c echo.Context) error {
+	clients, err := h.scimService.ListClients(c.Request().Context())
+	if err != nil {
+		h.logger.Errorf("Error listing SCIM clients: %v", err)
+		return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Сервер не отвечает",
+		})
+	}
+
+	if clients == nil {
+		clients = []domain.SCIMClient{}
+	}
+
+	return c.JSON(http.StatusOK, clients)
+}
+
+// CreateClient handles registering a SCIM provisioning client
+// @Summary Create SCIM client
+// @Description Register a provisioning client. The response holds its bearer token, which is not shown again.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body domain.CreateSCIMClientRequest true "Client"
+// @Success 201 {object} domain.CreateSCIMClientResponse
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /api/v1/admin/scimClients [post]
+func (h *SCIMHandler) CreateClient(c echo.Context) error {
+	var req domain.CreateSCIMClientRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	client, fieldErrors, err := h.scimService.CreateClient(c.Request().Context(), actorID(c), req)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Сервер не отвечает",
+		})
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusCreated, client)
+}
+
+// DeleteClient handles deleting a SCIM provisioning client
+// @Summary Delete SCIM client
+// @Description Delete a provisioning client, revoking its token. The users and groups it provisioned are kept.
+// @Tags admin
+// @Security BearerAuth
+// @Param clientId path int true "Client ID"
+// @Success 204
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /api/v1/admin/scimClients/{clientId} [delete]
+func (h *SCIMHandler) DeleteClient(c echo.Context) error {
+	clientID, err := strconv.ParseInt(c.Param("clientId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор клиента",
+		})
+	}
+
+	if err := h.scimService.DeleteClient(c.Request().Context(), clientID); err != nil {
+		if errors.Is(err, domain.ErrSCIMClientNotFound) {
+			return c.JSON(http.StatusNotFound, domain.ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+		h.logger.Errorf("Error deleting SCIM client: %v", err)
+		return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Сервер не отвечает",
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListUsers handles listing users over SCIM
+// @Summary List SCIM users
+// @Description Get a page of the users matching a SCIM filter, e.g. userName eq "jane@example.com"
+// @Tags scim
+// @Produce application/scim+json
+// @Security BearerAuth
+// @Param filter query string false "SCIM filter"
+// @Param startIndex query int false "1-based index of the first user"
+// @Param count query int false "Page size (default 100, max 200)"
+// @Success 200 {object} domain.SCIMListResponse
+// @Failure 400 {object} domain.SCIMError
+// @Failure 401 {object} domain.SCIMError
+// @Router /scim/v2/Users [get]
+func (h *SCIMHandler) ListUsers(c echo.Context) error {
+	req, err := scimListRequest(c)
+	if err != nil {
+		return h.scimError(c, err)
+	}
+
+	page, err := h.scimService.ListUsers(c.Request().Context(), req)
+	if err != nil {
+		return h.scimError(c, err)
+	}
+
+	return scimJSON(c, http.StatusOK, page)
+}
+
+// GetUser handles retrieving a user over SCIM
+// @Summary Get SCIM user
+// @Tags scim
+// @Produce application/scim+json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} domain.SCIMUser
+// @Failure 401 {object} domain.SCIMError
+// @Failure 404 {object} domain.SCIMError
+// @Router /scim/v2/Users/{id} [get]
+func (h *SCIMHandler) GetUser(c echo.Context) error {
+	user, err := h.scimService.GetUser(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return h.scimError(c, err)
+	}
+
+	return scimJSON(c, http.StatusOK, user)
+}
+
+// CreateUser handles provisioning a user over SCIM
+// @Summary Create SCIM user
+// @Description Provision a user. Its email is the primary email or else userName and counts as verified; without a
+// @Description nickName one is derived from the email. active false bans the user.
+// @Tags scim
+// @Accept application/scim+json
+// @Produce application/scim+json
+// @Security BearerAuth
+// @Param request body domain.SCIMUser true "User"
+// @Success 201 {object} domain.SCIMUser
+// @Failure 400 {object} domain.SCIMError
+// @Failure 401 {object} domain.SCIMError
+// @Failure 409 {object} domain.SCIMError
+// @Router /scim/v2/Users [post]
+func (h *SCIMHandler) CreateUser(c echo.Context) error {
+	var in domain.SCIMUser
+	if err := decodeSCIM(c, &in); err != nil {
+		return h.scimError(c, err)
+	}
+
+	user, err := h.scimService.CreateUser(c.Request().Context(), scimClient(c), in)
+	if err != nil {
+		return h.scimError(c, err)
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, user.Meta.Location)
+	return scimJSON(c, http.StatusCreated, user)
+}
+
+// ReplaceUser handles replacing a user over SCIM
+// @Summary Replace SCIM user
+// @Description Update a user with the attributes of the request; omitted name, nickName and active are unchanged
+// @Tags scim
+// @Accept application/scim+json
+// @Produce application/scim+json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body domain.SCIMUser true "User"
+// @Success 200 {object} domain.SCIMUser
+// @Failure 400 {object} domain.SCIMError
+// @Failure 401 {object} domain.SCIMError
+// @Failure 404 {object} domain.SCIMError
+// @Failure 409 {object} domain.SCIMError
+// @Router /scim/v2/Users/{id} [put]
+func (h *SCIMHandler) ReplaceUser(c echo.Context) error {
+	var in domain.SCIMUser
+	if err := decodeSCIM(c, &in); err != nil {
+		return h.scimError(c, err)
+	}
+
+	user, err := h.scimService.ReplaceUser(c.Request().Context(), scimClient(c), c.Param("id"), in)
+	if err != nil {
+		return h.scimError(c, err)
+	}
+
+	return scimJSON(c, http.StatusOK, user)
+}
+
+// PatchUser handles patching a user over SCIM
+// @Summary Patch SCIM user
+// @Description Apply add, replace and remove operations to a user
+// @Tags scim
+// @Accept application/scim+json
+// @Produce application/scim+json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body domain.SCIMPatchRequest true "Operations"
+// @Success 200 {object} domain.SCIMUser
+// @Failure 400 {object} domain.SCIMError
+// @Failure 401 {object} domain.SCIMError
+// @Failure 404 {object} domain.SCIMError
+// @Failure 409 {object} domain.SCIMError
+// @Router /scim/v2/Users/{id} [patch]
+func (h *SCIMHandler) PatchUser(c echo.Context) error {
+	var req domain.SCIMPatchRequest
+	if err := decodeSCIM(c, &req); err != nil {
+		return h.scimError(c, err)
+	}
+
+	user, err := h.scimService.PatchUser(c.Request().Context(), scimClient(c), c.Param("id"), req)
+	if err != nil {
+		return h.scimError(c, err)
+	}
+
+	return scimJSON(c, http.StatusOK, user)
+}
+
+// DeleteUser handles deleting a user over SCIM
+// @Summary Delete SCIM user
+// @Description Delete a user, or anonymize it when deleted accounts are anonymized
+// @Tags scim
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 204
+// @Failure 401 {object} domain.SCIMError
+// @Failure 404 {object} domain.SCIMError
+// @Router /scim/v2/Users/{id} [delete]
+func (h *SCIMHandler) DeleteUser(c echo.Context) error {
+	if err := h.scimService.DeleteUser(c.Request().Context(), scimClient(c), c.Param("id")); err != nil {
+		return h.scimError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListGroups handles listing roles as groups over SCIM
+// @Summary List SCIM groups
+// @Description Get a page of the roles matching a SCIM filter, e.g. displayName eq "moderator"
+// @Tags scim
+// @Produce application/scim+json
+// @Security BearerAuth
+// @Param filter query string false "SCIM filter"
+// @Param startIndex query int false "1-based index of the first group"
+// @Param count query int false "Page size (default 100, max 200)"
+// @Param excludedAttributes query string false "members to leave out the members"
+// @Success 200 {object} domain.SCIMListResponse
+// @Failure 400 {object} domain.SCIMError
+// @Failure 401 {object} domain.SCIMError
+// @Router /scim/v2/Groups [get]
+func (h *SCIMHandler) ListGroups(c echo.Context) error {
+	req, err := scimListRequest(c)
+	if err != nil {
+		return h.scimError(c, err)
+	}
+
+	page, err := h.scimService.ListGroups(c.Request().Context(), req)
+	if err != nil {
+		return h.scimError(c, err)
+	}
+
+	return scimJSON(c, http.StatusOK, page)
+}
+
+// GetGroup handles retrieving a role as a group over SCIM
+// @Summary Get SCIM group
+// @Tags scim
+// @Produce application/scim+json
+// @Security BearerAuth
+// @Param id path string true "Role ID"
+// @Param excludedAttributes query string false "members to leave out the members"
+// @Success 200 {object} domain.SCIMGroup
+// @Failure 401 {object} domain.SCIMError
+// @Failure 404 {object} domain.SCIMError
+// @Router /scim/v2/Groups/{id} [get]
+func (h *SCIMHandler) GetGroup(c echo.Context) error {
+	group, err := h.scimService.GetGroup(c.Request().Context(), c.Param("id"), excludeMembers(c))
+	if err != nil {
+		return h.scimError(c, err)
+	}
+
+	return scimJSON(c, http.StatusOK, group)
+}
+
+// CreateGroup handles creating a role as a group over SCIM
+// @Summary Create SCIM group
+// @Description Create a role named after the displayName and grant it to the members
+// @Tags scim
+// @Accept application/scim+json
+// @Produce application/scim+json
+// @Security BearerAuth
+// @Param request body domain.SCIMGroup true "Group"
+// @Success 201 {object} domain.SCIMGroup
+// @Failure 400 {object} domain.SCIMError
+// @Failure 401 {object} domain.SCIMError
+// @Failure 409 {object} domain.SCIMError
+// @Router /scim/v2/Groups [post]
+func (h *SCIMHandler) CreateGroup(c echo.Context) error {
+	var in domain.SCIMGroup
+	if err := decodeSCIM(c, &in); err != nil {
+		return h.scimError(c, err)
+	}
+
+	group, err := h.scimService.CreateGroup(c.Request().Context(), scimClient(c), in)
+	if err != nil {
+		return h.scimError(c, err)
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, group.Meta.Location)
+	return scimJSON(c, http.StatusCreated, group)
+}
+
+// ReplaceGroup handles replacing a role as a group over SCIM
+// @Summary Replace SCIM group
+// @Description Rename a role and grant it to exactly the members; omitted members are unchanged
+// @Tags scim
+// @Accept application/scim+json
+// @Produce application/scim+json
+// @Security BearerAuth
+// @Param id path string true "Role ID"
+// @Param request body domain.SCIMGroup true "Group"
+// @Success 200 {object} domain.SCIMGroup
+// @Failure 400 {object} domain.SCIMError
+// @Failure 401 {object} domain.SCIMError
+// @Failure 404 {object} domain.SCIMError
+// @Failure 409 {object} domain.SCIMError
+// @Router /scim/v2/Groups/{id} [put]
+func (h *SCIMHandler) ReplaceGroup(c echo.Context) error {
+	var in domain.SCIMGroup
+	if err := decodeSCIM(c, &in); err != nil {
+		return h.scimError(c, err)
+	}
+
+	group, err := h.scimService.ReplaceGroup(c.Request().Context(), scimClient(c), c.Param("id"), in)
+	if err != nil {
+		return h.scimError(c, err)
+	}
+
+	return scimJSON(c, http.StatusOK, group)
+}
+
+// PatchGroup handles patching a role as a group over SCIM
+// @Summary Patch SCIM group
+// @Description Apply add, replace and remove operations to the displayName and members of a role
+// @Tags scim
+// @Accept application/scim+json
+// @Produce application/scim+json
+// @Security BearerAuth
+// @Param id path string true "Role ID"
+// @Param request body domain.SCIMPatchRequest true "Operations"
+// @Success 200 {object} domain.SCIMGroup
+// @Failure 400 {object} domain.SCIMError
+// @Failure 401 {object} domain.SCIMError
+// @Failure 404 {object} domain.SCIMError
+// @Failure 409 {object} domain.SCIMError
+// @Router /scim/v2/Groups/{id} [patch]
+func (h *SCIMHandler) PatchGroup(c echo.Context) error {
+	var req domain.SCIMPatchRequest
+	if err := decodeSCIM(c, &req); err != nil {
+		return h.scimError(c, err)
+	}
+
+	group, err := h.scimService.PatchGroup(c.Request().Context(), scimClient(c), c.Param("id"), req)
+	if err != nil {
+		return h.scimError(c, err)
+	}
+
+	return scimJSON(c, http.StatusOK, group)
+}
+
+// DeleteGroup handles deleting a role as a group over SCIM
+// @Summary Delete SCIM group
+// @Description Delete a role; default roles cannot be deleted
+// @Tags scim
+// @Security BearerAuth
+// @Param id path string true "Role ID"
+// @Success 204
+// @Failure 400 {object} domain.SCIMError
+// @Failure 401 {object} domain.SCIMError
+// @Failure 404 {object} domain.SCIMError
+// @Router /scim/v2/Groups/{id} [delete]
+func (h *SCIMHandler) DeleteGroup(c echo.Context) error {
+	if err := h.scimService.DeleteGroup(c.Request().Context(), scimClient(c), c.Param("id")); err != nil {
+		return h.scimError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ServiceProviderConfig handles describing the supported SCIM features
+// @Summary SCIM service provider configuration
+// @Tags scim
+// @Produce application/scim+json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Router /scim/v2/ServiceProviderConfig [get]
+func (h *SCIMHandler) ServiceProviderConfig(c echo.Context) error {
+	supported := func(ok bool) map[string]interface{} {
+		return map[string]interface{}{"supported": ok}
+	}
+
+	return scimJSON(c, http.StatusOK, map[string]interface{}{
+		"schemas":        []string{domain.SCIMSchemas.ServiceProviderConfig},
+		"patch":          supported(true),
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": 200},
+		"changePassword": supported(false),
+		"sort":           supported(false),
+		"etag":           supported(false),
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "Token of a provisioning client registered by an admin",
+			"primary":     true,
+		}},
+	})
+}
+
+// ResourceTypes handles describing the SCIM resource types
+// @Summary SCIM resource types
+// @Tags scim
+// @Produce application/scim+json
+// @Security BearerAuth
+// @Success 200 {object} domain.SCIMListResponse
+// @Router /scim/v2/ResourceTypes [get]
+func (h *SCIMHandler) ResourceTypes(c echo.Context) error {
+	resourceType := func(name, endpoint, schema string) map[string]interface{} {
+		return map[string]interface{}{
+			"schemas":  []string{domain.SCIMSchemas.ResourceType},
+			"id":       name,
+			"name":     name,
+			"endpoint": endpoint,
+			"schema":   schema,
+		}
+	}
+
+	resources := []map[string]interface{}{
+		resourceType("User", "/Users", domain.SCIMSchemas.User),
+		resourceType("Group", "/Groups", domain.SCIMSchemas.Group),
+	}
+
+	return scimJSON(c, http.StatusOK, domain.SCIMListResponse{
+		Schemas:      []string{domain.SCIMSchemas.ListResponse},
+		TotalResults: len(resources),
+		StartIndex:   1,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// scimError answers with the SCIM error returned by the service, or with an internal error
+func (h *SCIMHandler) scimError(c echo.Context, err error) error {
+	var scimErr *domain.SCIMError
+	if errors.As(err, &scimErr) {
+		return scimJSON(c, scimErr.Status, scimErr)
+	}
+
+	h.logger.Errorf("Error handling SCIM request: %v", err)
+	return scimJSON(c, http.StatusInternalServerError, domain.NewSCIMError(http.StatusInternalServerError, "", "internal error"))
+}
+
+// scimJSON answers with a SCIM resource or message
+func scimJSON(c echo.Context, status int, body interface{}) error {
+	c.Response().Header().Set(echo.HeaderContentType, scimContentType)
+	return c.JSON(status, body)
+}
+
+// decodeSCIM decodes a request body sent as application/scim+json, which Bind does not accept
+func decodeSCIM(c echo.Context, v interface{}) error {
+	if err := json.NewDecoder(c.Request().Body).Decode(v); err != nil {
+		return domain.NewSCIMError(http.StatusBadRequest, "invalidSyntax", "malformed request body")
+	}
+	return nil
+}
+
+// scimListRequest parses the query parameters of a SCIM listing
+func scimListRequest(c echo.Context) (domain.SCIMListRequest, error) {
+	req := domain.SCIMListRequest{
+		Filter:         c.QueryParam("filter"),
+		StartIndex:     1,
+		ExcludeMembers: excludeMembers(c),
+	}
+
+	if value := c.QueryParam("startIndex"); value != "" {
+		startIndex, err := strconv.Atoi(value)
+		if err != nil {
+			return domain.SCIMListRequest{}, domain.NewSCIMError(http.StatusBadRequest, "invalidValue", "startIndex must be an integer")
+		}
+		req.StartIndex = startIndex
+	}
+
+	if value := c.QueryParam("count"); value != "" {
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return domain.SCIMListRequest{}, domain.NewSCIMError(http.StatusBadRequest, "invalidValue", "count must be an integer")
+		}
+		req.Count = &count
+	}
+
+	return req, nil
+}
+
+// excludeMembers reports whether the excludedAttributes query parameter lists members
+func excludeMembers(c echo.Context) bool {
+	for _, attribute := range strings.Split(c.QueryParam("excludedAttributes"), ",") {
+		if strings.EqualFold(strings.TrimSpace(attribute), "members") {
+			return true
+		}
+	}
+	return false
+}
+
+// scimClient returns the provisioning client authenticated by the SCIM middleware
+func scimClient(c echo.Context) domain.SCIMClient {
+	client, _ := c.Get("scimClient").(domain.SCIMClient)
+	return client
+}
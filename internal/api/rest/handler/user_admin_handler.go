@@ -0,0 +1,501 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type UserAdminService interface {
+	ListUsers(ctx context.Context, req domain.ListUsersRequest) (domain.UserPage, []domain.FieldError, error)
+	GetUserDetails(ctx context.Context, userID int64) (domain.UserDetails, error)
+	ListUsersUnderReview(ctx context.Context) ([]domain.User, error)
+	ApproveUser(ctx context.Context, userID int64) error
+	ChangeNickname(ctx context.Context, actorID, userID int64, req domain.ChangeNicknameRequest) (domain.User, []domain.FieldError, error)
+	ListNicknameHistory(ctx context.Context, userID int64) ([]domain.NicknameChange, error)
+	ListBlockedNicknames(ctx context.Context) ([]domain.BlockedNickname, error)
+	BlockNickname(ctx context.Context, actorID int64, req domain.BlockNicknameRequest) (domain.BlockedNickname, []domain.FieldError, error)
+	UnblockNickname(ctx context.Context, nickname string) error
+	SuspendUser(ctx context.Context, actorID, userID int64, req domain.SuspendUserRequest) (domain.User, []domain.FieldError, error)
+	BanUser(ctx context.Context, actorID, userID int64, req domain.BanUserRequest) (domain.User, []domain.FieldError, error)
+	ReinstateUser(ctx context.Context, actorID, userID int64) (domain.User, error)
+}
+
+type UserAdminHandler struct {
+	userAdminService UserAdminService
+	logger           logger.Logger
+}
+
+func NewUserAdminHandler(userAdminService UserAdminService, logger logger.Logger) *UserAdminHandler {
+	return &UserAdminHandler{
+		userAdminService: userAdminService,
+		logger:           logger,
+	}
+}
+
+// ListUsers handles browsing the user directory
+// @Summary List users
+// @Description List users page by page. The nextCursor of a page requests the following page with the same filters and
+// @Description sorting; it is left out on the last page. Anonymized accounts are not listed.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param email query string false "Email prefix"
+// @Param nickname query string false "Nickname prefix"
+// @Param role query string false "Name of a role granted directly"
+// @Param status query string false "Account status: active, suspended or banned"
+// @Param verified query bool false "Email verified"
+// @Param createdFrom query string false "Registered at or after (RFC 3339)"
+// @Param createdTo query string false "Registered before (RFC 3339)"
+// @Param sort query string false "Sort by createdAt (default), email or nickname"
+// @Param order query string false "Sort order: asc or desc (default)"
+// @Param cursor query string false "nextCursor of the previous page"
+// @Param limit query int false "Maximum number of users (default 50, at most 200)"
+// @Success 200 {object} domain.UserPage
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /api/v1/admin/users [get]
+func (h *UserAdminHandler) ListUsers(c echo.Context) error {
+	req, fieldErrors := listUsersRequest(c)
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	page, fieldErrors, err := h.userAdminService.ListUsers(c.Request().Context(), req)
+	if err != nil {
+		return h.userAdminError(c, "Error listing users", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// GetUser handles showing a user of the user directory
+// @Summary Get user
+// @Description Get a user together with their directly granted and effective roles and their active sessions
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Success 200 {object} domain.UserDetails
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/users/{userId} [get]
+func (h *UserAdminHandler) GetUser(c echo.Context) error {
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор пользователя",
+		})
+	}
+
+	details, err := h.userAdminService.GetUserDetails(c.Request().Context(), userID)
+	if err != nil {
+		return h.userAdminError(c, "Error getting user", err)
+	}
+
+	return c.JSON(http.StatusOK, details)
+}
+
+// ListUsersUnderReview handles listing the users flagged for review
+// @Summary List users under review
+// @Description List the users whose registration email was flagged by the email policy
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} domain.User
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /api/v1/admin/users/review [get]
+func (h *UserAdminHandler) ListUsersUnderReview(c echo.Context) error {
+	users, err := h.userAdminService.ListUsersUnderReview(c.Request().Context())
+	if err != nil {
+		h.logger.Errorf("Error listing users under review: %v", err)
+		return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Сервер не отвечает",
+		})
+	}
+
+	return c.JSON(http.StatusOK, users)
+}
+
+// ApproveUser handles clearing the review flag of a user
+// @Summary Approve user
+// @Description Mark a user flagged for review as reviewed
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Success 204
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/users/{userId}/approve [post]
+func (h *UserAdminHandler) ApproveUser(c echo.Context) error {
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор пользователя",
+		})
+	}
+
+	if err := h.userAdminService.ApproveUser(c.Request().Context(), userID); err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return c.JSON(http.StatusNotFound, domain.ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+		h.logger.Errorf("Error approving user: %v", err)
+		return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Сервер не отвечает",
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// SuspendUser handles suspending a user
+// @Summary Suspend user
+// @Description Block a user until the given time. All of the user's sessions are revoked, logins and token refreshes are
+// @Description refused until then and the user is notified by email.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Param request body domain.SuspendUserRequest true "Suspend user request"
+// @Success 200 {object} domain.User
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/users/{userId}/suspend [post]
+func (h *UserAdminHandler) SuspendUser(c echo.Context) error {
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор пользователя",
+		})
+	}
+
+	var req domain.SuspendUserRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	user, fieldErrors, err := h.userAdminService.SuspendUser(c.Request().Context(), actorID(c), userID, req)
+	if err != nil {
+		return h.userAdminError(c, "Error suspending user", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusOK, user)
+}
+
+// BanUser handles banning a user
+// @Summary Ban user
+// @Description Block a user for good. All of the user's sessions are revoked and the user is notified by email.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Param request body domain.BanUserRequest true "Ban user request"
+// @Success 200 {object} domain.User
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/users/{userId}/ban [post]
+func (h *UserAdminHandler) BanUser(c echo.Context) error {
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор пользователя",
+		})
+	}
+
+	var req domain.BanUserRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	user, fieldErrors, err := h.userAdminService.BanUser(c.Request().Context(), actorID(c), userID, req)
+	if err != nil {
+		return h.userAdminError(c, "Error banning user", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusOK, user)
+}
+
+// ReinstateUser handles lifting the suspension or ban of a user
+// @Summary Reinstate user
+// @Description Lift the suspension or ban of a user
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Success 200 {object} domain.User
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/users/{userId}/reinstate [post]
+func (h *UserAdminHandler) ReinstateUser(c echo.Context) error {
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор пользователя",
+		})
+	}
+
+	user, err := h.userAdminService.ReinstateUser(c.Request().Context(), actorID(c), userID)
+	if err != nil {
+		return h.userAdminError(c, "Error reinstating user", err)
+	}
+
+	return c.JSON(http.StatusOK, user)
+}
+
+// ChangeNickname handles changing the nickname of a user
+// @Summary Change user nickname
+// @Description Change the nickname of a user. The change is recorded in the nickname history and the previous nickname
+// @Description stays reserved; the change-frequency limit of self-service changes does not apply.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Param request body domain.ChangeNicknameRequest true "Change nickname request"
+// @Success 200 {object} domain.User
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/users/{userId}/nickname [put]
+func (h *UserAdminHandler) ChangeNickname(c echo.Context) error {
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор пользователя",
+		})
+	}
+
+	var req domain.ChangeNicknameRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	user, fieldErrors, err := h.userAdminService.ChangeNickname(c.Request().Context(), actorID(c), userID, req)
+	if err != nil {
+		return h.userAdminError(c, "Error changing nickname", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusOK, user)
+}
+
+// ListNicknameHistory handles listing the nickname changes of a user
+// @Summary List nickname history
+// @Description List the nickname changes of a user, newest first
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Success 200 {array} domain.NicknameChange
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/users/{userId}/nickname/history [get]
+func (h *UserAdminHandler) ListNicknameHistory(c echo.Context) error {
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный идентификатор пользователя",
+		})
+	}
+
+	changes, err := h.userAdminService.ListNicknameHistory(c.Request().Context(), userID)
+	if err != nil {
+		return h.userAdminError(c, "Error listing nickname history", err)
+	}
+
+	return c.JSON(http.StatusOK, changes)
+}
+
+// ListBlockedNicknames handles listing the blocked nicknames
+// @Summary List blocked nicknames
+// @Description List the nicknames nobody may take at registration or when changing their nickname
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} domain.BlockedNickname
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /api/v1/admin/nicknames/blocked [get]
+func (h *UserAdminHandler) ListBlockedNicknames(c echo.Context) error {
+	nicknames, err := h.userAdminService.ListBlockedNicknames(c.Request().Context())
+	if err != nil {
+		return h.userAdminError(c, "Error listing blocked nicknames", err)
+	}
+
+	return c.JSON(http.StatusOK, nicknames)
+}
+
+// BlockNickname handles blocking a nickname
+// @Summary Block nickname
+// @Description Add a nickname to the blocked list; it is matched case-insensitively
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body domain.BlockNicknameRequest true "Block nickname request"
+// @Success 201 {object} domain.BlockedNickname
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 409 {object} domain.ErrorResponse
+// @Router /api/v1/admin/nicknames/blocked [post]
+func (h *UserAdminHandler) BlockNickname(c echo.Context) error {
+	var req domain.BlockNicknameRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Errorf("Error binding request: %v", err)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	blocked, fieldErrors, err := h.userAdminService.BlockNickname(c.Request().Context(), actorID(c), req)
+	if err != nil {
+		return h.userAdminError(c, "Error blocking nickname", err)
+	}
+
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return c.JSON(http.StatusCreated, blocked)
+}
+
+// UnblockNickname handles removing a nickname from the blocked list
+// @Summary Unblock nickname
+// @Description Remove a nickname from the blocked list
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param nickname path string true "Nickname"
+// @Success 204
+// @Failure 404 {object} domain.ErrorResponse
+// @Router /api/v1/admin/nicknames/blocked/{nickname} [delete]
+func (h *UserAdminHandler) UnblockNickname(c echo.Context) error {
+	if err := h.userAdminService.UnblockNickname(c.Request().Context(), c.Param("nickname")); err != nil {
+		return h.userAdminError(c, "Error unblocking nickname", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// listUsersRequest parses the user directory filters, sorting and page from the query parameters
+func listUsersRequest(c echo.Context) (domain.ListUsersRequest, []domain.FieldError) {
+	req := domain.ListUsersRequest{
+		Email:    c.QueryParam("email"),
+		Nickname: c.QueryParam("nickname"),
+		Role:     c.QueryParam("role"),
+		Status:   c.QueryParam("status"),
+		Sort:     c.QueryParam("sort"),
+		Order:    c.QueryParam("order"),
+		Cursor:   c.QueryParam("cursor"),
+	}
+	req.Limit, _ = strconv.Atoi(c.QueryParam("limit"))
+
+	var fieldErrors []domain.FieldError
+	if value := c.QueryParam("verified"); value != "" {
+		verified, err := strconv.ParseBool(value)
+		if err != nil {
+			fieldErrors = append(fieldErrors, domain.FieldError{
+				Field:   "verified",
+				Message: "Ожидается true или false",
+			})
+		}
+		req.Verified = &verified
+	}
+	if value := c.QueryParam("createdFrom"); value != "" {
+		createdFrom, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			fieldErrors = append(fieldErrors, domain.FieldError{
+				Field:   "createdFrom",
+				Message: "Ожидается дата в формате RFC 3339",
+			})
+		}
+		req.CreatedFrom = &createdFrom
+	}
+	if value := c.QueryParam("createdTo"); value != "" {
+		createdTo, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			fieldErrors = append(fieldErrors, domain.FieldError{
+				Field:   "createdTo",
+				Message: "Ожидается дата в формате RFC 3339",
+			})
+		}
+		req.CreatedTo = &createdTo
+	}
+
+	return req, fieldErrors
+}
+
+// userAdminError maps user administration errors to HTTP responses
+func (h *UserAdminHandler) userAdminError(c echo.Context, msg string, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound),
+		errors.Is(err, domain.ErrBlockedNicknameNotFound):
+		return c.JSON(http.StatusNotFound, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	case errors.Is(err, domain.ErrNicknameBlocked):
+		return c.JSON(http.StatusConflict, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	case errors.Is(err, domain.ErrSelfStatusChange):
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	h.logger.Errorf("%s: %v", msg, err)
+	return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+		Error: "Сервер не отвечает",
+	})
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type UserBulkService interface {
+	ImportUsers(ctx context.Context, actorID int64, r io.Reader, opts domain.UserImportOptions) (domain.UserImportReport, error)
+	ExportUsers(ctx context.Context, req domain.ListUsersRequest, w io.Writer) ([]domain.FieldError, error)
+}
+
+type UserBulkHandler struct {
+	userBulkService UserBulkService
+	logger          logger.Logger
+}
+
+func NewUserBulkHandler(userBulkService UserBulkService, logger logger.Logger) *UserBulkHandler {
+	return &UserBulkHandler{
+		userBulkService: userBulkService,
+		logger:          logger,
+	}
+}
+
+// ImportUsers handles creating users in bulk
+// @Summary Import users
+// @Description Create the users listed in the request body. A CSV file has a header naming the columns firstName,
+// @Description lastName, nickname, email and optionally roles (separated by semicolons); a JSON file is an array of
+// @Description users. Every user is validated like a self-service registration and users are only created when all
+// @Description of them are valid. The report lists the errors of each row.
+// @Tags admin
+// @Accept json
+// @Accept text/csv
+// @Produce json
+// @Security BearerAuth
+// @Param format query string false "csv or json; by default taken from the Content-Type"
+// @Param dryRun query bool false "Only validate the users"
+// @Param sendWelcomeEmail query bool false "Send a welcome email to the created users"
+// @Success 200 {object} domain.UserImportReport
+// @Failure 400 {object} domain.UserImportReport "Some users are invalid, none were created"
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /api/v1/admin/users/import [post]
+func (h *UserBulkHandler) ImportUsers(c echo.Context) error {
+	opts := domain.UserImportOptions{
+		Format: c.QueryParam("format"),
+	}
+	if opts.Format == "" {
+		opts.Format = domain.UserFileFormats.JSON
+		if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), "text/csv") {
+			opts.Format = domain.UserFileFormats.CSV
+		}
+	}
+	opts.DryRun, _ = strconv.ParseBool(c.QueryParam("dryRun"))
+	opts.SendWelcomeEmail, _ = strconv.ParseBool(c.QueryParam("sendWelcomeEmail"))
+
+	report, err := h.userBulkService.ImportUsers(c.Request().Context(), actorID(c), c.Request().Body, opts)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidUserFileFormat) ||
+			errors.Is(err, domain.ErrInvalidUserFile) ||
+			errors.Is(err, domain.ErrUserImportTooLarge) {
+			return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+		h.logger.Errorf("Error importing users: %v", err)
+		return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Сервер не отвечает",
+		})
+	}
+
+	if !report.DryRun && report.Failed > 0 {
+		return c.JSON(http.StatusBadRequest, report)
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// ExportUsers handles exporting users as CSV
+// @Summary Export users
+// @Description Download the users matching the filters of the user directory as CSV, which can be imported again
+// @Tags admin
+// @Produce text/csv
+// @Security BearerAuth
+// @Param email query string false "Email prefix"
+// @Param nickname query string false "Nickname prefix"
+// @Param role query string false "Name of a role granted directly"
+// @Param status query string false "Account status: active, suspended or banned"
+// @Param verified query bool false "Email verified"
+// @Param createdFrom query string false "Registered at or after (RFC 3339)"
+// @Param createdTo query string false "Registered before (RFC 3339)"
+// @Param sort query string false "Sort by createdAt (default), email or nickname"
+// @Param order query string false "Sort order: asc or desc (default)"
+// @Success 200 {file} file
+// @Failure 400 {object} domain.ErrorResponse
+// @Failure 500 {object} domain.ErrorResponse
+// @Router /api/v1/admin/users/export [get]
+func (h *UserBulkHandler) ExportUsers(c echo.Context) error {
+	req, fieldErrors := listUsersRequest(c)
+	if len(fieldErrors) > 0 {
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	// The headers are only sent with the first row, validation errors can still be answered with JSON
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="users.csv"`)
+
+	fieldErrors, err := h.userBulkService.ExportUsers(c.Request().Context(), req, c.Response())
+	if err != nil {
+		h.logger.Errorf("Error exporting users: %v", err)
+		if c.Response().Committed {
+			return nil
+		}
+		c.Response().Header().Del(echo.HeaderContentDisposition)
+		return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Сервер не отвечает",
+		})
+	}
+
+	if len(fieldErrors) > 0 {
+		c.Response().Header().Del(echo.HeaderContentDisposition)
+		return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:          "Ошибка валидации",
+			DetailedErrors: fieldErrors,
+		})
+	}
+
+	return nil
+}
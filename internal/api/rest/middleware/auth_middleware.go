@@ -2,8 +2,11 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
@@ -12,11 +15,14 @@ import (
 )
 
 type TokenService interface {
-	ValidateToken(token string) (*domain.TokenClaims, error)
+	ValidateToken(ctx context.Context, token string) (*domain.TokenClaims, error)
 }
 
 type AuthService interface {
 	HasRole(ctx context.Context, userID int64, roleName string) (bool, error)
+	HasPermission(ctx context.Context, userID int64, permissionName string) (bool, error)
+	HasOrgRole(ctx context.Context, userID, orgID int64, roleName string) (bool, error)
+	IsOrgMember(ctx context.Context, userID, orgID int64) (bool, error)
 }
 
 type AuthMiddleware struct {
@@ -57,9 +63,19 @@ func (m *AuthMiddleware) JWT() echo.MiddlewareFunc {
 			token := parts[1]
 
 			// Validate token
-			claims, err := m.tokenService.ValidateToken(token)
+			claims, err := m.tokenService.ValidateToken(c.Request().Context(), token)
 			if err != nil {
 				m.logger.Errorf("Error validating token: %v", err)
+				if errors.Is(err, domain.ErrTokenOutdated) {
+					return c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
+						Error: "Token is outdated, refresh it",
+					})
+				}
+				if errors.Is(err, domain.ErrAccountSuspended) || errors.Is(err, domain.ErrAccountBanned) {
+					return c.JSON(http.StatusForbidden, domain.ErrorResponse{
+						Error: err.Error(),
+					})
+				}
 				return c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
 					Error: "Invalid or expired token",
 				})
@@ -71,6 +87,8 @@ func (m *AuthMiddleware) JWT() echo.MiddlewareFunc {
 			c.Set("email", claims.Email)
 			c.Set("nickname", claims.Nickname)
 			c.Set("roles", claims.Roles)
+			c.Set("permissions", claims.Permissions)
+			c.Set("memberships", claims.Memberships)
 
 			return next(c)
 		}
@@ -115,3 +133,166 @@ func (m *AuthMiddleware) RoleRequired(role string) echo.MiddlewareFunc {
 		}
 	}
 }
+
+// OrgMemberRequired middleware to check if the user is a member of the organization
+// identified by the given path parameter
+func (m *AuthMiddleware) OrgMemberRequired(orgParam string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// Check if user is authenticated
+			claims, ok := c.Get("user").(*domain.TokenClaims)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
+					Error: "Unauthorized",
+				})
+			}
+
+			orgID, err := strconv.ParseInt(c.Param(orgParam), 10, 64)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+					Error: "Неверный идентификатор организации",
+				})
+			}
+
+			// Check if the token lists the membership
+			for _, membership := range claims.Memberships {
+				if membership.OrganizationID == orgID {
+					return next(c)
+				}
+			}
+
+			// If not in token, double-check with database (token might be outdated)
+			isMember, err := m.authService.IsOrgMember(c.Request().Context(), claims.UserID, orgID)
+			if err != nil {
+				m.logger.Errorf("Error checking membership: %v", err)
+				return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+					Error: "Internal server error",
+				})
+			}
+
+			if isMember {
+				return next(c)
+			}
+
+			return c.JSON(http.StatusForbidden, domain.ErrorResponse{
+				Error: "Insufficient permissions",
+			})
+		}
+	}
+}
+
+// OrgRoleRequired middleware to check if the user has the required role within the organization
+// identified by the given path parameter
+func (m *AuthMiddleware) OrgRoleRequired(role, orgParam string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// Check if user is authenticated
+			claims, ok := c.Get("user").(*domain.TokenClaims)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
+					Error: "Unauthorized",
+				})
+			}
+
+			orgID, err := strconv.ParseInt(c.Param(orgParam), 10, 64)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+					Error: "Неверный идентификатор организации",
+				})
+			}
+
+			// Check if the user has the required role in the organization
+			for _, membership := range claims.Memberships {
+				if membership.OrganizationID != orgID {
+					continue
+				}
+				for _, r := range membership.Roles {
+					if r == role {
+						return next(c)
+					}
+				}
+			}
+
+			// If not in token, double-check with database (token might be outdated)
+			hasRole, err := m.authService.HasOrgRole(c.Request().Context(), claims.UserID, orgID, role)
+			if err != nil {
+				m.logger.Errorf("Error checking organization role: %v", err)
+				return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+					Error: "Internal server error",
+				})
+			}
+
+			if hasRole {
+				return next(c)
+			}
+
+			return c.JSON(http.StatusForbidden, domain.ErrorResponse{
+				Error: "Insufficient permissions",
+			})
+		}
+	}
+}
+
+// PermissionRequired middleware to check if the user has the required permission
+func (m *AuthMiddleware) PermissionRequired(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// Check if user is authenticated
+			claims, ok := c.Get("user").(*domain.TokenClaims)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
+					Error: "Unauthorized",
+				})
+			}
+
+			// Check embedded permissions first, if the token carries them
+			for _, p := range claims.Permissions {
+				if p == permission {
+					return next(c)
+				}
+			}
+
+			// Fall back to the database (permissions might not be embedded or be outdated)
+			hasPermission, err := m.authService.HasPermission(c.Request().Context(), claims.UserID, permission)
+			if err != nil {
+				m.logger.Errorf("Error checking permission: %v", err)
+				return c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+					Error: "Internal server error",
+				})
+			}
+
+			if hasPermission {
+				return next(c)
+			}
+
+			return c.JSON(http.StatusForbidden, domain.ErrorResponse{
+				Error: "Insufficient permissions",
+			})
+		}
+	}
+}
+
+// StepUpRequired middleware to check that the user has recently re-authenticated with an elevated token
+func (m *AuthMiddleware) StepUpRequired(maxAge time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// Check if user is authenticated
+			claims, ok := c.Get("user").(*domain.TokenClaims)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
+					Error: "Unauthorized",
+				})
+			}
+
+			// Check that the token was issued after a recent strong authentication
+			authTime := time.Unix(claims.AuthTime, 0)
+			if claims.ACR != domain.AuthContextClasses.Elevated || time.Since(authTime) > maxAge {
+				return c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
+					Error: "Re-authentication required",
+				})
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type SCIMClientAuthenticator interface {
+	AuthenticateClient(ctx context.Context, token string) (domain.SCIMClient, error)
+}
+
+// SCIMMiddleware authenticates the provisioning clients calling the SCIM API
+type SCIMMiddleware struct {
+	authenticator SCIMClientAuthenticator
+	logger        logger.Logger
+}
+
+func NewSCIMMiddleware(authenticator SCIMClientAuthenticator, logger logger.Logger) *SCIMMiddleware {
+	return &SCIMMiddleware{
+		authenticator: authenticator,
+		logger:        logger,
+	}
+}
+
+// Bearer middleware to authenticate a provisioning client by its bearer token. The client is stored in the context
+// as "scimClient"; failures are answered with SCIM errors.
+func (m *SCIMMiddleware) Bearer() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			auth := c.Request().Header.Get("Authorization")
+			parts := strings.SplitN(auth, " ", 2)
+			if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || parts[1] == "" {
+				return scimUnauthorized(c)
+			}
+
+			client, err := m.authenticator.AuthenticateClient(c.Request().Context(), parts[1])
+			if err != nil {
+				if !errors.Is(err, domain.ErrSCIMClientNotFound) {
+					m.logger.Errorf("Error authenticating SCIM client: %v", err)
+					return scimErrorJSON(c, http.StatusInternalServerError, "internal error")
+				}
+				return scimUnauthorized(c)
+			}
+
+			c.Set("scimClient", client)
+
+			return next(c)
+		}
+	}
+}
+
+func scimUnauthorized(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+	return scimErrorJSON(c, http.StatusUnauthorized, "invalid or missing bearer token")
+}
+
+// scimErrorJSON answers with a SCIM error
+func scimErrorJSON(c echo.Context, status int, detail string) error {
+	c.Response().Header().Set(echo.HeaderContentType, "application/scim+json")
+	return c.JSON(status, domain.NewSCIMError(status, "", detail))
+}
@@ -13,6 +13,8 @@ package router
 
 import (
 	"context"
+	"time"
+
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	echoSwagger "github.com/swaggo/echo-swagger"
@@ -37,7 +39,7 @@ type EchoRouter struct {
 }
 
 // NewRouter creates a new instance of the Router
-func NewRouter(authService *service.AuthService, tokenService *service.TokenService, logger logger.Logger) *EchoRouter {
+func NewRouter(authService *service.AuthService, tokenService *service.TokenService, roleService *service.RoleService, orgService *service.OrganizationService, invitationService *service.InvitationService, institutionService *service.InstitutionService, userAdminService *service.UserAdminService, userBulkService *service.UserBulkService, profileService *service.ProfileService, accountDeletionService *service.AccountDeletionService, deletionReauthMaxAge time.Duration, dataExportService *service.DataExportService, consentService *service.ConsentService, policyService *service.PolicyService, scimService *service.SCIMService, logger logger.Logger) *EchoRouter {
 	e := echo.New()
 
 	// Add middleware
@@ -47,9 +49,22 @@ func NewRouter(authService *service.AuthService, tokenService *service.TokenServ
 
 	// Initialize handlers
 	authHandler := handler.NewAuthHandler(authService, logger)
+	adminHandler := handler.NewAdminHandler(roleService, logger)
+	orgHandler := handler.NewOrganizationHandler(orgService, logger)
+	invitationHandler := handler.NewInvitationHandler(invitationService, logger)
+	institutionHandler := handler.NewInstitutionHandler(institutionService, logger)
+	userAdminHandler := handler.NewUserAdminHandler(userAdminService, logger)
+	userBulkHandler := handler.NewUserBulkHandler(userBulkService, logger)
+	profileHandler := handler.NewProfileHandler(profileService, logger)
+	accountDeletionHandler := handler.NewAccountDeletionHandler(accountDeletionService, logger)
+	dataExportHandler := handler.NewDataExportHandler(dataExportService, logger)
+	consentHandler := handler.NewConsentHandler(consentService, logger)
+	policyHandler := handler.NewPolicyHandler(policyService, logger)
+	scimHandler := handler.NewSCIMHandler(scimService, logger)
 
 	// Initialize middleware
 	authMiddleware := custommiddleware.NewAuthMiddleware(tokenService, authService, logger)
+	scimMiddleware := custommiddleware.NewSCIMMiddleware(scimService, logger)
 
 	// Public routes (no auth required)
 	v1 := e.Group("/auth/v1")
@@ -79,18 +94,139 @@ func NewRouter(authService *service.AuthService, tokenService *service.TokenServ
 	login.POST("/sendCodeEmail", authHandler.SendLoginCode)
 	login.POST("/confirmEmail", authHandler.ConfirmLogin)
 
+	// Invitation acceptance
+	v1.POST("/invitations/accept", invitationHandler.AcceptInvitation)
+
+	// Cancelling an email change from the link sent to the current email
+	v1.POST("/emailChange/cancel", authHandler.CancelEmailChange)
+
+	// Downloading a personal data export from the emailed link
+	v1.GET("/dataExports/download", dataExportHandler.Download)
+
 	// Token refresh
 	v1.POST("/refreshToken", authHandler.RefreshToken)
 
+	// Privacy policy and terms, accepted to complete a login or refresh that returned consent_required
+	v1.GET("/legalDocuments", consentHandler.ListCurrentDocuments)
+	v1.POST("/consents/accept", authHandler.AcceptConsent)
+
 	// Protected routes (auth required)
 	// This would be where we add endpoints that require authentication
 	protected := e.Group("/api/v1")
 	protected.Use(authMiddleware.JWT())
 
+	// Profile of the authenticated user
+	protected.GET("/me", profileHandler.GetMe)
+	protected.PATCH("/me", profileHandler.UpdateProfile)
+	protected.POST("/me/email", authHandler.RequestEmailChange)
+	protected.POST("/me/email/confirm", authHandler.ConfirmEmailChange)
+	protected.DELETE("/me", accountDeletionHandler.RequestDeletion, authMiddleware.StepUpRequired(deletionReauthMaxAge))
+	protected.DELETE("/me/deletion", accountDeletionHandler.CancelDeletion)
+	protected.POST("/me/exports", dataExportHandler.RequestExport)
+	protected.GET("/me/exports/:exportId", dataExportHandler.GetExport)
+	protected.GET("/me/consents", consentHandler.ListMyConsents)
+
+	// Re-authentication (step-up) endpoints
+	reauth := protected.Group("/reauth")
+	reauth.POST("/sendCodeEmail", authHandler.SendReauthCode)
+	reauth.POST("/confirmEmail", authHandler.ConfirmReauth)
+
+	// Attribute-based authorization decisions for the authenticated user
+	protected.POST("/authorize", policyHandler.Authorize)
+
+	// Organizations of the authenticated user and organization-scoped management
+	protected.GET("/organizations", orgHandler.ListMyMemberships)
+	org := protected.Group("/organizations/:orgId")
+	org.GET("/members", orgHandler.ListMembers, authMiddleware.OrgMemberRequired("orgId"))
+	org.PUT("/members/:userId/roles/:roleId", orgHandler.GrantOrgRole, authMiddleware.OrgRoleRequired("admin", "orgId"))
+	org.DELETE("/members/:userId/roles/:roleId", orgHandler.RevokeOrgRole, authMiddleware.OrgRoleRequired("admin", "orgId"))
+
 	// Admin routes (admin role required)
 	admin := protected.Group("/admin")
 	admin.Use(authMiddleware.RoleRequired("admin"))
 
+	// Role management; changes to roles and grants also require the roles:manage permission
+	manageRoles := authMiddleware.PermissionRequired("roles:manage")
+	admin.GET("/roles", adminHandler.ListRoles)
+	admin.POST("/roles", adminHandler.CreateRole, manageRoles)
+	admin.GET("/roles/history", adminHandler.ListRoleChanges)
+	admin.PATCH("/roles/:roleId", adminHandler.RenameRole, manageRoles)
+	admin.DELETE("/roles/:roleId", adminHandler.DeleteRole, manageRoles)
+	admin.GET("/roles/:roleId/users", adminHandler.ListUsersByRole)
+	admin.GET("/roles/:roleId/children", adminHandler.ListRoleChildren)
+	admin.PUT("/roles/:roleId/children/:childId", adminHandler.AddRoleChild, manageRoles)
+	admin.DELETE("/roles/:roleId/children/:childId", adminHandler.RemoveRoleChild, manageRoles)
+	admin.GET("/users", userAdminHandler.ListUsers)
+	admin.POST("/users/import", userBulkHandler.ImportUsers)
+	admin.GET("/users/export", userBulkHandler.ExportUsers)
+	admin.GET("/users/review", userAdminHandler.ListUsersUnderReview)
+	admin.GET("/users/:userId", userAdminHandler.GetUser)
+	admin.POST("/users/:userId/approve", userAdminHandler.ApproveUser)
+	admin.POST("/users/:userId/suspend", userAdminHandler.SuspendUser)
+	admin.POST("/users/:userId/ban", userAdminHandler.BanUser)
+	admin.POST("/users/:userId/reinstate", userAdminHandler.ReinstateUser)
+	admin.PUT("/users/:userId/nickname", userAdminHandler.ChangeNickname)
+	admin.GET("/users/:userId/nickname/history", userAdminHandler.ListNicknameHistory)
+	admin.GET("/nicknames/blocked", userAdminHandler.ListBlockedNicknames)
+	admin.POST("/nicknames/blocked", userAdminHandler.BlockNickname)
+	admin.DELETE("/nicknames/blocked/:nickname", userAdminHandler.UnblockNickname)
+	admin.GET("/users/:userId/roles", adminHandler.GetUserRoles)
+	admin.PUT("/users/:userId/roles/:roleId", adminHandler.AssignRole, manageRoles)
+	admin.DELETE("/users/:userId/roles/:roleId", adminHandler.UnassignRole, manageRoles)
+
+	// Organization management
+	admin.GET("/organizations", orgHandler.ListOrganizations)
+	admin.POST("/organizations", orgHandler.CreateOrganization)
+	admin.DELETE("/organizations/:orgId", orgHandler.DeleteOrganization)
+	admin.PUT("/organizations/:orgId/members/:userId", orgHandler.AddMember)
+	admin.DELETE("/organizations/:orgId/members/:userId", orgHandler.RemoveMember)
+	admin.GET("/organizations/:orgId/invitations", invitationHandler.ListInvitations)
+	admin.POST("/organizations/:orgId/invitations", invitationHandler.CreateInvitation)
+	admin.POST("/organizations/:orgId/invitations/:invitationId/resend", invitationHandler.ResendInvitation)
+	admin.DELETE("/organizations/:orgId/invitations/:invitationId", invitationHandler.RevokeInvitation)
+
+	// Institution registry
+	admin.GET("/institutions", institutionHandler.ListInstitutions)
+	admin.POST("/institutions", institutionHandler.CreateInstitution)
+	admin.GET("/institutions/:institutionId", institutionHandler.GetInstitution)
+	admin.PUT("/institutions/:institutionId", institutionHandler.UpdateInstitution)
+	admin.DELETE("/institutions/:institutionId", institutionHandler.DeleteInstitution)
+
+	// Privacy policy and terms versions
+	admin.GET("/legalDocuments", consentHandler.ListDocuments)
+	admin.POST("/legalDocuments", consentHandler.PublishDocument)
+
+	// Policy management
+	admin.GET("/policies", policyHandler.ListPolicies)
+	admin.POST("/policies", policyHandler.CreatePolicy)
+	admin.POST("/policies/dry-run", policyHandler.DryRunPolicies)
+	admin.GET("/policies/:policyId", policyHandler.GetPolicy)
+	admin.PUT("/policies/:policyId", policyHandler.UpdatePolicy)
+	admin.DELETE("/policies/:policyId", policyHandler.DeletePolicy)
+
+	// SCIM provisioning clients
+	admin.GET("/scimClients", scimHandler.ListClients)
+	admin.POST("/scimClients", scimHandler.CreateClient)
+	admin.DELETE("/scimClients/:clientId", scimHandler.DeleteClient)
+
+	// SCIM 2.0 provisioning, authenticated with the bearer token of a provisioning client
+	scim := e.Group("/scim/v2")
+	scim.Use(scimMiddleware.Bearer())
+	scim.GET("/ServiceProviderConfig", scimHandler.ServiceProviderConfig)
+	scim.GET("/ResourceTypes", scimHandler.ResourceTypes)
+	scim.GET("/Users", scimHandler.ListUsers)
+	scim.POST("/Users", scimHandler.CreateUser)
+	scim.GET("/Users/:id", scimHandler.GetUser)
+	scim.PUT("/Users/:id", scimHandler.ReplaceUser)
+	scim.PATCH("/Users/:id", scimHandler.PatchUser)
+	scim.DELETE("/Users/:id", scimHandler.DeleteUser)
+	scim.GET("/Groups", scimHandler.ListGroups)
+	scim.POST("/Groups", scimHandler.CreateGroup)
+	scim.GET("/Groups/:id", scimHandler.GetGroup)
+	scim.PUT("/Groups/:id", scimHandler.ReplaceGroup)
+	scim.PATCH("/Groups/:id", scimHandler.PatchGroup)
+	scim.DELETE("/Groups/:id", scimHandler.DeleteGroup)
+
 	return &EchoRouter{
 		e:      e,
 		logger: logger,
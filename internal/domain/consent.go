@@ -0,0 +1,69 @@
+package domain
+
+import "time"
+
+// LegalDocumentKinds defines the kinds of legal documents users must accept
+var LegalDocumentKinds = struct {
+	PrivacyPolicy string
+	Terms         string
+}{
+	PrivacyPolicy: "privacy_policy",
+	Terms:         "terms",
+}
+
+// TokenResponseStatuses defines the states of a login or token refresh that did not issue tokens
+var TokenResponseStatuses = struct {
+	ConsentRequired string
+}{
+	ConsentRequired: "consent_required",
+}
+
+// LegalDocument represents a version of a legal document. The current version of a kind is the latest one published;
+// once it is published, users must accept it before they are issued tokens again.
+type LegalDocument struct {
+	ID          int64     `json:"id" db:"id"`
+	Kind        string    `json:"kind" db:"kind"`
+	Version     string    `json:"version" db:"version"`
+	URL         string    `json:"url" db:"url"`
+	PublishedAt time.Time `json:"publishedAt" db:"published_at"`
+	CreatedBy   *int64    `json:"createdBy,omitempty" db:"created_by"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+}
+
+// Consent represents the acceptance of a legal document version by a user
+type Consent struct {
+	ID         int64     `json:"id" db:"id"`
+	UserID     int64     `json:"userId" db:"user_id"`
+	DocumentID int64     `json:"documentId" db:"document_id"`
+	Kind       string    `json:"kind" db:"kind"`
+	Version    string    `json:"version" db:"version"`
+	IP         string    `json:"ip" db:"ip"`
+	UserAgent  string    `json:"userAgent" db:"user_agent"`
+	AcceptedAt time.Time `json:"acceptedAt" db:"accepted_at"`
+}
+
+// ConsentSession represents a login or token refresh held back until the user accepts the current legal documents
+type ConsentSession struct {
+	ID             string    `db:"id"`
+	UserID         int64     `db:"user_id"`
+	TokenSessionID *string   `db:"token_session_id"` // the refresh token session to replace, for a held back refresh
+	AMR            string    `db:"amr"`              // Space-separated authentication methods
+	AuthTime       time.Time `db:"auth_time"`
+	ExpiresAt      time.Time `db:"expires_at"`
+	CreatedAt      time.Time `db:"created_at"`
+}
+
+// PublishLegalDocumentRequest represents the data needed to publish a new version of a legal document
+type PublishLegalDocumentRequest struct {
+	Kind        string     `json:"kind"`
+	Version     string     `json:"version"`
+	URL         string     `json:"url"`
+	PublishedAt *time.Time `json:"publishedAt,omitempty"` // defaults to now; a future time schedules the version
+}
+
+// AcceptConsentRequest represents the data needed to accept the current legal documents and complete a login or
+// token refresh that returned the consent_required status
+type AcceptConsentRequest struct {
+	ConsentToken string  `json:"consentToken"`
+	DocumentIDs  []int64 `json:"documentIds"`
+}
@@ -0,0 +1,98 @@
+package domain
+
+import "time"
+
+// DataExportFormats defines the formats a personal data export can be delivered in
+var DataExportFormats = struct {
+	JSON string
+	ZIP  string
+}{
+	JSON: "json",
+	ZIP:  "zip",
+}
+
+// DataExportStatuses defines the states of a personal data export
+var DataExportStatuses = struct {
+	Pending    string
+	Processing string
+	Ready      string
+	Failed     string
+}{
+	Pending:    "pending",
+	Processing: "processing",
+	Ready:      "ready",
+	Failed:     "failed",
+}
+
+// DataExport represents a request of a user for an export of their personal data.
+// The export is built in the background and downloaded with the token emailed to the user until it expires.
+type DataExport struct {
+	ID            string     `json:"id" db:"id"`
+	UserID        int64      `json:"userId" db:"user_id"`
+	Format        string     `json:"format" db:"format"`
+	Status        string     `json:"status" db:"status"`
+	Data          []byte     `json:"-" db:"data"`
+	DownloadToken string     `json:"-" db:"download_token"`
+	CreatedAt     time.Time  `json:"createdAt" db:"created_at"`
+	CompletedAt   *time.Time `json:"completedAt,omitempty" db:"completed_at"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty" db:"expires_at"`
+	Attempts      int        `json:"-" db:"attempts"` // how many times a worker claimed the export
+}
+
+// DataExportRequest represents the data needed to request an export of the user's personal data
+type DataExportRequest struct {
+	Format string `json:"format"` // "json" (default) or "zip"
+}
+
+// PersonalData is the document of a personal data export: everything the service holds on a user.
+// Secrets such as refresh tokens and one-time codes are left out.
+type PersonalData struct {
+	ExportedAt           time.Time                 `json:"exportedAt"`
+	Profile              User                      `json:"profile"`
+	Roles                []RoleGrant               `json:"roles"`
+	Memberships          []Membership              `json:"memberships"`
+	NicknameHistory      []NicknameChange          `json:"nicknameHistory"`
+	Consents             []Consent                 `json:"consents"`
+	TokenSessions        []TokenSessionData        `json:"tokenSessions"`
+	RegistrationSessions []RegistrationSessionData `json:"registrationSessions"`
+	LoginSessions        []LoginSessionData        `json:"loginSessions"`
+	EmailChange          *EmailChangeSessionData   `json:"emailChange,omitempty"`
+}
+
+// TokenSessionData is the exported form of a refresh token session
+type TokenSessionData struct {
+	ID        string    `json:"id"`
+	UserAgent string    `json:"userAgent"`
+	IP        string    `json:"ip"`
+	AMR       string    `json:"amr"`
+	AuthTime  time.Time `json:"authTime"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// RegistrationSessionData is the exported form of a pending registration session
+type RegistrationSessionData struct {
+	ID                    string    `json:"id"`
+	FirstName             string    `json:"firstName"`
+	LastName              string    `json:"lastName"`
+	Nickname              string    `json:"nickname"`
+	Email                 string    `json:"email"`
+	AcceptedPrivacyPolicy bool      `json:"acceptedPrivacyPolicy"`
+	CodeExpires           time.Time `json:"codeExpires"`
+	CreatedAt             time.Time `json:"createdAt"`
+}
+
+// LoginSessionData is the exported form of a pending login session
+type LoginSessionData struct {
+	ID          string    `json:"id"`
+	Email       string    `json:"email"`
+	CodeExpires time.Time `json:"codeExpires"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// EmailChangeSessionData is the exported form of a pending email change
+type EmailChangeSessionData struct {
+	NewEmail    string    `json:"newEmail"`
+	CodeExpires time.Time `json:"codeExpires"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
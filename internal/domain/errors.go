@@ -0,0 +1,51 @@
+package domain
+
+import "errors"
+
+// Common errors returned by repositories and services
+var (
+	ErrUserNotFound            = errors.New("user not found")
+	ErrEmailExists             = errors.New("email is already registered")
+	ErrNicknameExists          = errors.New("nickname is already taken")
+	ErrEmailChangeNotFound     = errors.New("email change not found")
+	ErrReauthSessionNotFound   = errors.New("re-authentication session not found")
+	ErrNicknameBlocked         = errors.New("nickname is already blocked")
+	ErrBlockedNicknameNotFound = errors.New("blocked nickname not found")
+	ErrDeletionNotScheduled    = errors.New("account deletion is not scheduled")
+	ErrDataExportNotFound      = errors.New("data export not found")
+	ErrInvalidExportFormat     = errors.New("unsupported data export format")
+	ErrLegalDocumentExists     = errors.New("legal document version already exists")
+	ErrConsentSessionNotFound  = errors.New("consent session not found or expired")
+	ErrConsentIncomplete       = errors.New("all required documents must be accepted")
+	ErrAccountSuspended        = errors.New("account is suspended")
+	ErrAccountBanned           = errors.New("account is banned")
+	ErrSelfStatusChange        = errors.New("cannot suspend or ban your own account")
+	ErrInvalidUserFileFormat   = errors.New("unsupported user file format")
+	ErrInvalidUserFile         = errors.New("malformed user file")
+	ErrUserImportTooLarge      = errors.New("too many users in the import")
+	ErrRoleNotFound            = errors.New("role not found")
+	ErrRoleExists              = errors.New("role already exists")
+	ErrRoleAlreadyGranted      = errors.New("role already assigned to user")
+	ErrRoleNotGranted          = errors.New("role not assigned to user")
+	ErrProtectedRole           = errors.New("default roles cannot be renamed or deleted")
+	ErrRoleCycle               = errors.New("role hierarchy cycle detected")
+	ErrRoleChildNotFound       = errors.New("role is not a child of the parent role")
+	ErrGrantExpiryInPast       = errors.New("role grant expiry must be in the future")
+	ErrTokenOutdated           = errors.New("token was issued before the user's roles changed")
+	ErrPolicyNotFound          = errors.New("policy not found")
+	ErrPolicyExists            = errors.New("policy already exists")
+	ErrBatchTooLarge           = errors.New("too many checks in a batch")
+	ErrOrgNotFound             = errors.New("organization not found")
+	ErrOrgExists               = errors.New("organization already exists")
+	ErrNotMember               = errors.New("user is not a member of the organization")
+	ErrAlreadyMember           = errors.New("user is already a member of the organization")
+	ErrInvitationNotFound      = errors.New("invitation not found")
+	ErrInvitationExists        = errors.New("a pending invitation for this email already exists")
+	ErrInvitationInvalid       = errors.New("invitation is invalid or expired")
+	ErrInvitationClosed        = errors.New("invitation was already accepted or revoked")
+	ErrInstitutionNotFound     = errors.New("institution not found")
+	ErrInstitutionExists       = errors.New("institution already exists")
+	ErrSCIMClientNotFound      = errors.New("SCIM client not found")
+	ErrInvalidSCIMFilter       = errors.New("invalid SCIM filter")
+	ErrDomainTaken             = errors.New("email domain is already assigned to an institution")
+)
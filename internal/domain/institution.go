@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// Institution represents an educational institution whose members may register.
+// A domain either matches an email domain exactly (msu.ru) or, prefixed with "*.", any of its subdomains (*.msu.ru).
+type Institution struct {
+	ID        int64     `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Domains   []string  `json:"domains" db:"-"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// InstitutionRequest represents the data needed to create or replace an institution
+type InstitutionRequest struct {
+	Name    string   `json:"name"`
+	Domains []string `json:"domains"`
+}
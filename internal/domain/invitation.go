@@ -0,0 +1,76 @@
+package domain
+
+import "time"
+
+// InvitationStatuses defines the states of an invitation
+var InvitationStatuses = struct {
+	Pending  string
+	Accepted string
+	Revoked  string
+	Expired  string
+}{
+	Pending:  "pending",
+	Accepted: "accepted",
+	Revoked:  "revoked",
+	Expired:  "expired",
+}
+
+// Invitation represents an invitation of a person, identified by email, into an organization.
+// The roles are granted within the organization once the invitation is accepted.
+type Invitation struct {
+	ID             int64      `json:"id" db:"id"`
+	OrganizationID int64      `json:"orgId" db:"organization_id"`
+	Email          string     `json:"email" db:"email"`
+	Roles          []Role     `json:"roles" db:"-"`
+	Nonce          string     `json:"-" db:"nonce"`
+	InvitedBy      *int64     `json:"invitedBy,omitempty" db:"invited_by"`
+	ExpiresAt      time.Time  `json:"expiresAt" db:"expires_at"`
+	AcceptedAt     *time.Time `json:"acceptedAt,omitempty" db:"accepted_at"`
+	AcceptedBy     *int64     `json:"acceptedBy,omitempty" db:"accepted_by"`
+	RevokedAt      *time.Time `json:"revokedAt,omitempty" db:"revoked_at"`
+	CreatedAt      time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+// Status reports the state of the invitation at the given time
+func (i Invitation) Status(now time.Time) string {
+	switch {
+	case i.AcceptedAt != nil:
+		return InvitationStatuses.Accepted
+	case i.RevokedAt != nil:
+		return InvitationStatuses.Revoked
+	case !now.Before(i.ExpiresAt):
+		return InvitationStatuses.Expired
+	}
+	return InvitationStatuses.Pending
+}
+
+// InvitationView represents an invitation together with its current status
+type InvitationView struct {
+	Invitation
+	Status string `json:"status"`
+}
+
+// CreateInvitationRequest represents the data needed to invite a person into an organization
+type CreateInvitationRequest struct {
+	Email   string  `json:"email"`
+	RoleIDs []int64 `json:"roleIds,omitempty"`
+}
+
+// AcceptInvitationRequest represents the data needed to accept an invitation.
+// The registration fields are only required when no account exists for the invited email.
+type AcceptInvitationRequest struct {
+	Token                 string `json:"token"`
+	FirstName             string `json:"firstName,omitempty"`
+	LastName              string `json:"lastName,omitempty"`
+	Nickname              string `json:"nickname,omitempty"`
+	AcceptedPrivacyPolicy bool   `json:"acceptedPrivacyPolicy,omitempty"`
+}
+
+// AcceptInvitationResponse represents the result of accepting an invitation
+type AcceptInvitationResponse struct {
+	OrganizationID int64 `json:"orgId"`
+	UserID         int64 `json:"userId"`
+	// Registered is set when a new account was created for the invited email
+	Registered bool `json:"registered"`
+}
@@ -0,0 +1,33 @@
+package domain
+
+import "time"
+
+// NicknameChange represents an entry of a user's nickname history
+type NicknameChange struct {
+	ID            int64     `json:"id" db:"id"`
+	UserID        int64     `json:"userId" db:"user_id"`
+	OldNickname   string    `json:"oldNickname" db:"old_nickname"`
+	NewNickname   string    `json:"newNickname" db:"new_nickname"`
+	ChangedBy     *int64    `json:"changedBy,omitempty" db:"changed_by"` // nil when the user changed it themselves
+	ReservedUntil time.Time `json:"reservedUntil" db:"reserved_until"`
+	CreatedAt     time.Time `json:"createdAt" db:"created_at"`
+}
+
+// BlockedNickname represents a nickname nobody may take
+type BlockedNickname struct {
+	Nickname  string    `json:"nickname" db:"nickname"`
+	Reason    string    `json:"reason" db:"reason"`
+	CreatedBy *int64    `json:"createdBy,omitempty" db:"created_by"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// ChangeNicknameRequest represents the data needed to change the nickname of a user
+type ChangeNicknameRequest struct {
+	Nickname string `json:"nickname"`
+}
+
+// BlockNicknameRequest represents the data needed to block a nickname
+type BlockNicknameRequest struct {
+	Nickname string `json:"nickname"`
+	Reason   string `json:"reason"`
+}
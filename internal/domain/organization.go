@@ -0,0 +1,34 @@
+package domain
+
+import "time"
+
+// Organization represents a tenant, e.g. a customer institution served by the deployment
+type Organization struct {
+	ID        int64     `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Slug      string    `json:"slug" db:"slug"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// Membership represents the membership of a user in an organization.
+// Roles granted within an organization apply to that organization only.
+type Membership struct {
+	OrganizationID int64    `json:"orgId"`
+	Roles          []string `json:"roles"`
+}
+
+// OrganizationMember represents a member of an organization together with their direct roles in it
+type OrganizationMember struct {
+	UserID   int64     `json:"userId"`
+	Email    string    `json:"email"`
+	Nickname string    `json:"nickname"`
+	Roles    []string  `json:"roles"`
+	JoinedAt time.Time `json:"joinedAt"`
+}
+
+// CreateOrganizationRequest represents the data needed to create an organization
+type CreateOrganizationRequest struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
@@ -0,0 +1,49 @@
+package domain
+
+import "time"
+
+// Permission represents a fine-grained permission that can be granted to roles
+type Permission struct {
+	ID          int64     `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// DefaultPermissions defines the permissions available in the system
+var DefaultPermissions = struct {
+	UsersRead      string
+	UsersWrite     string
+	SessionsRevoke string
+	RolesManage    string
+}{
+	UsersRead:      "users:read",
+	UsersWrite:     "users:write",
+	SessionsRevoke: "sessions:revoke",
+	RolesManage:    "roles:manage",
+}
+
+// AuthorizationCheck represents a single check of a batch: whether a user holds a role or a permission.
+// Exactly one of Role and Permission must be set.
+type AuthorizationCheck struct {
+	UserID     int64  `json:"userId"`
+	Role       string `json:"role,omitempty"`
+	Permission string `json:"permission,omitempty"`
+}
+
+// AuthorizationCheckResult represents the result of a single check of a batch
+type AuthorizationCheckResult struct {
+	Allowed bool   `json:"allowed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// MaxAuthorizationChecks is the maximum number of checks in a single batch
+const MaxAuthorizationChecks = 1000
+
+// RolePermission represents the association between a role and a permission
+type RolePermission struct {
+	RoleID       int64     `json:"roleId" db:"role_id"`
+	PermissionID int64     `json:"permissionId" db:"permission_id"`
+	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
+}
@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"time"
+)
+
+// Policy represents an attribute-based authorization rule.
+// A policy applies to requests whose action and resource type match its patterns ("*" matches anything,
+// "documents:*" matches any action starting with "documents:") and whose condition expression holds.
+type Policy struct {
+	ID           int64     `json:"id" db:"id"`
+	Name         string    `json:"name" db:"name"`
+	Description  string    `json:"description" db:"description"`
+	Effect       string    `json:"effect" db:"effect"`
+	Action       string    `json:"action" db:"action"`
+	ResourceType string    `json:"resourceType" db:"resource_type"`
+	Condition    string    `json:"condition" db:"condition"`
+	Enabled      bool      `json:"enabled" db:"enabled"`
+	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// PolicyEffects defines the effects of a policy; deny policies override allow policies
+var PolicyEffects = struct {
+	Allow string
+	Deny  string
+}{
+	Allow: "allow",
+	Deny:  "deny",
+}
+
+// PolicyRequest represents the data needed to create or replace a policy
+type PolicyRequest struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Effect       string `json:"effect"`
+	Action       string `json:"action"`
+	ResourceType string `json:"resourceType"`
+	Condition    string `json:"condition"`
+	Enabled      *bool  `json:"enabled"`
+}
+
+// AuthorizationResource describes the resource an action is performed on
+type AuthorizationResource struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// AuthorizeRequest represents an authorization question: may the subject perform the action on the resource
+type AuthorizeRequest struct {
+	SubjectID int64                  `json:"subjectId"`
+	Action    string                 `json:"action"`
+	Resource  AuthorizationResource  `json:"resource"`
+	Context   map[string]interface{} `json:"context"`
+	Explain   bool                   `json:"explain"`
+}
+
+// PolicyEvaluation explains how a single policy was evaluated for a request
+type PolicyEvaluation struct {
+	PolicyID     int64  `json:"policyId,omitempty"`
+	Policy       string `json:"policy"`
+	Effect       string `json:"effect"`
+	Applicable   bool   `json:"applicable"`
+	ConditionMet bool   `json:"conditionMet"`
+	Error        string `json:"error,omitempty"`
+}
+
+// AuthorizationDecision represents the result of an authorization request
+type AuthorizationDecision struct {
+	Allowed     bool               `json:"allowed"`
+	Reason      string             `json:"reason"`
+	Policy      string             `json:"policy,omitempty"`
+	Evaluations []PolicyEvaluation `json:"evaluations,omitempty"`
+}
+
+// PolicyDryRunRequest represents a request to evaluate candidate policies without storing them
+type PolicyDryRunRequest struct {
+	Policies      []PolicyRequest  `json:"policies"`
+	IncludeStored bool             `json:"includeStored"`
+	Request       AuthorizeRequest `json:"request"`
+}
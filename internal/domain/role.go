@@ -19,10 +19,89 @@ var DefaultRoles = struct {
 	User:  "user",
 }
 
+// RoleHierarchy represents a parent-child relationship between roles.
+// A user holding the parent role effectively holds the child role as well.
+type RoleHierarchy struct {
+	ParentRoleID int64     `json:"parentRoleId" db:"parent_role_id"`
+	ChildRoleID  int64     `json:"childRoleId" db:"child_role_id"`
+	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
+}
+
 // UserRole represents the association between a user and a role
 type UserRole struct {
+	ID        int64      `json:"id" db:"id"`
+	UserID    int64      `json:"userId" db:"user_id"`
+	RoleID    int64      `json:"roleId" db:"role_id"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" db:"expires_at"` // nil for permanent grants
+	GrantedBy *int64     `json:"grantedBy,omitempty" db:"granted_by"`
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+}
+
+// RoleGrant represents a role assigned directly to a user together with the grant details
+type RoleGrant struct {
+	Role
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" db:"expires_at"`
+	GrantedBy *int64     `json:"grantedBy,omitempty" db:"granted_by"`
+	GrantedAt time.Time  `json:"grantedAt" db:"granted_at"`
+}
+
+// RoleChangeActions defines the kinds of changes recorded in the role change history
+var RoleChangeActions = struct {
+	Created      string
+	Renamed      string
+	Deleted      string
+	Assigned     string
+	Unassigned   string
+	ChildAdded   string
+	ChildRemoved string
+	Expired      string
+}{
+	Created:      "created",
+	Renamed:      "renamed",
+	Deleted:      "deleted",
+	Assigned:     "assigned",
+	Unassigned:   "unassigned",
+	ChildAdded:   "child_added",
+	ChildRemoved: "child_removed",
+	Expired:      "expired",
+}
+
+// RoleChange represents an entry in the role change history
+type RoleChange struct {
 	ID        int64     `json:"id" db:"id"`
-	UserID    int64     `json:"userId" db:"user_id"`
+	Action    string    `json:"action" db:"action"`
 	RoleID    int64     `json:"roleId" db:"role_id"`
+	RoleName  string    `json:"roleName" db:"role_name"`
+	UserID    *int64    `json:"userId,omitempty" db:"user_id"`
+	ActorID   int64     `json:"actorId" db:"actor_id"` // zero for changes made by the system
+	Details   string    `json:"details,omitempty" db:"details"`
 	CreatedAt time.Time `json:"createdAt" db:"created_at"`
 }
+
+// UserRolesView represents the roles assigned to a user directly and the roles they hold
+// effectively through the role hierarchy
+type UserRolesView struct {
+	UserID    int64       `json:"userId"`
+	Direct    []RoleGrant `json:"direct"`
+	Effective []string    `json:"effective"`
+}
+
+// CreateRoleRequest represents the data needed to create a role
+type CreateRoleRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+// AssignRoleRequest represents the optional data for assigning a role to a user
+type AssignRoleRequest struct {
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"` // omit for a permanent grant
+}
+
+// RenameRoleRequest represents the data needed to rename a role
+type RenameRoleRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+// IsDefaultRole reports whether the role name is one of the built-in roles
+func IsDefaultRole(name string) bool {
+	return name == DefaultRoles.Admin || name == DefaultRoles.User
+}
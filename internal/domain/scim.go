@@ -0,0 +1,149 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SCIMSchemas defines the SCIM 2.0 schema URNs
+var SCIMSchemas = struct {
+	User                  string
+	Group                 string
+	ListResponse          string
+	PatchOp               string
+	Error                 string
+	ServiceProviderConfig string
+	ResourceType          string
+}{
+	User:                  "urn:ietf:params:scim:schemas:core:2.0:User",
+	Group:                 "urn:ietf:params:scim:schemas:core:2.0:Group",
+	ListResponse:          "urn:ietf:params:scim:api:messages:2.0:ListResponse",
+	PatchOp:               "urn:ietf:params:scim:api:messages:2.0:PatchOp",
+	Error:                 "urn:ietf:params:scim:api:messages:2.0:Error",
+	ServiceProviderConfig: "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig",
+	ResourceType:          "urn:ietf:params:scim:schemas:core:2.0:ResourceType",
+}
+
+// SCIMClient represents a provisioning client allowed to call the SCIM API
+type SCIMClient struct {
+	ID         int64      `json:"id" db:"id"`
+	Name       string     `json:"name" db:"name"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	CreatedBy  *int64     `json:"createdBy,omitempty" db:"created_by"`
+	CreatedAt  time.Time  `json:"createdAt" db:"created_at"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty" db:"last_used_at"`
+}
+
+// CreateSCIMClientRequest represents the data needed to register a provisioning client
+type CreateSCIMClientRequest struct {
+	Name string `json:"name"`
+}
+
+// CreateSCIMClientResponse represents a registered provisioning client with its bearer token, which is only shown once
+type CreateSCIMClientResponse struct {
+	SCIMClient
+	Token string `json:"token"`
+}
+
+// SCIMUser represents a user as a SCIM User resource. userName and the primary email are both the email of the user;
+// inactive users are banned.
+type SCIMUser struct {
+	Schemas    []string     `json:"schemas"`
+	ID         string       `json:"id,omitempty"`
+	ExternalID string       `json:"externalId,omitempty"`
+	UserName   string       `json:"userName"`
+	Name       *SCIMName    `json:"name,omitempty"`
+	NickName   string       `json:"nickName,omitempty"`
+	Emails     []SCIMEmail  `json:"emails,omitempty"`
+	Active     *bool        `json:"active,omitempty"`
+	Groups     []SCIMMember `json:"groups,omitempty"` // read-only, the roles granted directly
+	Meta       *SCIMMeta    `json:"meta,omitempty"`
+}
+
+// SCIMName represents the name of a SCIM user
+type SCIMName struct {
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// SCIMEmail represents an email of a SCIM user
+type SCIMEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// SCIMGroup represents a role as a SCIM Group resource; its members are the users the role is granted to directly
+type SCIMGroup struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []SCIMMember `json:"members,omitempty"`
+	Meta        *SCIMMeta    `json:"meta,omitempty"`
+}
+
+// SCIMMember represents a member of a SCIM group or a group of a SCIM user
+type SCIMMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+// SCIMMeta represents the metadata of a SCIM resource
+type SCIMMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
+}
+
+// SCIMListRequest represents the query of a SCIM resource listing
+type SCIMListRequest struct {
+	Filter         string
+	StartIndex     int  // 1-based
+	Count          *int // nil for the default page size
+	ExcludeMembers bool // leave out the members of groups
+}
+
+// SCIMListResponse represents a page of SCIM resources
+type SCIMListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// SCIMPatchRequest represents a SCIM PATCH request
+type SCIMPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations"`
+}
+
+// SCIMPatchOperation represents an operation of a SCIM PATCH request
+type SCIMPatchOperation struct {
+	Op    string          `json:"op"` // add, replace or remove, case-insensitive
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// SCIMError represents a SCIM error response. Services return it for errors to be reported to SCIM clients as is.
+type SCIMError struct {
+	Schemas  []string `json:"schemas"`
+	Status   int      `json:"status,string"`
+	SCIMType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+func (e *SCIMError) Error() string {
+	return e.Detail
+}
+
+// NewSCIMError creates a SCIM error with the HTTP status and the scimType, e.g. invalidValue or uniqueness
+func NewSCIMError(status int, scimType, detail string) *SCIMError {
+	return &SCIMError{
+		Schemas:  []string{SCIMSchemas.Error},
+		Status:   status,
+		SCIMType: scimType,
+		Detail:   detail,
+	}
+}
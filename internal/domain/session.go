@@ -6,24 +6,47 @@ import (
 
 // RegistrationSession represents a session for user registration process
 type RegistrationSession struct {
-	ID        string    `db:"id"`
-	FirstName string    `db:"first_name"`
-	LastName  string    `db:"last_name"`
-	Nickname  string    `db:"nickname"`
-	Email     string    `db:"email"`
-	AcceptedPrivacyPolicy bool `db:"accepted_privacy_policy"`
-	Code      string    `db:"code"`
-	CodeExpires time.Time `db:"code_expires"`
-	CreatedAt time.Time `db:"created_at"`
+	ID                    string    `db:"id"`
+	FirstName             string    `db:"first_name"`
+	LastName              string    `db:"last_name"`
+	Nickname              string    `db:"nickname"`
+	Email                 string    `db:"email"`
+	AcceptedPrivacyPolicy bool      `db:"accepted_privacy_policy"`
+	Code                  string    `db:"code"`
+	CodeExpires           time.Time `db:"code_expires"`
+	ReviewReason          *string   `db:"review_reason"`
+	CreatedAt             time.Time `db:"created_at"`
 }
 
 // LoginSession represents a session for user login process
 type LoginSession struct {
-	ID         string    `db:"id"`
-	Email      string    `db:"email"`
-	Code       string    `db:"code"`
+	ID          string    `db:"id"`
+	Email       string    `db:"email"`
+	Code        string    `db:"code"`
+	CodeExpires time.Time `db:"code_expires"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// EmailChangeSession represents a pending change of a user's email
+type EmailChangeSession struct {
+	ID           string    `db:"id"`
+	UserID       int64     `db:"user_id"`
+	NewEmail     string    `db:"new_email"`
+	Code         string    `db:"code"`
+	CodeExpires  time.Time `db:"code_expires"`
+	CancelToken  string    `db:"cancel_token"`
+	ReviewReason *string   `db:"review_reason"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// ReauthSession represents a pending re-authentication of an already authenticated user
+type ReauthSession struct {
+	ID          string    `db:"id"`
+	UserID      int64     `db:"user_id"`
+	Code        string    `db:"code"`
 	CodeExpires time.Time `db:"code_expires"`
-	CreatedAt  time.Time `db:"created_at"`
+	Attempts    int       `db:"attempts"`
+	CreatedAt   time.Time `db:"created_at"`
 }
 
 // TokenSession represents an active refresh token session
@@ -33,6 +56,8 @@ type TokenSession struct {
 	RefreshToken string    `db:"refresh_token"`
 	UserAgent    string    `db:"user_agent"`
 	IP           string    `db:"ip"`
+	AMR          string    `db:"amr"` // Space-separated authentication methods
+	AuthTime     time.Time `db:"auth_time"`
 	ExpiresAt    time.Time `db:"expires_at"`
 	CreatedAt    time.Time `db:"created_at"`
 }
@@ -4,14 +4,55 @@ import (
 	"time"
 )
 
+// AuthMethods defines the authentication methods recorded in the amr claim
+var AuthMethods = struct {
+	EmailCode string
+	TOTP      string
+	Passkey   string
+}{
+	EmailCode: "email",
+	TOTP:      "otp",
+	Passkey:   "hwk",
+}
+
+// AuthContextClasses defines the authentication context classes recorded in the acr claim
+var AuthContextClasses = struct {
+	Basic    string
+	Elevated string
+}{
+	Basic:    "basic",
+	Elevated: "elevated",
+}
+
+// AuthContext describes how and when a user authenticated
+type AuthContext struct {
+	Methods  []string
+	Class    string
+	AuthTime time.Time
+}
+
+// UserGrants holds the authorization data embedded into an access token
+type UserGrants struct {
+	Roles       []string
+	Permissions []string
+	Memberships []Membership
+	ExpiresAt   *time.Time // earliest expiry of a time-bound role grant, if any
+}
+
 // TokenClaims represents the claims in a JWT token
 type TokenClaims struct {
-	UserID    int64    `json:"userId"`
-	Email     string   `json:"email"`
-	Nickname  string   `json:"nickname"`
-	Roles     []string `json:"roles"`
-	ExpiresAt int64    `json:"exp"`
-	IssuedAt  int64    `json:"iat"`
+	UserID       int64        `json:"userId"`
+	Email        string       `json:"email"`
+	Nickname     string       `json:"nickname"`
+	Roles        []string     `json:"roles"`
+	Permissions  []string     `json:"permissions,omitempty"`
+	AMR          []string     `json:"amr"`
+	ACR          string       `json:"acr"`
+	AuthTime     int64        `json:"auth_time"`
+	AuthzVersion int64        `json:"authzVersion"`
+	Memberships  []Membership `json:"memberships"`
+	ExpiresAt    int64        `json:"exp"`
+	IssuedAt     int64        `json:"iat"`
 }
 
 // TokenPair represents a pair of access and refresh tokens
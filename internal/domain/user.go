@@ -6,15 +6,131 @@ import (
 
 // User represents a user in the system
 type User struct {
-	ID                    int64     `json:"id" db:"id"`
-	FirstName             string    `json:"firstName" db:"first_name"`
-	LastName              string    `json:"lastName" db:"last_name"`
-	Nickname              string    `json:"nickname" db:"nickname"`
-	Email                 string    `json:"email" db:"email"`
-	EmailVerified         bool      `json:"emailVerified" db:"email_verified"`
-	AcceptedPrivacyPolicy bool      `json:"acceptedPrivacyPolicy" db:"accepted_privacy_policy"`
-	CreatedAt             time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt             time.Time `json:"updatedAt" db:"updated_at"`
+	ID                    int64      `json:"id" db:"id"`
+	FirstName             string     `json:"firstName" db:"first_name"`
+	LastName              string     `json:"lastName" db:"last_name"`
+	Nickname              string     `json:"nickname" db:"nickname"`
+	Email                 string     `json:"email" db:"email"`
+	EmailVerified         bool       `json:"emailVerified" db:"email_verified"`
+	AcceptedPrivacyPolicy bool       `json:"acceptedPrivacyPolicy" db:"accepted_privacy_policy"`
+	AuthzVersion          int64      `json:"-" db:"authz_version"`
+	InstitutionID         *int64     `json:"institutionId,omitempty" db:"institution_id"`
+	ReviewReason          *string    `json:"reviewReason,omitempty" db:"review_reason"`                // set when the account awaits review
+	DeletionScheduledAt   *time.Time `json:"deletionScheduledAt,omitempty" db:"deletion_scheduled_at"` // set while the account is pending deletion
+	Status                string     `json:"status" db:"status"`
+	SuspendedUntil        *time.Time `json:"suspendedUntil,omitempty" db:"suspended_until"`
+	StatusReason          *string    `json:"statusReason,omitempty" db:"status_reason"`
+	StatusChangedBy       *int64     `json:"statusChangedBy,omitempty" db:"status_changed_by"`
+	StatusChangedAt       *time.Time `json:"statusChangedAt,omitempty" db:"status_changed_at"`
+	ExternalID            *string    `json:"externalId,omitempty" db:"external_id"` // set for users provisioned through SCIM
+	CreatedAt             time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt             time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+// AccountError reports whether the account is blocked at the given time, with domain.ErrAccountSuspended or
+// domain.ErrAccountBanned; it returns nil for an active account or an expired suspension
+func (u User) AccountError(now time.Time) error {
+	return AccountState{Status: u.Status, SuspendedUntil: u.SuspendedUntil}.AccountError(now)
+}
+
+// AccountStatuses defines the states of a user account
+var AccountStatuses = struct {
+	Active    string
+	Suspended string
+	Banned    string
+}{
+	Active:    "active",
+	Suspended: "suspended",
+	Banned:    "banned",
+}
+
+// AccountState is the part of a user checked on every token validation
+type AccountState struct {
+	AuthzVersion   int64      `db:"authz_version"`
+	Status         string     `db:"status"`
+	SuspendedUntil *time.Time `db:"suspended_until"`
+}
+
+// AccountError reports whether the account is blocked at the given time, see User.AccountError
+func (s AccountState) AccountError(now time.Time) error {
+	switch s.Status {
+	case AccountStatuses.Banned:
+		return ErrAccountBanned
+	case AccountStatuses.Suspended:
+		if s.SuspendedUntil == nil || now.Before(*s.SuspendedUntil) {
+			return ErrAccountSuspended
+		}
+	}
+	return nil
+}
+
+// SuspendUserRequest represents the data needed to suspend a user until the given time
+type SuspendUserRequest struct {
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
+}
+
+// BanUserRequest represents the data needed to ban a user
+type BanUserRequest struct {
+	Reason string `json:"reason"`
+}
+
+// UserSorts defines the fields the admin user directory can be sorted by
+var UserSorts = struct {
+	CreatedAt string
+	Email     string
+	Nickname  string
+}{
+	CreatedAt: "createdAt",
+	Email:     "email",
+	Nickname:  "nickname",
+}
+
+// SortOrders defines the directions a listing can be sorted in
+var SortOrders = struct {
+	Asc  string
+	Desc string
+}{
+	Asc:  "asc",
+	Desc: "desc",
+}
+
+// ListUsersRequest represents the filters, sorting and page of an admin user directory listing.
+// Empty fields do not filter; Sort defaults to createdAt and Order to desc.
+type ListUsersRequest struct {
+	Email       string // email prefix
+	Nickname    string // nickname prefix
+	Role        string // name of a role granted directly
+	Status      string // one of AccountStatuses; an expired suspension counts as active
+	Verified    *bool
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Sort        string
+	Order       string
+	Cursor      string // NextCursor of the previous page
+	Limit       int
+}
+
+// UserCursor is the position after which the next page of the user directory starts
+type UserCursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+// UserPage represents a page of the admin user directory
+type UserPage struct {
+	Users      []User `json:"users"`
+	NextCursor string `json:"nextCursor,omitempty"` // empty on the last page
+}
+
+// UserDetails represents a user as shown in the admin user directory, with their roles and active sessions
+type UserDetails struct {
+	User
+	Roles          []RoleGrant        `json:"roles"`
+	EffectiveRoles []string           `json:"effectiveRoles"`
+	Sessions       []TokenSessionData `json:"sessions"`
 }
 
 // RegistrationRequest represents the data needed to register a new user
@@ -61,10 +177,14 @@ type LoginConfirmRequest struct {
 	Code  string `json:"code" validate:"required,len=4,numeric"`
 }
 
-// TokenResponse represents the token pair response
+// TokenResponse represents the token pair response. When the user must first accept new versions of the legal
+// documents, no tokens are issued: the status is consent_required and the consent token completes the login or refresh.
 type TokenResponse struct {
-	AccessToken  string `json:"accessToken"`
-	RefreshToken string `json:"refreshToken"`
+	AccessToken       string          `json:"accessToken"`
+	RefreshToken      string          `json:"refreshToken"`
+	Status            string          `json:"status,omitempty"`
+	ConsentToken      string          `json:"consentToken,omitempty"`
+	RequiredDocuments []LegalDocument `json:"requiredDocuments,omitempty"`
 }
 
 // RefreshTokenRequest represents the data needed to refresh a token
@@ -72,6 +192,54 @@ type RefreshTokenRequest struct {
 	RefreshToken string `json:"refreshToken" validate:"required"`
 }
 
+// ReauthConfirmRequest represents the data needed to confirm a re-authentication
+type ReauthConfirmRequest struct {
+	Code string `json:"code" validate:"required,len=4,numeric"`
+}
+
+// ReauthCodeResponse represents the response after sending a re-authentication code
+type ReauthCodeResponse struct {
+	CodeExpires int64 `json:"codeExpires"`
+}
+
+// StepUpTokenResponse represents an elevated access token issued after re-authentication
+type StepUpTokenResponse struct {
+	AccessToken string `json:"accessToken"`
+	AuthTime    int64  `json:"authTime"`
+}
+
+// UpdateProfileRequest represents a change of the user's own profile; omitted fields are left unchanged
+type UpdateProfileRequest struct {
+	FirstName *string `json:"firstName,omitempty"`
+	LastName  *string `json:"lastName,omitempty"`
+	Nickname  *string `json:"nickname,omitempty"`
+}
+
+// ChangeEmailRequest represents the data needed to request a change of the user's email
+type ChangeEmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ChangeEmailResponse represents the response after requesting an email change
+type ChangeEmailResponse struct {
+	CodeExpires int64 `json:"codeExpires"`
+}
+
+// ConfirmEmailChangeRequest represents the data needed to confirm an email change with the code sent to the new address
+type ConfirmEmailChangeRequest struct {
+	Code string `json:"code" validate:"required,len=4,numeric"`
+}
+
+// CancelEmailChangeRequest represents the data needed to cancel an email change from the link sent to the current address
+type CancelEmailChangeRequest struct {
+	Token string `json:"token" validate:"required,uuid"`
+}
+
+// AccountDeletionResponse represents the response after requesting the deletion of the account
+type AccountDeletionResponse struct {
+	DeletionScheduledAt time.Time `json:"deletionScheduledAt"`
+}
+
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error          string       `json:"error"`
@@ -83,3 +251,28 @@ type FieldError struct {
 	Field   string `json:"field"`
 	Message string `json:"message"`
 }
+
+// EmailPolicyReasons defines why an email address failed the registration email policy
+var EmailPolicyReasons = struct {
+	Disposable string
+	NoMX       string
+}{
+	Disposable: "disposable_domain",
+	NoMX:       "no_mx_records",
+}
+
+// EmailPolicyActions defines what happens to a registration whose email fails the email policy
+var EmailPolicyActions = struct {
+	Reject string
+	Flag   string
+}{
+	Reject: "reject",
+	Flag:   "flag",
+}
+
+// EmailPolicyResult represents the outcome of the registration email policy check.
+// An empty Reason means the address passed; otherwise the registration is rejected or flagged for review.
+type EmailPolicyResult struct {
+	Reason string
+	Reject bool
+}
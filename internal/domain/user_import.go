@@ -0,0 +1,43 @@
+package domain
+
+// UserFileFormats defines the file formats of bulk user imports
+var UserFileFormats = struct {
+	CSV  string
+	JSON string
+}{
+	CSV:  "csv",
+	JSON: "json",
+}
+
+// ImportedUser represents a user to be created by a bulk import. In CSV files the roles are separated by semicolons.
+type ImportedUser struct {
+	FirstName string   `json:"firstName"`
+	LastName  string   `json:"lastName"`
+	Nickname  string   `json:"nickname"`
+	Email     string   `json:"email"`
+	Roles     []string `json:"roles,omitempty"` // granted in addition to the default user role
+}
+
+// UserImportOptions represents the settings of a bulk user import
+type UserImportOptions struct {
+	Format           string
+	DryRun           bool // only validate the users
+	SendWelcomeEmail bool
+}
+
+// UserImportReport represents the outcome of a bulk user import. Users are only created when every row is valid.
+type UserImportReport struct {
+	DryRun  bool            `json:"dryRun"`
+	Total   int             `json:"total"`
+	Created int             `json:"created"`
+	Failed  int             `json:"failed"`
+	Rows    []UserImportRow `json:"rows"`
+}
+
+// UserImportRow represents the outcome of a single user of a bulk import
+type UserImportRow struct {
+	Row    int          `json:"row"` // position of the user in the file, starting at 1 and not counting the CSV header
+	Email  string       `json:"email"`
+	UserID int64        `json:"userId,omitempty"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
@@ -0,0 +1,191 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"authmicro/internal/domain"
+)
+
+type ConsentRepository struct {
+	db *sqlx.DB
+}
+
+func NewConsentRepository(db *sqlx.DB) *ConsentRepository {
+	return &ConsentRepository{
+		db: db,
+	}
+}
+
+// CreateLegalDocument creates a version of a legal document and returns it
+func (r *ConsentRepository) CreateLegalDocument(ctx context.Context, document domain.LegalDocument) (domain.LegalDocument, error) {
+	query := `
+                INSERT INTO legal_documents (kind, version, url, published_at, created_by, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6)
+                RETURNING id, kind, version, url, published_at, created_by, created_at`
+
+	var created domain.LegalDocument
+	err := conn(ctx, r.db).GetContext(
+		ctx,
+		&created,
+		query,
+		document.Kind,
+		document.Version,
+		document.URL,
+		document.PublishedAt,
+		document.CreatedBy,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.LegalDocument{}, domain.ErrLegalDocumentExists
+		}
+		return domain.LegalDocument{}, err
+	}
+
+	return created, nil
+}
+
+// ListLegalDocuments retrieves all versions of the legal documents, newest first
+func (r *ConsentRepository) ListLegalDocuments(ctx context.Context) ([]domain.LegalDocument, error) {
+	query := `
+                SELECT id, kind, version, url, published_at, created_by, created_at
+                FROM legal_documents
+                ORDER BY published_at DESC, id DESC`
+
+	var documents []domain.LegalDocument
+	err := conn(ctx, r.db).SelectContext(ctx, &documents, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return documents, nil
+}
+
+// ListCurrentLegalDocuments retrieves the current version of each kind of legal document
+func (r *ConsentRepository) ListCurrentLegalDocuments(ctx context.Context, now time.Time) ([]domain.LegalDocument, error) {
+	query := `
+                SELECT DISTINCT ON (kind) id, kind, version, url, published_at, created_by, created_at
+                FROM legal_documents
+                WHERE published_at <= $1
+                ORDER BY kind, published_at DESC, id DESC`
+
+	var documents []domain.LegalDocument
+	err := conn(ctx, r.db).SelectContext(ctx, &documents, query, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return documents, nil
+}
+
+// ListRequiredDocuments retrieves the current legal documents the user has not accepted yet
+func (r *ConsentRepository) ListRequiredDocuments(ctx context.Context, userID int64, now time.Time) ([]domain.LegalDocument, error) {
+	query := `
+                SELECT d.id, d.kind, d.version, d.url, d.published_at, d.created_by, d.created_at
+                FROM (
+                    SELECT DISTINCT ON (kind) id, kind, version, url, published_at, created_by, created_at
+                    FROM legal_documents
+                    WHERE published_at <= $2
+                    ORDER BY kind, published_at DESC, id DESC
+                ) d
+                WHERE NOT EXISTS (SELECT 1 FROM consents c WHERE c.user_id = $1 AND c.document_id = d.id)
+                ORDER BY d.kind`
+
+	var documents []domain.LegalDocument
+	err := conn(ctx, r.db).SelectContext(ctx, &documents, query, userID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return documents, nil
+}
+
+// CreateConsent records the acceptance of a legal document by a user; accepting a document again is a no-op
+func (r *ConsentRepository) CreateConsent(ctx context.Context, consent domain.Consent) error {
+	query := `
+                INSERT INTO consents (user_id, document_id, ip, user_agent, accepted_at)
+                VALUES ($1, $2, $3, $4, $5)
+                ON CONFLICT (user_id, document_id) DO NOTHING`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, consent.UserID, consent.DocumentID, consent.IP, consent.UserAgent, time.Now().UTC())
+	return err
+}
+
+// ListUserConsents retrieves the legal documents accepted by a user, newest first
+func (r *ConsentRepository) ListUserConsents(ctx context.Context, userID int64) ([]domain.Consent, error) {
+	query := `
+                SELECT c.id, c.user_id, c.document_id, d.kind, d.version, c.ip, c.user_agent, c.accepted_at
+                FROM consents c
+                JOIN legal_documents d ON d.id = c.document_id
+                WHERE c.user_id = $1
+                ORDER BY c.accepted_at DESC, c.id DESC`
+
+	var consents []domain.Consent
+	err := conn(ctx, r.db).SelectContext(ctx, &consents, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return consents, nil
+}
+
+// CreateConsentSession creates a consent session
+func (r *ConsentRepository) CreateConsentSession(ctx context.Context, session domain.ConsentSession) (string, error) {
+	query := `
+                INSERT INTO consent_sessions (id, user_id, token_session_id, amr, auth_time, expires_at, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	id := uuid.New().String()
+
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		id,
+		session.UserID,
+		session.TokenSessionID,
+		session.AMR,
+		session.AuthTime,
+		session.ExpiresAt,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// GetConsentSession retrieves an unexpired consent session by ID
+func (r *ConsentRepository) GetConsentSession(ctx context.Context, id string) (domain.ConsentSession, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return domain.ConsentSession{}, domain.ErrConsentSessionNotFound
+	}
+
+	query := `
+                SELECT id, user_id, token_session_id, amr, auth_time, expires_at, created_at
+                FROM consent_sessions
+                WHERE id = $1 AND expires_at > $2`
+
+	var session domain.ConsentSession
+	err := conn(ctx, r.db).GetContext(ctx, &session, query, id, time.Now().UTC())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.ConsentSession{}, domain.ErrConsentSessionNotFound
+		}
+		return domain.ConsentSession{}, err
+	}
+
+	return session, nil
+}
+
+// DeleteConsentSession deletes a consent session
+func (r *ConsentRepository) DeleteConsentSession(ctx context.Context, id string) error {
+	query := `DELETE FROM consent_sessions WHERE id = $1`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+	return err
+}
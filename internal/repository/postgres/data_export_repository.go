@@ -0,0 +1,244 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"authmicro/internal/domain"
+)
+
+type DataExportRepository struct {
+	db *sqlx.DB
+}
+
+func NewDataExportRepository(db *sqlx.DB) *DataExportRepository {
+	return &DataExportRepository{
+		db: db,
+	}
+}
+
+// CreateDataExport creates a pending data export and returns it
+func (r *DataExportRepository) CreateDataExport(ctx context.Context, userID int64, format string) (domain.DataExport, error) {
+	query := `
+                INSERT INTO data_exports (id, user_id, format, status, download_token, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6)
+                RETURNING id, user_id, format, status, download_token, created_at, completed_at, expires_at`
+
+	var export domain.DataExport
+	err := conn(ctx, r.db).GetContext(
+		ctx,
+		&export,
+		query,
+		uuid.New().String(),
+		userID,
+		format,
+		domain.DataExportStatuses.Pending,
+		uuid.New().String(),
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return domain.DataExport{}, err
+	}
+
+	return export, nil
+}
+
+// GetDataExport retrieves a data export of a user without its data
+func (r *DataExportRepository) GetDataExport(ctx context.Context, userID int64, id string) (domain.DataExport, error) {
+	query := `
+                SELECT id, user_id, format, status, download_token, created_at, completed_at, expires_at
+                FROM data_exports
+                WHERE id = $1 AND user_id = $2`
+
+	var export domain.DataExport
+	err := conn(ctx, r.db).GetContext(ctx, &export, query, id, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.DataExport{}, domain.ErrDataExportNotFound
+		}
+		return domain.DataExport{}, err
+	}
+
+	return export, nil
+}
+
+// GetUnfinishedDataExport retrieves the export of a user that is still being built, if any
+func (r *DataExportRepository) GetUnfinishedDataExport(ctx context.Context, userID int64) (domain.DataExport, error) {
+	query := `
+                SELECT id, user_id, format, status, download_token, created_at, completed_at, expires_at
+                FROM data_exports
+                WHERE user_id = $1 AND status IN ($2, $3)
+                ORDER BY created_at DESC
+                LIMIT 1`
+
+	var export domain.DataExport
+	err := conn(ctx, r.db).GetContext(ctx, &export, query, userID, domain.DataExportStatuses.Pending, domain.DataExportStatuses.Processing)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.DataExport{}, domain.ErrDataExportNotFound
+		}
+		return domain.DataExport{}, err
+	}
+
+	return export, nil
+}
+
+// GetDataExportByDownloadToken retrieves a ready, unexpired data export with its data by the download token
+func (r *DataExportRepository) GetDataExportByDownloadToken(ctx context.Context, token string) (domain.DataExport, error) {
+	query := `
+                SELECT id, user_id, format, status, data, download_token, created_at, completed_at, expires_at
+                FROM data_exports
+                WHERE download_token = $1 AND status = $2 AND expires_at > $3`
+
+	var export domain.DataExport
+	err := conn(ctx, r.db).GetContext(ctx, &export, query, token, domain.DataExportStatuses.Ready, time.Now().UTC())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.DataExport{}, domain.ErrDataExportNotFound
+		}
+		return domain.DataExport{}, err
+	}
+
+	return export, nil
+}
+
+// ClaimPendingDataExport marks the oldest pending data export as processing and returns it. Exports left processing
+// by a worker that claimed them before staleBefore, presumably a crashed one, are claimed again unless they were already
+// claimed maxAttempts times. Exports claimed by another instance are skipped; domain.ErrDataExportNotFound is returned
+// when there is nothing to build.
+func (r *DataExportRepository) ClaimPendingDataExport(ctx context.Context, staleBefore time.Time, maxAttempts int) (domain.DataExport, error) {
+	query := `
+                UPDATE data_exports
+                SET status = $1, claimed_at = $2, attempts = attempts + 1
+                WHERE id = (
+                    SELECT id FROM data_exports
+                    WHERE status = $3
+                       OR (status = $1 AND COALESCE(claimed_at, created_at) < $4 AND attempts < $5)
+                    ORDER BY created_at
+                    LIMIT 1
+                    FOR UPDATE SKIP LOCKED
+                )
+                RETURNING id, user_id, format, status, download_token, created_at, completed_at, expires_at, attempts`
+
+	var export domain.DataExport
+	err := conn(ctx, r.db).GetContext(
+		ctx,
+		&export,
+		query,
+		domain.DataExportStatuses.Processing,
+		time.Now().UTC(),
+		domain.DataExportStatuses.Pending,
+		staleBefore,
+		maxAttempts,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.DataExport{}, domain.ErrDataExportNotFound
+		}
+		return domain.DataExport{}, err
+	}
+
+	return export, nil
+}
+
+// CompleteDataExport stores the built data of an export and makes it downloadable until expiresAt. It returns
+// domain.ErrDataExportNotFound if the export is no longer processing under the given claim, i.e. it was claimed again
+// or given up on after the claim went stale.
+func (r *DataExportRepository) CompleteDataExport(ctx context.Context, id string, attempt int, data []byte, expiresAt time.Time) error {
+	query := `
+                UPDATE data_exports
+                SET status = $1, data = $2, completed_at = $3, expires_at = $4
+                WHERE id = $5 AND status = $6 AND attempts = $7`
+
+	res, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		domain.DataExportStatuses.Ready,
+		data,
+		time.Now().UTC(),
+		expiresAt,
+		id,
+		domain.DataExportStatuses.Processing,
+		attempt,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrDataExportNotFound
+	}
+
+	return nil
+}
+
+// FailDataExport marks a data export as failed; it is kept until expiresAt so that its status can be checked.
+// Like CompleteDataExport, it only updates an export still processing under the given claim.
+func (r *DataExportRepository) FailDataExport(ctx context.Context, id string, attempt int, expiresAt time.Time) error {
+	query := `
+                UPDATE data_exports
+                SET status = $1, completed_at = $2, expires_at = $3
+                WHERE id = $4 AND status = $5 AND attempts = $6`
+
+	res, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		domain.DataExportStatuses.Failed,
+		time.Now().UTC(),
+		expiresAt,
+		id,
+		domain.DataExportStatuses.Processing,
+		attempt,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrDataExportNotFound
+	}
+
+	return nil
+}
+
+// FailStaleDataExports marks as failed the exports left processing by a worker that claimed them before staleBefore
+// once they were claimed maxAttempts times, so that they expire like other failed exports instead of staying
+// processing forever
+func (r *DataExportRepository) FailStaleDataExports(ctx context.Context, staleBefore time.Time, maxAttempts int, expiresAt time.Time) error {
+	query := `
+                UPDATE data_exports
+                SET status = $1, completed_at = $2, expires_at = $3
+                WHERE status = $4 AND COALESCE(claimed_at, created_at) < $5 AND attempts >= $6`
+
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		domain.DataExportStatuses.Failed,
+		time.Now().UTC(),
+		expiresAt,
+		domain.DataExportStatuses.Processing,
+		staleBefore,
+		maxAttempts,
+	)
+	return err
+}
+
+// DeleteExpiredDataExports deletes the data exports that can no longer be downloaded. Exports still pending or
+// processing have no expiry; stale processing exports expire once FailStaleDataExports gives up on them.
+func (r *DataExportRepository) DeleteExpiredDataExports(ctx context.Context) error {
+	query := `DELETE FROM data_exports WHERE expires_at < $1`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, time.Now().UTC())
+	return err
+}
@@ -1,11 +1,12 @@
 package postgres
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 
 	"authmicro/configs"
 )
@@ -44,6 +45,21 @@ func InitSchema(db *sqlx.DB) error {
 	return nil
 }
 
+// isUniqueViolation reports whether the error is a PostgreSQL unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// isEmailUniqueViolation reports whether err is a unique constraint violation on users.email
+func isEmailUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return false
+	}
+	return pqErr.Constraint == "users_email_key" || pqErr.Constraint == "idx_users_email_lower"
+}
+
 // schema defines the database schema
 const schema = `
 -- Create roles table
@@ -121,4 +137,321 @@ INSERT INTO roles (name, created_at, updated_at)
 VALUES ('admin', NOW(), NOW()), ('user', NOW(), NOW())
 ON CONFLICT (name) DO NOTHING;
 
+-- Record how and when the user authenticated for each refresh token session
+ALTER TABLE token_sessions ADD COLUMN IF NOT EXISTS amr TEXT NOT NULL DEFAULT '';
+ALTER TABLE token_sessions ADD COLUMN IF NOT EXISTS auth_time TIMESTAMP NOT NULL DEFAULT NOW();
+
+-- Create permissions table
+CREATE TABLE IF NOT EXISTS permissions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Create role_permissions table
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id INTEGER NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_permissions_permission_id ON role_permissions(permission_id);
+
+-- Insert default permissions
+INSERT INTO permissions (name, description, created_at, updated_at)
+VALUES ('users:read', 'Read user accounts', NOW(), NOW()),
+       ('users:write', 'Modify user accounts', NOW(), NOW()),
+       ('sessions:revoke', 'Revoke user sessions', NOW(), NOW()),
+       ('roles:manage', 'Manage roles and permissions', NOW(), NOW())
+ON CONFLICT (name) DO NOTHING;
+
+-- Grant all default permissions to the admin role
+INSERT INTO role_permissions (role_id, permission_id, created_at)
+SELECT r.id, p.id, NOW()
+FROM roles r
+CROSS JOIN permissions p
+WHERE r.name = 'admin'
+  AND p.name IN ('users:read', 'users:write', 'sessions:revoke', 'roles:manage')
+ON CONFLICT DO NOTHING;
+
+-- Create role_change_history table
+CREATE TABLE IF NOT EXISTS role_change_history (
+    id SERIAL PRIMARY KEY,
+    action VARCHAR(20) NOT NULL,
+    role_id INTEGER NOT NULL,
+    role_name VARCHAR(50) NOT NULL,
+    user_id INTEGER,
+    actor_id INTEGER NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_change_history_role_id ON role_change_history(role_id);
+CREATE INDEX IF NOT EXISTS idx_role_change_history_user_id ON role_change_history(user_id);
+
+-- Create role_hierarchy table; a user holding the parent role effectively holds the child role
+CREATE TABLE IF NOT EXISTS role_hierarchy (
+    parent_role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    child_role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (parent_role_id, child_role_id),
+    CHECK (parent_role_id <> child_role_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_hierarchy_child_role_id ON role_hierarchy(child_role_id);
+
+-- Allow time-bound role grants and record who granted a role
+ALTER TABLE user_roles ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+ALTER TABLE user_roles ADD COLUMN IF NOT EXISTS granted_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_expires_at ON user_roles(expires_at) WHERE expires_at IS NOT NULL;
+
+-- Per-user authorization version; access tokens carrying an older version are rejected
+ALTER TABLE users ADD COLUMN IF NOT EXISTS authz_version BIGINT NOT NULL DEFAULT 1;
+
+-- Attribute-based authorization policies
+CREATE TABLE IF NOT EXISTS policies (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    effect VARCHAR(10) NOT NULL CHECK (effect IN ('allow', 'deny')),
+    action VARCHAR(100) NOT NULL,
+    resource_type VARCHAR(100) NOT NULL DEFAULT '*',
+    condition TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Organizations (tenants) and their members
+CREATE TABLE IF NOT EXISTS organizations (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(50) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS memberships (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, organization_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_memberships_organization_id ON memberships(organization_id);
+
+-- Roles granted to a member within an organization
+CREATE TABLE IF NOT EXISTS membership_roles (
+    user_id INTEGER NOT NULL,
+    organization_id INTEGER NOT NULL,
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, organization_id, role_id),
+    FOREIGN KEY (user_id, organization_id) REFERENCES memberships(user_id, organization_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_membership_roles_role_id ON membership_roles(role_id);
+
+-- Invitations of people into organizations
+CREATE TABLE IF NOT EXISTS invitations (
+    id SERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    nonce VARCHAR(36) NOT NULL,
+    invited_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP,
+    accepted_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- At most one open invitation per email and organization
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invitations_open_email
+    ON invitations(organization_id, LOWER(email))
+    WHERE accepted_at IS NULL AND revoked_at IS NULL;
+
+-- Roles granted within the organization on acceptance
+CREATE TABLE IF NOT EXISTS invitation_roles (
+    invitation_id INTEGER NOT NULL REFERENCES invitations(id) ON DELETE CASCADE,
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    PRIMARY KEY (invitation_id, role_id)
+);
+
+-- Institutions allowed to register and their email domains.
+-- A domain either matches exactly (msu.ru) or is a wildcard matching any subdomain (*.msu.ru).
+CREATE TABLE IF NOT EXISTS institutions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS institution_domains (
+    domain VARCHAR(255) PRIMARY KEY,
+    institution_id INTEGER NOT NULL REFERENCES institutions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_institution_domains_institution_id ON institution_domains(institution_id);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS institution_id INTEGER REFERENCES institutions(id) ON DELETE SET NULL;
+
+-- Registrations whose email failed the email policy in flag mode are kept for review
+ALTER TABLE registration_sessions ADD COLUMN IF NOT EXISTS review_reason VARCHAR(50);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS review_reason VARCHAR(50);
+
+CREATE INDEX IF NOT EXISTS idx_users_review_reason ON users(id) WHERE review_reason IS NOT NULL;
+
+-- Emails are unique regardless of case. Existing addresses are lowercased unless that would merge accounts;
+-- collisions are reported (see cmd/emailmigrate) and the index is left out until they are resolved.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_users_email_lower') THEN
+        IF EXISTS (SELECT 1 FROM users GROUP BY LOWER(TRIM(email)) HAVING COUNT(*) > 1) THEN
+            RAISE WARNING 'users.email has case-insensitive duplicates, run cmd/emailmigrate to resolve them';
+        ELSE
+            UPDATE users SET email = LOWER(TRIM(email)) WHERE email <> LOWER(TRIM(email));
+            CREATE UNIQUE INDEX idx_users_email_lower ON users (LOWER(email));
+        END IF;
+    END IF;
+END
+$$;
+
+-- Pending email changes: a code is sent to the new address and a cancel link to the current one.
+-- A user has at most one pending change; requesting another replaces it.
+CREATE TABLE IF NOT EXISTS email_change_sessions (
+    id UUID PRIMARY KEY,
+    user_id INTEGER NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    new_email VARCHAR(255) NOT NULL,
+    code VARCHAR(6) NOT NULL,
+    code_expires TIMESTAMP NOT NULL,
+    cancel_token UUID NOT NULL UNIQUE,
+    review_reason VARCHAR(50),
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Nickname changes. The previous nickname stays reserved for other users until reserved_until.
+CREATE TABLE IF NOT EXISTS nickname_history (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    old_nickname VARCHAR(255) NOT NULL,
+    new_nickname VARCHAR(255) NOT NULL,
+    changed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    reserved_until TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_nickname_history_user_id ON nickname_history(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_nickname_history_old_nickname ON nickname_history(old_nickname, reserved_until);
+
+-- Nicknames nobody may take, matched case-insensitively and stored lowercased
+CREATE TABLE IF NOT EXISTS blocked_nicknames (
+    nickname VARCHAR(255) PRIMARY KEY,
+    reason TEXT NOT NULL DEFAULT '',
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Accounts pending deletion are deleted or anonymized once deletion_scheduled_at has passed;
+-- anonymized accounts keep their row with deleted_at set
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON users(deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
+
+-- Personal data exports, built in the background and downloadable with the emailed token until expires_at
+CREATE TABLE IF NOT EXISTS data_exports (
+    id UUID PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    format VARCHAR(10) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    data BYTEA,
+    download_token UUID NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_data_exports_status ON data_exports(status, created_at);
+
+-- Versions of the privacy policy and terms. The current version of a kind is the latest published one.
+CREATE TABLE IF NOT EXISTS legal_documents (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(50) NOT NULL,
+    version VARCHAR(50) NOT NULL,
+    url TEXT NOT NULL,
+    published_at TIMESTAMP NOT NULL,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (kind, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_legal_documents_kind_published_at ON legal_documents(kind, published_at);
+
+-- Acceptances of legal document versions by users
+CREATE TABLE IF NOT EXISTS consents (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    document_id INTEGER NOT NULL REFERENCES legal_documents(id) ON DELETE CASCADE,
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    accepted_at TIMESTAMP NOT NULL,
+    UNIQUE (user_id, document_id)
+);
+
+-- Logins and token refreshes held back until the user accepts the current legal documents
+CREATE TABLE IF NOT EXISTS consent_sessions (
+    id UUID PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_session_id UUID,
+    amr TEXT NOT NULL DEFAULT '',
+    auth_time TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_consent_sessions_user_id ON consent_sessions(user_id);
+
+-- Account status: suspended accounts are blocked until suspended_until, banned accounts for good
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_until TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_reason TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_changed_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP;
+
+-- Provisioning clients calling the SCIM API, authenticated by a bearer token of which only the SHA-256 hash is kept
+CREATE TABLE IF NOT EXISTS scim_clients (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP
+);
+
+-- Identifier of a provisioned user in the system of the provisioning client
+ALTER TABLE users ADD COLUMN IF NOT EXISTS external_id VARCHAR(255);
+
+-- Pending re-authentications of signed-in users, kept apart from login codes so that neither can stand in for the other.
+-- A user has at most one pending re-authentication; requesting another replaces it.
+CREATE TABLE IF NOT EXISTS reauth_sessions (
+    id UUID PRIMARY KEY,
+    user_id INTEGER NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    code VARCHAR(6) NOT NULL,
+    code_expires TIMESTAMP NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- When a data export was last claimed by a worker and how many times, so that exports left processing by a crashed
+-- worker are claimed again or given up on
+ALTER TABLE data_exports ADD COLUMN IF NOT EXISTS claimed_at TIMESTAMP;
+ALTER TABLE data_exports ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+
 `
@@ -0,0 +1,229 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"authmicro/internal/domain"
+)
+
+type InstitutionRepository struct {
+	db *sqlx.DB
+}
+
+func NewInstitutionRepository(db *sqlx.DB) *InstitutionRepository {
+	return &InstitutionRepository{
+		db: db,
+	}
+}
+
+// CreateInstitution creates a new institution with its email domains
+func (r *InstitutionRepository) CreateInstitution(ctx context.Context, name string, domains []string) (int64, error) {
+	query := `
+                INSERT INTO institutions (name, created_at, updated_at)
+                VALUES ($1, $2, $3)
+                RETURNING id`
+
+	var id int64
+	now := time.Now().UTC()
+
+	err := NewUnitOfWork(r.db).Do(ctx, func(ctx context.Context) error {
+		tx := conn(ctx, r.db)
+
+		err := tx.QueryRowContext(ctx, query, name, now, now).Scan(&id)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return domain.ErrInstitutionExists
+			}
+			return err
+		}
+
+		return insertInstitutionDomains(ctx, tx, id, domains)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// GetInstitutionByID retrieves an institution with its domains by ID
+func (r *InstitutionRepository) GetInstitutionByID(ctx context.Context, id int64) (domain.Institution, error) {
+	query := `
+                SELECT id, name, created_at, updated_at
+                FROM institutions
+                WHERE id = $1`
+
+	var institution domain.Institution
+	err := conn(ctx, r.db).GetContext(ctx, &institution, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.Institution{}, domain.ErrInstitutionNotFound
+		}
+		return domain.Institution{}, err
+	}
+
+	institutions := []domain.Institution{institution}
+	if err := r.loadDomains(ctx, institutions); err != nil {
+		return domain.Institution{}, err
+	}
+
+	return institutions[0], nil
+}
+
+// ListInstitutions retrieves all institutions with their domains
+func (r *InstitutionRepository) ListInstitutions(ctx context.Context) ([]domain.Institution, error) {
+	query := `
+                SELECT id, name, created_at, updated_at
+                FROM institutions
+                ORDER BY name`
+
+	var institutions []domain.Institution
+	err := conn(ctx, r.db).SelectContext(ctx, &institutions, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadDomains(ctx, institutions); err != nil {
+		return nil, err
+	}
+
+	return institutions, nil
+}
+
+// UpdateInstitution renames an institution and replaces its domains
+func (r *InstitutionRepository) UpdateInstitution(ctx context.Context, id int64, name string, domains []string) error {
+	query := `
+                UPDATE institutions
+                SET name = $2, updated_at = $3
+                WHERE id = $1`
+
+	return NewUnitOfWork(r.db).Do(ctx, func(ctx context.Context) error {
+		tx := conn(ctx, r.db)
+
+		res, err := tx.ExecContext(ctx, query, id, name, time.Now().UTC())
+		if err != nil {
+			if isUniqueViolation(err) {
+				return domain.ErrInstitutionExists
+			}
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return domain.ErrInstitutionNotFound
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM institution_domains WHERE institution_id = $1`, id); err != nil {
+			return err
+		}
+
+		return insertInstitutionDomains(ctx, tx, id, domains)
+	})
+}
+
+// DeleteInstitution deletes an institution; its users keep their accounts
+func (r *InstitutionRepository) DeleteInstitution(ctx context.Context, id int64) error {
+	query := `DELETE FROM institutions WHERE id = $1`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrInstitutionNotFound
+	}
+
+	return nil
+}
+
+// FindInstitutionByDomains retrieves the institution registered for the most specific of the given domain patterns,
+// preferring exact domains over wildcards
+func (r *InstitutionRepository) FindInstitutionByDomains(ctx context.Context, patterns []string) (domain.Institution, error) {
+	query := `
+                SELECT i.id, i.name, i.created_at, i.updated_at
+                FROM institution_domains d
+                JOIN institutions i ON i.id = d.institution_id
+                WHERE d.domain = ANY($1)
+                ORDER BY d.domain LIKE '*.%', LENGTH(d.domain) DESC
+                LIMIT 1`
+
+	var institution domain.Institution
+	err := conn(ctx, r.db).GetContext(ctx, &institution, query, pq.Array(patterns))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.Institution{}, domain.ErrInstitutionNotFound
+		}
+		return domain.Institution{}, err
+	}
+
+	return institution, nil
+}
+
+// insertInstitutionDomains assigns the domains to an institution
+func insertInstitutionDomains(ctx context.Context, tx executor, institutionID int64, domains []string) error {
+	if len(domains) == 0 {
+		return nil
+	}
+
+	query := `
+                INSERT INTO institution_domains (domain, institution_id)
+                SELECT UNNEST($2::VARCHAR[]), $1`
+
+	if _, err := tx.ExecContext(ctx, query, institutionID, pq.Array(domains)); err != nil {
+		if isUniqueViolation(err) {
+			return domain.ErrDomainTaken
+		}
+		return err
+	}
+
+	return nil
+}
+
+// loadDomains fills in the domains of the given institutions
+func (r *InstitutionRepository) loadDomains(ctx context.Context, institutions []domain.Institution) error {
+	if len(institutions) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(institutions))
+	index := make(map[int64]int, len(institutions))
+	for i := range institutions {
+		institutions[i].Domains = []string{}
+		ids = append(ids, institutions[i].ID)
+		index[institutions[i].ID] = i
+	}
+
+	query := `
+                SELECT institution_id, domain
+                FROM institution_domains
+                WHERE institution_id = ANY($1)
+                ORDER BY domain`
+
+	var rows []struct {
+		InstitutionID int64  `db:"institution_id"`
+		Domain        string `db:"domain"`
+	}
+	if err := conn(ctx, r.db).SelectContext(ctx, &rows, query, pq.Array(ids)); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		i := index[row.InstitutionID]
+		institutions[i].Domains = append(institutions[i].Domains, row.Domain)
+	}
+
+	return nil
+}
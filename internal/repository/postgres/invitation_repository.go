@@ -0,0 +1,225 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"authmicro/internal/domain"
+)
+
+type InvitationRepository struct {
+	db *sqlx.DB
+}
+
+func NewInvitationRepository(db *sqlx.DB) *InvitationRepository {
+	return &InvitationRepository{
+		db: db,
+	}
+}
+
+// CreateInvitation creates a new invitation together with the roles to grant on acceptance. Call it within a unit of
+// work to store both at once.
+func (r *InvitationRepository) CreateInvitation(ctx context.Context, invitation domain.Invitation, roleIDs []int64) (int64, error) {
+	query := `
+                INSERT INTO invitations (organization_id, email, nonce, invited_by, expires_at, created_at, updated_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7)
+                RETURNING id`
+
+	var id int64
+	now := time.Now().UTC()
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, invitation.OrganizationID, invitation.Email, invitation.Nonce,
+		invitation.InvitedBy, invitation.ExpiresAt, now, now).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, domain.ErrInvitationExists
+		}
+		return 0, err
+	}
+
+	if len(roleIDs) > 0 {
+		rolesQuery := `
+                INSERT INTO invitation_roles (invitation_id, role_id)
+                SELECT $1, UNNEST($2::INTEGER[])
+                ON CONFLICT DO NOTHING`
+
+		if _, err := conn(ctx, r.db).ExecContext(ctx, rolesQuery, id, pq.Array(roleIDs)); err != nil {
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+// GetInvitationByID retrieves an invitation with its roles by ID
+func (r *InvitationRepository) GetInvitationByID(ctx context.Context, id int64) (domain.Invitation, error) {
+	query := `
+                SELECT id, organization_id, email, nonce, invited_by, expires_at, accepted_at, accepted_by, revoked_at, created_at, updated_at
+                FROM invitations
+                WHERE id = $1`
+
+	var invitation domain.Invitation
+	err := conn(ctx, r.db).GetContext(ctx, &invitation, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.Invitation{}, domain.ErrInvitationNotFound
+		}
+		return domain.Invitation{}, err
+	}
+
+	invitations := []domain.Invitation{invitation}
+	if err := r.loadRoles(ctx, invitations); err != nil {
+		return domain.Invitation{}, err
+	}
+
+	return invitations[0], nil
+}
+
+// ListInvitations retrieves the invitations of an organization with their roles, newest first
+func (r *InvitationRepository) ListInvitations(ctx context.Context, orgID int64) ([]domain.Invitation, error) {
+	query := `
+                SELECT id, organization_id, email, nonce, invited_by, expires_at, accepted_at, accepted_by, revoked_at, created_at, updated_at
+                FROM invitations
+                WHERE organization_id = $1
+                ORDER BY created_at DESC, id DESC`
+
+	var invitations []domain.Invitation
+	err := conn(ctx, r.db).SelectContext(ctx, &invitations, query, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadRoles(ctx, invitations); err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+// RenewInvitation replaces the nonce and expiry of an open invitation, invalidating previously issued tokens
+func (r *InvitationRepository) RenewInvitation(ctx context.Context, id int64, nonce string, expiresAt time.Time) error {
+	query := `
+                UPDATE invitations
+                SET nonce = $2, expires_at = $3, updated_at = $4
+                WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, id, nonce, expiresAt, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrInvitationClosed
+	}
+
+	return nil
+}
+
+// RevokeInvitation revokes an open invitation
+func (r *InvitationRepository) RevokeInvitation(ctx context.Context, id int64) error {
+	query := `
+                UPDATE invitations
+                SET revoked_at = $2, updated_at = $2
+                WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, id, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrInvitationClosed
+	}
+
+	return nil
+}
+
+// AcceptInvitation marks an open, unexpired invitation issued with the given nonce as accepted by the user,
+// adds the user to the organization and grants the invitation roles within it. Call it within a unit of work to
+// make these changes at once.
+func (r *InvitationRepository) AcceptInvitation(ctx context.Context, id int64, nonce string, userID int64) error {
+	now := time.Now().UTC()
+
+	acceptQuery := `
+                UPDATE invitations
+                SET accepted_at = $3, accepted_by = $4, updated_at = $3
+                WHERE id = $1 AND nonce = $2 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > $3
+                RETURNING organization_id`
+
+	var orgID int64
+	err := conn(ctx, r.db).QueryRowContext(ctx, acceptQuery, id, nonce, now, userID).Scan(&orgID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.ErrInvitationInvalid
+		}
+		return err
+	}
+
+	memberQuery := `
+                INSERT INTO memberships (user_id, organization_id, created_at)
+                VALUES ($1, $2, $3)
+                ON CONFLICT DO NOTHING`
+
+	if _, err := conn(ctx, r.db).ExecContext(ctx, memberQuery, userID, orgID, now); err != nil {
+		return err
+	}
+
+	rolesQuery := `
+                INSERT INTO membership_roles (user_id, organization_id, role_id, created_at)
+                SELECT $1, $2, ir.role_id, $4
+                FROM invitation_roles ir
+                WHERE ir.invitation_id = $3
+                ON CONFLICT DO NOTHING`
+
+	_, err = conn(ctx, r.db).ExecContext(ctx, rolesQuery, userID, orgID, id, now)
+	return err
+}
+
+// loadRoles fills in the roles of the given invitations
+func (r *InvitationRepository) loadRoles(ctx context.Context, invitations []domain.Invitation) error {
+	if len(invitations) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(invitations))
+	index := make(map[int64]int, len(invitations))
+	for i := range invitations {
+		invitations[i].Roles = []domain.Role{}
+		ids = append(ids, invitations[i].ID)
+		index[invitations[i].ID] = i
+	}
+
+	query := `
+                SELECT ir.invitation_id, r.id, r.name, r.created_at, r.updated_at
+                FROM invitation_roles ir
+                JOIN roles r ON r.id = ir.role_id
+                WHERE ir.invitation_id = ANY($1)
+                ORDER BY r.name`
+
+	var rows []struct {
+		InvitationID int64 `db:"invitation_id"`
+		domain.Role
+	}
+	if err := conn(ctx, r.db).SelectContext(ctx, &rows, query, pq.Array(ids)); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		i := index[row.InvitationID]
+		invitations[i].Roles = append(invitations[i].Roles, row.Role)
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"authmicro/internal/domain"
+)
+
+type NicknameRepository struct {
+	db *sqlx.DB
+}
+
+func NewNicknameRepository(db *sqlx.DB) *NicknameRepository {
+	return &NicknameRepository{
+		db: db,
+	}
+}
+
+// CreateNicknameChange records a nickname change
+func (r *NicknameRepository) CreateNicknameChange(ctx context.Context, change domain.NicknameChange) error {
+	query := `
+                INSERT INTO nickname_history (user_id, old_nickname, new_nickname, changed_by, reserved_until, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		change.UserID,
+		change.OldNickname,
+		change.NewNickname,
+		change.ChangedBy,
+		change.ReservedUntil,
+		time.Now().UTC(),
+	)
+	return err
+}
+
+// GetLastNicknameChange retrieves the time of the latest nickname change of a user, nil when there is none
+func (r *NicknameRepository) GetLastNicknameChange(ctx context.Context, userID int64) (*time.Time, error) {
+	query := `SELECT MAX(created_at) FROM nickname_history WHERE user_id = $1`
+
+	var changedAt *time.Time
+	err := conn(ctx, r.db).GetContext(ctx, &changedAt, query, userID)
+	return changedAt, err
+}
+
+// ListNicknameHistory retrieves the nickname changes of a user, newest first
+func (r *NicknameRepository) ListNicknameHistory(ctx context.Context, userID int64) ([]domain.NicknameChange, error) {
+	query := `
+                SELECT id, user_id, old_nickname, new_nickname, changed_by, reserved_until, created_at
+                FROM nickname_history
+                WHERE user_id = $1
+                ORDER BY created_at DESC, id DESC`
+
+	var changes []domain.NicknameChange
+	err := conn(ctx, r.db).SelectContext(ctx, &changes, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// ListBlockedNicknames retrieves all blocked nicknames
+func (r *NicknameRepository) ListBlockedNicknames(ctx context.Context) ([]domain.BlockedNickname, error) {
+	query := `
+                SELECT nickname, reason, created_by, created_at
+                FROM blocked_nicknames
+                ORDER BY nickname`
+
+	var nicknames []domain.BlockedNickname
+	err := conn(ctx, r.db).SelectContext(ctx, &nicknames, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return nicknames, nil
+}
+
+// BlockNickname adds a nickname to the blocked list
+func (r *NicknameRepository) BlockNickname(ctx context.Context, nickname domain.BlockedNickname) error {
+	query := `
+                INSERT INTO blocked_nicknames (nickname, reason, created_by, created_at)
+                VALUES ($1, $2, $3, $4)`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, strings.ToLower(nickname.Nickname), nickname.Reason, nickname.CreatedBy, time.Now().UTC())
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ErrNicknameBlocked
+		}
+		return err
+	}
+
+	return nil
+}
+
+// UnblockNickname removes a nickname from the blocked list
+func (r *NicknameRepository) UnblockNickname(ctx context.Context, nickname string) error {
+	query := `DELETE FROM blocked_nicknames WHERE nickname = LOWER($1)`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, nickname)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrBlockedNicknameNotFound
+	}
+
+	return nil
+}
+
+// GetBlockedNickname retrieves a blocked nickname
+func (r *NicknameRepository) GetBlockedNickname(ctx context.Context, nickname string) (domain.BlockedNickname, error) {
+	query := `
+                SELECT nickname, reason, created_by, created_at
+                FROM blocked_nicknames
+                WHERE nickname = LOWER($1)`
+
+	var blocked domain.BlockedNickname
+	err := conn(ctx, r.db).GetContext(ctx, &blocked, query, nickname)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.BlockedNickname{}, domain.ErrBlockedNicknameNotFound
+		}
+		return domain.BlockedNickname{}, err
+	}
+
+	return blocked, nil
+}
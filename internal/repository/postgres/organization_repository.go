@@ -0,0 +1,308 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"authmicro/internal/domain"
+)
+
+// effectiveOrgRolesCTE expands the roles a user holds within organizations through the role hierarchy.
+// It expects the user ID as the first query parameter and yields (organization_id, role_id) rows.
+const effectiveOrgRolesCTE = `
+                WITH RECURSIVE effective_org_roles(organization_id, role_id) AS (
+                        SELECT mr.organization_id, mr.role_id
+                        FROM membership_roles mr
+                        WHERE mr.user_id = $1
+                        UNION
+                        SELECT eor.organization_id, rh.child_role_id
+                        FROM role_hierarchy rh
+                        JOIN effective_org_roles eor ON rh.parent_role_id = eor.role_id
+                )`
+
+type OrganizationRepository struct {
+	db *sqlx.DB
+}
+
+func NewOrganizationRepository(db *sqlx.DB) *OrganizationRepository {
+	return &OrganizationRepository{
+		db: db,
+	}
+}
+
+// CreateOrganization creates a new organization
+func (r *OrganizationRepository) CreateOrganization(ctx context.Context, name, slug string) (int64, error) {
+	query := `
+                INSERT INTO organizations (name, slug, created_at, updated_at)
+                VALUES ($1, $2, $3, $4)
+                RETURNING id`
+
+	var id int64
+	now := time.Now().UTC()
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, name, slug, now, now).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, domain.ErrOrgExists
+		}
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// GetOrganizationByID retrieves an organization by ID
+func (r *OrganizationRepository) GetOrganizationByID(ctx context.Context, id int64) (domain.Organization, error) {
+	query := `
+                SELECT id, name, slug, created_at, updated_at
+                FROM organizations
+                WHERE id = $1`
+
+	var org domain.Organization
+	err := conn(ctx, r.db).GetContext(ctx, &org, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.Organization{}, domain.ErrOrgNotFound
+		}
+		return domain.Organization{}, err
+	}
+
+	return org, nil
+}
+
+// ListOrganizations retrieves all organizations
+func (r *OrganizationRepository) ListOrganizations(ctx context.Context) ([]domain.Organization, error) {
+	query := `
+                SELECT id, name, slug, created_at, updated_at
+                FROM organizations
+                ORDER BY name`
+
+	var orgs []domain.Organization
+	err := conn(ctx, r.db).SelectContext(ctx, &orgs, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return orgs, nil
+}
+
+// DeleteOrganization deletes an organization together with its memberships
+func (r *OrganizationRepository) DeleteOrganization(ctx context.Context, id int64) error {
+	query := `DELETE FROM organizations WHERE id = $1`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrOrgNotFound
+	}
+
+	return nil
+}
+
+// AddMember adds a user to an organization
+func (r *OrganizationRepository) AddMember(ctx context.Context, orgID, userID int64) error {
+	query := `
+                INSERT INTO memberships (user_id, organization_id, created_at)
+                VALUES ($1, $2, $3)`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, userID, orgID, time.Now().UTC())
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ErrAlreadyMember
+		}
+		return err
+	}
+
+	return nil
+}
+
+// RemoveMember removes a user and their roles from an organization
+func (r *OrganizationRepository) RemoveMember(ctx context.Context, orgID, userID int64) error {
+	query := `
+                DELETE FROM memberships
+                WHERE user_id = $1 AND organization_id = $2`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, userID, orgID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrNotMember
+	}
+
+	return nil
+}
+
+// IsMember checks if a user is a member of an organization
+func (r *OrganizationRepository) IsMember(ctx context.Context, orgID, userID int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM memberships WHERE user_id = $1 AND organization_id = $2)`
+
+	var isMember bool
+	err := conn(ctx, r.db).GetContext(ctx, &isMember, query, userID, orgID)
+	return isMember, err
+}
+
+// ListMembers retrieves the members of an organization with their direct roles in it
+func (r *OrganizationRepository) ListMembers(ctx context.Context, orgID int64) ([]domain.OrganizationMember, error) {
+	query := `
+                SELECT u.id, u.email, u.nickname, m.created_at, r.name AS role_name
+                FROM memberships m
+                JOIN users u ON u.id = m.user_id
+                LEFT JOIN membership_roles mr ON mr.user_id = m.user_id AND mr.organization_id = m.organization_id
+                LEFT JOIN roles r ON r.id = mr.role_id
+                WHERE m.organization_id = $1
+                ORDER BY u.id, r.name`
+
+	var rows []struct {
+		UserID   int64          `db:"id"`
+		Email    string         `db:"email"`
+		Nickname string         `db:"nickname"`
+		JoinedAt time.Time      `db:"created_at"`
+		RoleName sql.NullString `db:"role_name"`
+	}
+	err := conn(ctx, r.db).SelectContext(ctx, &rows, query, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	var members []domain.OrganizationMember
+	for _, row := range rows {
+		if len(members) == 0 || members[len(members)-1].UserID != row.UserID {
+			members = append(members, domain.OrganizationMember{
+				UserID:   row.UserID,
+				Email:    row.Email,
+				Nickname: row.Nickname,
+				Roles:    []string{},
+				JoinedAt: row.JoinedAt,
+			})
+		}
+		if row.RoleName.Valid {
+			member := &members[len(members)-1]
+			member.Roles = append(member.Roles, row.RoleName.String)
+		}
+	}
+
+	return members, nil
+}
+
+// GetMemberIDs retrieves the IDs of all members of an organization
+func (r *OrganizationRepository) GetMemberIDs(ctx context.Context, orgID int64) ([]int64, error) {
+	query := `SELECT user_id FROM memberships WHERE organization_id = $1`
+
+	var userIDs []int64
+	err := conn(ctx, r.db).SelectContext(ctx, &userIDs, query, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+// GrantOrgRole grants a role to a member within an organization
+func (r *OrganizationRepository) GrantOrgRole(ctx context.Context, orgID, userID, roleID int64) error {
+	query := `
+                INSERT INTO membership_roles (user_id, organization_id, role_id, created_at)
+                VALUES ($1, $2, $3, $4)`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, userID, orgID, roleID, time.Now().UTC())
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ErrRoleAlreadyGranted
+		}
+		return err
+	}
+
+	return nil
+}
+
+// RevokeOrgRole revokes a role from a member within an organization
+func (r *OrganizationRepository) RevokeOrgRole(ctx context.Context, orgID, userID, roleID int64) error {
+	query := `
+                DELETE FROM membership_roles
+                WHERE user_id = $1 AND organization_id = $2 AND role_id = $3`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, userID, orgID, roleID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrRoleNotGranted
+	}
+
+	return nil
+}
+
+// GetUserMemberships retrieves the organizations of a user with their effective roles in each of them
+func (r *OrganizationRepository) GetUserMemberships(ctx context.Context, userID int64) ([]domain.Membership, error) {
+	query := effectiveOrgRolesCTE + `
+                SELECT m.organization_id, r.name AS role_name
+                FROM memberships m
+                LEFT JOIN effective_org_roles eor ON eor.organization_id = m.organization_id
+                LEFT JOIN roles r ON r.id = eor.role_id
+                WHERE m.user_id = $1
+                ORDER BY m.organization_id, r.name`
+
+	var rows []struct {
+		OrganizationID int64          `db:"organization_id"`
+		RoleName       sql.NullString `db:"role_name"`
+	}
+	err := conn(ctx, r.db).SelectContext(ctx, &rows, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var memberships []domain.Membership
+	for _, row := range rows {
+		if len(memberships) == 0 || memberships[len(memberships)-1].OrganizationID != row.OrganizationID {
+			memberships = append(memberships, domain.Membership{
+				OrganizationID: row.OrganizationID,
+				Roles:          []string{},
+			})
+		}
+		if row.RoleName.Valid {
+			membership := &memberships[len(memberships)-1]
+			membership.Roles = append(membership.Roles, row.RoleName.String)
+		}
+	}
+
+	return memberships, nil
+}
+
+// HasOrgRole checks if a user effectively holds a role within an organization
+func (r *OrganizationRepository) HasOrgRole(ctx context.Context, userID, orgID int64, roleName string) (bool, error) {
+	query := effectiveOrgRolesCTE + `
+                SELECT EXISTS (
+                        SELECT 1
+                        FROM effective_org_roles eor
+                        JOIN roles r ON r.id = eor.role_id
+                        WHERE eor.organization_id = $2 AND r.name = $3
+                )`
+
+	var hasRole bool
+	err := conn(ctx, r.db).GetContext(ctx, &hasRole, query, userID, orgID, roleName)
+	if err != nil {
+		return false, err
+	}
+
+	return hasRole, nil
+}
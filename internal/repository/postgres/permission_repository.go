@@ -0,0 +1,185 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"authmicro/internal/domain"
+)
+
+type PermissionRepository struct {
+	db *sqlx.DB
+}
+
+func NewPermissionRepository(db *sqlx.DB) *PermissionRepository {
+	return &PermissionRepository{
+		db: db,
+	}
+}
+
+// CreatePermission creates a new permission
+func (r *PermissionRepository) CreatePermission(ctx context.Context, name, description string) (int64, error) {
+	query := `
+                INSERT INTO permissions (name, description, created_at, updated_at)
+                VALUES ($1, $2, $3, $4)
+                RETURNING id`
+
+	var id int64
+	now := time.Now().UTC()
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, name, description, now, now).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// GetPermissionByName retrieves a permission by name
+func (r *PermissionRepository) GetPermissionByName(ctx context.Context, name string) (domain.Permission, error) {
+	query := `
+                SELECT id, name, description, created_at, updated_at
+                FROM permissions
+                WHERE name = $1`
+
+	var permission domain.Permission
+	err := conn(ctx, r.db).GetContext(ctx, &permission, query, name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.Permission{}, errors.New("permission not found")
+		}
+		return domain.Permission{}, err
+	}
+
+	return permission, nil
+}
+
+// ListPermissions retrieves all permissions
+func (r *PermissionRepository) ListPermissions(ctx context.Context) ([]domain.Permission, error) {
+	query := `
+                SELECT id, name, description, created_at, updated_at
+                FROM permissions
+                ORDER BY name`
+
+	var permissions []domain.Permission
+	err := conn(ctx, r.db).SelectContext(ctx, &permissions, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// GrantPermissionToRole grants a permission to a role
+func (r *PermissionRepository) GrantPermissionToRole(ctx context.Context, roleID, permissionID int64) error {
+	query := `
+                INSERT INTO role_permissions (role_id, permission_id, created_at)
+                VALUES ($1, $2, $3)
+                ON CONFLICT DO NOTHING`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, roleID, permissionID, time.Now().UTC())
+	return err
+}
+
+// RevokePermissionFromRole revokes a permission from a role
+func (r *PermissionRepository) RevokePermissionFromRole(ctx context.Context, roleID, permissionID int64) error {
+	query := `
+                DELETE FROM role_permissions
+                WHERE role_id = $1 AND permission_id = $2`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, roleID, permissionID)
+	return err
+}
+
+// GetRolePermissions retrieves all permissions granted to a role
+func (r *PermissionRepository) GetRolePermissions(ctx context.Context, roleID int64) ([]domain.Permission, error) {
+	query := `
+                SELECT p.id, p.name, p.description, p.created_at, p.updated_at
+                FROM permissions p
+                JOIN role_permissions rp ON p.id = rp.permission_id
+                WHERE rp.role_id = $1
+                ORDER BY p.name`
+
+	var permissions []domain.Permission
+	err := conn(ctx, r.db).SelectContext(ctx, &permissions, query, roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// GetUserPermissionNames retrieves the names of all permissions a user has through their effective roles
+func (r *PermissionRepository) GetUserPermissionNames(ctx context.Context, userID int64) ([]string, error) {
+	query := effectiveRolesCTE + `
+                SELECT DISTINCT p.name
+                FROM permissions p
+                JOIN role_permissions rp ON p.id = rp.permission_id
+                JOIN effective_roles er ON rp.role_id = er.role_id
+                ORDER BY p.name`
+
+	var permissionNames []string
+	err := conn(ctx, r.db).SelectContext(ctx, &permissionNames, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return permissionNames, nil
+}
+
+// GetUserGrants retrieves the effective role and permission names of a user in a single query
+func (r *PermissionRepository) GetUserGrants(ctx context.Context, userID int64) (domain.UserGrants, error) {
+	query := effectiveRolesCTE + `
+                SELECT 'role' AS kind, r.name
+                FROM roles r
+                JOIN effective_roles er ON r.id = er.role_id
+                UNION
+                SELECT 'permission' AS kind, p.name
+                FROM permissions p
+                JOIN role_permissions rp ON p.id = rp.permission_id
+                JOIN effective_roles er ON rp.role_id = er.role_id`
+
+	var rows []struct {
+		Kind string `db:"kind"`
+		Name string `db:"name"`
+	}
+	err := conn(ctx, r.db).SelectContext(ctx, &rows, query, userID)
+	if err != nil {
+		return domain.UserGrants{}, err
+	}
+
+	var grants domain.UserGrants
+	for _, row := range rows {
+		if row.Kind == "role" {
+			grants.Roles = append(grants.Roles, row.Name)
+		} else {
+			grants.Permissions = append(grants.Permissions, row.Name)
+		}
+	}
+
+	return grants, nil
+}
+
+// HasPermission checks if a user has a specific permission through any of their effective roles
+func (r *PermissionRepository) HasPermission(ctx context.Context, userID int64, permissionName string) (bool, error) {
+	query := effectiveRolesCTE + `
+                SELECT EXISTS (
+                        SELECT 1
+                        FROM effective_roles er
+                        JOIN role_permissions rp ON er.role_id = rp.role_id
+                        JOIN permissions p ON rp.permission_id = p.id
+                        WHERE p.name = $2
+                )`
+
+	var hasPermission bool
+	err := conn(ctx, r.db).GetContext(ctx, &hasPermission, query, userID, permissionName)
+	if err != nil {
+		return false, err
+	}
+
+	return hasPermission, nil
+}
@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"authmicro/internal/domain"
+)
+
+type PolicyRepository struct {
+	db *sqlx.DB
+}
+
+func NewPolicyRepository(db *sqlx.DB) *PolicyRepository {
+	return &PolicyRepository{
+		db: db,
+	}
+}
+
+// CreatePolicy creates a new policy
+func (r *PolicyRepository) CreatePolicy(ctx context.Context, policy domain.Policy) (int64, error) {
+	query := `
+                INSERT INTO policies (name, description, effect, action, resource_type, condition, enabled, created_at, updated_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+                RETURNING id`
+
+	var id int64
+	now := time.Now().UTC()
+
+	err := conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		policy.Name,
+		policy.Description,
+		policy.Effect,
+		policy.Action,
+		policy.ResourceType,
+		policy.Condition,
+		policy.Enabled,
+		now,
+		now,
+	).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, domain.ErrPolicyExists
+		}
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// GetPolicyByID retrieves a policy by ID
+func (r *PolicyRepository) GetPolicyByID(ctx context.Context, id int64) (domain.Policy, error) {
+	query := `
+                SELECT id, name, description, effect, action, resource_type, condition, enabled, created_at, updated_at
+                FROM policies
+                WHERE id = $1`
+
+	var policy domain.Policy
+	err := conn(ctx, r.db).GetContext(ctx, &policy, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.Policy{}, domain.ErrPolicyNotFound
+		}
+		return domain.Policy{}, err
+	}
+
+	return policy, nil
+}
+
+// ListPolicies retrieves all policies
+func (r *PolicyRepository) ListPolicies(ctx context.Context) ([]domain.Policy, error) {
+	query := `
+                SELECT id, name, description, effect, action, resource_type, condition, enabled, created_at, updated_at
+                FROM policies
+                ORDER BY id`
+
+	var policies []domain.Policy
+	err := conn(ctx, r.db).SelectContext(ctx, &policies, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+// ListEnabledPolicies retrieves all policies taking part in authorization decisions
+func (r *PolicyRepository) ListEnabledPolicies(ctx context.Context) ([]domain.Policy, error) {
+	query := `
+                SELECT id, name, description, effect, action, resource_type, condition, enabled, created_at, updated_at
+                FROM policies
+                WHERE enabled
+                ORDER BY id`
+
+	var policies []domain.Policy
+	err := conn(ctx, r.db).SelectContext(ctx, &policies, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+// UpdatePolicy replaces the definition of a policy
+func (r *PolicyRepository) UpdatePolicy(ctx context.Context, policy domain.Policy) error {
+	query := `
+                UPDATE policies
+                SET name = $1, description = $2, effect = $3, action = $4, resource_type = $5, condition = $6, enabled = $7, updated_at = $8
+                WHERE id = $9`
+
+	res, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		policy.Name,
+		policy.Description,
+		policy.Effect,
+		policy.Action,
+		policy.ResourceType,
+		policy.Condition,
+		policy.Enabled,
+		time.Now().UTC(),
+		policy.ID,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ErrPolicyExists
+		}
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrPolicyNotFound
+	}
+
+	return nil
+}
+
+// DeletePolicy deletes a policy
+func (r *PolicyRepository) DeletePolicy(ctx context.Context, id int64) error {
+	query := `DELETE FROM policies WHERE id = $1`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrPolicyNotFound
+	}
+
+	return nil
+}
@@ -3,14 +3,30 @@ package postgres
 import (
 	"context"
 	"database/sql"
-	"errors"
+	"fmt"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 
 	"authmicro/internal/domain"
+	"authmicro/pkg/scimfilter"
 )
 
+// effectiveRolesCTE selects the IDs of all roles a user ($1) holds directly or through the role hierarchy.
+// Expired grants are ignored. UNION (rather than UNION ALL) discards already visited roles,
+// so the recursion terminates even on cycles.
+const effectiveRolesCTE = `
+                WITH RECURSIVE effective_roles(role_id) AS (
+                        SELECT ur.role_id
+                        FROM user_roles ur
+                        WHERE ur.user_id = $1 AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+                        UNION
+                        SELECT rh.child_role_id
+                        FROM role_hierarchy rh
+                        JOIN effective_roles er ON rh.parent_role_id = er.role_id
+                )`
+
 type RoleRepository struct {
 	db *sqlx.DB
 }
@@ -31,8 +47,11 @@ func (r *RoleRepository) CreateRole(ctx context.Context, name string) (int64, er
 	var id int64
 	now := time.Now().UTC()
 
-	err := r.db.QueryRowContext(ctx, query, name, now, now).Scan(&id)
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, name, now, now).Scan(&id)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, domain.ErrRoleExists
+		}
 		return 0, err
 	}
 
@@ -47,10 +66,10 @@ func (r *RoleRepository) GetRoleByName(ctx context.Context, name string) (domain
                 WHERE name = $1`
 
 	var role domain.Role
-	err := r.db.GetContext(ctx, &role, query, name)
+	err := conn(ctx, r.db).GetContext(ctx, &role, query, name)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return domain.Role{}, errors.New("role not found")
+			return domain.Role{}, domain.ErrRoleNotFound
 		}
 		return domain.Role{}, err
 	}
@@ -66,10 +85,10 @@ func (r *RoleRepository) GetRoleByID(ctx context.Context, id int64) (domain.Role
                 WHERE id = $1`
 
 	var role domain.Role
-	err := r.db.GetContext(ctx, &role, query, id)
+	err := conn(ctx, r.db).GetContext(ctx, &role, query, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return domain.Role{}, errors.New("role not found")
+			return domain.Role{}, domain.ErrRoleNotFound
 		}
 		return domain.Role{}, err
 	}
@@ -83,20 +102,23 @@ func (r *RoleRepository) AssignRoleToUser(ctx context.Context, userID, roleID in
                 INSERT INTO user_roles (user_id, role_id, created_at)
                 VALUES ($1, $2, $3)`
 
-	_, err := r.db.ExecContext(ctx, query, userID, roleID, time.Now().UTC())
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, userID, roleID, time.Now().UTC())
+	if isUniqueViolation(err) {
+		return domain.ErrRoleAlreadyGranted
+	}
 	return err
 }
 
-// GetUserRoles retrieves all roles assigned to a user
+// GetUserRoles retrieves all roles assigned directly to a user, ignoring expired grants
 func (r *RoleRepository) GetUserRoles(ctx context.Context, userID int64) ([]domain.Role, error) {
 	query := `
                 SELECT r.id, r.name, r.created_at, r.updated_at
                 FROM roles r
                 JOIN user_roles ur ON r.id = ur.role_id
-                WHERE ur.user_id = $1`
+                WHERE ur.user_id = $1 AND (ur.expires_at IS NULL OR ur.expires_at > NOW())`
 
 	var roles []domain.Role
-	err := r.db.SelectContext(ctx, &roles, query, userID)
+	err := conn(ctx, r.db).SelectContext(ctx, &roles, query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -104,16 +126,51 @@ func (r *RoleRepository) GetUserRoles(ctx context.Context, userID int64) ([]doma
 	return roles, nil
 }
 
-// GetUserRoleNames retrieves all role names assigned to a user
-func (r *RoleRepository) GetUserRoleNames(ctx context.Context, userID int64) ([]string, error) {
+// GetUserRoleGrants retrieves the active roles assigned directly to a user together with the grant details
+func (r *RoleRepository) GetUserRoleGrants(ctx context.Context, userID int64) ([]domain.RoleGrant, error) {
 	query := `
-                SELECT r.name
+                SELECT r.id, r.name, r.created_at, r.updated_at, ur.expires_at, ur.granted_by, ur.created_at AS granted_at
                 FROM roles r
                 JOIN user_roles ur ON r.id = ur.role_id
-                WHERE ur.user_id = $1`
+                WHERE ur.user_id = $1 AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+                ORDER BY r.name`
+
+	var grants []domain.RoleGrant
+	err := conn(ctx, r.db).SelectContext(ctx, &grants, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return grants, nil
+}
+
+// GetEarliestRoleExpiry retrieves the earliest expiry among a user's active time-bound role grants.
+// It returns nil if the user has no such grants.
+func (r *RoleRepository) GetEarliestRoleExpiry(ctx context.Context, userID int64) (*time.Time, error) {
+	query := `
+                SELECT MIN(expires_at)
+                FROM user_roles
+                WHERE user_id = $1 AND expires_at > NOW()`
+
+	var expiresAt *time.Time
+	err := conn(ctx, r.db).GetContext(ctx, &expiresAt, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return expiresAt, nil
+}
+
+// GetUserRoleNames retrieves the names of all roles a user holds, including roles inherited through the hierarchy
+func (r *RoleRepository) GetUserRoleNames(ctx context.Context, userID int64) ([]string, error) {
+	query := effectiveRolesCTE + `
+                SELECT r.name
+                FROM roles r
+                JOIN effective_roles er ON r.id = er.role_id
+                ORDER BY r.name`
 
 	var roleNames []string
-	err := r.db.SelectContext(ctx, &roleNames, query, userID)
+	err := conn(ctx, r.db).SelectContext(ctx, &roleNames, query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -121,31 +178,406 @@ func (r *RoleRepository) GetUserRoleNames(ctx context.Context, userID int64) ([]
 	return roleNames, nil
 }
 
+// GrantRoleToUser assigns a role to a user on behalf of another user, optionally until the given time.
+// An expired grant of the same role is replaced.
+func (r *RoleRepository) GrantRoleToUser(ctx context.Context, userID, roleID, grantedBy int64, expiresAt *time.Time) error {
+	query := `
+                INSERT INTO user_roles (user_id, role_id, expires_at, granted_by, created_at)
+                VALUES ($1, $2, $3, $4, $5)
+                ON CONFLICT (user_id, role_id) DO UPDATE
+                SET expires_at = EXCLUDED.expires_at, granted_by = EXCLUDED.granted_by, created_at = EXCLUDED.created_at
+                WHERE user_roles.expires_at IS NOT NULL AND user_roles.expires_at <= EXCLUDED.created_at`
+
+	var grantor *int64
+	if grantedBy != 0 {
+		grantor = &grantedBy
+	}
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, userID, roleID, expiresAt, grantor, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrRoleAlreadyGranted
+	}
+
+	return nil
+}
+
+// DeleteExpiredRoleGrants deletes all expired role grants and returns them
+func (r *RoleRepository) DeleteExpiredRoleGrants(ctx context.Context) ([]domain.UserRole, error) {
+	query := `
+                DELETE FROM user_roles
+                WHERE expires_at IS NOT NULL AND expires_at <= NOW()
+                RETURNING id, user_id, role_id, expires_at, granted_by, created_at`
+
+	var grants []domain.UserRole
+	err := conn(ctx, r.db).SelectContext(ctx, &grants, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return grants, nil
+}
+
 // RemoveRoleFromUser removes a role from a user
 func (r *RoleRepository) RemoveRoleFromUser(ctx context.Context, userID, roleID int64) error {
 	query := `
                 DELETE FROM user_roles
                 WHERE user_id = $1 AND role_id = $2`
 
-	_, err := r.db.ExecContext(ctx, query, userID, roleID)
-	return err
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, userID, roleID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrRoleNotGranted
+	}
+
+	return nil
 }
 
-// HasRole checks if a user has a specific role
+// HasRole checks if a user has a specific role, directly or through the role hierarchy
 func (r *RoleRepository) HasRole(ctx context.Context, userID int64, roleName string) (bool, error) {
-	query := `
+	query := effectiveRolesCTE + `
                 SELECT EXISTS (
                         SELECT 1
-                        FROM user_roles ur
-                        JOIN roles r ON ur.role_id = r.id
-                        WHERE ur.user_id = $1 AND r.name = $2
+                        FROM effective_roles er
+                        JOIN roles r ON er.role_id = r.id
+                        WHERE r.name = $2
                 )`
 
 	var hasRole bool
-	err := r.db.GetContext(ctx, &hasRole, query, userID, roleName)
+	err := conn(ctx, r.db).GetContext(ctx, &hasRole, query, userID, roleName)
 	if err != nil {
 		return false, err
 	}
 
 	return hasRole, nil
 }
+
+// ListRoles retrieves all roles
+func (r *RoleRepository) ListRoles(ctx context.Context) ([]domain.Role, error) {
+	query := `
+                SELECT id, name, created_at, updated_at
+                FROM roles
+                ORDER BY name`
+
+	var roles []domain.Role
+	err := conn(ctx, r.db).SelectContext(ctx, &roles, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// RenameRole changes the name of a role
+func (r *RoleRepository) RenameRole(ctx context.Context, id int64, name string) error {
+	query := `
+                UPDATE roles
+                SET name = $1, updated_at = $2
+                WHERE id = $3`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, name, time.Now().UTC(), id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ErrRoleExists
+		}
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrRoleNotFound
+	}
+
+	return nil
+}
+
+// DeleteRole deletes a role together with all its assignments
+func (r *RoleRepository) DeleteRole(ctx context.Context, id int64) error {
+	query := `DELETE FROM roles WHERE id = $1`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrRoleNotFound
+	}
+
+	return nil
+}
+
+// GetUserIDsWithEffectiveRole retrieves the IDs of all users whose effective roles include the role,
+// i.e. users granted the role itself or any role above it in the hierarchy, globally or within an organization
+func (r *RoleRepository) GetUserIDsWithEffectiveRole(ctx context.Context, roleID int64) ([]int64, error) {
+	query := `
+                WITH RECURSIVE ancestor_roles(role_id) AS (
+                        SELECT $1::INTEGER
+                        UNION
+                        SELECT rh.parent_role_id
+                        FROM role_hierarchy rh
+                        JOIN ancestor_roles ar ON rh.child_role_id = ar.role_id
+                )
+                SELECT ur.user_id
+                FROM user_roles ur
+                JOIN ancestor_roles ar ON ur.role_id = ar.role_id
+                WHERE ur.expires_at IS NULL OR ur.expires_at > NOW()
+                UNION
+                SELECT mr.user_id
+                FROM membership_roles mr
+                JOIN ancestor_roles ar ON mr.role_id = ar.role_id`
+
+	var userIDs []int64
+	err := conn(ctx, r.db).SelectContext(ctx, &userIDs, query, roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+// GetUsersByRole retrieves all users that have a role assigned
+func (r *RoleRepository) GetUsersByRole(ctx context.Context, roleID int64) ([]domain.User, error) {
+	query := `
+                SELECT u.id, u.first_name, u.last_name, u.nickname, u.email, u.email_verified, u.accepted_privacy_policy, u.institution_id, u.review_reason, u.deletion_scheduled_at, u.status, u.suspended_until, u.status_reason, u.status_changed_by, u.status_changed_at, u.external_id, u.created_at, u.updated_at
+                FROM users u
+                JOIN user_roles ur ON u.id = ur.user_id
+                WHERE ur.role_id = $1 AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+                ORDER BY u.id`
+
+	var users []domain.User
+	err := conn(ctx, r.db).SelectContext(ctx, &users, query, roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetRolesByUsers retrieves the active roles granted directly to each of the users
+func (r *RoleRepository) GetRolesByUsers(ctx context.Context, userIDs []int64) (map[int64][]domain.Role, error) {
+	query := `
+                SELECT ur.user_id, r.id, r.name, r.created_at, r.updated_at
+                FROM user_roles ur
+                JOIN roles r ON r.id = ur.role_id
+                WHERE ur.user_id = ANY($1) AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+                ORDER BY ur.user_id, r.name`
+
+	var rows []struct {
+		UserID int64 `db:"user_id"`
+		domain.Role
+	}
+	err := conn(ctx, r.db).SelectContext(ctx, &rows, query, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make(map[int64][]domain.Role, len(userIDs))
+	for _, row := range rows {
+		roles[row.UserID] = append(roles[row.UserID], row.Role)
+	}
+
+	return roles, nil
+}
+
+// SearchRoles retrieves a page of the roles with the given names matching the SCIM filter, ordered by ID, along with
+// the number of matching roles. A nil filter matches all of them.
+func (r *RoleRepository) SearchRoles(ctx context.Context, filter scimfilter.Expr, names []string, offset, limit int) ([]domain.Role, int, error) {
+	where, args, err := scimWhere(filter, scimGroupAttributes)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, pq.Array(names))
+	where = fmt.Sprintf("name = ANY($%d) AND %s", len(args), where)
+
+	var total int
+	err = conn(ctx, r.db).GetContext(ctx, &total, `SELECT COUNT(*) FROM roles WHERE `+where, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+                SELECT id, name, created_at, updated_at
+                FROM roles
+                WHERE ` + where + fmt.Sprintf(`
+                ORDER BY id
+                OFFSET %d LIMIT %d`, offset, limit)
+
+	var roles []domain.Role
+	err = conn(ctx, r.db).SelectContext(ctx, &roles, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return roles, total, nil
+}
+
+// RoleImplies reports whether holding a role implies holding the named role through the role hierarchy,
+// including the role itself
+func (r *RoleRepository) RoleImplies(ctx context.Context, roleID int64, name string) (bool, error) {
+	query := `
+                WITH RECURSIVE descendants(role_id) AS (
+                        SELECT $1::INTEGER
+                        UNION
+                        SELECT rh.child_role_id
+                        FROM role_hierarchy rh
+                        JOIN descendants d ON rh.parent_role_id = d.role_id
+                )
+                SELECT EXISTS (
+                        SELECT 1
+                        FROM descendants d
+                        JOIN roles r ON r.id = d.role_id
+                        WHERE r.name = $2
+                )`
+
+	var implies bool
+	err := conn(ctx, r.db).GetContext(ctx, &implies, query, roleID, name)
+	if err != nil {
+		return false, err
+	}
+
+	return implies, nil
+}
+
+// CreateRoleChange records an entry in the role change history
+func (r *RoleRepository) CreateRoleChange(ctx context.Context, change domain.RoleChange) error {
+	query := `
+                INSERT INTO role_change_history (action, role_id, role_name, user_id, actor_id, details, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		change.Action,
+		change.RoleID,
+		change.RoleName,
+		change.UserID,
+		change.ActorID,
+		change.Details,
+		time.Now().UTC(),
+	)
+	return err
+}
+
+// ListRoleChanges retrieves the most recent entries of the role change history
+func (r *RoleRepository) ListRoleChanges(ctx context.Context, limit int) ([]domain.RoleChange, error) {
+	query := `
+                SELECT id, action, role_id, role_name, user_id, actor_id, details, created_at
+                FROM role_change_history
+                ORDER BY id DESC
+                LIMIT $1`
+
+	var changes []domain.RoleChange
+	err := conn(ctx, r.db).SelectContext(ctx, &changes, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// AddRoleChild makes the child role implied by the parent role, rejecting changes that would create a cycle.
+// It joins the unit of work running in ctx, if any.
+func (r *RoleRepository) AddRoleChild(ctx context.Context, parentID, childID int64) error {
+	if parentID == childID {
+		return domain.ErrRoleCycle
+	}
+
+	return NewUnitOfWork(r.db).Do(ctx, func(ctx context.Context) error {
+		tx := conn(ctx, r.db)
+
+		// Serialize hierarchy changes so that concurrent inserts cannot form a cycle together
+		if _, err := tx.ExecContext(ctx, `LOCK TABLE role_hierarchy IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+			return err
+		}
+
+		// The new edge creates a cycle if the parent is already reachable from the child
+		cycleQuery := `
+                WITH RECURSIVE descendants(role_id) AS (
+                        SELECT $1::INTEGER
+                        UNION
+                        SELECT rh.child_role_id
+                        FROM role_hierarchy rh
+                        JOIN descendants d ON rh.parent_role_id = d.role_id
+                )
+                SELECT EXISTS (SELECT 1 FROM descendants WHERE role_id = $2)`
+
+		var cycle bool
+		if err := tx.GetContext(ctx, &cycle, cycleQuery, childID, parentID); err != nil {
+			return err
+		}
+		if cycle {
+			return domain.ErrRoleCycle
+		}
+
+		insertQuery := `
+                INSERT INTO role_hierarchy (parent_role_id, child_role_id, created_at)
+                VALUES ($1, $2, $3)
+                ON CONFLICT DO NOTHING`
+
+		_, err := tx.ExecContext(ctx, insertQuery, parentID, childID, time.Now().UTC())
+		return err
+	})
+}
+
+// RemoveRoleChild removes a parent-child relationship between roles
+func (r *RoleRepository) RemoveRoleChild(ctx context.Context, parentID, childID int64) error {
+	query := `
+                DELETE FROM role_hierarchy
+                WHERE parent_role_id = $1 AND child_role_id = $2`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, parentID, childID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrRoleChildNotFound
+	}
+
+	return nil
+}
+
+// GetRoleChildren retrieves the roles directly implied by a role
+func (r *RoleRepository) GetRoleChildren(ctx context.Context, roleID int64) ([]domain.Role, error) {
+	query := `
+                SELECT r.id, r.name, r.created_at, r.updated_at
+                FROM roles r
+                JOIN role_hierarchy rh ON r.id = rh.child_role_id
+                WHERE rh.parent_role_id = $1
+                ORDER BY r.name`
+
+	var roles []domain.Role
+	err := conn(ctx, r.db).SelectContext(ctx, &roles, query, roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"authmicro/internal/domain"
+)
+
+type SCIMClientRepository struct {
+	db *sqlx.DB
+}
+
+func NewSCIMClientRepository(db *sqlx.DB) *SCIMClientRepository {
+	return &SCIMClientRepository{
+		db: db,
+	}
+}
+
+// Create registers a provisioning client and returns it
+func (r *SCIMClientRepository) Create(ctx context.Context, client domain.SCIMClient) (domain.SCIMClient, error) {
+	query := `
+                INSERT INTO scim_clients (name, token_hash, created_by, created_at)
+                VALUES ($1, $2, $3, $4)
+                RETURNING id, name, token_hash, created_by, created_at, last_used_at`
+
+	var created domain.SCIMClient
+	err := conn(ctx, r.db).GetContext(ctx, &created, query, client.Name, client.TokenHash, client.CreatedBy, time.Now().UTC())
+	if err != nil {
+		return domain.SCIMClient{}, err
+	}
+
+	return created, nil
+}
+
+// List retrieves all provisioning clients, oldest first
+func (r *SCIMClientRepository) List(ctx context.Context) ([]domain.SCIMClient, error) {
+	query := `
+                SELECT id, name, token_hash, created_by, created_at, last_used_at
+                FROM scim_clients
+                ORDER BY id`
+
+	var clients []domain.SCIMClient
+	err := conn(ctx, r.db).SelectContext(ctx, &clients, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+// GetByTokenHash retrieves the provisioning client a bearer token belongs to
+func (r *SCIMClientRepository) GetByTokenHash(ctx context.Context, tokenHash string) (domain.SCIMClient, error) {
+	query := `
+                SELECT id, name, token_hash, created_by, created_at, last_used_at
+                FROM scim_clients
+                WHERE token_hash = $1`
+
+	var client domain.SCIMClient
+	err := conn(ctx, r.db).GetContext(ctx, &client, query, tokenHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.SCIMClient{}, domain.ErrSCIMClientNotFound
+		}
+		return domain.SCIMClient{}, err
+	}
+
+	return client, nil
+}
+
+// Touch records that a provisioning client called the SCIM API
+func (r *SCIMClientRepository) Touch(ctx context.Context, id int64, at time.Time) error {
+	query := `
+                UPDATE scim_clients
+                SET last_used_at = $1
+                WHERE id = $2`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, at, id)
+	return err
+}
+
+// Delete deletes a provisioning client, revoking its token
+func (r *SCIMClientRepository) Delete(ctx context.Context, id int64) error {
+	query := `DELETE FROM scim_clients WHERE id = $1`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrSCIMClientNotFound
+	}
+
+	return nil
+}
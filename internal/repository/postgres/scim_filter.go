@@ -0,0 +1,181 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/scimfilter"
+)
+
+// scimAttributeKinds defines how the values of SCIM attributes are compared
+var scimAttributeKinds = struct {
+	String    string // compared case-insensitively
+	CaseExact string
+	Boolean   string
+	DateTime  string
+}{
+	String:    "string",
+	CaseExact: "caseExact",
+	Boolean:   "boolean",
+	DateTime:  "dateTime",
+}
+
+// scimAttribute is an attribute SCIM filters may refer to, with the SQL expression it is stored in
+type scimAttribute struct {
+	column string
+	kind   string
+}
+
+// scimUserAttributes maps the filterable attributes of SCIM users, lowercased, to the columns of users.
+// Users are active unless banned.
+var scimUserAttributes = map[string]scimAttribute{
+	"id":                {"id::text", scimAttributeKinds.CaseExact},
+	"externalid":        {"external_id", scimAttributeKinds.CaseExact},
+	"username":          {"email", scimAttributeKinds.String},
+	"emails":            {"email", scimAttributeKinds.String},
+	"emails.value":      {"email", scimAttributeKinds.String},
+	"nickname":          {"nickname", scimAttributeKinds.String},
+	"name.givenname":    {"first_name", scimAttributeKinds.String},
+	"name.familyname":   {"last_name", scimAttributeKinds.String},
+	"active":            {"(status <> 'banned')", scimAttributeKinds.Boolean},
+	"meta.created":      {"created_at", scimAttributeKinds.DateTime},
+	"meta.lastmodified": {"updated_at", scimAttributeKinds.DateTime},
+}
+
+// scimGroupAttributes maps the filterable attributes of SCIM groups, lowercased, to the columns of roles
+var scimGroupAttributes = map[string]scimAttribute{
+	"id":                {"id::text", scimAttributeKinds.CaseExact},
+	"displayname":       {"name", scimAttributeKinds.String},
+	"meta.created":      {"created_at", scimAttributeKinds.DateTime},
+	"meta.lastmodified": {"updated_at", scimAttributeKinds.DateTime},
+}
+
+// scimComparisons maps the SCIM ordering operators to SQL
+var scimComparisons = map[string]string{
+	"eq": "=", "ne": "<>", "gt": ">", "ge": ">=", "lt": "<", "le": "<=",
+}
+
+// scimWhere translates a SCIM filter into an SQL condition on the attributes, numbering its arguments from $1.
+// A nil filter matches everything. Filters on unknown attributes or with mistyped values fail
+// with domain.ErrInvalidSCIMFilter.
+func scimWhere(filter scimfilter.Expr, attributes map[string]scimAttribute) (string, []interface{}, error) {
+	if filter == nil {
+		return "TRUE", nil, nil
+	}
+
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	where, err := scimCondition(filter, attributes, arg)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return where, args, nil
+}
+
+func scimCondition(expr scimfilter.Expr, attributes map[string]scimAttribute, arg func(interface{}) string) (string, error) {
+	switch expr := expr.(type) {
+	case *scimfilter.Logical:
+		left, err := scimCondition(expr.Left, attributes, arg)
+		if err != nil {
+			return "", err
+		}
+		right, err := scimCondition(expr.Right, attributes, arg)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(%s %s %s)", left, strings.ToUpper(expr.Op), right), nil
+
+	case *scimfilter.Not:
+		condition, err := scimCondition(expr.Expr, attributes, arg)
+		if err != nil {
+			return "", err
+		}
+		// Comparisons with NULL columns are unknown rather than false, which NOT would leave unknown
+		return fmt.Sprintf("NOT COALESCE(%s, FALSE)", condition), nil
+
+	case *scimfilter.Comparison:
+		return scimComparison(expr, attributes, arg)
+	}
+
+	return "", fmt.Errorf("%w: unsupported expression", domain.ErrInvalidSCIMFilter)
+}
+
+func scimComparison(c *scimfilter.Comparison, attributes map[string]scimAttribute, arg func(interface{}) string) (string, error) {
+	attribute, ok := attributes[strings.ToLower(c.Attr)]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown attribute %q", domain.ErrInvalidSCIMFilter, c.Attr)
+	}
+	column := attribute.column
+
+	if c.Op == "pr" {
+		if attribute.kind == scimAttributeKinds.String || attribute.kind == scimAttributeKinds.CaseExact {
+			return fmt.Sprintf("(%s IS NOT NULL AND %s <> '')", column, column), nil
+		}
+		return column + " IS NOT NULL", nil
+	}
+
+	if c.Value == nil {
+		switch c.Op {
+		case "eq":
+			return column + " IS NULL", nil
+		case "ne":
+			return column + " IS NOT NULL", nil
+		}
+		return "", fmt.Errorf("%w: null can only be compared with eq and ne", domain.ErrInvalidSCIMFilter)
+	}
+
+	switch attribute.kind {
+	case scimAttributeKinds.String, scimAttributeKinds.CaseExact:
+		value, ok := c.Value.(string)
+		if !ok {
+			return "", fmt.Errorf("%w: %s takes a string", domain.ErrInvalidSCIMFilter, c.Attr)
+		}
+		if attribute.kind == scimAttributeKinds.String {
+			column, value = "LOWER("+column+")", strings.ToLower(value)
+		}
+
+		switch c.Op {
+		case "co":
+			return column + " LIKE " + arg("%"+likeEscape(value)+"%"), nil
+		case "sw":
+			return column + " LIKE " + arg(likeEscape(value)+"%"), nil
+		case "ew":
+			return column + " LIKE " + arg("%"+likeEscape(value)), nil
+		}
+		return column + " " + scimComparisons[c.Op] + " " + arg(value), nil
+
+	case scimAttributeKinds.Boolean:
+		value, ok := c.Value.(bool)
+		if !ok {
+			return "", fmt.Errorf("%w: %s takes a boolean", domain.ErrInvalidSCIMFilter, c.Attr)
+		}
+		if c.Op != "eq" && c.Op != "ne" {
+			return "", fmt.Errorf("%w: %s can only be compared with eq and ne", domain.ErrInvalidSCIMFilter, c.Attr)
+		}
+		return column + " " + scimComparisons[c.Op] + " " + arg(value), nil
+
+	case scimAttributeKinds.DateTime:
+		text, ok := c.Value.(string)
+		if !ok {
+			return "", fmt.Errorf("%w: %s takes a date and time", domain.ErrInvalidSCIMFilter, c.Attr)
+		}
+		value, err := time.Parse(time.RFC3339, text)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s takes a date and time", domain.ErrInvalidSCIMFilter, c.Attr)
+		}
+		op, ok := scimComparisons[c.Op]
+		if !ok {
+			return "", fmt.Errorf("%w: %s cannot be compared with %s", domain.ErrInvalidSCIMFilter, c.Attr, c.Op)
+		}
+		return column + " " + op + " " + arg(value.UTC()), nil
+	}
+
+	return "", fmt.Errorf("%w: unsupported attribute %q", domain.ErrInvalidSCIMFilter, c.Attr)
+}
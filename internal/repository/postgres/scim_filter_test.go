@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/scimfilter"
+)
+
+func TestSCIMWhere(t *testing.T) {
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		filter    string
+		want      string
+		wantArgs  []interface{}
+		forGroups bool
+	}{
+		// Strings are compared case-insensitively, case-exact attributes as is
+		{filter: `userName eq "Alice@Example.com"`, want: "LOWER(email) = $1", wantArgs: []interface{}{"alice@example.com"}},
+		{filter: `EMAILS.VALUE ne "a@b.c"`, want: "LOWER(email) <> $1", wantArgs: []interface{}{"a@b.c"}},
+		{filter: `externalId eq "AbC"`, want: "external_id = $1", wantArgs: []interface{}{"AbC"}},
+		{filter: `id gt "5"`, want: "id::text > $1", wantArgs: []interface{}{"5"}},
+		{filter: `urn:ietf:params:scim:schemas:core:2.0:User:name.givenName eq "Al"`, want: "LOWER(first_name) = $1", wantArgs: []interface{}{"al"}},
+
+		// Substring operators escape LIKE wildcards
+		{filter: `nickName co "50%_off"`, want: "LOWER(nickname) LIKE $1", wantArgs: []interface{}{`%50\%\_off%`}},
+		{filter: `name.familyName sw "O'"`, want: "LOWER(last_name) LIKE $1", wantArgs: []interface{}{"o'%"}},
+		{filter: `externalId ew "\\x"`, want: "external_id LIKE $1", wantArgs: []interface{}{`%\\x`}},
+
+		// Booleans and dates
+		{filter: `active eq true`, want: "(status <> 'banned') = $1", wantArgs: []interface{}{true}},
+		{filter: `active ne false`, want: "(status <> 'banned') <> $1", wantArgs: []interface{}{false}},
+		{filter: `meta.created ge "2024-05-01T13:00:00+03:00"`, want: "created_at >= $1", wantArgs: []interface{}{created}},
+		{filter: `meta.lastModified lt "2024-05-01T10:00:00Z"`, want: "updated_at < $1", wantArgs: []interface{}{created}},
+
+		// Presence and null
+		{filter: `externalId pr`, want: "(external_id IS NOT NULL AND external_id <> '')"},
+		{filter: `active pr`, want: "(status <> 'banned') IS NOT NULL"},
+		{filter: `meta.created pr`, want: "created_at IS NOT NULL"},
+		{filter: `externalId eq null`, want: "external_id IS NULL"},
+		{filter: `nickName ne null`, want: "nickname IS NOT NULL"},
+
+		// Logical operators keep the parser's precedence and number arguments in order
+		{
+			filter:   `userName sw "a" or nickName eq "b" and active eq true`,
+			want:     "(LOWER(email) LIKE $1 OR (LOWER(nickname) = $2 AND (status <> 'banned') = $3))",
+			wantArgs: []interface{}{"a%", "b", true},
+		},
+		{
+			filter:   `(userName sw "a" or nickName eq "b") and active eq true`,
+			want:     "((LOWER(email) LIKE $1 OR LOWER(nickname) = $2) AND (status <> 'banned') = $3)",
+			wantArgs: []interface{}{"a%", "b", true},
+		},
+		{
+			filter:   `not (nickName eq "x")`,
+			want:     "NOT COALESCE(LOWER(nickname) = $1, FALSE)",
+			wantArgs: []interface{}{"x"},
+		},
+		{
+			filter:   `active eq true and not (externalId pr or userName ew "@test")`,
+			want:     "((status <> 'banned') = $1 AND NOT COALESCE(((external_id IS NOT NULL AND external_id <> '') OR LOWER(email) LIKE $2), FALSE))",
+			wantArgs: []interface{}{true, "%@test"},
+		},
+
+		// Groups
+		{filter: `displayName eq "Editors"`, want: "LOWER(name) = $1", wantArgs: []interface{}{"editors"}, forGroups: true},
+		{filter: `id eq "3" or displayName pr`, want: "(id::text = $1 OR (name IS NOT NULL AND name <> ''))", wantArgs: []interface{}{"3"}, forGroups: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			expr, err := scimfilter.Parse(tt.filter)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.filter, err)
+			}
+
+			attributes := scimUserAttributes
+			if tt.forGroups {
+				attributes = scimGroupAttributes
+			}
+
+			where, args, err := scimWhere(expr, attributes)
+			if err != nil {
+				t.Fatalf("scimWhere(%q) error = %v", tt.filter, err)
+			}
+			if where != tt.want {
+				t.Errorf("scimWhere(%q) = %s, want %s", tt.filter, where, tt.want)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("scimWhere(%q) args = %#v, want %#v", tt.filter, args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestSCIMWhereNil(t *testing.T) {
+	where, args, err := scimWhere(nil, scimUserAttributes)
+	if err != nil || where != "TRUE" || args != nil {
+		t.Errorf("scimWhere(nil) = %q, %v, %v, want TRUE", where, args, err)
+	}
+}
+
+func TestSCIMWhereErrors(t *testing.T) {
+	tests := []struct {
+		filter    string
+		forGroups bool
+	}{
+		// Unknown attributes
+		{filter: `password eq "x"`},
+		{filter: `name eq "x"`},
+		{filter: `emails.type eq "work"`},
+		{filter: `userName pr and title pr`},
+		{filter: `not (userName eq "a" or unknown pr)`},
+		{filter: `userName eq "x"`, forGroups: true},
+		{filter: `members eq "1"`, forGroups: true},
+
+		// Mistyped values
+		{filter: `userName eq 1`},
+		{filter: `userName eq true`},
+		{filter: `active eq "true"`},
+		{filter: `active gt true`},
+		{filter: `active co true`},
+		{filter: `meta.created gt 5`},
+		{filter: `meta.created gt "yesterday"`},
+		{filter: `meta.created co "2024"`},
+
+		// null only works with eq and ne
+		{filter: `externalId gt null`},
+		{filter: `nickName co null`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			expr, err := scimfilter.Parse(tt.filter)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.filter, err)
+			}
+
+			attributes := scimUserAttributes
+			if tt.forGroups {
+				attributes = scimGroupAttributes
+			}
+
+			_, _, err = scimWhere(expr, attributes)
+			if !errors.Is(err, domain.ErrInvalidSCIMFilter) {
+				t.Errorf("scimWhere(%q) error = %v, want ErrInvalidSCIMFilter", tt.filter, err)
+			}
+		})
+	}
+}
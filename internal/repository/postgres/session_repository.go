@@ -25,14 +25,14 @@ func NewSessionRepository(db *sqlx.DB) *SessionRepository {
 // CreateRegistrationSession creates a new registration session
 func (r *SessionRepository) CreateRegistrationSession(ctx context.Context, session domain.RegistrationSession) (string, error) {
 	query := `
-                INSERT INTO registration_sessions (id, first_name, last_name, nickname, email, accepted_privacy_policy, code, code_expires, created_at)
-                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+                INSERT INTO registration_sessions (id, first_name, last_name, nickname, email, accepted_privacy_policy, code, code_expires, review_reason, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
                 RETURNING id`
 
 	id := uuid.New().String()
 	now := time.Now().UTC()
 
-	err := r.db.QueryRowContext(
+	err := conn(ctx, r.db).QueryRowContext(
 		ctx,
 		query,
 		id,
@@ -43,6 +43,7 @@ func (r *SessionRepository) CreateRegistrationSession(ctx context.Context, sessi
 		session.AcceptedPrivacyPolicy,
 		session.Code,
 		session.CodeExpires,
+		session.ReviewReason,
 		now,
 	).Scan(&id)
 
@@ -56,12 +57,12 @@ func (r *SessionRepository) CreateRegistrationSession(ctx context.Context, sessi
 // GetRegistrationSession retrieves a registration session by ID
 func (r *SessionRepository) GetRegistrationSession(ctx context.Context, id string) (domain.RegistrationSession, error) {
 	query := `
-                SELECT id, first_name, last_name, nickname, email, accepted_privacy_policy, code, code_expires, created_at
+                SELECT id, first_name, last_name, nickname, email, accepted_privacy_policy, code, code_expires, review_reason, created_at
                 FROM registration_sessions
                 WHERE id = $1`
 
 	var session domain.RegistrationSession
-	err := r.db.GetContext(ctx, &session, query, id)
+	err := conn(ctx, r.db).GetContext(ctx, &session, query, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return domain.RegistrationSession{}, errors.New("registration session not found")
@@ -79,17 +80,185 @@ func (r *SessionRepository) UpdateRegistrationSessionCode(ctx context.Context, i
                 SET code = $1, code_expires = $2
                 WHERE id = $3`
 
-	_, err := r.db.ExecContext(ctx, query, code, expires, id)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, code, expires, id)
 	return err
 }
 
 // DeleteRegistrationSession deletes a registration session
 func (r *SessionRepository) DeleteRegistrationSession(ctx context.Context, id string) error {
 	query := `DELETE FROM registration_sessions WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, id)
 	return err
 }
 
+// CreateEmailChangeSession creates a pending email change, replacing the pending change of the user if any
+func (r *SessionRepository) CreateEmailChangeSession(ctx context.Context, session domain.EmailChangeSession) (string, error) {
+	query := `
+                INSERT INTO email_change_sessions (id, user_id, new_email, code, code_expires, cancel_token, review_reason, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+                ON CONFLICT (user_id) DO UPDATE
+                SET id = EXCLUDED.id, new_email = EXCLUDED.new_email, code = EXCLUDED.code, code_expires = EXCLUDED.code_expires,
+                    cancel_token = EXCLUDED.cancel_token, review_reason = EXCLUDED.review_reason, created_at = EXCLUDED.created_at
+                RETURNING id`
+
+	id := uuid.New().String()
+
+	err := conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		id,
+		session.UserID,
+		session.NewEmail,
+		session.Code,
+		session.CodeExpires,
+		session.CancelToken,
+		session.ReviewReason,
+		time.Now().UTC(),
+	).Scan(&id)
+
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// GetEmailChangeSessionByUser retrieves the pending email change of a user
+func (r *SessionRepository) GetEmailChangeSessionByUser(ctx context.Context, userID int64) (domain.EmailChangeSession, error) {
+	query := `
+                SELECT id, user_id, new_email, code, code_expires, cancel_token, review_reason, created_at
+                FROM email_change_sessions
+                WHERE user_id = $1`
+
+	var session domain.EmailChangeSession
+	err := conn(ctx, r.db).GetContext(ctx, &session, query, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.EmailChangeSession{}, domain.ErrEmailChangeNotFound
+		}
+		return domain.EmailChangeSession{}, err
+	}
+
+	return session, nil
+}
+
+// DeleteEmailChangeSession deletes a pending email change
+func (r *SessionRepository) DeleteEmailChangeSession(ctx context.Context, id string) error {
+	query := `DELETE FROM email_change_sessions WHERE id = $1`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+	return err
+}
+
+// DeleteEmailChangeSessionByCancelToken deletes the pending email change with the cancel token
+func (r *SessionRepository) DeleteEmailChangeSessionByCancelToken(ctx context.Context, token string) error {
+	query := `DELETE FROM email_change_sessions WHERE cancel_token = $1`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, token)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrEmailChangeNotFound
+	}
+
+	return nil
+}
+
+// CreateReauthSession creates a pending re-authentication, replacing the pending one of the user if any
+func (r *SessionRepository) CreateReauthSession(ctx context.Context, session domain.ReauthSession) (string, error) {
+	query := `
+                INSERT INTO reauth_sessions (id, user_id, code, code_expires, attempts, created_at)
+                VALUES ($1, $2, $3, $4, 0, $5)
+                ON CONFLICT (user_id) DO UPDATE
+                SET id = EXCLUDED.id, code = EXCLUDED.code, code_expires = EXCLUDED.code_expires,
+                    attempts = 0, created_at = EXCLUDED.created_at
+                RETURNING id`
+
+	id := uuid.New().String()
+
+	err := conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		id,
+		session.UserID,
+		session.Code,
+		session.CodeExpires,
+		time.Now().UTC(),
+	).Scan(&id)
+
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// GetReauthSessionByUser retrieves the pending re-authentication of a user
+func (r *SessionRepository) GetReauthSessionByUser(ctx context.Context, userID int64) (domain.ReauthSession, error) {
+	query := `
+                SELECT id, user_id, code, code_expires, attempts, created_at
+                FROM reauth_sessions
+                WHERE user_id = $1`
+
+	var session domain.ReauthSession
+	err := conn(ctx, r.db).GetContext(ctx, &session, query, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.ReauthSession{}, domain.ErrReauthSessionNotFound
+		}
+		return domain.ReauthSession{}, err
+	}
+
+	return session, nil
+}
+
+// IncrementReauthAttempts records a wrong code entered for a pending re-authentication and returns the number of
+// wrong codes so far
+func (r *SessionRepository) IncrementReauthAttempts(ctx context.Context, id string) (int, error) {
+	query := `
+                UPDATE reauth_sessions
+                SET attempts = attempts + 1
+                WHERE id = $1
+                RETURNING attempts`
+
+	var attempts int
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(&attempts)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, domain.ErrReauthSessionNotFound
+		}
+		return 0, err
+	}
+
+	return attempts, nil
+}
+
+// DeleteReauthSession deletes a pending re-authentication, failing with domain.ErrReauthSessionNotFound when it is
+// already gone so that a code can only be used once
+func (r *SessionRepository) DeleteReauthSession(ctx context.Context, id string) error {
+	query := `DELETE FROM reauth_sessions WHERE id = $1`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrReauthSessionNotFound
+	}
+
+	return nil
+}
+
 // CreateLoginSession creates a new login session
 func (r *SessionRepository) CreateLoginSession(ctx context.Context, session domain.LoginSession) (string, error) {
 	query := `
@@ -100,7 +269,7 @@ func (r *SessionRepository) CreateLoginSession(ctx context.Context, session doma
 	id := uuid.New().String()
 	now := time.Now().UTC()
 
-	err := r.db.QueryRowContext(
+	err := conn(ctx, r.db).QueryRowContext(
 		ctx,
 		query,
 		id,
@@ -125,7 +294,7 @@ func (r *SessionRepository) GetLoginSessionByEmailAndCode(ctx context.Context, e
                 WHERE email = $1 AND code = $2 AND code_expires > NOW()`
 
 	var session domain.LoginSession
-	err := r.db.GetContext(ctx, &session, query, email, code)
+	err := conn(ctx, r.db).GetContext(ctx, &session, query, email, code)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return domain.LoginSession{}, errors.New("invalid or expired code")
@@ -139,24 +308,24 @@ func (r *SessionRepository) GetLoginSessionByEmailAndCode(ctx context.Context, e
 // DeleteLoginSession deletes a login session
 func (r *SessionRepository) DeleteLoginSession(ctx context.Context, id string) error {
 	query := `DELETE FROM login_sessions WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, id)
 	return err
 }
 
 // DeleteExpiredLoginSessions deletes all expired login sessions
 func (r *SessionRepository) DeleteExpiredLoginSessions(ctx context.Context) error {
 	query := `DELETE FROM login_sessions WHERE code_expires < NOW()`
-	_, err := r.db.ExecContext(ctx, query)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query)
 	return err
 }
 
 // CreateTokenSession creates a new token session
 func (r *SessionRepository) CreateTokenSession(ctx context.Context, session domain.TokenSession) error {
 	query := `
-                INSERT INTO token_sessions (id, user_id, refresh_token, user_agent, ip, expires_at, created_at)
-                VALUES ($1, $2, $3, $4, $5, $6, $7)`
+                INSERT INTO token_sessions (id, user_id, refresh_token, user_agent, ip, amr, auth_time, expires_at, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
 
-	_, err := r.db.ExecContext(
+	_, err := conn(ctx, r.db).ExecContext(
 		ctx,
 		query,
 		session.ID,
@@ -164,6 +333,8 @@ func (r *SessionRepository) CreateTokenSession(ctx context.Context, session doma
 		session.RefreshToken,
 		session.UserAgent,
 		session.IP,
+		session.AMR,
+		session.AuthTime,
 		session.ExpiresAt,
 		session.CreatedAt,
 	)
@@ -173,12 +344,12 @@ func (r *SessionRepository) CreateTokenSession(ctx context.Context, session doma
 // GetTokenSession retrieves a token session by refresh token
 func (r *SessionRepository) GetTokenSession(ctx context.Context, refreshToken string) (domain.TokenSession, error) {
 	query := `
-                SELECT id, user_id, refresh_token, user_agent, ip, expires_at, created_at
+                SELECT id, user_id, refresh_token, user_agent, ip, amr, auth_time, expires_at, created_at
                 FROM token_sessions
                 WHERE refresh_token = $1 AND expires_at > NOW()`
 
 	var session domain.TokenSession
-	err := r.db.GetContext(ctx, &session, query, refreshToken)
+	err := conn(ctx, r.db).GetContext(ctx, &session, query, refreshToken)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return domain.TokenSession{}, errors.New("token expires")
@@ -192,20 +363,71 @@ func (r *SessionRepository) GetTokenSession(ctx context.Context, refreshToken st
 // DeleteTokenSession deletes a token session
 func (r *SessionRepository) DeleteTokenSession(ctx context.Context, id string) error {
 	query := `DELETE FROM token_sessions WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, id)
 	return err
 }
 
 // DeleteExpiredTokenSessions deletes all expired token sessions
 func (r *SessionRepository) DeleteExpiredTokenSessions(ctx context.Context) error {
 	query := `DELETE FROM token_sessions WHERE expires_at < NOW()`
-	_, err := r.db.ExecContext(ctx, query)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query)
 	return err
 }
 
 // DeleteUserTokenSessions deletes all token sessions for a user
 func (r *SessionRepository) DeleteUserTokenSessions(ctx context.Context, userID int64) error {
 	query := `DELETE FROM token_sessions WHERE user_id = $1`
-	_, err := r.db.ExecContext(ctx, query, userID)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, userID)
 	return err
 }
+
+// ListUserTokenSessions retrieves the token sessions of a user, newest first
+func (r *SessionRepository) ListUserTokenSessions(ctx context.Context, userID int64) ([]domain.TokenSession, error) {
+	query := `
+                SELECT id, user_id, refresh_token, user_agent, ip, amr, auth_time, expires_at, created_at
+                FROM token_sessions
+                WHERE user_id = $1
+                ORDER BY created_at DESC`
+
+	var sessions []domain.TokenSession
+	err := conn(ctx, r.db).SelectContext(ctx, &sessions, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// ListRegistrationSessionsByEmail retrieves the registration sessions for an email, newest first
+func (r *SessionRepository) ListRegistrationSessionsByEmail(ctx context.Context, email string) ([]domain.RegistrationSession, error) {
+	query := `
+                SELECT id, first_name, last_name, nickname, email, accepted_privacy_policy, code, code_expires, review_reason, created_at
+                FROM registration_sessions
+                WHERE LOWER(email) = LOWER($1)
+                ORDER BY created_at DESC`
+
+	var sessions []domain.RegistrationSession
+	err := conn(ctx, r.db).SelectContext(ctx, &sessions, query, email)
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// ListLoginSessionsByEmail retrieves the login sessions for an email, newest first
+func (r *SessionRepository) ListLoginSessionsByEmail(ctx context.Context, email string) ([]domain.LoginSession, error) {
+	query := `
+                SELECT id, email, code, code_expires, created_at
+                FROM login_sessions
+                WHERE LOWER(email) = LOWER($1)
+                ORDER BY created_at DESC`
+
+	var sessions []domain.LoginSession
+	err := conn(ctx, r.db).SelectContext(ctx, &sessions, query, email)
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
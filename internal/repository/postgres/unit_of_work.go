@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// executor runs queries either directly on the database or within a transaction; *sqlx.DB and *sqlx.Tx implement it
+type executor interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txKey struct{}
+
+// UnitOfWork runs several repository calls in a single transaction
+type UnitOfWork struct {
+	db *sqlx.DB
+}
+
+func NewUnitOfWork(db *sqlx.DB) *UnitOfWork {
+	return &UnitOfWork{
+		db: db,
+	}
+}
+
+// Do runs fn in a transaction. The repositories of this package called with the context passed to fn execute
+// within it. The transaction is committed when fn returns nil and rolled back otherwise;
+// a nested Do joins the transaction of the outer one.
+func (u *UnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := u.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// conn returns the transaction of the unit of work running in ctx, or db outside of one
+func conn(ctx context.Context, db *sqlx.DB) executor {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return tx
+	}
+	return db
+}
@@ -3,12 +3,15 @@ package postgres
 import (
 	"context"
 	"database/sql"
-	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 
 	"authmicro/internal/domain"
+	"authmicro/pkg/scimfilter"
 )
 
 type UserRepository struct {
@@ -23,14 +26,14 @@ func NewUserRepository(db *sqlx.DB) *UserRepository {
 
 func (r *UserRepository) Create(ctx context.Context, user domain.User) (int64, error) {
 	query := `
-                INSERT INTO users (first_name, last_name, nickname, email, email_verified, accepted_privacy_policy, created_at, updated_at) 
-                VALUES ($1, $2, $3, $4, $5, $6, $7, $8) 
+                INSERT INTO users (first_name, last_name, nickname, email, email_verified, accepted_privacy_policy, institution_id, review_reason, created_at, updated_at) 
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) 
                 RETURNING id`
 
 	var id int64
 	now := time.Now().UTC()
 
-	err := r.db.QueryRowContext(
+	err := conn(ctx, r.db).QueryRowContext(
 		ctx,
 		query,
 		user.FirstName,
@@ -39,11 +42,16 @@ func (r *UserRepository) Create(ctx context.Context, user domain.User) (int64, e
 		user.Email,
 		user.EmailVerified,
 		user.AcceptedPrivacyPolicy,
+		user.InstitutionID,
+		user.ReviewReason,
 		now,
 		now,
 	).Scan(&id)
 
 	if err != nil {
+		if isEmailUniqueViolation(err) {
+			return 0, domain.ErrEmailExists
+		}
 		return 0, err
 	}
 
@@ -52,15 +60,15 @@ func (r *UserRepository) Create(ctx context.Context, user domain.User) (int64, e
 
 func (r *UserRepository) GetByID(ctx context.Context, id int64) (domain.User, error) {
 	query := `
-                SELECT id, first_name, last_name, nickname, email, email_verified, accepted_privacy_policy, created_at, updated_at 
+                SELECT id, first_name, last_name, nickname, email, email_verified, accepted_privacy_policy, authz_version, institution_id, review_reason, deletion_scheduled_at, status, suspended_until, status_reason, status_changed_by, status_changed_at, external_id, created_at, updated_at 
                 FROM users 
                 WHERE id = $1`
 
 	var user domain.User
-	err := r.db.GetContext(ctx, &user, query, id)
+	err := conn(ctx, r.db).GetContext(ctx, &user, query, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return domain.User{}, errors.New("user not found")
+			return domain.User{}, domain.ErrUserNotFound
 		}
 		return domain.User{}, err
 	}
@@ -70,15 +78,15 @@ func (r *UserRepository) GetByID(ctx context.Context, id int64) (domain.User, er
 
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (domain.User, error) {
 	query := `
-                SELECT id, first_name, last_name, nickname, email, email_verified, accepted_privacy_policy, created_at, updated_at 
+                SELECT id, first_name, last_name, nickname, email, email_verified, accepted_privacy_policy, authz_version, institution_id, review_reason, deletion_scheduled_at, status, suspended_until, status_reason, status_changed_by, status_changed_at, external_id, created_at, updated_at 
                 FROM users 
-                WHERE email = $1`
+                WHERE LOWER(email) = LOWER($1)`
 
 	var user domain.User
-	err := r.db.GetContext(ctx, &user, query, email)
+	err := conn(ctx, r.db).GetContext(ctx, &user, query, email)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return domain.User{}, errors.New("user not found")
+			return domain.User{}, domain.ErrUserNotFound
 		}
 		return domain.User{}, err
 	}
@@ -88,15 +96,15 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (domain.U
 
 func (r *UserRepository) GetByNickname(ctx context.Context, nickname string) (domain.User, error) {
 	query := `
-                SELECT id, first_name, last_name, nickname, email, email_verified, accepted_privacy_policy, created_at, updated_at 
+                SELECT id, first_name, last_name, nickname, email, email_verified, accepted_privacy_policy, authz_version, institution_id, review_reason, deletion_scheduled_at, status, suspended_until, status_reason, status_changed_by, status_changed_at, external_id, created_at, updated_at 
                 FROM users 
                 WHERE nickname = $1`
 
 	var user domain.User
-	err := r.db.GetContext(ctx, &user, query, nickname)
+	err := conn(ctx, r.db).GetContext(ctx, &user, query, nickname)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return domain.User{}, errors.New("user not found")
+			return domain.User{}, domain.ErrUserNotFound
 		}
 		return domain.User{}, err
 	}
@@ -110,22 +118,472 @@ func (r *UserRepository) UpdateEmailVerificationStatus(ctx context.Context, user
                 SET email_verified = $1, updated_at = $2 
                 WHERE id = $3`
 
-	_, err := r.db.ExecContext(ctx, query, verified, time.Now().UTC(), userID)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, verified, time.Now().UTC(), userID)
 	return err
 }
 
+// UpdateProfile updates the first name, last name and nickname of a user
+func (r *UserRepository) UpdateProfile(ctx context.Context, user domain.User) error {
+	query := `
+                UPDATE users 
+                SET first_name = $1, last_name = $2, nickname = $3, updated_at = $4 
+                WHERE id = $5`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, user.FirstName, user.LastName, user.Nickname, time.Now().UTC(), user.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ErrNicknameExists
+		}
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// UpdateEmail changes the email of a user along with the institution of its domain. A review reason, when given,
+// flags the user for review; an existing flag is kept otherwise.
+func (r *UserRepository) UpdateEmail(ctx context.Context, userID int64, email string, institutionID *int64, reviewReason *string) error {
+	query := `
+                UPDATE users 
+                SET email = $1, institution_id = $2, review_reason = COALESCE($3, review_reason), updated_at = $4 
+                WHERE id = $5`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, email, institutionID, reviewReason, time.Now().UTC(), userID)
+	if err != nil {
+		if isEmailUniqueViolation(err) {
+			return domain.ErrEmailExists
+		}
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// NicknameExists reports whether a nickname is unavailable: taken by a user, blocked
+// or still reserved after a recent change
 func (r *UserRepository) NicknameExists(ctx context.Context, nickname string) (bool, error) {
-	query := `SELECT EXISTS (SELECT 1 FROM users WHERE nickname = $1)`
+	query := `
+                SELECT EXISTS (SELECT 1 FROM users WHERE nickname = $1)
+                    OR EXISTS (SELECT 1 FROM blocked_nicknames WHERE nickname = LOWER($1))
+                    OR EXISTS (SELECT 1 FROM nickname_history WHERE old_nickname = $1 AND reserved_until > $2)`
 
 	var exists bool
-	err := r.db.GetContext(ctx, &exists, query, nickname)
+	err := conn(ctx, r.db).GetContext(ctx, &exists, query, nickname, time.Now().UTC())
 	return exists, err
 }
 
 func (r *UserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
-	query := `SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)`
+	query := `SELECT EXISTS (SELECT 1 FROM users WHERE LOWER(email) = LOWER($1))`
 
 	var exists bool
-	err := r.db.GetContext(ctx, &exists, query, email)
+	err := conn(ctx, r.db).GetContext(ctx, &exists, query, email)
 	return exists, err
 }
+
+// GetAccountState retrieves the authorization version and status of a user
+func (r *UserRepository) GetAccountState(ctx context.Context, userID int64) (domain.AccountState, error) {
+	query := `SELECT authz_version, status, suspended_until FROM users WHERE id = $1`
+
+	var state domain.AccountState
+	err := conn(ctx, r.db).GetContext(ctx, &state, query, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.AccountState{}, domain.ErrUserNotFound
+		}
+		return domain.AccountState{}, err
+	}
+
+	return state, nil
+}
+
+// SetAccountStatus changes the status of a user, recording the reason and the admin who changed it; changedBy is zero
+// for changes made by the system
+func (r *UserRepository) SetAccountStatus(ctx context.Context, userID int64, status string, suspendedUntil *time.Time, reason *string, changedBy int64) error {
+	query := `
+                UPDATE users 
+                SET status = $1, suspended_until = $2, status_reason = $3, status_changed_by = $4, status_changed_at = $5, updated_at = $5 
+                WHERE id = $6`
+
+	var changer *int64
+	if changedBy != 0 {
+		changer = &changedBy
+	}
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, status, suspendedUntil, reason, changer, time.Now().UTC(), userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// IncrementAuthzVersion bumps the authorization version of the users, invalidating their access tokens
+func (r *UserRepository) IncrementAuthzVersion(ctx context.Context, userIDs ...int64) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	query := `
+                UPDATE users 
+                SET authz_version = authz_version + 1 
+                WHERE id = ANY($1)`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, pq.Array(userIDs))
+	return err
+}
+
+// ScheduleDeletion marks a user as pending deletion at the given time
+func (r *UserRepository) ScheduleDeletion(ctx context.Context, userID int64, at time.Time) error {
+	query := `
+                UPDATE users 
+                SET deletion_scheduled_at = $1, updated_at = $2 
+                WHERE id = $3`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, at, time.Now().UTC(), userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// CancelDeletion clears the pending deletion of a user
+func (r *UserRepository) CancelDeletion(ctx context.Context, userID int64) error {
+	query := `
+                UPDATE users 
+                SET deletion_scheduled_at = NULL, updated_at = $1 
+                WHERE id = $2 AND deletion_scheduled_at IS NOT NULL`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, time.Now().UTC(), userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrDeletionNotScheduled
+	}
+
+	return nil
+}
+
+// ListUsersDueForDeletion retrieves the IDs of the users whose deletion is due
+func (r *UserRepository) ListUsersDueForDeletion(ctx context.Context, now time.Time) ([]int64, error) {
+	query := `
+                SELECT id 
+                FROM users 
+                WHERE deletion_scheduled_at <= $1
+                ORDER BY deletion_scheduled_at, id`
+
+	var userIDs []int64
+	err := conn(ctx, r.db).SelectContext(ctx, &userIDs, query, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+// DeleteUser deletes a user along with everything that references it
+func (r *UserRepository) DeleteUser(ctx context.Context, userID int64) error {
+	query := `DELETE FROM users WHERE id = $1`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, userID)
+	return err
+}
+
+// DeleteDueUser deletes a user whose deletion is due at now. The schedule is checked by the delete itself, so
+// a deletion cancelled after the user was listed as due returns ErrDeletionNotScheduled.
+func (r *UserRepository) DeleteDueUser(ctx context.Context, userID int64, now time.Time) error {
+	query := `DELETE FROM users WHERE id = $1 AND deletion_scheduled_at <= $2`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, userID, now)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrDeletionNotScheduled
+	}
+
+	return nil
+}
+
+// AnonymizeUser replaces the personal data of a user with placeholders and removes its sessions, roles, memberships
+// and history, keeping the row so that references to it stay valid
+func (r *UserRepository) AnonymizeUser(ctx context.Context, userID int64) error {
+	query := `
+                UPDATE users 
+                SET first_name = 'Deleted', last_name = 'User', nickname = 'deleted' || id, email = 'deleted-' || id || '@deleted.invalid', 
+                    email_verified = false, institution_id = NULL, review_reason = NULL, deletion_scheduled_at = NULL, status_reason = NULL, external_id = NULL, 
+                    authz_version = authz_version + 1, deleted_at = $1, updated_at = $1 
+                WHERE id = $2`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, time.Now().UTC(), userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return r.deleteAnonymizedUserData(ctx, userID)
+}
+
+// AnonymizeDueUser anonymizes a user whose deletion is due at now, like AnonymizeUser. The schedule is checked by
+// the update itself, so a deletion cancelled after the user was listed as due returns ErrDeletionNotScheduled.
+func (r *UserRepository) AnonymizeDueUser(ctx context.Context, userID int64, now time.Time) error {
+	query := `
+                UPDATE users 
+                SET first_name = 'Deleted', last_name = 'User', nickname = 'deleted' || id, email = 'deleted-' || id || '@deleted.invalid', 
+                    email_verified = false, institution_id = NULL, review_reason = NULL, deletion_scheduled_at = NULL, status_reason = NULL, external_id = NULL, 
+                    authz_version = authz_version + 1, deleted_at = $1, updated_at = $1 
+                WHERE id = $2 AND deletion_scheduled_at <= $1`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, now, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrDeletionNotScheduled
+	}
+
+	return r.deleteAnonymizedUserData(ctx, userID)
+}
+
+// deleteAnonymizedUserData removes the sessions, roles, memberships and history of an anonymized user
+func (r *UserRepository) deleteAnonymizedUserData(ctx context.Context, userID int64) error {
+	queries := []string{
+		`DELETE FROM token_sessions WHERE user_id = $1`,
+		`DELETE FROM email_change_sessions WHERE user_id = $1`,
+		`DELETE FROM nickname_history WHERE user_id = $1`,
+		`DELETE FROM user_roles WHERE user_id = $1`,
+		`DELETE FROM memberships WHERE user_id = $1`,
+		`DELETE FROM data_exports WHERE user_id = $1`,
+		`DELETE FROM consent_sessions WHERE user_id = $1`,
+		`DELETE FROM consents WHERE user_id = $1`,
+	}
+
+	for _, query := range queries {
+		if _, err := conn(ctx, r.db).ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListUsersUnderReview retrieves the users flagged for review, oldest first
+func (r *UserRepository) ListUsersUnderReview(ctx context.Context) ([]domain.User, error) {
+	query := `
+                SELECT id, first_name, last_name, nickname, email, email_verified, accepted_privacy_policy, authz_version, institution_id, review_reason, deletion_scheduled_at, status, suspended_until, status_reason, status_changed_by, status_changed_at, external_id, created_at, updated_at 
+                FROM users 
+                WHERE review_reason IS NOT NULL
+                ORDER BY created_at, id`
+
+	var users []domain.User
+	err := conn(ctx, r.db).SelectContext(ctx, &users, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// userSortColumns maps the sort fields of the user directory to their columns and the type cursor values are cast to
+var userSortColumns = map[string][2]string{
+	domain.UserSorts.CreatedAt: {"created_at", "timestamp"},
+	domain.UserSorts.Email:     {"email", "text"},
+	domain.UserSorts.Nickname:  {"nickname", "text"},
+}
+
+// ListUsers retrieves a page of the users matching the filters of the request, starting after the cursor.
+// The request must be validated: its sort and order must be set and known. Anonymized accounts are left out.
+func (r *UserRepository) ListUsers(ctx context.Context, req domain.ListUsersRequest, after *domain.UserCursor, limit int) ([]domain.User, error) {
+	now := time.Now().UTC()
+	conditions := []string{"deleted_at IS NULL"}
+	var args []interface{}
+
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if req.Email != "" {
+		conditions = append(conditions, "LOWER(email) LIKE "+arg(likePrefix(req.Email)))
+	}
+	if req.Nickname != "" {
+		conditions = append(conditions, "LOWER(nickname) LIKE "+arg(likePrefix(req.Nickname)))
+	}
+	if req.Role != "" {
+		conditions = append(conditions, `EXISTS (
+                    SELECT 1 
+                    FROM user_roles ur 
+                    JOIN roles ro ON ro.id = ur.role_id 
+                    WHERE ur.user_id = users.id AND ro.name = `+arg(req.Role)+` AND (ur.expires_at IS NULL OR ur.expires_at > `+arg(now)+`))`)
+	}
+	switch req.Status {
+	case domain.AccountStatuses.Active:
+		conditions = append(conditions, "(status = 'active' OR (status = 'suspended' AND suspended_until <= "+arg(now)+"))")
+	case domain.AccountStatuses.Suspended:
+		conditions = append(conditions, "status = 'suspended' AND suspended_until > "+arg(now))
+	case domain.AccountStatuses.Banned:
+		conditions = append(conditions, "status = 'banned'")
+	}
+	if req.Verified != nil {
+		conditions = append(conditions, "email_verified = "+arg(*req.Verified))
+	}
+	if req.CreatedFrom != nil {
+		conditions = append(conditions, "created_at >= "+arg(req.CreatedFrom.UTC()))
+	}
+	if req.CreatedTo != nil {
+		conditions = append(conditions, "created_at < "+arg(req.CreatedTo.UTC()))
+	}
+
+	sort := userSortColumns[req.Sort]
+	direction, op := "ASC", ">"
+	if req.Order == domain.SortOrders.Desc {
+		direction, op = "DESC", "<"
+	}
+	if after != nil {
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s::%s, %s)", sort[0], op, arg(after.Value), sort[1], arg(after.ID)))
+	}
+
+	query := `
+                SELECT id, first_name, last_name, nickname, email, email_verified, accepted_privacy_policy, authz_version, institution_id, review_reason, deletion_scheduled_at, status, suspended_until, status_reason, status_changed_by, status_changed_at, external_id, created_at, updated_at 
+                FROM users 
+                WHERE ` + strings.Join(conditions, " AND ") + fmt.Sprintf(`
+                ORDER BY %s %s, id %s
+                LIMIT %s`, sort[0], direction, direction, arg(limit))
+
+	var users []domain.User
+	err := conn(ctx, r.db).SelectContext(ctx, &users, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// likePrefix builds a LIKE pattern matching the lowercased prefix
+func likePrefix(prefix string) string {
+	return likeEscape(strings.ToLower(prefix)) + "%"
+}
+
+// likeEscape escapes the LIKE wildcards in a string
+func likeEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// SearchUsers retrieves a page of the users matching the SCIM filter, ordered by ID, along with the number of
+// matching users. A nil filter matches all users. Anonymized accounts are left out.
+func (r *UserRepository) SearchUsers(ctx context.Context, filter scimfilter.Expr, offset, limit int) ([]domain.User, int, error) {
+	where, args, err := scimWhere(filter, scimUserAttributes)
+	if err != nil {
+		return nil, 0, err
+	}
+	where = "deleted_at IS NULL AND " + where
+
+	var total int
+	err = conn(ctx, r.db).GetContext(ctx, &total, `SELECT COUNT(*) FROM users WHERE `+where, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+                SELECT id, first_name, last_name, nickname, email, email_verified, accepted_privacy_policy, authz_version, institution_id, review_reason, deletion_scheduled_at, status, suspended_until, status_reason, status_changed_by, status_changed_at, external_id, created_at, updated_at 
+                FROM users 
+                WHERE ` + where + fmt.Sprintf(`
+                ORDER BY id
+                OFFSET %d LIMIT %d`, offset, limit)
+
+	var users []domain.User
+	err = conn(ctx, r.db).SelectContext(ctx, &users, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// SetExternalID sets the identifier of a user in the system of its provisioning client; nil clears it
+func (r *UserRepository) SetExternalID(ctx context.Context, userID int64, externalID *string) error {
+	query := `
+                UPDATE users 
+                SET external_id = $1, updated_at = $2 
+                WHERE id = $3`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, externalID, time.Now().UTC(), userID)
+	return err
+}
+
+// ClearReviewReason marks a user flagged for review as reviewed
+func (r *UserRepository) ClearReviewReason(ctx context.Context, userID int64) error {
+	query := `
+                UPDATE users 
+                SET review_reason = NULL, updated_at = $2 
+                WHERE id = $1`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, userID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
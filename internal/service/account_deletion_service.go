@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"authmicro/configs"
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type accountDeletionRepository interface {
+	GetByID(ctx context.Context, id int64) (domain.User, error)
+	ScheduleDeletion(ctx context.Context, userID int64, at time.Time) error
+	CancelDeletion(ctx context.Context, userID int64) error
+	ListUsersDueForDeletion(ctx context.Context, now time.Time) ([]int64, error)
+	DeleteDueUser(ctx context.Context, userID int64, now time.Time) error
+	AnonymizeDueUser(ctx context.Context, userID int64, now time.Time) error
+	IncrementAuthzVersion(ctx context.Context, userIDs ...int64) error
+}
+
+type accountSessionRepository interface {
+	DeleteUserTokenSessions(ctx context.Context, userID int64) error
+}
+
+type accountDeletionEmailService interface {
+	SendAccountDeletionScheduled(to string, deleteAt time.Time) error
+}
+
+// AccountDeletionService implements user-initiated deletion of accounts. A deleted account is kept for a grace period
+// during which the user can log in again and cancel the deletion; after it the account is deleted or anonymized.
+type AccountDeletionService struct {
+	userRepo    accountDeletionRepository
+	sessionRepo accountSessionRepository
+	uow         unitOfWork
+	emailSvc    accountDeletionEmailService
+	config      configs.AccountDeletionConfig
+	logger      logger.Logger
+}
+
+func NewAccountDeletionService(
+	userRepo accountDeletionRepository,
+	sessionRepo accountSessionRepository,
+	uow unitOfWork,
+	emailSvc accountDeletionEmailService,
+	config configs.AccountDeletionConfig,
+	logger logger.Logger,
+) *AccountDeletionService {
+	return &AccountDeletionService{
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		uow:         uow,
+		emailSvc:    emailSvc,
+		config:      config,
+		logger:      logger,
+	}
+}
+
+// RequestDeletion schedules the deletion of the user's account after the grace period and logs the user out everywhere
+func (s *AccountDeletionService) RequestDeletion(ctx context.Context, userID int64) (*domain.AccountDeletionResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	deleteAt := time.Now().UTC().Add(s.config.GracePeriod)
+	if user.DeletionScheduledAt != nil {
+		// Requesting again keeps the original schedule
+		deleteAt = *user.DeletionScheduledAt
+	}
+
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.ScheduleDeletion(ctx, userID, deleteAt); err != nil {
+			return err
+		}
+
+		if err := s.sessionRepo.DeleteUserTokenSessions(ctx, userID); err != nil {
+			return err
+		}
+
+		return s.userRepo.IncrementAuthzVersion(ctx, userID)
+	})
+	if err != nil {
+		s.logger.Errorf("Error scheduling account deletion: %v", err)
+		return nil, err
+	}
+
+	if err := s.emailSvc.SendAccountDeletionScheduled(user.Email, deleteAt); err != nil {
+		s.logger.Errorf("Error sending account deletion notice: %v", err)
+		// Just log the error and continue
+	}
+
+	return &domain.AccountDeletionResponse{
+		DeletionScheduledAt: deleteAt,
+	}, nil
+}
+
+// CancelDeletion cancels the pending deletion of the user's account
+func (s *AccountDeletionService) CancelDeletion(ctx context.Context, userID int64) error {
+	return s.userRepo.CancelDeletion(ctx, userID)
+}
+
+// DeleteDueAccounts deletes or anonymizes the accounts whose grace period has passed. An account whose deletion
+// is cancelled after it was listed is skipped.
+func (s *AccountDeletionService) DeleteDueAccounts(ctx context.Context) error {
+	now := time.Now().UTC()
+	userIDs, err := s.userRepo.ListUsersDueForDeletion(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		err := s.uow.Do(ctx, func(ctx context.Context) error {
+			if s.config.Anonymize {
+				return s.userRepo.AnonymizeDueUser(ctx, userID, now)
+			}
+			return s.userRepo.DeleteDueUser(ctx, userID, now)
+		})
+		if errors.Is(err, domain.ErrDeletionNotScheduled) {
+			s.logger.Infof("Deletion of the account of user %d was cancelled", userID)
+			continue
+		}
+		if err != nil {
+			s.logger.Errorf("Error deleting account of user %d: %v", userID, err)
+			continue
+		}
+
+		s.logger.Infof("Deleted account of user %d", userID)
+	}
+
+	return nil
+}
+
+// RunDeletionCleanup periodically deletes the accounts whose grace period has passed until the context is cancelled
+func (s *AccountDeletionService) RunDeletionCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.DeleteDueAccounts(ctx); err != nil {
+				s.logger.Errorf("Error deleting due accounts: %v", err)
+			}
+		}
+	}
+}
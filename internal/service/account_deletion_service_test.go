@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"authmicro/configs"
+	"authmicro/internal/domain"
+)
+
+// fakeAccountDeletionRepository keeps the deletion schedule of users in memory. The hook runs right after the due
+// users are listed, before any of them is deleted.
+type fakeAccountDeletionRepository struct {
+	accountDeletionRepository
+	scheduled  map[int64]time.Time
+	deleted    []int64
+	anonymized []int64
+	afterList  func()
+}
+
+func (r *fakeAccountDeletionRepository) ListUsersDueForDeletion(ctx context.Context, now time.Time) ([]int64, error) {
+	var userIDs []int64
+	for userID, at := range r.scheduled {
+		if !at.After(now) {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	if r.afterList != nil {
+		r.afterList()
+	}
+	return userIDs, nil
+}
+
+func (r *fakeAccountDeletionRepository) CancelDeletion(ctx context.Context, userID int64) error {
+	if _, ok := r.scheduled[userID]; !ok {
+		return domain.ErrDeletionNotScheduled
+	}
+	delete(r.scheduled, userID)
+	return nil
+}
+
+func (r *fakeAccountDeletionRepository) DeleteDueUser(ctx context.Context, userID int64, now time.Time) error {
+	if at, ok := r.scheduled[userID]; !ok || at.After(now) {
+		return domain.ErrDeletionNotScheduled
+	}
+	delete(r.scheduled, userID)
+	r.deleted = append(r.deleted, userID)
+	return nil
+}
+
+func (r *fakeAccountDeletionRepository) AnonymizeDueUser(ctx context.Context, userID int64, now time.Time) error {
+	if at, ok := r.scheduled[userID]; !ok || at.After(now) {
+		return domain.ErrDeletionNotScheduled
+	}
+	delete(r.scheduled, userID)
+	r.anonymized = append(r.anonymized, userID)
+	return nil
+}
+
+// fakeUnitOfWork runs the function without a transaction
+type fakeUnitOfWork struct{}
+
+func (fakeUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func TestAccountDeletionServiceDeleteDueAccountsSkipsCancelled(t *testing.T) {
+	for _, anonymize := range []bool{false, true} {
+		repo := &fakeAccountDeletionRepository{
+			scheduled: map[int64]time.Time{
+				1: time.Now().Add(-time.Hour),
+				2: time.Now().Add(-time.Hour),
+				3: time.Now().Add(time.Hour),
+			},
+		}
+		s := NewAccountDeletionService(repo, nil, fakeUnitOfWork{}, nil, configs.AccountDeletionConfig{Anonymize: anonymize}, nopLogger{})
+
+		// The user logs in again and cancels the deletion while the cleanup is running
+		repo.afterList = func() {
+			if err := s.CancelDeletion(context.Background(), 2); err != nil {
+				t.Fatalf("CancelDeletion() error = %v", err)
+			}
+		}
+
+		if err := s.DeleteDueAccounts(context.Background()); err != nil {
+			t.Fatalf("DeleteDueAccounts() error = %v", err)
+		}
+
+		removed := repo.deleted
+		if anonymize {
+			removed = repo.anonymized
+		}
+		if len(removed) != 1 || removed[0] != 1 {
+			t.Errorf("DeleteDueAccounts() with anonymize %v removed %v, want [1]", anonymize, removed)
+		}
+		if len(repo.deleted)+len(repo.anonymized) != 1 {
+			t.Errorf("DeleteDueAccounts() with anonymize %v deleted %v and anonymized %v", anonymize, repo.deleted, repo.anonymized)
+		}
+		if _, ok := repo.scheduled[3]; !ok {
+			t.Errorf("DeleteDueAccounts() removed an account whose deletion is not due")
+		}
+	}
+}
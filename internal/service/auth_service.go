@@ -2,14 +2,21 @@ package service
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"math/rand"
+	"net/url"
 	"regexp"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+
+	"authmicro/configs"
 	"authmicro/internal/domain"
 	"authmicro/pkg/logger"
+	"authmicro/pkg/mailaddr"
 )
 
 type userRepository interface {
@@ -20,6 +27,12 @@ type userRepository interface {
 	UpdateEmailVerificationStatus(ctx context.Context, userID int64, verified bool) error
 	NicknameExists(ctx context.Context, nickname string) (bool, error)
 	EmailExists(ctx context.Context, email string) (bool, error)
+	UpdateEmail(ctx context.Context, userID int64, email string, institutionID *int64, reviewReason *string) error
+	IncrementAuthzVersion(ctx context.Context, userIDs ...int64) error
+}
+
+type nicknameChecker interface {
+	NicknameExists(ctx context.Context, nickname string) (bool, error)
 }
 
 type roleRepository interface {
@@ -31,6 +44,27 @@ type roleRepository interface {
 	GetUserRoleNames(ctx context.Context, userID int64) ([]string, error)
 	RemoveRoleFromUser(ctx context.Context, userID, roleID int64) error
 	HasRole(ctx context.Context, userID int64, roleName string) (bool, error)
+	GetEarliestRoleExpiry(ctx context.Context, userID int64) (*time.Time, error)
+}
+
+type permissionRepository interface {
+	GetUserPermissionNames(ctx context.Context, userID int64) ([]string, error)
+	HasPermission(ctx context.Context, userID int64, permissionName string) (bool, error)
+	GetUserGrants(ctx context.Context, userID int64) (domain.UserGrants, error)
+}
+
+type membershipRepository interface {
+	GetUserMemberships(ctx context.Context, userID int64) ([]domain.Membership, error)
+	IsMember(ctx context.Context, orgID, userID int64) (bool, error)
+	HasOrgRole(ctx context.Context, userID, orgID int64, roleName string) (bool, error)
+}
+
+type institutionLookup interface {
+	FindInstitutionByDomains(ctx context.Context, patterns []string) (domain.Institution, error)
+}
+
+type registrationEmailPolicy interface {
+	Check(ctx context.Context, email string) (domain.EmailPolicyResult, error)
 }
 
 type sessionRepository interface {
@@ -47,95 +81,258 @@ type sessionRepository interface {
 	DeleteTokenSession(ctx context.Context, id string) error
 	DeleteExpiredTokenSessions(ctx context.Context) error
 	DeleteUserTokenSessions(ctx context.Context, userID int64) error
+	CreateEmailChangeSession(ctx context.Context, session domain.EmailChangeSession) (string, error)
+	GetEmailChangeSessionByUser(ctx context.Context, userID int64) (domain.EmailChangeSession, error)
+	DeleteEmailChangeSession(ctx context.Context, id string) error
+	DeleteEmailChangeSessionByCancelToken(ctx context.Context, token string) error
+	CreateReauthSession(ctx context.Context, session domain.ReauthSession) (string, error)
+	GetReauthSessionByUser(ctx context.Context, userID int64) (domain.ReauthSession, error)
+	IncrementReauthAttempts(ctx context.Context, id string) (int, error)
+	DeleteReauthSession(ctx context.Context, id string) error
+}
+
+type consentRepository interface {
+	ListCurrentLegalDocuments(ctx context.Context, now time.Time) ([]domain.LegalDocument, error)
+	ListRequiredDocuments(ctx context.Context, userID int64, now time.Time) ([]domain.LegalDocument, error)
+	CreateConsent(ctx context.Context, consent domain.Consent) error
+	CreateConsentSession(ctx context.Context, session domain.ConsentSession) (string, error)
+	GetConsentSession(ctx context.Context, id string) (domain.ConsentSession, error)
+	DeleteConsentSession(ctx context.Context, id string) error
+}
+
+// unitOfWork runs the repository calls made with the context passed to fn in a single transaction
+type unitOfWork interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
 type tokenService interface {
-	GenerateTokenPair(ctx context.Context, user domain.User, roles []string) (domain.TokenPair, error)
-	ValidateToken(token string) (*domain.TokenClaims, error)
-	StoreRefreshToken(ctx context.Context, userID int64, refreshToken, userAgent, ip string) error
+	GenerateTokenPair(ctx context.Context, user domain.User, grants domain.UserGrants, authCtx domain.AuthContext) (domain.TokenPair, error)
+	GenerateAccessToken(ctx context.Context, user domain.User, grants domain.UserGrants, authCtx domain.AuthContext) (string, error)
+	ValidateToken(ctx context.Context, token string) (*domain.TokenClaims, error)
+	StoreRefreshToken(ctx context.Context, userID int64, refreshToken, userAgent, ip string, authCtx domain.AuthContext) error
 	RevokeRefreshToken(ctx context.Context, refreshToken string) error
 }
 
 type emailService interface {
 	SendVerificationCode(to, code string) error
+	SendRegistrationAttemptNotice(to, loginLink string) error
+	SendEmailChangeNotice(to, newEmail, cancelLink string) error
 }
 
 type AuthService struct {
-	userRepo    userRepository
-	roleRepo    roleRepository
-	sessionRepo sessionRepository
-	tokenSvc    tokenService
-	emailSvc    emailService
-	logger      logger.Logger
+	userRepo       userRepository
+	roleRepo       roleRepository
+	permissionRepo permissionRepository
+	orgRepo        membershipRepository
+	institutions   institutionLookup
+	emailPolicy    registrationEmailPolicy
+	sessionRepo    sessionRepository
+	consentRepo    consentRepository
+	uow            unitOfWork
+	tokenSvc       tokenService
+	emailSvc       emailService
+	emailNorm      mailaddr.Normalizer
+	config         configs.RegistrationConfig
+	emailChange    configs.EmailChangeConfig
+	logger         logger.Logger
 }
 
 func NewAuthService(
 	userRepo userRepository,
 	roleRepo roleRepository,
+	permissionRepo permissionRepository,
+	orgRepo membershipRepository,
+	institutions institutionLookup,
+	emailPolicy registrationEmailPolicy,
 	sessionRepo sessionRepository,
+	consentRepo consentRepository,
+	uow unitOfWork,
 	tokenSvc tokenService,
 	emailSvc emailService,
+	emailNorm mailaddr.Normalizer,
+	config configs.RegistrationConfig,
+	emailChange configs.EmailChangeConfig,
 	logger logger.Logger,
 ) *AuthService {
 	return &AuthService{
-		userRepo:    userRepo,
-		roleRepo:    roleRepo,
-		sessionRepo: sessionRepo,
-		tokenSvc:    tokenSvc,
-		emailSvc:    emailSvc,
-		logger:      logger,
+		userRepo:       userRepo,
+		roleRepo:       roleRepo,
+		permissionRepo: permissionRepo,
+		orgRepo:        orgRepo,
+		institutions:   institutions,
+		emailPolicy:    emailPolicy,
+		sessionRepo:    sessionRepo,
+		consentRepo:    consentRepo,
+		uow:            uow,
+		tokenSvc:       tokenSvc,
+		emailSvc:       emailSvc,
+		emailNorm:      emailNorm,
+		config:         config,
+		emailChange:    emailChange,
+		logger:         logger,
 	}
 }
 
 // CreateRegistrationSession creates a new registration session
 func (s *AuthService) CreateRegistrationSession(ctx context.Context, req domain.RegistrationRequest) (*domain.RegistrationSessionResponse, []domain.FieldError, error) {
-	var fieldErrors []domain.FieldError
+	req.Email = s.normalizeEmail(req.Email)
 
-	// Validate firstName
-	if req.FirstName == "" {
-		fieldErrors = append(fieldErrors, domain.FieldError{
-			Field:   "firstName",
-			Message: "Поле пустое",
+	fieldErrors, reviewReason, err := s.validateRegistration(ctx, req, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Return errors if any
+	if len(fieldErrors) > 0 {
+		return nil, fieldErrors, nil
+	}
+
+	// Generate verification code
+	code := generateCode()
+	codeExpires := time.Now().UTC().Add(15 * time.Minute)
+
+	// Create registration session
+	session := domain.RegistrationSession{
+		FirstName:             req.FirstName,
+		LastName:              req.LastName,
+		Nickname:              req.Nickname,
+		Email:                 req.Email,
+		AcceptedPrivacyPolicy: req.AcceptedPrivacyPolicy,
+		Code:                  code,
+		CodeExpires:           codeExpires,
+		ReviewReason:          reviewReason,
+		CreatedAt:             time.Now().UTC(),
+	}
+
+	sessionID, err := s.sessionRepo.CreateRegistrationSession(ctx, session)
+	if err != nil {
+		s.logger.Errorf("Error creating registration session: %v", err)
+		return nil, nil, err
+	}
+
+	// Send verification code
+	if err := s.sendRegistrationCode(ctx, req.Email, code); err != nil {
+		return nil, nil, err
+	}
+
+	return &domain.RegistrationSessionResponse{
+		RegistrationSessionID: sessionID,
+		CodeExpires:           codeExpires.Unix(),
+		Code:                  code, // Only for debugging
+	}, nil, nil
+}
+
+// RegisterVerifiedUser registers a user whose email has already been verified by other means, e.g. an invitation
+// delivered to it. The request is validated like in CreateRegistrationSession, but no confirmation code is sent and,
+// the user having been vetted already, the institution and email policy checks are skipped.
+func (s *AuthService) RegisterVerifiedUser(ctx context.Context, req domain.RegistrationRequest) (int64, []domain.FieldError, error) {
+	req.Email = s.normalizeEmail(req.Email)
+
+	fieldErrors, _, err := s.validateRegistration(ctx, req, false)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(fieldErrors) > 0 {
+		return 0, fieldErrors, nil
+	}
+
+	var userID int64
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		userID, err = s.createUser(ctx, domain.User{
+			FirstName:             req.FirstName,
+			LastName:              req.LastName,
+			Nickname:              req.Nickname,
+			Email:                 req.Email,
+			EmailVerified:         true,
+			AcceptedPrivacyPolicy: req.AcceptedPrivacyPolicy,
 		})
+		return err
+	})
+	if err != nil {
+		return 0, nil, err
 	}
 
-	// Validate lastName
-	if req.LastName == "" {
-		fieldErrors = append(fieldErrors, domain.FieldError{
-			Field:   "lastName",
-			Message: "Поле пустое",
+	return userID, nil, nil
+}
+
+// RegisterImportedUser registers a user imported by an admin. The request is validated like in
+// CreateRegistrationSession, except that the user accepts the privacy policy at their first login rather than here,
+// and the email must not be registered yet. The email counts as verified; an email flagged by the email policy puts
+// the user under review. With dryRun the user is only validated.
+func (s *AuthService) RegisterImportedUser(ctx context.Context, req domain.RegistrationRequest, dryRun bool) (int64, []domain.FieldError, error) {
+	req.Email = s.normalizeEmail(req.Email)
+
+	validationErrors, reviewReason, err := s.validateRegistration(ctx, req, true)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var fieldErrors []domain.FieldError
+	emailValid := true
+	for _, fe := range validationErrors {
+		if fe.Field == "acceptedPrivacyPolicy" {
+			continue
+		}
+		if fe.Field == "email" {
+			emailValid = false
+		}
+		fieldErrors = append(fieldErrors, fe)
+	}
+
+	if emailValid {
+		exists, err := s.userRepo.EmailExists(ctx, req.Email)
+		if err != nil {
+			return 0, nil, err
+		}
+		if exists {
+			fieldErrors = append(fieldErrors, emailTakenError)
+		}
+	}
+
+	if len(fieldErrors) > 0 || dryRun {
+		return 0, fieldErrors, nil
+	}
+
+	var userID int64
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		userID, err = s.createUser(ctx, domain.User{
+			FirstName:     req.FirstName,
+			LastName:      req.LastName,
+			Nickname:      req.Nickname,
+			Email:         req.Email,
+			EmailVerified: true,
+			ReviewReason:  reviewReason,
 		})
+		return err
+	})
+	if err != nil {
+		return 0, nil, err
 	}
 
+	return userID, nil, nil
+}
+
+// validateRegistration validates the registration data. Self-service registrations are also subject to the email policy
+// and, when required, must come from the email domain of a registered institution. The returned review reason is set
+// when the email policy flags the registration instead of rejecting it.
+func (s *AuthService) validateRegistration(ctx context.Context, req domain.RegistrationRequest, selfService bool) ([]domain.FieldError, *string, error) {
+	var fieldErrors []domain.FieldError
+	var reviewReason *string
+
+	// Validate firstName and lastName
+	fieldErrors = append(fieldErrors, validateName("firstName", req.FirstName)...)
+	fieldErrors = append(fieldErrors, validateName("lastName", req.LastName)...)
+
 	// Validate nickname
-	if req.Nickname == "" {
-		fieldErrors = append(fieldErrors, domain.FieldError{
-			Field:   "nickname",
-			Message: "Поле пустое",
-		})
-	} else {
-		// Check if nickname contains only allowed characters
-		matched, _ := regexp.MatchString(`^[a-zA-Z0-9]+$`, req.Nickname)
-		if !matched {
-			fieldErrors = append(fieldErrors, domain.FieldError{
-				Field:   "nickname",
-				Message: "В nickname используются запрещённые символы",
-			})
-		} else {
-			// Check if nickname is unique
-			exists, err := s.userRepo.NicknameExists(ctx, req.Nickname)
-			if err != nil {
-				s.logger.Errorf("Error checking nickname existence: %v", err)
-				return nil, nil, err
-			}
-			if exists {
-				fieldErrors = append(fieldErrors, domain.FieldError{
-					Field:   "nickname",
-					Message: "Такой nickname уже существует",
-				})
-			}
-		}
+	nicknameErrors, err := validateNickname(ctx, s.userRepo, req.Nickname)
+	if err != nil {
+		s.logger.Errorf("Error checking nickname existence: %v", err)
+		return nil, nil, err
 	}
+	fieldErrors = append(fieldErrors, nicknameErrors...)
 
 	// Validate email
 	if req.Email == "" {
@@ -145,12 +342,18 @@ func (s *AuthService) CreateRegistrationSession(ctx context.Context, req domain.
 		})
 	} else {
 		// Check if email is valid
-		matched, _ := regexp.MatchString(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`, req.Email)
-		if !matched {
+		if !isEmail(req.Email) {
 			fieldErrors = append(fieldErrors, domain.FieldError{
 				Field:   "email",
 				Message: "Введенная строка не является электронной почтой",
 			})
+		} else if selfService {
+			emailErrors, reason, err := s.checkRegistrationEmail(ctx, req.Email)
+			if err != nil {
+				return nil, nil, err
+			}
+			fieldErrors = append(fieldErrors, emailErrors...)
+			reviewReason = reason
 		}
 	}
 
@@ -162,46 +365,108 @@ func (s *AuthService) CreateRegistrationSession(ctx context.Context, req domain.
 		})
 	}
 
-	// Return errors if any
-	if len(fieldErrors) > 0 {
-		return nil, fieldErrors, nil
+	return fieldErrors, reviewReason, nil
+}
+
+// isEmail reports whether a normalized email is well-formed.
+// Internationalized domains arrive here in their punycode form, e.g. xn--e1afmkfd.xn--p1ai
+func isEmail(email string) bool {
+	matched, _ := regexp.MatchString(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.([a-zA-Z]{2,}|xn--[a-zA-Z0-9-]+)$`, email)
+	return matched
+}
+
+// validateName validates a first or last name
+func validateName(field, name string) []domain.FieldError {
+	if name == "" {
+		return []domain.FieldError{{
+			Field:   field,
+			Message: "Поле пустое",
+		}}
 	}
+	return nil
+}
 
-	// Generate verification code
-	code := generateCode()
-	codeExpires := time.Now().UTC().Add(15 * time.Minute)
+// validateNickname validates the charset of a nickname and checks that it is not taken
+func validateNickname(ctx context.Context, users nicknameChecker, nickname string) ([]domain.FieldError, error) {
+	if nickname == "" {
+		return []domain.FieldError{{
+			Field:   "nickname",
+			Message: "Поле пустое",
+		}}, nil
+	}
 
-	// Create registration session
-	session := domain.RegistrationSession{
-		FirstName:             req.FirstName,
-		LastName:              req.LastName,
-		Nickname:              req.Nickname,
-		Email:                 req.Email,
-		AcceptedPrivacyPolicy: req.AcceptedPrivacyPolicy,
-		Code:                  code,
-		CodeExpires:           codeExpires,
-		CreatedAt:             time.Now().UTC(),
+	// Check if nickname contains only allowed characters
+	matched, _ := regexp.MatchString(`^[a-zA-Z0-9]+$`, nickname)
+	if !matched {
+		return []domain.FieldError{{
+			Field:   "nickname",
+			Message: "В nickname используются запрещённые символы",
+		}}, nil
 	}
 
-	sessionID, err := s.sessionRepo.CreateRegistrationSession(ctx, session)
+	// Check if nickname is unique
+	exists, err := users.NicknameExists(ctx, nickname)
 	if err != nil {
-		s.logger.Errorf("Error creating registration session: %v", err)
-		return nil, nil, err
+		return nil, err
+	}
+	if exists {
+		return []domain.FieldError{nicknameTakenError}, nil
 	}
 
-	// Send verification code
-	err = s.emailSvc.SendVerificationCode(req.Email, code)
+	return nil, nil
+}
+
+// nicknameTakenError is reported when a nickname belongs to another user
+var nicknameTakenError = domain.FieldError{
+	Field:   "nickname",
+	Message: "Такой nickname уже существует",
+}
+
+// emailTakenError is reported when an email belongs to another user
+var emailTakenError = domain.FieldError{
+	Field:   "email",
+	Message: "Электронная почта уже зарегистрирована",
+}
+
+// checkRegistrationEmail applies the email policy and the institution allowlist to a well-formed email
+func (s *AuthService) checkRegistrationEmail(ctx context.Context, email string) ([]domain.FieldError, *string, error) {
+	result, err := s.emailPolicy.Check(ctx, email)
 	if err != nil {
-		s.logger.Errorf("Error sending verification code: %v", err)
-		// We don't want to fail the registration process if email sending fails
-		// Just log the error and continue
+		s.logger.Errorf("Error checking email policy: %v", err)
+		return nil, nil, err
 	}
 
-	return &domain.RegistrationSessionResponse{
-		RegistrationSessionID: sessionID,
-		CodeExpires:           codeExpires.Unix(),
-		Code:                  code, // Only for debugging
-	}, nil, nil
+	var reviewReason *string
+	if result.Reason != "" {
+		if result.Reject {
+			message := "Адреса одноразовой электронной почты не принимаются"
+			if result.Reason == domain.EmailPolicyReasons.NoMX {
+				message = "Домен электронной почты не принимает письма"
+			}
+			return []domain.FieldError{{
+				Field:   "email",
+				Message: message,
+			}}, nil, nil
+		}
+		reviewReason = &result.Reason
+	}
+
+	if s.config.RequireInstitution {
+		// Check if the email belongs to a registered institution
+		emailDomain, patterns := emailDomainPatterns(email)
+		_, err := s.institutions.FindInstitutionByDomains(ctx, patterns)
+		if errors.Is(err, domain.ErrInstitutionNotFound) {
+			return []domain.FieldError{{
+				Field:   "email",
+				Message: fmt.Sprintf("Учебное заведение с доменом %s не зарегистрирован в StudBridge", emailDomain),
+			}}, nil, nil
+		} else if err != nil {
+			s.logger.Errorf("Error looking up institution: %v", err)
+			return nil, nil, err
+		}
+	}
+
+	return nil, reviewReason, nil
 }
 
 // ConfirmEmail confirms a user's email during registration
@@ -227,32 +492,142 @@ func (s *AuthService) ConfirmEmail(ctx context.Context, req domain.ConfirmEmailR
 		Email:                 session.Email,
 		EmailVerified:         true,
 		AcceptedPrivacyPolicy: session.AcceptedPrivacyPolicy,
+		ReviewReason:          session.ReviewReason,
+	}
+
+	// Create the user with its default role and consume the registration session at once
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if _, err := s.createUser(ctx, user); err != nil {
+			return err
+		}
+
+		if err := s.sessionRepo.DeleteRegistrationSession(ctx, req.RegistrationSessionID); err != nil {
+			s.logger.Errorf("Error deleting registration session: %v", err)
+			return err
+		}
+
+		return nil
+	})
+	if err == nil || !errors.Is(err, domain.ErrEmailExists) {
+		return err
+	}
+
+	// The email was registered after the session had been created. Like at registration, the caller
+	// is not told about it; the owner of the account has already been notified.
+	s.logger.Infof("Registration confirmed for an already registered email: %s", session.Email)
+
+	if err := s.sessionRepo.DeleteRegistrationSession(ctx, req.RegistrationSessionID); err != nil {
+		s.logger.Errorf("Error deleting registration session: %v", err)
+		// Don't fail if we can't delete the session
+	}
+
+	return nil
+}
+
+// sendRegistrationCode sends the confirmation code of a registration session. To keep from revealing which emails
+// are registered, registering with an existing email proceeds exactly like a new registration, except that the owner of
+// the account receives a notice with a link to the login page instead of the code.
+func (s *AuthService) sendRegistrationCode(ctx context.Context, email, code string) error {
+	exists, err := s.userRepo.EmailExists(ctx, email)
+	if err != nil {
+		s.logger.Errorf("Error checking email existence: %v", err)
+		return err
+	}
+
+	if exists {
+		loginLink := s.config.LoginURL + "?" + url.Values{"email": {email}}.Encode()
+		if err := s.emailSvc.SendRegistrationAttemptNotice(email, loginLink); err != nil {
+			s.logger.Errorf("Error sending registration attempt notice: %v", err)
+		}
+		return nil
+	}
+
+	if err := s.emailSvc.SendVerificationCode(email, code); err != nil {
+		s.logger.Errorf("Error sending verification code: %v", err)
+		// We don't want to fail the registration process if email sending fails
+		// Just log the error and continue
+	}
+
+	return nil
+}
+
+// normalizeEmail normalizes an email address, leaving malformed input for the validation to report
+func (s *AuthService) normalizeEmail(email string) string {
+	normalized, err := s.emailNorm.Normalize(email)
+	if err != nil {
+		return strings.TrimSpace(email)
+	}
+	return normalized
+}
+
+// emailInstitution finds the institution of the email domain, returning nil when there is none
+func (s *AuthService) emailInstitution(ctx context.Context, email string) (*int64, error) {
+	_, patterns := emailDomainPatterns(email)
+	institution, err := s.institutions.FindInstitutionByDomains(ctx, patterns)
+	if err != nil {
+		if errors.Is(err, domain.ErrInstitutionNotFound) {
+			return nil, nil
+		}
+		s.logger.Errorf("Error looking up institution: %v", err)
+		return nil, err
+	}
+
+	return &institution.ID, nil
+}
+
+// createUser creates a user, recording the institution of their email domain, and assigns the default user role
+func (s *AuthService) createUser(ctx context.Context, user domain.User) (int64, error) {
+	institutionID, err := s.emailInstitution(ctx, user.Email)
+	if err != nil {
+		return 0, err
 	}
+	user.InstitutionID = institutionID
 
 	userID, err := s.userRepo.Create(ctx, user)
 	if err != nil {
 		s.logger.Errorf("Error creating user: %v", err)
-		return err
+		return 0, err
 	}
 
 	// Assign default user role
 	role, err := s.roleRepo.GetRoleByName(ctx, domain.DefaultRoles.User)
 	if err != nil {
 		s.logger.Errorf("Error getting default user role: %v", err)
-		return err
+		return 0, err
 	}
 
 	err = s.roleRepo.AssignRoleToUser(ctx, userID, role.ID)
 	if err != nil {
 		s.logger.Errorf("Error assigning role to user: %v", err)
-		return err
+		return 0, err
+	}
+
+	// Record the acceptance of the privacy policy given at registration against its current version
+	if user.AcceptedPrivacyPolicy {
+		if err := s.acceptCurrentPrivacyPolicy(ctx, userID); err != nil {
+			s.logger.Errorf("Error recording privacy policy acceptance: %v", err)
+			return 0, err
+		}
 	}
 
-	// Delete registration session
-	err = s.sessionRepo.DeleteRegistrationSession(ctx, req.RegistrationSessionID)
+	return userID, nil
+}
+
+// acceptCurrentPrivacyPolicy records that a user has accepted the current version of the privacy policy
+func (s *AuthService) acceptCurrentPrivacyPolicy(ctx context.Context, userID int64) error {
+	documents, err := s.consentRepo.ListCurrentLegalDocuments(ctx, time.Now().UTC())
 	if err != nil {
-		s.logger.Errorf("Error deleting registration session: %v", err)
-		// Don't fail if we can't delete the session
+		return err
+	}
+
+	for _, document := range documents {
+		if document.Kind != domain.LegalDocumentKinds.PrivacyPolicy {
+			continue
+		}
+
+		if err := s.consentRepo.CreateConsent(ctx, domain.Consent{UserID: userID, DocumentID: document.ID}); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -283,11 +658,8 @@ func (s *AuthService) ResendVerificationCode(ctx context.Context, req domain.Res
 	}
 
 	// Send verification code
-	err = s.emailSvc.SendVerificationCode(session.Email, code)
-	if err != nil {
-		s.logger.Errorf("Error sending verification code: %v", err)
-		// We don't want to fail the registration process if email sending fails
-		// Just log the error and continue
+	if err := s.sendRegistrationCode(ctx, session.Email, code); err != nil {
+		return nil, err
 	}
 
 	return &domain.RegistrationSessionResponse{
@@ -299,6 +671,8 @@ func (s *AuthService) ResendVerificationCode(ctx context.Context, req domain.Res
 
 // SendLoginCode sends a login code to a user's email
 func (s *AuthService) SendLoginCode(ctx context.Context, req domain.LoginRequest) (*domain.LoginSessionResponse, error) {
+	req.Email = s.normalizeEmail(req.Email)
+
 	// Generate verification code
 	code := generateCode()
 	codeExpires := time.Now().UTC().Add(15 * time.Minute)
@@ -341,6 +715,8 @@ func (s *AuthService) SendLoginCode(ctx context.Context, req domain.LoginRequest
 
 // ConfirmLogin confirms a login attempt with a verification code
 func (s *AuthService) ConfirmLogin(ctx context.Context, req domain.LoginConfirmRequest, userAgent, ip string) (*domain.TokenResponse, error) {
+	req.Email = s.normalizeEmail(req.Email)
+
 	// Get login session
 	session, err := s.sessionRepo.GetLoginSessionByEmailAndCode(ctx, req.Email, req.Code)
 	if err != nil {
@@ -354,39 +730,65 @@ func (s *AuthService) ConfirmLogin(ctx context.Context, req domain.LoginConfirmR
 		return nil, errors.New("неверный или истекший код подтверждения. Пожалуйста, запросите новый код и попробуйте снова")
 	}
 
-	// Get user roles
-	roles, err := s.roleRepo.GetUserRoleNames(ctx, user.ID)
-	if err != nil {
-		s.logger.Errorf("Error getting user roles: %v", err)
+	// Suspended and banned users are not issued tokens
+	if err := user.AccountError(time.Now().UTC()); err != nil {
 		return nil, err
 	}
 
-	// Generate token pair
-	tokenPair, err := s.tokenSvc.GenerateTokenPair(ctx, user, roles)
+	// Get user roles and permissions
+	grants, err := s.getUserGrants(ctx, user.ID)
 	if err != nil {
-		s.logger.Errorf("Error generating token pair: %v", err)
 		return nil, err
 	}
 
-	// Store refresh token
-	err = s.tokenSvc.StoreRefreshToken(ctx, user.ID, tokenPair.RefreshToken, userAgent, ip)
+	// Record that the user has just proven possession of the email address
+	authCtx := domain.AuthContext{
+		Methods:  []string{domain.AuthMethods.EmailCode},
+		Class:    domain.AuthContextClasses.Basic,
+		AuthTime: time.Now().UTC(),
+	}
+
+	// Hold the login back until the user accepts the current legal documents; the login code is consumed either way
+	required, err := s.consentRepo.ListRequiredDocuments(ctx, user.ID, time.Now().UTC())
 	if err != nil {
-		s.logger.Errorf("Error storing refresh token: %v", err)
 		return nil, err
 	}
+	if len(required) > 0 {
+		return s.requireConsent(ctx, user.ID, authCtx, nil, required, func(ctx context.Context) error {
+			return s.sessionRepo.DeleteLoginSession(ctx, session.ID)
+		})
+	}
 
-	// Delete login session
-	err = s.sessionRepo.DeleteLoginSession(ctx, session.ID)
+	// Generate token pair
+	tokenPair, err := s.tokenSvc.GenerateTokenPair(ctx, user, grants, authCtx)
 	if err != nil {
-		s.logger.Errorf("Error deleting login session: %v", err)
-		// Don't fail if we can't delete the session
+		s.logger.Errorf("Error generating token pair: %v", err)
+		return nil, err
 	}
 
-	return &domain.TokenResponse{
-		AccessToken:  tokenPair.AccessToken,
-		RefreshToken: tokenPair.RefreshToken,
-	}, nil
-}
+	// Store the refresh token and consume the login code at once
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.tokenSvc.StoreRefreshToken(ctx, user.ID, tokenPair.RefreshToken, userAgent, ip, authCtx); err != nil {
+			s.logger.Errorf("Error storing refresh token: %v", err)
+			return err
+		}
+
+		if err := s.sessionRepo.DeleteLoginSession(ctx, session.ID); err != nil {
+			s.logger.Errorf("Error deleting login session: %v", err)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.TokenResponse{
+		AccessToken:  tokenPair.AccessToken,
+		RefreshToken: tokenPair.RefreshToken,
+	}, nil
+}
 
 // RefreshToken refreshes an access token using a refresh token
 func (s *AuthService) RefreshToken(ctx context.Context, req domain.RefreshTokenRequest, userAgent, ip string) (*domain.TokenResponse, error) {
@@ -407,31 +809,55 @@ func (s *AuthService) RefreshToken(ctx context.Context, req domain.RefreshTokenR
 		return nil, errors.New("token invalid")
 	}
 
-	// Get user roles
-	roles, err := s.roleRepo.GetUserRoleNames(ctx, user.ID)
+	if err := user.AccountError(time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	// Get user roles and permissions
+	grants, err := s.getUserGrants(ctx, user.ID)
 	if err != nil {
-		s.logger.Errorf("Error getting user roles: %v", err)
 		return nil, err
 	}
 
-	// Revoke old refresh token
-	err = s.tokenSvc.RevokeRefreshToken(ctx, req.RefreshToken)
+	// Keep the original authentication methods and time; elevation is never carried over a refresh
+	authCtx := domain.AuthContext{
+		Methods:  strings.Fields(tokenSession.AMR),
+		Class:    domain.AuthContextClasses.Basic,
+		AuthTime: tokenSession.AuthTime,
+	}
+
+	// Hold the refresh back until the user accepts the current legal documents; the refresh token is only
+	// rotated once they are accepted
+	required, err := s.consentRepo.ListRequiredDocuments(ctx, user.ID, time.Now().UTC())
 	if err != nil {
-		s.logger.Errorf("Error revoking refresh token: %v", err)
-		// Don't fail if we can't revoke the token
+		return nil, err
+	}
+	if len(required) > 0 {
+		return s.requireConsent(ctx, user.ID, authCtx, &tokenSession.ID, required, nil)
 	}
 
 	// Generate new token pair
-	tokenPair, err := s.tokenSvc.GenerateTokenPair(ctx, user, roles)
+	tokenPair, err := s.tokenSvc.GenerateTokenPair(ctx, user, grants, authCtx)
 	if err != nil {
 		s.logger.Errorf("Error generating token pair: %v", err)
 		return nil, err
 	}
 
-	// Store new refresh token
-	err = s.tokenSvc.StoreRefreshToken(ctx, user.ID, tokenPair.RefreshToken, userAgent, ip)
+	// Rotate the refresh token: the old one is revoked only together with storing the new one
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.tokenSvc.RevokeRefreshToken(ctx, req.RefreshToken); err != nil {
+			s.logger.Errorf("Error revoking refresh token: %v", err)
+			return err
+		}
+
+		if err := s.tokenSvc.StoreRefreshToken(ctx, user.ID, tokenPair.RefreshToken, userAgent, ip, authCtx); err != nil {
+			s.logger.Errorf("Error storing refresh token: %v", err)
+			return err
+		}
+
+		return nil
+	})
 	if err != nil {
-		s.logger.Errorf("Error storing refresh token: %v", err)
 		return nil, err
 	}
 
@@ -441,6 +867,479 @@ func (s *AuthService) RefreshToken(ctx context.Context, req domain.RefreshTokenR
 	}, nil
 }
 
+// requireConsent holds back a login or token refresh until the user accepts the required legal documents. It creates
+// a consent session, together with running cleanup if given, and returns the consent_required response.
+func (s *AuthService) requireConsent(
+	ctx context.Context,
+	userID int64,
+	authCtx domain.AuthContext,
+	tokenSessionID *string,
+	required []domain.LegalDocument,
+	cleanup func(ctx context.Context) error,
+) (*domain.TokenResponse, error) {
+	var consentToken string
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		consentToken, err = s.consentRepo.CreateConsentSession(ctx, domain.ConsentSession{
+			UserID:         userID,
+			TokenSessionID: tokenSessionID,
+			AMR:            strings.Join(authCtx.Methods, " "),
+			AuthTime:       authCtx.AuthTime,
+			ExpiresAt:      time.Now().UTC().Add(15 * time.Minute),
+		})
+		if err != nil {
+			s.logger.Errorf("Error creating consent session: %v", err)
+			return err
+		}
+
+		if cleanup != nil {
+			return cleanup(ctx)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.TokenResponse{
+		Status:            domain.TokenResponseStatuses.ConsentRequired,
+		ConsentToken:      consentToken,
+		RequiredDocuments: required,
+	}, nil
+}
+
+// AcceptConsent records the acceptance of the required legal documents and completes the login or token refresh
+// held back for it, issuing a new token pair
+func (s *AuthService) AcceptConsent(ctx context.Context, req domain.AcceptConsentRequest, userAgent, ip string) (*domain.TokenResponse, error) {
+	session, err := s.consentRepo.GetConsentSession(ctx, req.ConsentToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := user.AccountError(time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	// Every document required now must be accepted; a version published in the meantime is required as well
+	required, err := s.consentRepo.ListRequiredDocuments(ctx, user.ID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	accepted := make(map[int64]bool, len(req.DocumentIDs))
+	for _, id := range req.DocumentIDs {
+		accepted[id] = true
+	}
+	for _, document := range required {
+		if !accepted[document.ID] {
+			return nil, domain.ErrConsentIncomplete
+		}
+	}
+
+	grants, err := s.getUserGrants(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	authCtx := domain.AuthContext{
+		Methods:  strings.Fields(session.AMR),
+		Class:    domain.AuthContextClasses.Basic,
+		AuthTime: session.AuthTime,
+	}
+
+	tokenPair, err := s.tokenSvc.GenerateTokenPair(ctx, user, grants, authCtx)
+	if err != nil {
+		s.logger.Errorf("Error generating token pair: %v", err)
+		return nil, err
+	}
+
+	// Record the consents, replace the refresh token of a held back refresh and consume the consent session at once
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		for _, document := range required {
+			err := s.consentRepo.CreateConsent(ctx, domain.Consent{
+				UserID:     user.ID,
+				DocumentID: document.ID,
+				IP:         ip,
+				UserAgent:  userAgent,
+			})
+			if err != nil {
+				s.logger.Errorf("Error recording consent: %v", err)
+				return err
+			}
+		}
+
+		if session.TokenSessionID != nil {
+			if err := s.sessionRepo.DeleteTokenSession(ctx, *session.TokenSessionID); err != nil {
+				s.logger.Errorf("Error revoking refresh token: %v", err)
+				return err
+			}
+		}
+
+		if err := s.tokenSvc.StoreRefreshToken(ctx, user.ID, tokenPair.RefreshToken, userAgent, ip, authCtx); err != nil {
+			s.logger.Errorf("Error storing refresh token: %v", err)
+			return err
+		}
+
+		return s.consentRepo.DeleteConsentSession(ctx, session.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.TokenResponse{
+		AccessToken:  tokenPair.AccessToken,
+		RefreshToken: tokenPair.RefreshToken,
+	}, nil
+}
+
+// maxReauthAttempts is the number of wrong codes after which a pending re-authentication is dropped
+const maxReauthAttempts = 5
+
+// SendReauthCode sends a re-authentication code to the email of an already authenticated user
+func (s *AuthService) SendReauthCode(ctx context.Context, userID int64) (*domain.ReauthCodeResponse, error) {
+	// Get user by ID
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.Errorf("Error getting user by ID: %v", err)
+		return nil, err
+	}
+
+	// Generate verification code
+	code := generateCode()
+	codeExpires := time.Now().UTC().Add(15 * time.Minute)
+
+	// Create re-authentication session bound to the user; login codes cannot be used to confirm it and vice versa
+	_, err = s.sessionRepo.CreateReauthSession(ctx, domain.ReauthSession{
+		UserID:      user.ID,
+		Code:        code,
+		CodeExpires: codeExpires,
+	})
+	if err != nil {
+		s.logger.Errorf("Error creating re-authentication session: %v", err)
+		return nil, err
+	}
+
+	// Send verification code
+	err = s.emailSvc.SendVerificationCode(user.Email, code)
+	if err != nil {
+		s.logger.Errorf("Error sending verification code: %v", err)
+		// Just log the error and continue
+	}
+
+	// The code is never returned: knowing it must prove access to the mailbox
+	return &domain.ReauthCodeResponse{
+		CodeExpires: codeExpires.Unix(),
+	}, nil
+}
+
+// ConfirmReauth confirms a re-authentication code and issues an elevated access token. The pending
+// re-authentication is dropped after maxReauthAttempts wrong codes.
+func (s *AuthService) ConfirmReauth(ctx context.Context, userID int64, req domain.ReauthConfirmRequest) (*domain.StepUpTokenResponse, error) {
+	invalidCode := errors.New("неверный или истекший код подтверждения. Пожалуйста, запросите новый код и попробуйте снова")
+
+	// Get user by ID
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.Errorf("Error getting user by ID: %v", err)
+		return nil, err
+	}
+
+	// Suspended and banned users are not issued tokens
+	now := time.Now().UTC()
+	if err := user.AccountError(now); err != nil {
+		return nil, err
+	}
+
+	// Get re-authentication session
+	session, err := s.sessionRepo.GetReauthSessionByUser(ctx, user.ID)
+	if err != nil {
+		if !errors.Is(err, domain.ErrReauthSessionNotFound) {
+			s.logger.Errorf("Error getting re-authentication session: %v", err)
+		}
+		return nil, invalidCode
+	}
+
+	if now.After(session.CodeExpires) {
+		return nil, invalidCode
+	}
+
+	if subtle.ConstantTimeCompare([]byte(session.Code), []byte(req.Code)) != 1 {
+		attempts, err := s.sessionRepo.IncrementReauthAttempts(ctx, session.ID)
+		if err != nil {
+			if !errors.Is(err, domain.ErrReauthSessionNotFound) {
+				s.logger.Errorf("Error recording re-authentication attempt: %v", err)
+			}
+			return nil, invalidCode
+		}
+
+		if attempts >= maxReauthAttempts {
+			s.logger.Infof("Re-authentication of user %d dropped after %d wrong codes", user.ID, attempts)
+			if err := s.sessionRepo.DeleteReauthSession(ctx, session.ID); err != nil && !errors.Is(err, domain.ErrReauthSessionNotFound) {
+				s.logger.Errorf("Error deleting re-authentication session: %v", err)
+			}
+		}
+
+		return nil, invalidCode
+	}
+
+	// Consume the code before issuing the token so that it cannot be used twice
+	if err := s.sessionRepo.DeleteReauthSession(ctx, session.ID); err != nil {
+		if !errors.Is(err, domain.ErrReauthSessionNotFound) {
+			s.logger.Errorf("Error deleting re-authentication session: %v", err)
+			return nil, err
+		}
+		return nil, invalidCode
+	}
+
+	// Get user roles and permissions
+	grants, err := s.getUserGrants(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	authCtx := domain.AuthContext{
+		Methods:  []string{domain.AuthMethods.EmailCode},
+		Class:    domain.AuthContextClasses.Elevated,
+		AuthTime: now,
+	}
+
+	// Generate elevated access token; the refresh token session is left untouched
+	accessToken, err := s.tokenSvc.GenerateAccessToken(ctx, user, grants, authCtx)
+	if err != nil {
+		s.logger.Errorf("Error generating access token: %v", err)
+		return nil, err
+	}
+
+	return &domain.StepUpTokenResponse{
+		AccessToken: accessToken,
+		AuthTime:    authCtx.AuthTime.Unix(),
+	}, nil
+}
+
+// RequestEmailChange starts changing the email of a user. A confirmation code is sent to the new address and a notice
+// with a cancel link to the current one. To keep from revealing which emails are registered, a request for an address
+// that already has an account proceeds the same way, except that no code is sent to it.
+func (s *AuthService) RequestEmailChange(ctx context.Context, userID int64, req domain.ChangeEmailRequest) (*domain.ChangeEmailResponse, []domain.FieldError, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.Errorf("Error getting user by ID: %v", err)
+		return nil, nil, err
+	}
+
+	email := s.normalizeEmail(req.Email)
+
+	var fieldErrors []domain.FieldError
+	var reviewReason *string
+	switch {
+	case email == "":
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "email",
+			Message: "Поле пустое",
+		})
+	case !isEmail(email):
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "email",
+			Message: "Введенная строка не является электронной почтой",
+		})
+	case email == user.Email:
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "email",
+			Message: "Совпадает с текущей электронной почтой",
+		})
+	default:
+		// The new address is subject to the rules of self-service registration
+		fieldErrors, reviewReason, err = s.checkRegistrationEmail(ctx, email)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return nil, fieldErrors, nil
+	}
+
+	code := generateCode()
+	codeExpires := time.Now().UTC().Add(15 * time.Minute)
+	cancelToken := uuid.New().String()
+
+	_, err = s.sessionRepo.CreateEmailChangeSession(ctx, domain.EmailChangeSession{
+		UserID:       user.ID,
+		NewEmail:     email,
+		Code:         code,
+		CodeExpires:  codeExpires,
+		CancelToken:  cancelToken,
+		ReviewReason: reviewReason,
+	})
+	if err != nil {
+		s.logger.Errorf("Error creating email change session: %v", err)
+		return nil, nil, err
+	}
+
+	exists, err := s.userRepo.EmailExists(ctx, email)
+	if err != nil {
+		s.logger.Errorf("Error checking email existence: %v", err)
+		return nil, nil, err
+	}
+
+	if exists {
+		s.logger.Infof("Email change of user %d requested to an already registered email", user.ID)
+	} else if err := s.emailSvc.SendVerificationCode(email, code); err != nil {
+		s.logger.Errorf("Error sending verification code: %v", err)
+		// Just log the error and continue
+	}
+
+	cancelLink := s.emailChange.CancelURL + "?" + url.Values{"token": {cancelToken}}.Encode()
+	if err := s.emailSvc.SendEmailChangeNotice(user.Email, email, cancelLink); err != nil {
+		s.logger.Errorf("Error sending email change notice: %v", err)
+		// Just log the error and continue
+	}
+
+	// The code is never returned: confirming it must prove access to the new address
+	return &domain.ChangeEmailResponse{
+		CodeExpires: codeExpires.Unix(),
+	}, nil, nil
+}
+
+// ConfirmEmailChange changes the email of a user after the code sent to the new address has been confirmed.
+// Existing access tokens carrying the previous email are invalidated and, when configured, so are all refresh tokens.
+func (s *AuthService) ConfirmEmailChange(ctx context.Context, userID int64, req domain.ConfirmEmailChangeRequest) error {
+	invalidCode := errors.New("Неверный или истекший код подтверждения. Пожалуйста, запросите новый код и попробуйте снова")
+
+	session, err := s.sessionRepo.GetEmailChangeSessionByUser(ctx, userID)
+	if err != nil {
+		if !errors.Is(err, domain.ErrEmailChangeNotFound) {
+			s.logger.Errorf("Error getting email change session: %v", err)
+		}
+		return invalidCode
+	}
+
+	if session.Code != req.Code || time.Now().UTC().After(session.CodeExpires) {
+		return invalidCode
+	}
+
+	institutionID, err := s.emailInstitution(ctx, session.NewEmail)
+	if err != nil {
+		return err
+	}
+
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.UpdateEmail(ctx, userID, session.NewEmail, institutionID, session.ReviewReason); err != nil {
+			return err
+		}
+
+		if err := s.sessionRepo.DeleteEmailChangeSession(ctx, session.ID); err != nil {
+			return err
+		}
+
+		if err := s.userRepo.IncrementAuthzVersion(ctx, userID); err != nil {
+			return err
+		}
+
+		if s.emailChange.RevokeSessions {
+			return s.sessionRepo.DeleteUserTokenSessions(ctx, userID)
+		}
+
+		return nil
+	})
+	if err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, domain.ErrEmailExists) {
+		s.logger.Errorf("Error changing email: %v", err)
+		return err
+	}
+
+	// The new address belongs to another account, which the caller is not told about
+	if err := s.sessionRepo.DeleteEmailChangeSession(ctx, session.ID); err != nil {
+		s.logger.Errorf("Error deleting email change session: %v", err)
+	}
+
+	return invalidCode
+}
+
+// CancelEmailChange cancels a pending email change from the link sent to the current address
+func (s *AuthService) CancelEmailChange(ctx context.Context, req domain.CancelEmailChangeRequest) error {
+	if _, err := uuid.Parse(req.Token); err != nil {
+		return nil
+	}
+
+	err := s.sessionRepo.DeleteEmailChangeSessionByCancelToken(ctx, req.Token)
+	if err != nil && !errors.Is(err, domain.ErrEmailChangeNotFound) {
+		s.logger.Errorf("Error cancelling email change: %v", err)
+		return err
+	}
+
+	// Cancelling is idempotent; an unknown token is not reported
+	return nil
+}
+
+// SetVerifiedEmail changes the email of a user without a confirmation code, e.g. on behalf of a provisioning client
+// that owns the address. The address is subject to the rules of self-service registration; access tokens carrying
+// the previous email are invalidated.
+func (s *AuthService) SetVerifiedEmail(ctx context.Context, userID int64, email string) ([]domain.FieldError, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	email = s.normalizeEmail(email)
+
+	switch {
+	case email == "":
+		return []domain.FieldError{{
+			Field:   "email",
+			Message: "Поле пустое",
+		}}, nil
+	case !isEmail(email):
+		return []domain.FieldError{{
+			Field:   "email",
+			Message: "Введенная строка не является электронной почтой",
+		}}, nil
+	case email == user.Email:
+		return nil, nil
+	}
+
+	fieldErrors, reviewReason, err := s.checkRegistrationEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if len(fieldErrors) > 0 {
+		return fieldErrors, nil
+	}
+
+	institutionID, err := s.emailInstitution(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.UpdateEmail(ctx, userID, email, institutionID, reviewReason); err != nil {
+			return err
+		}
+
+		if err := s.userRepo.UpdateEmailVerificationStatus(ctx, userID, true); err != nil {
+			return err
+		}
+
+		return s.userRepo.IncrementAuthzVersion(ctx, userID)
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrEmailExists) {
+			return []domain.FieldError{emailTakenError}, nil
+		}
+		s.logger.Errorf("Error changing email: %v", err)
+		return nil, err
+	}
+
+	return nil, nil
+}
+
 // GetUserByID retrieves a user by ID
 func (s *AuthService) GetUserByID(ctx context.Context, id int64) (domain.User, error) {
 	return s.userRepo.GetByID(ctx, id)
@@ -456,6 +1355,113 @@ func (s *AuthService) HasRole(ctx context.Context, userID int64, roleName string
 	return s.roleRepo.HasRole(ctx, userID, roleName)
 }
 
+// HasOrgRole checks if a user has a specific role within an organization
+func (s *AuthService) HasOrgRole(ctx context.Context, userID, orgID int64, roleName string) (bool, error) {
+	return s.orgRepo.HasOrgRole(ctx, userID, orgID, roleName)
+}
+
+// IsOrgMember checks if a user is a member of an organization
+func (s *AuthService) IsOrgMember(ctx context.Context, userID, orgID int64) (bool, error) {
+	return s.orgRepo.IsMember(ctx, orgID, userID)
+}
+
+// HasPermission checks if a user has a specific permission through any of their roles
+func (s *AuthService) HasPermission(ctx context.Context, userID int64, permissionName string) (bool, error) {
+	return s.permissionRepo.HasPermission(ctx, userID, permissionName)
+}
+
+// CheckBatch checks many (user, role or permission) pairs at once, querying the grants of each distinct user once.
+// Results are returned in the order of the checks.
+func (s *AuthService) CheckBatch(ctx context.Context, checks []domain.AuthorizationCheck) ([]domain.AuthorizationCheckResult, error) {
+	if len(checks) > domain.MaxAuthorizationChecks {
+		return nil, domain.ErrBatchTooLarge
+	}
+
+	type grantSet struct {
+		roles       map[string]bool
+		permissions map[string]bool
+	}
+
+	grantsByUser := make(map[int64]grantSet)
+	results := make([]domain.AuthorizationCheckResult, len(checks))
+
+	for i, check := range checks {
+		if (check.Role == "") == (check.Permission == "") {
+			results[i].Error = "exactly one of role or permission must be set"
+			continue
+		}
+
+		grants, ok := grantsByUser[check.UserID]
+		if !ok {
+			userGrants, err := s.permissionRepo.GetUserGrants(ctx, check.UserID)
+			if err != nil {
+				s.logger.Errorf("Error getting grants of user %d: %v", check.UserID, err)
+				return nil, err
+			}
+
+			grants = grantSet{
+				roles:       make(map[string]bool, len(userGrants.Roles)),
+				permissions: make(map[string]bool, len(userGrants.Permissions)),
+			}
+			for _, role := range userGrants.Roles {
+				grants.roles[role] = true
+			}
+			for _, permission := range userGrants.Permissions {
+				grants.permissions[permission] = true
+			}
+			grantsByUser[check.UserID] = grants
+		}
+
+		if check.Role != "" {
+			results[i].Allowed = grants.roles[check.Role]
+		} else {
+			results[i].Allowed = grants.permissions[check.Permission]
+		}
+	}
+
+	return results, nil
+}
+
+// GetUserPermissions retrieves the effective permissions of a user
+func (s *AuthService) GetUserPermissions(ctx context.Context, userID int64) ([]string, error) {
+	return s.permissionRepo.GetUserPermissionNames(ctx, userID)
+}
+
+// getUserGrants collects the roles and permissions to embed into a user's access token
+func (s *AuthService) getUserGrants(ctx context.Context, userID int64) (domain.UserGrants, error) {
+	roles, err := s.roleRepo.GetUserRoleNames(ctx, userID)
+	if err != nil {
+		s.logger.Errorf("Error getting user roles: %v", err)
+		return domain.UserGrants{}, err
+	}
+
+	permissions, err := s.permissionRepo.GetUserPermissionNames(ctx, userID)
+	if err != nil {
+		s.logger.Errorf("Error getting user permissions: %v", err)
+		return domain.UserGrants{}, err
+	}
+
+	memberships, err := s.orgRepo.GetUserMemberships(ctx, userID)
+	if err != nil {
+		s.logger.Errorf("Error getting user memberships: %v", err)
+		return domain.UserGrants{}, err
+	}
+
+	// Access tokens must not outlive a time-bound role grant they carry
+	expiresAt, err := s.roleRepo.GetEarliestRoleExpiry(ctx, userID)
+	if err != nil {
+		s.logger.Errorf("Error getting role grant expiry: %v", err)
+		return domain.UserGrants{}, err
+	}
+
+	return domain.UserGrants{
+		Roles:       roles,
+		Permissions: permissions,
+		Memberships: memberships,
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
 // generateCode generates a random 4-digit verification code
 func generateCode() string {
 	// Seed the random number generator
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"authmicro/internal/domain"
+)
+
+// fakePermissionRepository serves fixed grants per user and counts how often each user's grants are loaded
+type fakePermissionRepository struct {
+	permissionRepository
+	grants map[int64]domain.UserGrants
+	loads  map[int64]int
+}
+
+func (r *fakePermissionRepository) GetUserGrants(ctx context.Context, userID int64) (domain.UserGrants, error) {
+	r.loads[userID]++
+	return r.grants[userID], nil
+}
+
+func TestAuthServiceCheckBatch(t *testing.T) {
+	repo := &fakePermissionRepository{
+		grants: map[int64]domain.UserGrants{
+			1: {Roles: []string{"admin"}, Permissions: []string{"roles:manage", "users:read"}},
+			2: {Roles: []string{"user"}, Permissions: []string{"users:read"}},
+		},
+		loads: make(map[int64]int),
+	}
+	s := &AuthService{permissionRepo: repo, logger: nopLogger{}}
+
+	checks := []domain.AuthorizationCheck{
+		{UserID: 1, Role: "admin"},
+		{UserID: 2, Role: "admin"},
+		{UserID: 1, Permission: "roles:manage"},
+		{UserID: 2, Permission: "users:read"},
+		{UserID: 2, Permission: "roles:manage"},
+		{UserID: 3, Role: "user"},
+		{UserID: 1, Role: "admin", Permission: "roles:manage"},
+		{UserID: 1},
+	}
+	want := []domain.AuthorizationCheckResult{
+		{Allowed: true},
+		{Allowed: false},
+		{Allowed: true},
+		{Allowed: true},
+		{Allowed: false},
+		{Allowed: false},
+		{Error: "exactly one of role or permission must be set"},
+		{Error: "exactly one of role or permission must be set"},
+	}
+
+	results, err := s.CheckBatch(context.Background(), checks)
+	if err != nil {
+		t.Fatalf("CheckBatch() error = %v", err)
+	}
+	if len(results) != len(want) {
+		t.Fatalf("CheckBatch() returned %d results, want %d", len(results), len(want))
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("CheckBatch() result %d = %+v, want %+v", i, results[i], want[i])
+		}
+	}
+
+	for userID, loads := range repo.loads {
+		if loads != 1 {
+			t.Errorf("grants of user %d loaded %d times, want once", userID, loads)
+		}
+	}
+	if len(repo.loads) != 3 {
+		t.Errorf("grants loaded for %d users, want 3", len(repo.loads))
+	}
+}
+
+func TestAuthServiceCheckBatchTooLarge(t *testing.T) {
+	repo := &fakePermissionRepository{loads: make(map[int64]int)}
+	s := &AuthService{permissionRepo: repo, logger: nopLogger{}}
+
+	checks := make([]domain.AuthorizationCheck, domain.MaxAuthorizationChecks)
+	for i := range checks {
+		checks[i] = domain.AuthorizationCheck{UserID: int64(i), Permission: "users:read"}
+	}
+
+	if _, err := s.CheckBatch(context.Background(), checks); err != nil {
+		t.Fatalf("CheckBatch() with %d checks error = %v", len(checks), err)
+	}
+
+	repo.loads = make(map[int64]int)
+	checks = append(checks, domain.AuthorizationCheck{UserID: 1, Permission: "users:read"})
+	if _, err := s.CheckBatch(context.Background(), checks); !errors.Is(err, domain.ErrBatchTooLarge) {
+		t.Fatalf("CheckBatch() with %d checks error = %v, want ErrBatchTooLarge", len(checks), err)
+	}
+	if len(repo.loads) != 0 {
+		t.Errorf("CheckBatch() loaded grants for a batch that is too large")
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type legalDocumentRepository interface {
+	CreateLegalDocument(ctx context.Context, document domain.LegalDocument) (domain.LegalDocument, error)
+	ListLegalDocuments(ctx context.Context) ([]domain.LegalDocument, error)
+	ListCurrentLegalDocuments(ctx context.Context, now time.Time) ([]domain.LegalDocument, error)
+	ListUserConsents(ctx context.Context, userID int64) ([]domain.Consent, error)
+}
+
+// ConsentService implements management of the versioned legal documents and of the users' acceptances of them
+type ConsentService struct {
+	consentRepo legalDocumentRepository
+	logger      logger.Logger
+}
+
+func NewConsentService(consentRepo legalDocumentRepository, logger logger.Logger) *ConsentService {
+	return &ConsentService{
+		consentRepo: consentRepo,
+		logger:      logger,
+	}
+}
+
+// ListDocuments retrieves all versions of the legal documents
+func (s *ConsentService) ListDocuments(ctx context.Context) ([]domain.LegalDocument, error) {
+	return s.consentRepo.ListLegalDocuments(ctx)
+}
+
+// ListCurrentDocuments retrieves the current version of each kind of legal document
+func (s *ConsentService) ListCurrentDocuments(ctx context.Context) ([]domain.LegalDocument, error) {
+	return s.consentRepo.ListCurrentLegalDocuments(ctx, time.Now().UTC())
+}
+
+// ListUserConsents retrieves the legal documents accepted by a user
+func (s *ConsentService) ListUserConsents(ctx context.Context, userID int64) ([]domain.Consent, error) {
+	return s.consentRepo.ListUserConsents(ctx, userID)
+}
+
+// PublishDocument publishes a new version of a legal document. From its publication time on, users must accept it
+// before they are issued tokens again.
+func (s *ConsentService) PublishDocument(ctx context.Context, actorID int64, req domain.PublishLegalDocumentRequest) (domain.LegalDocument, []domain.FieldError, error) {
+	document := domain.LegalDocument{
+		Kind:        req.Kind,
+		Version:     strings.TrimSpace(req.Version),
+		URL:         strings.TrimSpace(req.URL),
+		PublishedAt: time.Now().UTC(),
+		CreatedBy:   &actorID,
+	}
+	if req.PublishedAt != nil {
+		document.PublishedAt = req.PublishedAt.UTC()
+	}
+
+	var fieldErrors []domain.FieldError
+
+	if document.Kind != domain.LegalDocumentKinds.PrivacyPolicy && document.Kind != domain.LegalDocumentKinds.Terms {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "kind",
+			Message: "Допустимые значения: privacy_policy, terms",
+		})
+	}
+
+	if document.Version == "" {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "version",
+			Message: "Поле пустое",
+		})
+	} else if len(document.Version) > 50 {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "version",
+			Message: "Версия не может быть длиннее 50 символов",
+		})
+	}
+
+	if document.URL == "" {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "url",
+			Message: "Поле пустое",
+		})
+	} else if u, err := url.Parse(document.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "url",
+			Message: "Некорректный адрес документа",
+		})
+	}
+
+	if len(fieldErrors) > 0 {
+		return domain.LegalDocument{}, fieldErrors, nil
+	}
+
+	document, err := s.consentRepo.CreateLegalDocument(ctx, document)
+	if err != nil {
+		return domain.LegalDocument{}, nil, err
+	}
+
+	s.logger.Infof("Published %s version %s", document.Kind, document.Version)
+
+	return document, nil, nil
+}
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"time"
+
+	"authmicro/configs"
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+// personalDataFileName is the name of the JSON document inside ZIP exports
+const personalDataFileName = "personal-data.json"
+
+// maxDataExportAttempts is how many times an export is built before it is failed, so that an export crashing its
+// worker is not retried forever
+const maxDataExportAttempts = 3
+
+type dataExportRepository interface {
+	CreateDataExport(ctx context.Context, userID int64, format string) (domain.DataExport, error)
+	GetDataExport(ctx context.Context, userID int64, id string) (domain.DataExport, error)
+	GetUnfinishedDataExport(ctx context.Context, userID int64) (domain.DataExport, error)
+	GetDataExportByDownloadToken(ctx context.Context, token string) (domain.DataExport, error)
+	ClaimPendingDataExport(ctx context.Context, staleBefore time.Time, maxAttempts int) (domain.DataExport, error)
+	CompleteDataExport(ctx context.Context, id string, attempt int, data []byte, expiresAt time.Time) error
+	FailDataExport(ctx context.Context, id string, attempt int, expiresAt time.Time) error
+	FailStaleDataExports(ctx context.Context, staleBefore time.Time, maxAttempts int, expiresAt time.Time) error
+	DeleteExpiredDataExports(ctx context.Context) error
+}
+
+type personalDataConsentRepository interface {
+	ListUserConsents(ctx context.Context, userID int64) ([]domain.Consent, error)
+}
+
+type personalDataSessionRepository interface {
+	ListUserTokenSessions(ctx context.Context, userID int64) ([]domain.TokenSession, error)
+	ListRegistrationSessionsByEmail(ctx context.Context, email string) ([]domain.RegistrationSession, error)
+	ListLoginSessionsByEmail(ctx context.Context, email string) ([]domain.LoginSession, error)
+	GetEmailChangeSessionByUser(ctx context.Context, userID int64) (domain.EmailChangeSession, error)
+}
+
+type personalDataUserRepository interface {
+	GetByID(ctx context.Context, id int64) (domain.User, error)
+}
+
+type personalDataRoleRepository interface {
+	GetUserRoleGrants(ctx context.Context, userID int64) ([]domain.RoleGrant, error)
+}
+
+type personalDataOrgRepository interface {
+	GetUserMemberships(ctx context.Context, userID int64) ([]domain.Membership, error)
+}
+
+type personalDataNicknameRepository interface {
+	ListNicknameHistory(ctx context.Context, userID int64) ([]domain.NicknameChange, error)
+}
+
+type dataExportEmailService interface {
+	SendDataExportReady(to, downloadLink string, expiresAt time.Time) error
+}
+
+// DataExportService builds exports of everything the service holds on a user. Exports are requested by the user,
+// built in the background and downloaded with a link emailed to the user.
+type DataExportService struct {
+	exportRepo   dataExportRepository
+	userRepo     personalDataUserRepository
+	roleRepo     personalDataRoleRepository
+	orgRepo      personalDataOrgRepository
+	nicknameRepo personalDataNicknameRepository
+	consentRepo  personalDataConsentRepository
+	sessionRepo  personalDataSessionRepository
+	emailSvc     dataExportEmailService
+	config       configs.DataExportConfig
+	logger       logger.Logger
+}
+
+func NewDataExportService(
+	exportRepo dataExportRepository,
+	userRepo personalDataUserRepository,
+	roleRepo personalDataRoleRepository,
+	orgRepo personalDataOrgRepository,
+	nicknameRepo personalDataNicknameRepository,
+	consentRepo personalDataConsentRepository,
+	sessionRepo personalDataSessionRepository,
+	emailSvc dataExportEmailService,
+	config configs.DataExportConfig,
+	logger logger.Logger,
+) *DataExportService {
+	return &DataExportService{
+		exportRepo:   exportRepo,
+		userRepo:     userRepo,
+		roleRepo:     roleRepo,
+		orgRepo:      orgRepo,
+		nicknameRepo: nicknameRepo,
+		consentRepo:  consentRepo,
+		sessionRepo:  sessionRepo,
+		emailSvc:     emailSvc,
+		config:       config,
+		logger:       logger,
+	}
+}
+
+// RequestExport queues an export of the user's personal data. While an export of the user is still being built,
+// it is returned instead of queueing another one.
+func (s *DataExportService) RequestExport(ctx context.Context, userID int64, req domain.DataExportRequest) (domain.DataExport, error) {
+	format := req.Format
+	if format == "" {
+		format = domain.DataExportFormats.JSON
+	}
+	if format != domain.DataExportFormats.JSON && format != domain.DataExportFormats.ZIP {
+		return domain.DataExport{}, domain.ErrInvalidExportFormat
+	}
+
+	export, err := s.exportRepo.GetUnfinishedDataExport(ctx, userID)
+	if err == nil {
+		return export, nil
+	}
+	if !errors.Is(err, domain.ErrDataExportNotFound) {
+		return domain.DataExport{}, err
+	}
+
+	return s.exportRepo.CreateDataExport(ctx, userID, format)
+}
+
+// GetExport retrieves the status of an export of the user
+func (s *DataExportService) GetExport(ctx context.Context, userID int64, id string) (domain.DataExport, error) {
+	return s.exportRepo.GetDataExport(ctx, userID, id)
+}
+
+// Download retrieves a ready export with its data by the emailed download token
+func (s *DataExportService) Download(ctx context.Context, token string) (domain.DataExport, error) {
+	if token == "" {
+		return domain.DataExport{}, domain.ErrDataExportNotFound
+	}
+
+	return s.exportRepo.GetDataExportByDownloadToken(ctx, token)
+}
+
+// ProcessPendingExports builds the pending exports one by one and emails their download links. Exports left
+// processing for longer than the processing timeout are built again, up to maxDataExportAttempts times, then failed.
+func (s *DataExportService) ProcessPendingExports(ctx context.Context) error {
+	staleBefore := time.Now().UTC().Add(-s.config.ProcessingTimeout)
+	if err := s.exportRepo.FailStaleDataExports(ctx, staleBefore, maxDataExportAttempts, time.Now().UTC().Add(s.config.TTL)); err != nil {
+		return err
+	}
+
+	for {
+		export, err := s.exportRepo.ClaimPendingDataExport(ctx, staleBefore, maxDataExportAttempts)
+		if err != nil {
+			if errors.Is(err, domain.ErrDataExportNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		s.processExport(ctx, export)
+	}
+}
+
+// processExport builds a claimed export and emails its download link; failures are recorded on the export
+func (s *DataExportService) processExport(ctx context.Context, export domain.DataExport) {
+	expiresAt := time.Now().UTC().Add(s.config.TTL)
+
+	data, err := s.collect(ctx, export.UserID)
+	if err == nil {
+		var content []byte
+		content, err = encodeExport(data, export.Format)
+		if err == nil {
+			err = s.exportRepo.CompleteDataExport(ctx, export.ID, export.Attempts, content, expiresAt)
+		}
+	}
+	if errors.Is(err, domain.ErrDataExportNotFound) {
+		// The claim went stale while the export was being built and another worker took over
+		s.logger.Warnf("Data export %s was claimed again while being built", export.ID)
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("Error building data export %s: %v", export.ID, err)
+		if err := s.exportRepo.FailDataExport(ctx, export.ID, export.Attempts, expiresAt); err != nil {
+			s.logger.Errorf("Error marking data export %s as failed: %v", export.ID, err)
+		}
+		return
+	}
+
+	link := s.config.DownloadURL + "?" + url.Values{"token": {export.DownloadToken}}.Encode()
+	if err := s.emailSvc.SendDataExportReady(data.Profile.Email, link, expiresAt); err != nil {
+		s.logger.Errorf("Error sending data export link: %v", err)
+		// Just log the error and continue
+	}
+}
+
+// collect gathers everything the service holds on a user
+func (s *DataExportService) collect(ctx context.Context, userID int64) (*domain.PersonalData, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &domain.PersonalData{
+		ExportedAt:           time.Now().UTC(),
+		Profile:              user,
+		TokenSessions:        []domain.TokenSessionData{},
+		RegistrationSessions: []domain.RegistrationSessionData{},
+		LoginSessions:        []domain.LoginSessionData{},
+	}
+
+	if data.Roles, err = s.roleRepo.GetUserRoleGrants(ctx, userID); err != nil {
+		return nil, err
+	}
+	if data.Memberships, err = s.orgRepo.GetUserMemberships(ctx, userID); err != nil {
+		return nil, err
+	}
+	if data.NicknameHistory, err = s.nicknameRepo.ListNicknameHistory(ctx, userID); err != nil {
+		return nil, err
+	}
+	if data.Consents, err = s.consentRepo.ListUserConsents(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	tokenSessions, err := s.sessionRepo.ListUserTokenSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range tokenSessions {
+		data.TokenSessions = append(data.TokenSessions, tokenSessionData(session))
+	}
+
+	registrationSessions, err := s.sessionRepo.ListRegistrationSessionsByEmail(ctx, user.Email)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range registrationSessions {
+		data.RegistrationSessions = append(data.RegistrationSessions, domain.RegistrationSessionData{
+			ID:                    session.ID,
+			FirstName:             session.FirstName,
+			LastName:              session.LastName,
+			Nickname:              session.Nickname,
+			Email:                 session.Email,
+			AcceptedPrivacyPolicy: session.AcceptedPrivacyPolicy,
+			CodeExpires:           session.CodeExpires,
+			CreatedAt:             session.CreatedAt,
+		})
+	}
+
+	loginSessions, err := s.sessionRepo.ListLoginSessionsByEmail(ctx, user.Email)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range loginSessions {
+		data.LoginSessions = append(data.LoginSessions, domain.LoginSessionData{
+			ID:          session.ID,
+			Email:       session.Email,
+			CodeExpires: session.CodeExpires,
+			CreatedAt:   session.CreatedAt,
+		})
+	}
+
+	emailChange, err := s.sessionRepo.GetEmailChangeSessionByUser(ctx, userID)
+	switch {
+	case err == nil:
+		data.EmailChange = &domain.EmailChangeSessionData{
+			NewEmail:    emailChange.NewEmail,
+			CodeExpires: emailChange.CodeExpires,
+			CreatedAt:   emailChange.CreatedAt,
+		}
+	case !errors.Is(err, domain.ErrEmailChangeNotFound):
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// encodeExport renders the personal data as JSON, packed into a ZIP archive for the ZIP format
+func encodeExport(data *domain.PersonalData, format string) ([]byte, error) {
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if format != domain.DataExportFormats.ZIP {
+		return content, nil
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	file, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     personalDataFileName,
+		Method:   zip.Deflate,
+		Modified: data.ExportedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(content); err != nil {
+		return nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// RunExportProcessing periodically builds the pending exports and purges the expired ones until the context is cancelled
+func (s *DataExportService) RunExportProcessing(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.ProcessPendingExports(ctx); err != nil {
+				s.logger.Errorf("Error processing data exports: %v", err)
+			}
+			if err := s.exportRepo.DeleteExpiredDataExports(ctx); err != nil {
+				s.logger.Errorf("Error deleting expired data exports: %v", err)
+			}
+		}
+	}
+}
+
+// tokenSessionData converts a token session to the form shown to users and admins, without the refresh token
+func tokenSessionData(session domain.TokenSession) domain.TokenSessionData {
+	return domain.TokenSessionData{
+		ID:        session.ID,
+		UserAgent: session.UserAgent,
+		IP:        session.IP,
+		AMR:       session.AMR,
+		AuthTime:  session.AuthTime,
+		ExpiresAt: session.ExpiresAt,
+		CreatedAt: session.CreatedAt,
+	}
+}
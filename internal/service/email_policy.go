@@ -0,0 +1,192 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"authmicro/configs"
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+//go:embed disposable_domains.txt
+var bundledDisposableDomains string
+
+// MXResolver looks up the mail exchangers of a domain; *net.Resolver satisfies it
+type MXResolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+}
+
+// EmailPolicy checks registration addresses against a disposable-domain blocklist and, optionally,
+// that their domain accepts mail. The blocklist combines a bundled list with an optional file that is re-read periodically.
+type EmailPolicy struct {
+	resolver MXResolver
+	config   configs.EmailPolicyConfig
+	logger   logger.Logger
+
+	mu        sync.RWMutex
+	blocklist map[string]struct{}
+}
+
+// NewEmailPolicy creates an email policy. A nil resolver uses the system resolver.
+func NewEmailPolicy(resolver MXResolver, config configs.EmailPolicyConfig, logger logger.Logger) *EmailPolicy {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	if config.Action != domain.EmailPolicyActions.Reject && config.Action != domain.EmailPolicyActions.Flag {
+		logger.Errorf("Unknown email policy action %q, rejecting failing addresses", config.Action)
+		config.Action = domain.EmailPolicyActions.Reject
+	}
+
+	p := &EmailPolicy{
+		resolver: resolver,
+		config:   config,
+		logger:   logger,
+	}
+	if err := p.ReloadBlocklist(); err != nil {
+		logger.Errorf("Error loading email blocklist file, using the bundled list only: %v", err)
+	}
+
+	return p
+}
+
+// Check evaluates an email address. A zero result means the address passed.
+func (p *EmailPolicy) Check(ctx context.Context, email string) (domain.EmailPolicyResult, error) {
+	host, _ := emailDomainPatterns(email)
+	if host == "" {
+		return domain.EmailPolicyResult{}, nil
+	}
+
+	reason := ""
+	if p.isDisposable(host) {
+		reason = domain.EmailPolicyReasons.Disposable
+	} else if p.config.CheckMX {
+		acceptsMail, err := p.acceptsMail(ctx, host)
+		if err != nil {
+			// DNS outages must not block registrations
+			p.logger.Errorf("Error looking up MX records of %s: %v", host, err)
+		} else if !acceptsMail {
+			reason = domain.EmailPolicyReasons.NoMX
+		}
+	}
+
+	if reason == "" {
+		return domain.EmailPolicyResult{}, nil
+	}
+
+	return domain.EmailPolicyResult{
+		Reason: reason,
+		Reject: p.config.Action != domain.EmailPolicyActions.Flag,
+	}, nil
+}
+
+// ReloadBlocklist rebuilds the blocklist from the bundled list and the configured file
+func (p *EmailPolicy) ReloadBlocklist() error {
+	blocklist := make(map[string]struct{})
+	readDomainList(strings.NewReader(bundledDisposableDomains), blocklist)
+
+	var fileErr error
+	if p.config.BlocklistFile != "" {
+		f, err := os.Open(p.config.BlocklistFile)
+		if err != nil {
+			fileErr = err
+		} else {
+			readDomainList(f, blocklist)
+			f.Close()
+		}
+	}
+
+	p.mu.Lock()
+	p.blocklist = blocklist
+	p.mu.Unlock()
+
+	return fileErr
+}
+
+// RunBlocklistReload periodically re-reads the blocklist file until the context is cancelled
+func (p *EmailPolicy) RunBlocklistReload(ctx context.Context, interval time.Duration) {
+	if p.config.BlocklistFile == "" || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.ReloadBlocklist(); err != nil {
+				p.logger.Errorf("Error reloading email blocklist: %v", err)
+			}
+		}
+	}
+}
+
+// isDisposable reports whether the domain or one of its parent domains is blocklisted
+func (p *EmailPolicy) isDisposable(host string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for rest := host; rest != ""; {
+		if _, ok := p.blocklist[rest]; ok {
+			return true
+		}
+		dot := strings.Index(rest, ".")
+		if dot < 0 {
+			break
+		}
+		rest = rest[dot+1:]
+	}
+
+	return false
+}
+
+// acceptsMail reports whether the domain publishes a usable MX record
+func (p *EmailPolicy) acceptsMail(ctx context.Context, host string) (bool, error) {
+	if p.config.MXTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.config.MXTimeout)
+		defer cancel()
+	}
+
+	records, err := p.resolver.LookupMX(ctx, host)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	for _, mx := range records {
+		// A null MX (RFC 7505) explicitly declares that the domain accepts no mail
+		if mx.Host != "." && mx.Host != "" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// readDomainList adds the domains listed one per line to the set, skipping blank lines and # comments
+func readDomainList(r io.Reader, set map[string]struct{}) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		set[strings.ToLower(strings.TrimSuffix(line, "."))] = struct{}{}
+	}
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"authmicro/configs"
+	"authmicro/internal/domain"
+)
+
+// fakeMXResolver answers MX lookups from a fixed table; unknown domains do not exist
+type fakeMXResolver struct {
+	records map[string][]*net.MX
+	errs    map[string]error
+	lookups []string
+}
+
+func (r *fakeMXResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	r.lookups = append(r.lookups, name)
+
+	if err, ok := r.errs[name]; ok {
+		return nil, err
+	}
+	if records, ok := r.records[name]; ok {
+		return records, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func newFakeMXResolver() *fakeMXResolver {
+	return &fakeMXResolver{
+		records: map[string][]*net.MX{
+			"example.com":     {{Host: "mx.example.com.", Pref: 10}},
+			"null-mx.example": {{Host: ".", Pref: 0}},
+			"no-records.test": {},
+		},
+		errs: map[string]error{
+			"timeout.test": &net.DNSError{Err: "i/o timeout", Name: "timeout.test", IsTimeout: true},
+			"broken.test":  errors.New("resolver unavailable"),
+		},
+	}
+}
+
+func TestEmailPolicyCheck(t *testing.T) {
+	blocklist := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(blocklist, []byte("# extra domains\nthrowaway.example\n\nTRASH.example.\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	reject, flag := domain.EmailPolicyActions.Reject, domain.EmailPolicyActions.Flag
+	disposable, noMX := domain.EmailPolicyReasons.Disposable, domain.EmailPolicyReasons.NoMX
+
+	tests := []struct {
+		name       string
+		email      string
+		action     string
+		checkMX    bool
+		wantReason string
+		wantReject bool
+		wantLookup bool
+	}{
+		{
+			name:  "regular address",
+			email: "alice@example.com",
+		},
+		{
+			name:       "bundled disposable domain",
+			email:      "alice@10minutemail.com",
+			wantReason: disposable,
+			wantReject: true,
+		},
+		{
+			name:       "subdomain of a disposable domain",
+			email:      "alice@inbox.eu.10minutemail.com",
+			wantReason: disposable,
+			wantReject: true,
+		},
+		{
+			name:       "disposable domain from the blocklist file, matched case-insensitively",
+			email:      "alice@Mail.Trash.Example",
+			wantReason: disposable,
+			wantReject: true,
+		},
+		{
+			name:  "domain merely ending like a disposable one",
+			email: "alice@not10minutemail.com",
+		},
+		{
+			name:       "disposable domain is flagged in flag mode",
+			email:      "alice@throwaway.example",
+			action:     flag,
+			wantReason: disposable,
+		},
+		{
+			name:       "disposable domain is not looked up",
+			email:      "alice@throwaway.example",
+			checkMX:    true,
+			wantReason: disposable,
+			wantReject: true,
+		},
+		{
+			name:  "MX is not checked unless enabled",
+			email: "alice@missing.test",
+		},
+		{
+			name:       "domain with an MX record",
+			email:      "alice@example.com",
+			checkMX:    true,
+			wantLookup: true,
+		},
+		{
+			name:       "domain that does not exist",
+			email:      "alice@missing.test",
+			checkMX:    true,
+			wantReason: noMX,
+			wantReject: true,
+			wantLookup: true,
+		},
+		{
+			name:       "domain without MX records",
+			email:      "alice@no-records.test",
+			checkMX:    true,
+			wantReason: noMX,
+			wantReject: true,
+			wantLookup: true,
+		},
+		{
+			name:       "domain with a null MX",
+			email:      "alice@null-mx.example",
+			checkMX:    true,
+			wantReason: noMX,
+			wantReject: true,
+			wantLookup: true,
+		},
+		{
+			name:       "domain without MX records is flagged in flag mode",
+			email:      "alice@missing.test",
+			action:     flag,
+			checkMX:    true,
+			wantReason: noMX,
+			wantLookup: true,
+		},
+		{
+			name:       "DNS timeout fails open",
+			email:      "alice@timeout.test",
+			checkMX:    true,
+			wantLookup: true,
+		},
+		{
+			name:       "resolver error fails open",
+			email:      "alice@broken.test",
+			checkMX:    true,
+			wantLookup: true,
+		},
+		{
+			name:    "address without a domain",
+			email:   "alice",
+			checkMX: true,
+		},
+		{
+			name:       "unknown action rejects",
+			email:      "alice@10minutemail.com",
+			action:     "quarantine",
+			wantReason: disposable,
+			wantReject: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action := tt.action
+			if action == "" {
+				action = reject
+			}
+
+			resolver := newFakeMXResolver()
+			policy := NewEmailPolicy(resolver, configs.EmailPolicyConfig{
+				BlocklistFile: blocklist,
+				CheckMX:       tt.checkMX,
+				Action:        action,
+			}, nopLogger{})
+
+			result, err := policy.Check(context.Background(), tt.email)
+			if err != nil {
+				t.Fatalf("Check(%q) error = %v", tt.email, err)
+			}
+
+			want := domain.EmailPolicyResult{Reason: tt.wantReason, Reject: tt.wantReject}
+			if result != want {
+				t.Errorf("Check(%q) = %+v, want %+v", tt.email, result, want)
+			}
+			if looked := len(resolver.lookups) > 0; looked != tt.wantLookup {
+				t.Errorf("Check(%q) looked up MX records: %v, want %v", tt.email, looked, tt.wantLookup)
+			}
+		})
+	}
+}
+
+func TestEmailPolicyReloadBlocklist(t *testing.T) {
+	blocklist := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(blocklist, []byte("first.example\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	policy := NewEmailPolicy(newFakeMXResolver(), configs.EmailPolicyConfig{
+		BlocklistFile: blocklist,
+		Action:        domain.EmailPolicyActions.Reject,
+	}, nopLogger{})
+
+	if err := os.WriteFile(blocklist, []byte("second.example\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := policy.ReloadBlocklist(); err != nil {
+		t.Fatalf("ReloadBlocklist() error = %v", err)
+	}
+
+	for email, want := range map[string]string{
+		"alice@first.example":    "",
+		"alice@second.example":   domain.EmailPolicyReasons.Disposable,
+		"alice@10minutemail.com": domain.EmailPolicyReasons.Disposable,
+	} {
+		result, err := policy.Check(context.Background(), email)
+		if err != nil {
+			t.Fatalf("Check(%q) error = %v", email, err)
+		}
+		if result.Reason != want {
+			t.Errorf("Check(%q) reason = %q, want %q", email, result.Reason, want)
+		}
+	}
+
+	// A missing file keeps the bundled list working
+	if err := os.Remove(blocklist); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := policy.ReloadBlocklist(); err == nil {
+		t.Errorf("ReloadBlocklist() of a missing file succeeded")
+	}
+
+	result, _ := policy.Check(context.Background(), "alice@10minutemail.com")
+	if result.Reason != domain.EmailPolicyReasons.Disposable {
+		t.Errorf("bundled blocklist lost after a failed reload")
+	}
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"fmt"
 	"net/smtp"
+	"time"
 
 	"authmicro/configs"
 )
@@ -58,3 +59,144 @@ func (s *EmailService) SendWelcomeEmail(to, name string) error {
 	addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
 	return smtp.SendMail(addr, auth, s.config.From, []string{to}, []byte(message))
 }
+
+// SendRegistrationAttemptNotice tells the owner of an account that someone tried to register with their email
+// and links them to the login page instead of sending a confirmation code
+func (s *EmailService) SendRegistrationAttemptNotice(to, loginLink string) error {
+	// If SMTP is not configured, just return without error for development purposes
+	if s.config.Username == "" || s.config.Password == "" {
+		fmt.Printf("SMTP not configured, would send registration attempt notice with link %s to %s\n", loginLink, to)
+		return nil
+	}
+
+	// Set up authentication
+	auth := smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+
+	// Compose message
+	subject := "Registration attempt with your email"
+	body := fmt.Sprintf("Hello,\n\nSomeone tried to register a new account with your email address, but you already have an account. "+
+		"If it was you, log in here instead:\n%s\n\nIf it wasn't you, you can safely ignore this email.\n\nBest regards,\nThe Team", loginLink)
+	message := fmt.Sprintf("To: %s\r\nFrom: %s\r\nSubject: %s\r\n\r\n%s", to, s.config.From, subject, body)
+
+	// Send email
+	addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
+	return smtp.SendMail(addr, auth, s.config.From, []string{to}, []byte(message))
+}
+
+// SendEmailChangeNotice tells the owner of an account that its email is about to be changed
+// and links them to cancel the change
+func (s *EmailService) SendEmailChangeNotice(to, newEmail, cancelLink string) error {
+	// If SMTP is not configured, just return without error for development purposes
+	if s.config.Username == "" || s.config.Password == "" {
+		fmt.Printf("SMTP not configured, would send email change notice with link %s to %s\n", cancelLink, to)
+		return nil
+	}
+
+	// Set up authentication
+	auth := smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+
+	// Compose message
+	subject := "Your email is being changed"
+	body := fmt.Sprintf("Hello,\n\nA change of your account email to %s has been requested. It takes effect once confirmed with the code sent to the new address. "+
+		"If you didn't request it, cancel the change here:\n%s\n\nBest regards,\nThe Team", newEmail, cancelLink)
+	message := fmt.Sprintf("To: %s\r\nFrom: %s\r\nSubject: %s\r\n\r\n%s", to, s.config.From, subject, body)
+
+	// Send email
+	addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
+	return smtp.SendMail(addr, auth, s.config.From, []string{to}, []byte(message))
+}
+
+// SendAccountDeletionScheduled confirms that an account is scheduled for deletion and how to cancel it
+func (s *EmailService) SendAccountDeletionScheduled(to string, deleteAt time.Time) error {
+	// If SMTP is not configured, just return without error for development purposes
+	if s.config.Username == "" || s.config.Password == "" {
+		fmt.Printf("SMTP not configured, would send account deletion notice to %s\n", to)
+		return nil
+	}
+
+	// Set up authentication
+	auth := smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+
+	// Compose message
+	subject := "Your account is scheduled for deletion"
+	body := fmt.Sprintf("Hello,\n\nYour account will be deleted on %s and you have been logged out everywhere. "+
+		"To keep your account, log in before then and cancel the deletion.\n\nBest regards,\nThe Team", deleteAt.UTC().Format("2006-01-02 15:04 MST"))
+	message := fmt.Sprintf("To: %s\r\nFrom: %s\r\nSubject: %s\r\n\r\n%s", to, s.config.From, subject, body)
+
+	// Send email
+	addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
+	return smtp.SendMail(addr, auth, s.config.From, []string{to}, []byte(message))
+}
+
+// SendDataExportReady sends the download link of a personal data export
+func (s *EmailService) SendDataExportReady(to, downloadLink string, expiresAt time.Time) error {
+	// If SMTP is not configured, just return without error for development purposes
+	if s.config.Username == "" || s.config.Password == "" {
+		fmt.Printf("SMTP not configured, would send data export link %s to %s\n", downloadLink, to)
+		return nil
+	}
+
+	// Set up authentication
+	auth := smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+
+	// Compose message
+	subject := "Your data export is ready"
+	body := fmt.Sprintf("Hello,\n\nThe export of your personal data you requested is ready. Download it here:\n\n%s\n\n"+
+		"The link expires on %s. If you did not request this export, please contact us.\n\nBest regards,\nThe Team",
+		downloadLink, expiresAt.UTC().Format("2006-01-02 15:04 MST"))
+	message := fmt.Sprintf("To: %s\r\nFrom: %s\r\nSubject: %s\r\n\r\n%s", to, s.config.From, subject, body)
+
+	// Send email
+	addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
+	return smtp.SendMail(addr, auth, s.config.From, []string{to}, []byte(message))
+}
+
+// SendAccountSuspendedNotice tells a user that their account has been suspended until the given time, or banned when
+// until is nil
+func (s *EmailService) SendAccountSuspendedNotice(to string, until *time.Time, reason string) error {
+	// If SMTP is not configured, just return without error for development purposes
+	if s.config.Username == "" || s.config.Password == "" {
+		fmt.Printf("SMTP not configured, would send account suspension notice to %s\n", to)
+		return nil
+	}
+
+	// Set up authentication
+	auth := smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+
+	// Compose message
+	subject := "Your account has been banned"
+	status := "banned"
+	if until != nil {
+		subject = "Your account has been suspended"
+		status = fmt.Sprintf("suspended until %s", until.UTC().Format("2006-01-02 15:04 MST"))
+	}
+	body := fmt.Sprintf("Hello,\n\nYour account has been %s and you have been logged out everywhere.\n\nReason: %s\n\n"+
+		"If you believe this is a mistake, please contact us.\n\nBest regards,\nThe Team", status, reason)
+	message := fmt.Sprintf("To: %s\r\nFrom: %s\r\nSubject: %s\r\n\r\n%s", to, s.config.From, subject, body)
+
+	// Send email
+	addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
+	return smtp.SendMail(addr, auth, s.config.From, []string{to}, []byte(message))
+}
+
+// SendInvitation sends an invitation into an organization with a link to accept it
+func (s *EmailService) SendInvitation(to, organization, link string, expiresAt time.Time) error {
+	// If SMTP is not configured, just return without error for development purposes
+	if s.config.Username == "" || s.config.Password == "" {
+		fmt.Printf("SMTP not configured, would send invitation link %s to %s\n", link, to)
+		return nil
+	}
+
+	// Set up authentication
+	auth := smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+
+	// Compose message
+	subject := fmt.Sprintf("You are invited to join %s", organization)
+	body := fmt.Sprintf("Hello,\n\nYou have been invited to join %s. Follow the link below to accept the invitation:\n%s\n\nThe invitation expires on %s.\n\nBest regards,\nThe Team",
+		organization, link, expiresAt.UTC().Format("2006-01-02 15:04 MST"))
+	message := fmt.Sprintf("To: %s\r\nFrom: %s\r\nSubject: %s\r\n\r\n%s", to, s.config.From, subject, body)
+
+	// Send email
+	addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
+	return smtp.SendMail(addr, auth, s.config.From, []string{to}, []byte(message))
+}
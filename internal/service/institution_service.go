@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type institutionRepository interface {
+	CreateInstitution(ctx context.Context, name string, domains []string) (int64, error)
+	GetInstitutionByID(ctx context.Context, id int64) (domain.Institution, error)
+	ListInstitutions(ctx context.Context) ([]domain.Institution, error)
+	UpdateInstitution(ctx context.Context, id int64, name string, domains []string) error
+	DeleteInstitution(ctx context.Context, id int64) error
+}
+
+// domainPatternRegexp matches a lowercase domain name, optionally prefixed with the "*." subdomain wildcard
+var domainPatternRegexp = regexp.MustCompile(`^(\*\.)?([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// InstitutionService implements management of the institutions allowed to register
+type InstitutionService struct {
+	institutionRepo institutionRepository
+	logger          logger.Logger
+}
+
+func NewInstitutionService(institutionRepo institutionRepository, logger logger.Logger) *InstitutionService {
+	return &InstitutionService{
+		institutionRepo: institutionRepo,
+		logger:          logger,
+	}
+}
+
+// ListInstitutions retrieves all institutions
+func (s *InstitutionService) ListInstitutions(ctx context.Context) ([]domain.Institution, error) {
+	return s.institutionRepo.ListInstitutions(ctx)
+}
+
+// GetInstitution retrieves an institution by ID
+func (s *InstitutionService) GetInstitution(ctx context.Context, id int64) (domain.Institution, error) {
+	return s.institutionRepo.GetInstitutionByID(ctx, id)
+}
+
+// CreateInstitution creates a new institution
+func (s *InstitutionService) CreateInstitution(ctx context.Context, req domain.InstitutionRequest) (domain.Institution, []domain.FieldError, error) {
+	name, domains, fieldErrors := validateInstitution(req)
+	if len(fieldErrors) > 0 {
+		return domain.Institution{}, fieldErrors, nil
+	}
+
+	id, err := s.institutionRepo.CreateInstitution(ctx, name, domains)
+	if err != nil {
+		return domain.Institution{}, nil, err
+	}
+
+	institution, err := s.institutionRepo.GetInstitutionByID(ctx, id)
+	return institution, nil, err
+}
+
+// UpdateInstitution renames an institution and replaces its domains
+func (s *InstitutionService) UpdateInstitution(ctx context.Context, id int64, req domain.InstitutionRequest) (domain.Institution, []domain.FieldError, error) {
+	name, domains, fieldErrors := validateInstitution(req)
+	if len(fieldErrors) > 0 {
+		return domain.Institution{}, fieldErrors, nil
+	}
+
+	if err := s.institutionRepo.UpdateInstitution(ctx, id, name, domains); err != nil {
+		return domain.Institution{}, nil, err
+	}
+
+	institution, err := s.institutionRepo.GetInstitutionByID(ctx, id)
+	return institution, nil, err
+}
+
+// DeleteInstitution deletes an institution
+func (s *InstitutionService) DeleteInstitution(ctx context.Context, id int64) error {
+	return s.institutionRepo.DeleteInstitution(ctx, id)
+}
+
+// validateInstitution normalizes and validates an institution request
+func validateInstitution(req domain.InstitutionRequest) (string, []string, []domain.FieldError) {
+	var fieldErrors []domain.FieldError
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "name",
+			Message: "Поле пустое",
+		})
+	} else if len(name) > 255 {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "name",
+			Message: "Название учебного заведения не может быть длиннее 255 символов",
+		})
+	}
+
+	if len(req.Domains) == 0 {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "domains",
+			Message: "Поле пустое",
+		})
+	}
+
+	seen := make(map[string]bool, len(req.Domains))
+	domains := make([]string, 0, len(req.Domains))
+	for _, d := range req.Domains {
+		d = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(d), "."))
+		if !domainPatternRegexp.MatchString(d) || len(d) > 255 {
+			fieldErrors = append(fieldErrors, domain.FieldError{
+				Field:   "domains",
+				Message: fmt.Sprintf("%q не является доменом (допустим шаблон *.example.com)", d),
+			})
+			continue
+		}
+		if !seen[d] {
+			seen[d] = true
+			domains = append(domains, d)
+		}
+	}
+
+	return name, domains, fieldErrors
+}
+
+// emailDomainPatterns returns the domain of an email together with the registry patterns matching it,
+// e.g. "a.msu.ru", "*.msu.ru" and "*.ru" for an address at a.msu.ru
+func emailDomainPatterns(email string) (string, []string) {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return "", nil
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(email[at+1:], "."))
+	patterns := []string{host}
+	for rest := host; ; {
+		dot := strings.Index(rest, ".")
+		if dot < 0 {
+			break
+		}
+		rest = rest[dot+1:]
+		patterns = append(patterns, "*."+rest)
+	}
+
+	return host, patterns
+}
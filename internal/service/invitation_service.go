@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"authmicro/configs"
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+	"authmicro/pkg/mailaddr"
+)
+
+// invitationTokenType distinguishes invitation tokens from access and refresh tokens signed with the same secret
+const invitationTokenType = "invitation"
+
+type invitationRepository interface {
+	CreateInvitation(ctx context.Context, invitation domain.Invitation, roleIDs []int64) (int64, error)
+	GetInvitationByID(ctx context.Context, id int64) (domain.Invitation, error)
+	ListInvitations(ctx context.Context, orgID int64) ([]domain.Invitation, error)
+	RenewInvitation(ctx context.Context, id int64, nonce string, expiresAt time.Time) error
+	RevokeInvitation(ctx context.Context, id int64) error
+	AcceptInvitation(ctx context.Context, id int64, nonce string, userID int64) error
+}
+
+type invitationOrgRepository interface {
+	GetOrganizationByID(ctx context.Context, id int64) (domain.Organization, error)
+}
+
+type invitationUserRepository interface {
+	GetByEmail(ctx context.Context, email string) (domain.User, error)
+	IncrementAuthzVersion(ctx context.Context, userIDs ...int64) error
+}
+
+type userRegistrar interface {
+	RegisterVerifiedUser(ctx context.Context, req domain.RegistrationRequest) (int64, []domain.FieldError, error)
+}
+
+type invitationEmailService interface {
+	SendInvitation(to, organization, link string, expiresAt time.Time) error
+}
+
+// InvitationService implements invitations of people into organizations.
+// An invitation is delivered as a signed, expiring token; resending it issues a new token and invalidates the previous one.
+type InvitationService struct {
+	invitationRepo invitationRepository
+	orgRepo        invitationOrgRepository
+	roleRepo       orgRoleRepository
+	userRepo       invitationUserRepository
+	registrar      userRegistrar
+	emailSvc       invitationEmailService
+	emailNorm      mailaddr.Normalizer
+	uow            unitOfWork
+	invalidator    *tokenInvalidator
+	secret         []byte
+	config         configs.InvitationConfig
+	logger         logger.Logger
+}
+
+func NewInvitationService(
+	invitationRepo invitationRepository,
+	orgRepo invitationOrgRepository,
+	roleRepo orgRoleRepository,
+	userRepo invitationUserRepository,
+	registrar userRegistrar,
+	emailSvc invitationEmailService,
+	emailNorm mailaddr.Normalizer,
+	uow unitOfWork,
+	tokenSvc tokenRevoker,
+	jwtConfig configs.JWTConfig,
+	config configs.InvitationConfig,
+	logger logger.Logger,
+) *InvitationService {
+	return &InvitationService{
+		invitationRepo: invitationRepo,
+		orgRepo:        orgRepo,
+		roleRepo:       roleRepo,
+		userRepo:       userRepo,
+		registrar:      registrar,
+		emailSvc:       emailSvc,
+		emailNorm:      emailNorm,
+		uow:            uow,
+		invalidator: &tokenInvalidator{
+			versionRepo:         userRepo,
+			tokenSvc:            tokenSvc,
+			revokeRefreshTokens: jwtConfig.RevokeRefreshOnRoleChange,
+			logger:              logger,
+		},
+		secret: []byte(jwtConfig.Secret),
+		config: config,
+		logger: logger,
+	}
+}
+
+// CreateInvitation invites a person into an organization and emails them the invitation link
+func (s *InvitationService) CreateInvitation(ctx context.Context, orgID, inviterID int64, req domain.CreateInvitationRequest) (domain.InvitationView, []domain.FieldError, error) {
+	org, err := s.orgRepo.GetOrganizationByID(ctx, orgID)
+	if err != nil {
+		return domain.InvitationView{}, nil, err
+	}
+
+	email, err := s.emailNorm.Normalize(req.Email)
+	if err != nil {
+		email = strings.TrimSpace(req.Email)
+	}
+
+	var fieldErrors []domain.FieldError
+	if email == "" {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "email",
+			Message: "Поле пустое",
+		})
+	} else {
+		matched, _ := regexp.MatchString(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.([a-zA-Z]{2,}|xn--[a-zA-Z0-9-]+)$`, email)
+		if !matched {
+			fieldErrors = append(fieldErrors, domain.FieldError{
+				Field:   "email",
+				Message: "Введенная строка не является электронной почтой",
+			})
+		}
+	}
+
+	for _, roleID := range req.RoleIDs {
+		if _, err := s.roleRepo.GetRoleByID(ctx, roleID); err != nil {
+			if !errors.Is(err, domain.ErrRoleNotFound) {
+				return domain.InvitationView{}, nil, err
+			}
+			fieldErrors = append(fieldErrors, domain.FieldError{
+				Field:   "roleIds",
+				Message: fmt.Sprintf("Роль %d не найдена", roleID),
+			})
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return domain.InvitationView{}, fieldErrors, nil
+	}
+
+	invitation := domain.Invitation{
+		OrganizationID: orgID,
+		Email:          email,
+		Nonce:          uuid.New().String(),
+		ExpiresAt:      time.Now().UTC().Add(s.config.TTL),
+	}
+	if inviterID != 0 {
+		invitation.InvitedBy = &inviterID
+	}
+
+	var id int64
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		id, err = s.invitationRepo.CreateInvitation(ctx, invitation, req.RoleIDs)
+		return err
+	})
+	if err != nil {
+		return domain.InvitationView{}, nil, err
+	}
+
+	invitation, err = s.invitationRepo.GetInvitationByID(ctx, id)
+	if err != nil {
+		return domain.InvitationView{}, nil, err
+	}
+
+	s.send(invitation, org)
+
+	return toInvitationView(invitation), nil, nil
+}
+
+// ListInvitations retrieves the invitations of an organization
+func (s *InvitationService) ListInvitations(ctx context.Context, orgID int64) ([]domain.InvitationView, error) {
+	if _, err := s.orgRepo.GetOrganizationByID(ctx, orgID); err != nil {
+		return nil, err
+	}
+
+	invitations, err := s.invitationRepo.ListInvitations(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]domain.InvitationView, 0, len(invitations))
+	for _, invitation := range invitations {
+		views = append(views, toInvitationView(invitation))
+	}
+
+	return views, nil
+}
+
+// ResendInvitation issues a new token with a fresh expiry for an open invitation and emails it again.
+// Tokens sent earlier stop working.
+func (s *InvitationService) ResendInvitation(ctx context.Context, orgID, id int64) (domain.InvitationView, error) {
+	invitation, err := s.getOrgInvitation(ctx, orgID, id)
+	if err != nil {
+		return domain.InvitationView{}, err
+	}
+
+	org, err := s.orgRepo.GetOrganizationByID(ctx, orgID)
+	if err != nil {
+		return domain.InvitationView{}, err
+	}
+
+	nonce := uuid.New().String()
+	expiresAt := time.Now().UTC().Add(s.config.TTL)
+	if err := s.invitationRepo.RenewInvitation(ctx, id, nonce, expiresAt); err != nil {
+		return domain.InvitationView{}, err
+	}
+
+	invitation.Nonce = nonce
+	invitation.ExpiresAt = expiresAt
+	s.send(invitation, org)
+
+	return toInvitationView(invitation), nil
+}
+
+// RevokeInvitation revokes an open invitation
+func (s *InvitationService) RevokeInvitation(ctx context.Context, orgID, id int64) error {
+	if _, err := s.getOrgInvitation(ctx, orgID, id); err != nil {
+		return err
+	}
+
+	return s.invitationRepo.RevokeInvitation(ctx, id)
+}
+
+// AcceptInvitation accepts an invitation. When an account exists for the invited email it is added to the organization,
+// otherwise a new account is registered from the request with the email already verified by the invitation.
+func (s *InvitationService) AcceptInvitation(ctx context.Context, req domain.AcceptInvitationRequest) (*domain.AcceptInvitationResponse, []domain.FieldError, error) {
+	id, nonce, err := s.parseToken(req.Token)
+	if err != nil {
+		s.logger.Errorf("Error parsing invitation token: %v", err)
+		return nil, nil, domain.ErrInvitationInvalid
+	}
+
+	invitation, err := s.invitationRepo.GetInvitationByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvitationNotFound) {
+			return nil, nil, domain.ErrInvitationInvalid
+		}
+		return nil, nil, err
+	}
+
+	if invitation.Nonce != nonce || invitation.Status(time.Now().UTC()) != domain.InvitationStatuses.Pending {
+		return nil, nil, domain.ErrInvitationInvalid
+	}
+
+	// The user is registered, the invitation accepted and the membership added at once, so that a failed or lost
+	// acceptance leaves neither a user without the membership nor a user with the invitation still open
+	var user domain.User
+	var fieldErrors []domain.FieldError
+	registered := false
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = s.userRepo.GetByEmail(ctx, invitation.Email)
+		switch {
+		case err == nil:
+		case errors.Is(err, domain.ErrUserNotFound):
+			var userID int64
+			userID, fieldErrors, err = s.registrar.RegisterVerifiedUser(ctx, domain.RegistrationRequest{
+				FirstName:             req.FirstName,
+				LastName:              req.LastName,
+				Nickname:              req.Nickname,
+				Email:                 invitation.Email,
+				AcceptedPrivacyPolicy: req.AcceptedPrivacyPolicy,
+			})
+			if err != nil || len(fieldErrors) > 0 {
+				return err
+			}
+			user.ID = userID
+			registered = true
+		default:
+			return err
+		}
+
+		if err := s.invitationRepo.AcceptInvitation(ctx, invitation.ID, nonce, user.ID); err != nil {
+			return err
+		}
+
+		// Tokens of an existing user do not list the new membership yet
+		if !registered {
+			return s.invalidator.invalidate(ctx, user.ID)
+		}
+		return nil
+	})
+	if err != nil || len(fieldErrors) > 0 {
+		return nil, fieldErrors, err
+	}
+
+	return &domain.AcceptInvitationResponse{
+		OrganizationID: invitation.OrganizationID,
+		UserID:         user.ID,
+		Registered:     registered,
+	}, nil, nil
+}
+
+// getOrgInvitation retrieves an invitation, making sure it belongs to the organization
+func (s *InvitationService) getOrgInvitation(ctx context.Context, orgID, id int64) (domain.Invitation, error) {
+	invitation, err := s.invitationRepo.GetInvitationByID(ctx, id)
+	if err != nil {
+		return domain.Invitation{}, err
+	}
+
+	if invitation.OrganizationID != orgID {
+		return domain.Invitation{}, domain.ErrInvitationNotFound
+	}
+
+	return invitation, nil
+}
+
+// send emails the invitation link; delivery failures are logged, the invitation can be resent
+func (s *InvitationService) send(invitation domain.Invitation, org domain.Organization) {
+	token, err := s.generateToken(invitation)
+	if err != nil {
+		s.logger.Errorf("Error generating invitation token: %v", err)
+		return
+	}
+
+	link := s.config.AcceptURL + "?token=" + url.QueryEscape(token)
+	if strings.Contains(s.config.AcceptURL, "?") {
+		link = s.config.AcceptURL + "&token=" + url.QueryEscape(token)
+	}
+
+	if err := s.emailSvc.SendInvitation(invitation.Email, org.Name, link, invitation.ExpiresAt); err != nil {
+		s.logger.Errorf("Error sending invitation %d: %v", invitation.ID, err)
+	}
+}
+
+// generateToken signs an invitation token carrying the invitation ID and its current nonce
+func (s *InvitationService) generateToken(invitation domain.Invitation) (string, error) {
+	claims := jwt.MapClaims{
+		"typ":          invitationTokenType,
+		"invitationId": invitation.ID,
+		"jti":          invitation.Nonce,
+		"exp":          invitation.ExpiresAt.Unix(),
+		"iat":          time.Now().UTC().Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(s.secret)
+}
+
+// parseToken verifies an invitation token and returns the invitation ID and nonce it carries
+func (s *InvitationService) parseToken(tokenString string) (int64, string, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return s.secret, nil
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return 0, "", errors.New("invalid token")
+	}
+
+	if typ, _ := claims["typ"].(string); typ != invitationTokenType {
+		return 0, "", errors.New("not an invitation token")
+	}
+
+	id, ok := claims["invitationId"].(float64)
+	if !ok {
+		return 0, "", errors.New("invalid invitation ID")
+	}
+
+	nonce, _ := claims["jti"].(string)
+	if nonce == "" {
+		return 0, "", errors.New("invalid invitation nonce")
+	}
+
+	return int64(id), nonce, nil
+}
+
+// toInvitationView attaches the current status to an invitation
+func toInvitationView(invitation domain.Invitation) domain.InvitationView {
+	return domain.InvitationView{
+		Invitation: invitation,
+		Status:     invitation.Status(time.Now().UTC()),
+	}
+}
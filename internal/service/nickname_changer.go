@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"authmicro/configs"
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type nicknameUserRepository interface {
+	NicknameExists(ctx context.Context, nickname string) (bool, error)
+	UpdateProfile(ctx context.Context, user domain.User) error
+}
+
+type nicknameHistoryRepository interface {
+	CreateNicknameChange(ctx context.Context, change domain.NicknameChange) error
+	GetLastNicknameChange(ctx context.Context, userID int64) (*time.Time, error)
+}
+
+// nicknameChanger changes nicknames, recording them in the nickname history and reserving the previous nickname
+type nicknameChanger struct {
+	userRepo     nicknameUserRepository
+	nicknameRepo nicknameHistoryRepository
+	uow          unitOfWork
+	config       configs.NicknameConfig
+	logger       logger.Logger
+}
+
+// validate validates a new nickname for a user with the registration rules. Users changing their own nickname
+// are also held to the change-frequency limit.
+func (c *nicknameChanger) validate(ctx context.Context, userID int64, nickname string, selfService bool) ([]domain.FieldError, error) {
+	fieldErrors, err := validateNickname(ctx, c.userRepo, nickname)
+	if err != nil {
+		c.logger.Errorf("Error checking nickname existence: %v", err)
+		return nil, err
+	}
+
+	if !selfService || c.config.ChangeInterval <= 0 {
+		return fieldErrors, nil
+	}
+
+	lastChange, err := c.nicknameRepo.GetLastNicknameChange(ctx, userID)
+	if err != nil {
+		c.logger.Errorf("Error getting last nickname change: %v", err)
+		return nil, err
+	}
+
+	if lastChange != nil {
+		next := lastChange.Add(c.config.ChangeInterval)
+		if time.Now().UTC().Before(next) {
+			fieldErrors = append(fieldErrors, domain.FieldError{
+				Field:   "nickname",
+				Message: fmt.Sprintf("Nickname можно будет изменить после %s", next.Format("2006-01-02 15:04 MST")),
+			})
+		}
+	}
+
+	return fieldErrors, nil
+}
+
+// save stores the user with its new nickname and records the change; actorID is nil when users change their own nickname
+func (c *nicknameChanger) save(ctx context.Context, user domain.User, oldNickname string, actorID *int64) error {
+	return c.uow.Do(ctx, func(ctx context.Context) error {
+		if err := c.userRepo.UpdateProfile(ctx, user); err != nil {
+			return err
+		}
+
+		return c.nicknameRepo.CreateNicknameChange(ctx, domain.NicknameChange{
+			UserID:        user.ID,
+			OldNickname:   oldNickname,
+			NewNickname:   user.Nickname,
+			ChangedBy:     actorID,
+			ReservedUntil: time.Now().UTC().Add(c.config.ReservationPeriod),
+		})
+	})
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type organizationRepository interface {
+	CreateOrganization(ctx context.Context, name, slug string) (int64, error)
+	GetOrganizationByID(ctx context.Context, id int64) (domain.Organization, error)
+	ListOrganizations(ctx context.Context) ([]domain.Organization, error)
+	DeleteOrganization(ctx context.Context, id int64) error
+	AddMember(ctx context.Context, orgID, userID int64) error
+	RemoveMember(ctx context.Context, orgID, userID int64) error
+	IsMember(ctx context.Context, orgID, userID int64) (bool, error)
+	ListMembers(ctx context.Context, orgID int64) ([]domain.OrganizationMember, error)
+	GetMemberIDs(ctx context.Context, orgID int64) ([]int64, error)
+	GrantOrgRole(ctx context.Context, orgID, userID, roleID int64) error
+	RevokeOrgRole(ctx context.Context, orgID, userID, roleID int64) error
+	GetUserMemberships(ctx context.Context, userID int64) ([]domain.Membership, error)
+}
+
+type orgRoleRepository interface {
+	GetRoleByID(ctx context.Context, id int64) (domain.Role, error)
+}
+
+var slugRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,49}$`)
+
+// OrganizationService implements management of organizations, their members and organization-scoped roles
+type OrganizationService struct {
+	orgRepo     organizationRepository
+	roleRepo    orgRoleRepository
+	userRepo    roleUserRepository
+	uow         unitOfWork
+	invalidator *tokenInvalidator
+	logger      logger.Logger
+}
+
+// NewOrganizationService creates an organization service. When revokeRefreshTokens is set, a membership change
+// also revokes the refresh tokens of the affected users, forcing them to log in again.
+func NewOrganizationService(orgRepo organizationRepository, roleRepo orgRoleRepository, userRepo roleUserRepository, uow unitOfWork, tokenSvc tokenRevoker, revokeRefreshTokens bool, logger logger.Logger) *OrganizationService {
+	return &OrganizationService{
+		orgRepo:  orgRepo,
+		roleRepo: roleRepo,
+		userRepo: userRepo,
+		uow:      uow,
+		invalidator: &tokenInvalidator{
+			versionRepo:         userRepo,
+			tokenSvc:            tokenSvc,
+			revokeRefreshTokens: revokeRefreshTokens,
+			logger:              logger,
+		},
+		logger: logger,
+	}
+}
+
+// ListOrganizations retrieves all organizations
+func (s *OrganizationService) ListOrganizations(ctx context.Context) ([]domain.Organization, error) {
+	return s.orgRepo.ListOrganizations(ctx)
+}
+
+// CreateOrganization creates a new organization
+func (s *OrganizationService) CreateOrganization(ctx context.Context, req domain.CreateOrganizationRequest) (domain.Organization, []domain.FieldError, error) {
+	name := strings.TrimSpace(req.Name)
+	slug := strings.ToLower(strings.TrimSpace(req.Slug))
+
+	var fieldErrors []domain.FieldError
+	if name == "" {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "name",
+			Message: "Поле пустое",
+		})
+	} else if len(name) > 100 {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "name",
+			Message: "Название организации не может быть длиннее 100 символов",
+		})
+	}
+
+	if slug == "" {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "slug",
+			Message: "Поле пустое",
+		})
+	} else if !slugRegexp.MatchString(slug) {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "slug",
+			Message: "Допустимы строчные латинские буквы, цифры и дефис (от 2 до 50 символов)",
+		})
+	}
+
+	if len(fieldErrors) > 0 {
+		return domain.Organization{}, fieldErrors, nil
+	}
+
+	id, err := s.orgRepo.CreateOrganization(ctx, name, slug)
+	if err != nil {
+		return domain.Organization{}, nil, err
+	}
+
+	org, err := s.orgRepo.GetOrganizationByID(ctx, id)
+	return org, nil, err
+}
+
+// DeleteOrganization deletes an organization together with its memberships
+func (s *OrganizationService) DeleteOrganization(ctx context.Context, orgID int64) error {
+	// Collect the members first, their memberships are removed together with the organization
+	userIDs, err := s.orgRepo.GetMemberIDs(ctx, orgID)
+	if err != nil {
+		return err
+	}
+
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.orgRepo.DeleteOrganization(ctx, orgID); err != nil {
+			return err
+		}
+
+		return s.invalidator.invalidate(ctx, userIDs...)
+	})
+}
+
+// AddMember adds a user to an organization
+func (s *OrganizationService) AddMember(ctx context.Context, orgID, userID int64) error {
+	if _, err := s.orgRepo.GetOrganizationByID(ctx, orgID); err != nil {
+		return err
+	}
+
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.orgRepo.AddMember(ctx, orgID, userID); err != nil {
+			return err
+		}
+
+		return s.invalidator.invalidate(ctx, userID)
+	})
+}
+
+// RemoveMember removes a user and their roles from an organization
+func (s *OrganizationService) RemoveMember(ctx context.Context, orgID, userID int64) error {
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.orgRepo.RemoveMember(ctx, orgID, userID); err != nil {
+			return err
+		}
+
+		return s.invalidator.invalidate(ctx, userID)
+	})
+}
+
+// ListMembers retrieves the members of an organization with their roles in it
+func (s *OrganizationService) ListMembers(ctx context.Context, orgID int64) ([]domain.OrganizationMember, error) {
+	if _, err := s.orgRepo.GetOrganizationByID(ctx, orgID); err != nil {
+		return nil, err
+	}
+
+	return s.orgRepo.ListMembers(ctx, orgID)
+}
+
+// GrantOrgRole grants a role to a member within an organization
+func (s *OrganizationService) GrantOrgRole(ctx context.Context, orgID, userID, roleID int64) error {
+	if _, err := s.roleRepo.GetRoleByID(ctx, roleID); err != nil {
+		return err
+	}
+
+	if err := s.requireMember(ctx, orgID, userID); err != nil {
+		return err
+	}
+
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.orgRepo.GrantOrgRole(ctx, orgID, userID, roleID); err != nil {
+			return err
+		}
+
+		return s.invalidator.invalidate(ctx, userID)
+	})
+}
+
+// RevokeOrgRole revokes a role from a member within an organization
+func (s *OrganizationService) RevokeOrgRole(ctx context.Context, orgID, userID, roleID int64) error {
+	if err := s.requireMember(ctx, orgID, userID); err != nil {
+		return err
+	}
+
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.orgRepo.RevokeOrgRole(ctx, orgID, userID, roleID); err != nil {
+			return err
+		}
+
+		return s.invalidator.invalidate(ctx, userID)
+	})
+}
+
+// GetUserMemberships retrieves the organizations of a user with their effective roles in each of them
+func (s *OrganizationService) GetUserMemberships(ctx context.Context, userID int64) ([]domain.Membership, error) {
+	return s.orgRepo.GetUserMemberships(ctx, userID)
+}
+
+// requireMember checks that the organization exists and the user is one of its members
+func (s *OrganizationService) requireMember(ctx context.Context, orgID, userID int64) error {
+	if _, err := s.orgRepo.GetOrganizationByID(ctx, orgID); err != nil {
+		return err
+	}
+
+	isMember, err := s.orgRepo.IsMember(ctx, orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	if !isMember {
+		return domain.ErrNotMember
+	}
+
+	return nil
+}
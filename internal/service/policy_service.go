@@ -0,0 +1,490 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"authmicro/configs"
+	"authmicro/internal/domain"
+	"authmicro/pkg/expr"
+	"authmicro/pkg/logger"
+)
+
+type policyRepository interface {
+	CreatePolicy(ctx context.Context, policy domain.Policy) (int64, error)
+	GetPolicyByID(ctx context.Context, id int64) (domain.Policy, error)
+	ListPolicies(ctx context.Context) ([]domain.Policy, error)
+	ListEnabledPolicies(ctx context.Context) ([]domain.Policy, error)
+	UpdatePolicy(ctx context.Context, policy domain.Policy) error
+	DeletePolicy(ctx context.Context, id int64) error
+}
+
+type subjectRepository interface {
+	GetByID(ctx context.Context, id int64) (domain.User, error)
+}
+
+type subjectGrantsRepository interface {
+	GetUserRoleNames(ctx context.Context, userID int64) ([]string, error)
+}
+
+type subjectPermissionsRepository interface {
+	GetUserPermissionNames(ctx context.Context, userID int64) ([]string, error)
+}
+
+type subjectMembershipsRepository interface {
+	GetUserMemberships(ctx context.Context, userID int64) ([]domain.Membership, error)
+}
+
+// compiledPolicy is a policy together with its parsed condition
+type compiledPolicy struct {
+	policy     domain.Policy
+	program    *expr.Program
+	compileErr error
+}
+
+// PolicyService evaluates attribute-based authorization policies.
+// Enabled policies are cached in memory and reloaded once the cache TTL passes or a policy is changed.
+type PolicyService struct {
+	policyRepo     policyRepository
+	userRepo       subjectRepository
+	roleRepo       subjectGrantsRepository
+	permissionRepo subjectPermissionsRepository
+	orgRepo        subjectMembershipsRepository
+	cacheTTL       time.Duration
+	location       *time.Location
+	logger         logger.Logger
+
+	mu       sync.RWMutex
+	cache    []compiledPolicy
+	cachedAt time.Time
+}
+
+func NewPolicyService(policyRepo policyRepository, userRepo subjectRepository, roleRepo subjectGrantsRepository, permissionRepo subjectPermissionsRepository, orgRepo subjectMembershipsRepository, config configs.PolicyConfig, logger logger.Logger) *PolicyService {
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		logger.Errorf("Unknown policy timezone %q, falling back to UTC: %v", config.Timezone, err)
+		location = time.UTC
+	}
+
+	return &PolicyService{
+		policyRepo:     policyRepo,
+		userRepo:       userRepo,
+		roleRepo:       roleRepo,
+		permissionRepo: permissionRepo,
+		orgRepo:        orgRepo,
+		cacheTTL:       config.CacheTTL,
+		location:       location,
+		logger:         logger,
+	}
+}
+
+// ListPolicies retrieves all policies
+func (s *PolicyService) ListPolicies(ctx context.Context) ([]domain.Policy, error) {
+	return s.policyRepo.ListPolicies(ctx)
+}
+
+// GetPolicy retrieves a policy by ID
+func (s *PolicyService) GetPolicy(ctx context.Context, id int64) (domain.Policy, error) {
+	return s.policyRepo.GetPolicyByID(ctx, id)
+}
+
+// CreatePolicy validates and stores a new policy
+func (s *PolicyService) CreatePolicy(ctx context.Context, req domain.PolicyRequest) (domain.Policy, []domain.FieldError, error) {
+	policy, fieldErrors := buildPolicy(req, "")
+	if len(fieldErrors) > 0 {
+		return domain.Policy{}, fieldErrors, nil
+	}
+
+	id, err := s.policyRepo.CreatePolicy(ctx, policy)
+	if err != nil {
+		return domain.Policy{}, nil, err
+	}
+
+	s.invalidateCache()
+
+	policy, err = s.policyRepo.GetPolicyByID(ctx, id)
+	return policy, nil, err
+}
+
+// UpdatePolicy validates and replaces the definition of a policy
+func (s *PolicyService) UpdatePolicy(ctx context.Context, id int64, req domain.PolicyRequest) (domain.Policy, []domain.FieldError, error) {
+	policy, fieldErrors := buildPolicy(req, "")
+	if len(fieldErrors) > 0 {
+		return domain.Policy{}, fieldErrors, nil
+	}
+
+	policy.ID = id
+	if err := s.policyRepo.UpdatePolicy(ctx, policy); err != nil {
+		return domain.Policy{}, nil, err
+	}
+
+	s.invalidateCache()
+
+	policy, err := s.policyRepo.GetPolicyByID(ctx, id)
+	return policy, nil, err
+}
+
+// DeletePolicy deletes a policy
+func (s *PolicyService) DeletePolicy(ctx context.Context, id int64) error {
+	if err := s.policyRepo.DeletePolicy(ctx, id); err != nil {
+		return err
+	}
+
+	s.invalidateCache()
+	return nil
+}
+
+// Authorize decides whether the subject may perform the action on the resource according to the enabled policies.
+// Deny policies override allow policies; without an applicable allow policy the request is denied.
+func (s *PolicyService) Authorize(ctx context.Context, req domain.AuthorizeRequest) (domain.AuthorizationDecision, []domain.FieldError, error) {
+	if fieldErrors := validateAuthorizeRequest(req, ""); len(fieldErrors) > 0 {
+		return domain.AuthorizationDecision{}, fieldErrors, nil
+	}
+
+	policies, err := s.getPolicies(ctx)
+	if err != nil {
+		return domain.AuthorizationDecision{}, nil, err
+	}
+
+	env, err := s.buildEnvironment(ctx, req)
+	if err != nil {
+		return domain.AuthorizationDecision{}, nil, err
+	}
+
+	return evaluatePolicies(policies, env, req), nil, nil
+}
+
+// DryRun evaluates candidate policies against a request without storing them, always explaining the decision.
+// When requested, the stored policies take part too; a candidate replaces the stored policy with the same name.
+func (s *PolicyService) DryRun(ctx context.Context, req domain.PolicyDryRunRequest) (domain.AuthorizationDecision, []domain.FieldError, error) {
+	fieldErrors := validateAuthorizeRequest(req.Request, "request.")
+
+	var candidates []compiledPolicy
+	for i, policyReq := range req.Policies {
+		policy, errs := buildPolicy(policyReq, fmt.Sprintf("policies[%d].", i))
+		if len(errs) > 0 {
+			fieldErrors = append(fieldErrors, errs...)
+			continue
+		}
+		candidates = append(candidates, compilePolicy(policy))
+	}
+
+	if len(fieldErrors) > 0 {
+		return domain.AuthorizationDecision{}, fieldErrors, nil
+	}
+
+	var policies []compiledPolicy
+	if req.IncludeStored {
+		stored, err := s.getPolicies(ctx)
+		if err != nil {
+			return domain.AuthorizationDecision{}, nil, err
+		}
+
+		replaced := make(map[string]bool, len(candidates))
+		for _, candidate := range candidates {
+			replaced[candidate.policy.Name] = true
+		}
+		for _, policy := range stored {
+			if !replaced[policy.policy.Name] {
+				policies = append(policies, policy)
+			}
+		}
+	}
+	policies = append(policies, candidates...)
+
+	env, err := s.buildEnvironment(ctx, req.Request)
+	if err != nil {
+		return domain.AuthorizationDecision{}, nil, err
+	}
+
+	authReq := req.Request
+	authReq.Explain = true
+
+	return evaluatePolicies(policies, env, authReq), nil, nil
+}
+
+// getPolicies returns the compiled enabled policies, reloading them when the cache is stale
+func (s *PolicyService) getPolicies(ctx context.Context) ([]compiledPolicy, error) {
+	s.mu.RLock()
+	if !s.cachedAt.IsZero() && time.Since(s.cachedAt) < s.cacheTTL {
+		policies := s.cache
+		s.mu.RUnlock()
+		return policies, nil
+	}
+	s.mu.RUnlock()
+
+	stored, err := s.policyRepo.ListEnabledPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make([]compiledPolicy, 0, len(stored))
+	for _, policy := range stored {
+		compiled := compilePolicy(policy)
+		if compiled.compileErr != nil {
+			s.logger.Errorf("Error compiling condition of policy %s: %v", policy.Name, compiled.compileErr)
+		}
+		policies = append(policies, compiled)
+	}
+
+	s.mu.Lock()
+	s.cache = policies
+	s.cachedAt = time.Now()
+	s.mu.Unlock()
+
+	return policies, nil
+}
+
+// invalidateCache forces the policies to be reloaded on the next decision
+func (s *PolicyService) invalidateCache() {
+	s.mu.Lock()
+	s.cachedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+// buildEnvironment collects the attributes policy conditions are evaluated against
+func (s *PolicyService) buildEnvironment(ctx context.Context, req domain.AuthorizeRequest) (map[string]interface{}, error) {
+	user, err := s.userRepo.GetByID(ctx, req.SubjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.roleRepo.GetUserRoleNames(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := s.permissionRepo.GetUserPermissionNames(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	memberships, err := s.orgRepo.GetUserMemberships(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Organization roles are keyed by the organization ID as a string, e.g. subject.orgRoles[resource.orgId]
+	organizations := make([]int64, 0, len(memberships))
+	orgRoles := make(map[string][]string, len(memberships))
+	for _, membership := range memberships {
+		organizations = append(organizations, membership.OrganizationID)
+		orgRoles[strconv.FormatInt(membership.OrganizationID, 10)] = membership.Roles
+	}
+
+	resource := make(map[string]interface{}, len(req.Resource.Attributes)+2)
+	for key, value := range req.Resource.Attributes {
+		resource[key] = value
+	}
+	resource["type"] = req.Resource.Type
+	resource["id"] = req.Resource.ID
+
+	requestContext := req.Context
+	if requestContext == nil {
+		requestContext = map[string]interface{}{}
+	}
+
+	now := time.Now().In(s.location)
+	weekday := int(now.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+
+	return map[string]interface{}{
+		"subject": map[string]interface{}{
+			"id":            user.ID,
+			"email":         user.Email,
+			"nickname":      user.Nickname,
+			"emailVerified": user.EmailVerified,
+			"roles":         roles,
+			"permissions":   permissions,
+			"organizations": organizations,
+			"orgRoles":      orgRoles,
+		},
+		"action":   req.Action,
+		"resource": resource,
+		"context":  requestContext,
+		"now": map[string]interface{}{
+			"unix":    now.Unix(),
+			"year":    now.Year(),
+			"month":   int(now.Month()),
+			"day":     now.Day(),
+			"hour":    now.Hour(),
+			"minute":  now.Minute(),
+			"weekday": weekday, // 1 = Monday, 7 = Sunday
+		},
+	}, nil
+}
+
+// evaluatePolicies combines the policies into a decision using deny-overrides
+func evaluatePolicies(policies []compiledPolicy, env map[string]interface{}, req domain.AuthorizeRequest) domain.AuthorizationDecision {
+	var allowedBy, deniedBy string
+	var evaluations []domain.PolicyEvaluation
+
+	for _, compiled := range policies {
+		policy := compiled.policy
+		evaluation := domain.PolicyEvaluation{
+			PolicyID: policy.ID,
+			Policy:   policy.Name,
+			Effect:   policy.Effect,
+			Applicable: matchPattern(policy.Action, req.Action) &&
+				matchPattern(policy.ResourceType, req.Resource.Type),
+		}
+
+		if evaluation.Applicable {
+			err := compiled.compileErr
+			if err == nil && compiled.program != nil {
+				evaluation.ConditionMet, err = compiled.program.EvalBool(env)
+			} else if err == nil {
+				evaluation.ConditionMet = true
+			}
+
+			if err != nil {
+				evaluation.Error = err.Error()
+				// A deny policy that cannot be evaluated fails closed
+				evaluation.ConditionMet = policy.Effect == domain.PolicyEffects.Deny
+			}
+
+			if evaluation.ConditionMet {
+				if policy.Effect == domain.PolicyEffects.Deny && deniedBy == "" {
+					deniedBy = policy.Name
+				}
+				if policy.Effect == domain.PolicyEffects.Allow && allowedBy == "" {
+					allowedBy = policy.Name
+				}
+			}
+		}
+
+		evaluations = append(evaluations, evaluation)
+	}
+
+	var decision domain.AuthorizationDecision
+	switch {
+	case deniedBy != "":
+		decision = domain.AuthorizationDecision{
+			Allowed: false,
+			Reason:  fmt.Sprintf("denied by policy %s", deniedBy),
+			Policy:  deniedBy,
+		}
+	case allowedBy != "":
+		decision = domain.AuthorizationDecision{
+			Allowed: true,
+			Reason:  fmt.Sprintf("allowed by policy %s", allowedBy),
+			Policy:  allowedBy,
+		}
+	default:
+		decision = domain.AuthorizationDecision{
+			Allowed: false,
+			Reason:  "no applicable policy allows the action",
+		}
+	}
+
+	if req.Explain {
+		decision.Evaluations = evaluations
+	}
+
+	return decision
+}
+
+// matchPattern matches a value against a policy pattern: "*" matches anything, a trailing "*" matches a prefix
+func matchPattern(pattern, value string) bool {
+	if pattern == "*" || pattern == value {
+		return true
+	}
+
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(value, strings.TrimSuffix(pattern, "*"))
+	}
+
+	return false
+}
+
+// compilePolicy parses the condition of a policy; an empty condition always holds
+func compilePolicy(policy domain.Policy) compiledPolicy {
+	compiled := compiledPolicy{policy: policy}
+	if strings.TrimSpace(policy.Condition) != "" {
+		compiled.program, compiled.compileErr = expr.Compile(policy.Condition)
+	}
+	return compiled
+}
+
+// buildPolicy validates a policy request and converts it to a policy; prefix is prepended to field names
+func buildPolicy(req domain.PolicyRequest, prefix string) (domain.Policy, []domain.FieldError) {
+	var fieldErrors []domain.FieldError
+
+	policy := domain.Policy{
+		Name:         strings.TrimSpace(req.Name),
+		Description:  strings.TrimSpace(req.Description),
+		Effect:       strings.TrimSpace(req.Effect),
+		Action:       strings.TrimSpace(req.Action),
+		ResourceType: strings.TrimSpace(req.ResourceType),
+		Condition:    strings.TrimSpace(req.Condition),
+		Enabled:      true,
+	}
+	if req.Enabled != nil {
+		policy.Enabled = *req.Enabled
+	}
+	if policy.ResourceType == "" {
+		policy.ResourceType = "*"
+	}
+
+	if policy.Name == "" {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   prefix + "name",
+			Message: "Поле пустое",
+		})
+	} else if len(policy.Name) > 100 {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   prefix + "name",
+			Message: "Название политики не может быть длиннее 100 символов",
+		})
+	}
+
+	if policy.Effect != domain.PolicyEffects.Allow && policy.Effect != domain.PolicyEffects.Deny {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   prefix + "effect",
+			Message: "Допустимые значения: allow, deny",
+		})
+	}
+
+	if policy.Action == "" {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   prefix + "action",
+			Message: "Поле пустое",
+		})
+	}
+
+	if compiled := compilePolicy(policy); compiled.compileErr != nil {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   prefix + "condition",
+			Message: fmt.Sprintf("Некорректное выражение: %v", compiled.compileErr),
+		})
+	}
+
+	return policy, fieldErrors
+}
+
+// validateAuthorizeRequest validates an authorization request; prefix is prepended to field names
+func validateAuthorizeRequest(req domain.AuthorizeRequest, prefix string) []domain.FieldError {
+	var fieldErrors []domain.FieldError
+
+	if req.SubjectID == 0 {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   prefix + "subjectId",
+			Message: "Поле пустое",
+		})
+	}
+
+	if strings.TrimSpace(req.Action) == "" {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   prefix + "action",
+			Message: "Поле пустое",
+		})
+	}
+
+	return fieldErrors
+}
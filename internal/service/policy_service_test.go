@@ -0,0 +1,384 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"authmicro/configs"
+	"authmicro/internal/domain"
+)
+
+// nopLogger discards everything logged by the services under test
+type nopLogger struct{}
+
+func (nopLogger) Debug(args ...interface{})                 {}
+func (nopLogger) Debugf(format string, args ...interface{}) {}
+func (nopLogger) Info(args ...interface{})                  {}
+func (nopLogger) Infof(format string, args ...interface{})  {}
+func (nopLogger) Warn(args ...interface{})                  {}
+func (nopLogger) Warnf(format string, args ...interface{})  {}
+func (nopLogger) Error(args ...interface{})                 {}
+func (nopLogger) Errorf(format string, args ...interface{}) {}
+func (nopLogger) Fatal(args ...interface{})                 {}
+func (nopLogger) Fatalf(format string, args ...interface{}) {}
+
+// fakePolicyRepository serves a fixed set of enabled policies and counts how often they are loaded
+type fakePolicyRepository struct {
+	policyRepository
+	policies []domain.Policy
+	loads    int
+}
+
+func (r *fakePolicyRepository) ListEnabledPolicies(ctx context.Context) ([]domain.Policy, error) {
+	r.loads++
+	return r.policies, nil
+}
+
+func (r *fakePolicyRepository) DeletePolicy(ctx context.Context, id int64) error {
+	return nil
+}
+
+// fakeSubjects provides a single subject with fixed roles, permissions and memberships
+type fakeSubjects struct {
+	user        domain.User
+	roles       []string
+	permissions []string
+	memberships []domain.Membership
+}
+
+func (f *fakeSubjects) GetByID(ctx context.Context, id int64) (domain.User, error) {
+	if id != f.user.ID {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	return f.user, nil
+}
+
+func (f *fakeSubjects) GetUserRoleNames(ctx context.Context, userID int64) ([]string, error) {
+	return f.roles, nil
+}
+
+func (f *fakeSubjects) GetUserPermissionNames(ctx context.Context, userID int64) ([]string, error) {
+	return f.permissions, nil
+}
+
+func (f *fakeSubjects) GetUserMemberships(ctx context.Context, userID int64) ([]domain.Membership, error) {
+	return f.memberships, nil
+}
+
+func newTestPolicyService(repo *fakePolicyRepository, ttl time.Duration) *PolicyService {
+	subjects := &fakeSubjects{
+		user:        domain.User{ID: 1, Email: "alice@example.com", Nickname: "alice", EmailVerified: true},
+		roles:       []string{"editor"},
+		permissions: []string{"documents:read"},
+		memberships: []domain.Membership{{OrganizationID: 42, Roles: []string{"admin"}}},
+	}
+
+	return NewPolicyService(repo, subjects, subjects, subjects, subjects, configs.PolicyConfig{CacheTTL: ttl, Timezone: "UTC"}, nopLogger{})
+}
+
+func testPolicy(id int64, name, effect, action, condition string) domain.Policy {
+	return domain.Policy{
+		ID:           id,
+		Name:         name,
+		Effect:       effect,
+		Action:       action,
+		ResourceType: "*",
+		Condition:    condition,
+		Enabled:      true,
+	}
+}
+
+func TestPolicyServiceAuthorize(t *testing.T) {
+	allow, deny := domain.PolicyEffects.Allow, domain.PolicyEffects.Deny
+
+	tests := []struct {
+		name       string
+		policies   []domain.Policy
+		action     string
+		resource   domain.AuthorizationResource
+		wantAllow  bool
+		wantPolicy string
+	}{
+		{
+			name:     "no policies",
+			action:   "documents:read",
+			policies: nil,
+		},
+		{
+			name:       "allow policy with a condition that holds",
+			action:     "documents:read",
+			policies:   []domain.Policy{testPolicy(1, "editors", allow, "documents:*", "'editor' in subject.roles")},
+			wantAllow:  true,
+			wantPolicy: "editors",
+		},
+		{
+			name:     "allow policy with a condition that does not hold",
+			action:   "documents:read",
+			policies: []domain.Policy{testPolicy(1, "admins", allow, "*", "'admin' in subject.roles")},
+		},
+		{
+			name:     "allow policy for another action",
+			action:   "billing:read",
+			policies: []domain.Policy{testPolicy(1, "documents", allow, "documents:*", "")},
+		},
+		{
+			name:   "deny overrides an earlier allow",
+			action: "documents:delete",
+			policies: []domain.Policy{
+				testPolicy(1, "editors", allow, "documents:*", ""),
+				testPolicy(2, "no-deletes", deny, "documents:delete", ""),
+			},
+			wantPolicy: "no-deletes",
+		},
+		{
+			name:   "deny that does not apply leaves the allow",
+			action: "documents:read",
+			policies: []domain.Policy{
+				testPolicy(1, "no-deletes", deny, "documents:delete", ""),
+				testPolicy(2, "editors", allow, "documents:*", ""),
+			},
+			wantAllow:  true,
+			wantPolicy: "editors",
+		},
+		{
+			name:   "deny whose condition fails to evaluate fails closed",
+			action: "documents:read",
+			policies: []domain.Policy{
+				testPolicy(1, "editors", allow, "*", ""),
+				testPolicy(2, "owner-only", deny, "*", "resource.owner != subject.id"),
+			},
+			wantPolicy: "owner-only",
+		},
+		{
+			name:     "allow whose condition fails to evaluate does not allow",
+			action:   "documents:read",
+			policies: []domain.Policy{testPolicy(1, "owners", allow, "*", "resource.owner == subject.id")},
+		},
+		{
+			name:     "allow whose condition does not compile does not allow",
+			action:   "documents:read",
+			policies: []domain.Policy{testPolicy(1, "broken", allow, "*", "subject.roles ==")},
+		},
+		{
+			name:       "resource and organization attributes",
+			action:     "documents:read",
+			resource:   domain.AuthorizationResource{Type: "document", Attributes: map[string]interface{}{"orgId": "42"}},
+			policies:   []domain.Policy{testPolicy(1, "org-admins", allow, "*", "'admin' in subject.orgRoles[resource.orgId]")},
+			wantAllow:  true,
+			wantPolicy: "org-admins",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestPolicyService(&fakePolicyRepository{policies: tt.policies}, time.Minute)
+
+			decision, fieldErrors, err := s.Authorize(context.Background(), domain.AuthorizeRequest{
+				SubjectID: 1,
+				Action:    tt.action,
+				Resource:  tt.resource,
+			})
+			if err != nil || len(fieldErrors) > 0 {
+				t.Fatalf("Authorize() = %v, %v", fieldErrors, err)
+			}
+
+			if decision.Allowed != tt.wantAllow || decision.Policy != tt.wantPolicy {
+				t.Errorf("Authorize() = allowed %v by %q (%s), want allowed %v by %q",
+					decision.Allowed, decision.Policy, decision.Reason, tt.wantAllow, tt.wantPolicy)
+			}
+			if decision.Evaluations != nil {
+				t.Errorf("Authorize() explained a decision that was not asked to be explained")
+			}
+		})
+	}
+}
+
+func TestPolicyServiceAuthorizeExplain(t *testing.T) {
+	repo := &fakePolicyRepository{policies: []domain.Policy{
+		testPolicy(1, "editors", domain.PolicyEffects.Allow, "documents:*", "'editor' in subject.roles"),
+		testPolicy(2, "billing", domain.PolicyEffects.Allow, "billing:*", ""),
+		testPolicy(3, "owner-only", domain.PolicyEffects.Deny, "*", "resource.owner != subject.id"),
+	}}
+	s := newTestPolicyService(repo, time.Minute)
+
+	decision, _, err := s.Authorize(context.Background(), domain.AuthorizeRequest{
+		SubjectID: 1,
+		Action:    "documents:read",
+		Explain:   true,
+	})
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+
+	want := []domain.PolicyEvaluation{
+		{PolicyID: 1, Policy: "editors", Effect: "allow", Applicable: true, ConditionMet: true},
+		{PolicyID: 2, Policy: "billing", Effect: "allow"},
+		{PolicyID: 3, Policy: "owner-only", Effect: "deny", Applicable: true, ConditionMet: true, Error: `no such attribute "owner"`},
+	}
+	if len(decision.Evaluations) != len(want) {
+		t.Fatalf("Authorize() evaluations = %+v, want %+v", decision.Evaluations, want)
+	}
+	for i := range want {
+		if decision.Evaluations[i] != want[i] {
+			t.Errorf("evaluation %d = %+v, want %+v", i, decision.Evaluations[i], want[i])
+		}
+	}
+}
+
+func TestPolicyServiceAuthorizeValidation(t *testing.T) {
+	s := newTestPolicyService(&fakePolicyRepository{}, time.Minute)
+
+	_, fieldErrors, err := s.Authorize(context.Background(), domain.AuthorizeRequest{})
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	if len(fieldErrors) != 2 || fieldErrors[0].Field != "subjectId" || fieldErrors[1].Field != "action" {
+		t.Errorf("Authorize() field errors = %+v, want subjectId and action", fieldErrors)
+	}
+}
+
+func TestPolicyServiceCache(t *testing.T) {
+	ctx := context.Background()
+	req := domain.AuthorizeRequest{SubjectID: 1, Action: "documents:read"}
+
+	t.Run("reuses policies within the TTL", func(t *testing.T) {
+		repo := &fakePolicyRepository{}
+		s := newTestPolicyService(repo, time.Hour)
+
+		for i := 0; i < 3; i++ {
+			if _, _, err := s.Authorize(ctx, req); err != nil {
+				t.Fatalf("Authorize() error = %v", err)
+			}
+		}
+		if repo.loads != 1 {
+			t.Errorf("policies loaded %d times, want 1", repo.loads)
+		}
+	})
+
+	t.Run("reloads policies once the TTL has passed", func(t *testing.T) {
+		repo := &fakePolicyRepository{}
+		s := newTestPolicyService(repo, time.Hour)
+
+		if _, _, err := s.Authorize(ctx, req); err != nil {
+			t.Fatalf("Authorize() error = %v", err)
+		}
+
+		// A policy enabled in the meantime is seen only after the cache expires
+		repo.policies = []domain.Policy{testPolicy(1, "everyone", domain.PolicyEffects.Allow, "*", "")}
+		decision, _, _ := s.Authorize(ctx, req)
+		if decision.Allowed {
+			t.Fatalf("Authorize() used reloaded policies before the TTL passed")
+		}
+
+		s.mu.Lock()
+		s.cachedAt = time.Now().Add(-time.Hour)
+		s.mu.Unlock()
+
+		decision, _, _ = s.Authorize(ctx, req)
+		if !decision.Allowed {
+			t.Errorf("Authorize() did not reload the policies after the TTL passed")
+		}
+		if repo.loads != 2 {
+			t.Errorf("policies loaded %d times, want 2", repo.loads)
+		}
+	})
+
+	t.Run("zero TTL reloads on every decision", func(t *testing.T) {
+		repo := &fakePolicyRepository{}
+		s := newTestPolicyService(repo, 0)
+
+		for i := 0; i < 3; i++ {
+			if _, _, err := s.Authorize(ctx, req); err != nil {
+				t.Fatalf("Authorize() error = %v", err)
+			}
+		}
+		if repo.loads != 3 {
+			t.Errorf("policies loaded %d times, want 3", repo.loads)
+		}
+	})
+
+	t.Run("changing a policy invalidates the cache", func(t *testing.T) {
+		repo := &fakePolicyRepository{}
+		s := newTestPolicyService(repo, time.Hour)
+
+		if _, _, err := s.Authorize(ctx, req); err != nil {
+			t.Fatalf("Authorize() error = %v", err)
+		}
+		if err := s.DeletePolicy(ctx, 1); err != nil {
+			t.Fatalf("DeletePolicy() error = %v", err)
+		}
+		if _, _, err := s.Authorize(ctx, req); err != nil {
+			t.Fatalf("Authorize() error = %v", err)
+		}
+		if repo.loads != 2 {
+			t.Errorf("policies loaded %d times, want 2", repo.loads)
+		}
+	})
+}
+
+func TestPolicyServiceDryRun(t *testing.T) {
+	repo := &fakePolicyRepository{policies: []domain.Policy{
+		testPolicy(1, "no-reads", domain.PolicyEffects.Deny, "documents:read", ""),
+	}}
+	s := newTestPolicyService(repo, time.Minute)
+
+	candidate := domain.PolicyRequest{Name: "editors", Effect: "allow", Action: "documents:*"}
+
+	decision, fieldErrors, err := s.DryRun(context.Background(), domain.PolicyDryRunRequest{
+		Policies:      []domain.PolicyRequest{candidate},
+		IncludeStored: true,
+		Request:       domain.AuthorizeRequest{SubjectID: 1, Action: "documents:read"},
+	})
+	if err != nil || len(fieldErrors) > 0 {
+		t.Fatalf("DryRun() = %v, %v", fieldErrors, err)
+	}
+	if decision.Allowed || decision.Policy != "no-reads" || len(decision.Evaluations) != 2 {
+		t.Errorf("DryRun() = %+v, want a denial by the stored policy explained", decision)
+	}
+
+	// A candidate replaces the stored policy of the same name
+	replacement := domain.PolicyRequest{Name: "no-reads", Effect: "deny", Action: "documents:delete"}
+	decision, _, err = s.DryRun(context.Background(), domain.PolicyDryRunRequest{
+		Policies:      []domain.PolicyRequest{candidate, replacement},
+		IncludeStored: true,
+		Request:       domain.AuthorizeRequest{SubjectID: 1, Action: "documents:read"},
+	})
+	if err != nil {
+		t.Fatalf("DryRun() error = %v", err)
+	}
+	if !decision.Allowed || decision.Policy != "editors" {
+		t.Errorf("DryRun() = %+v, want allowed by the candidate", decision)
+	}
+
+	_, fieldErrors, err = s.DryRun(context.Background(), domain.PolicyDryRunRequest{
+		Policies: []domain.PolicyRequest{{Name: "broken", Effect: "maybe", Action: "*", Condition: "1 +"}},
+		Request:  domain.AuthorizeRequest{SubjectID: 1, Action: "documents:read"},
+	})
+	if err != nil {
+		t.Fatalf("DryRun() error = %v", err)
+	}
+	if len(fieldErrors) != 2 || fieldErrors[0].Field != "policies[0].effect" || fieldErrors[1].Field != "policies[0].condition" {
+		t.Errorf("DryRun() field errors = %+v, want the effect and condition of the candidate", fieldErrors)
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, value string
+		want           bool
+	}{
+		{"*", "anything", true},
+		{"documents:read", "documents:read", true},
+		{"documents:read", "documents:write", false},
+		{"documents:*", "documents:write", true},
+		{"documents:*", "billing:read", false},
+		{"documents", "documents:read", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
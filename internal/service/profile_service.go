@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"authmicro/configs"
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type profileRepository interface {
+	GetByID(ctx context.Context, id int64) (domain.User, error)
+	NicknameExists(ctx context.Context, nickname string) (bool, error)
+	UpdateProfile(ctx context.Context, user domain.User) error
+}
+
+// ProfileService implements self-service management of the user's own profile
+type ProfileService struct {
+	userRepo  profileRepository
+	nicknames *nicknameChanger
+	logger    logger.Logger
+}
+
+func NewProfileService(userRepo profileRepository, nicknameRepo nicknameHistoryRepository, uow unitOfWork, nicknameConfig configs.NicknameConfig, logger logger.Logger) *ProfileService {
+	return &ProfileService{
+		userRepo: userRepo,
+		nicknames: &nicknameChanger{
+			userRepo:     userRepo,
+			nicknameRepo: nicknameRepo,
+			uow:          uow,
+			config:       nicknameConfig,
+			logger:       logger,
+		},
+		logger: logger,
+	}
+}
+
+// GetMe retrieves the profile of the user
+func (s *ProfileService) GetMe(ctx context.Context, userID int64) (domain.User, error) {
+	return s.userRepo.GetByID(ctx, userID)
+}
+
+// UpdateProfile changes the first name, last name and nickname of the user with the rules applied at registration.
+// Nickname changes are recorded in the nickname history and limited in frequency.
+func (s *ProfileService) UpdateProfile(ctx context.Context, userID int64, req domain.UpdateProfileRequest) (domain.User, []domain.FieldError, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return domain.User{}, nil, err
+	}
+
+	var fieldErrors []domain.FieldError
+
+	if req.FirstName != nil {
+		user.FirstName = *req.FirstName
+		fieldErrors = append(fieldErrors, validateName("firstName", user.FirstName)...)
+	}
+
+	if req.LastName != nil {
+		user.LastName = *req.LastName
+		fieldErrors = append(fieldErrors, validateName("lastName", user.LastName)...)
+	}
+
+	oldNickname := user.Nickname
+	if req.Nickname != nil && *req.Nickname != user.Nickname {
+		user.Nickname = *req.Nickname
+		nicknameErrors, err := s.nicknames.validate(ctx, userID, user.Nickname, true)
+		if err != nil {
+			return domain.User{}, nil, err
+		}
+		fieldErrors = append(fieldErrors, nicknameErrors...)
+	}
+
+	if len(fieldErrors) > 0 {
+		return domain.User{}, fieldErrors, nil
+	}
+
+	if user.Nickname != oldNickname {
+		err = s.nicknames.save(ctx, user, oldNickname, nil)
+	} else {
+		err = s.userRepo.UpdateProfile(ctx, user)
+	}
+	if err != nil {
+		if errors.Is(err, domain.ErrNicknameExists) {
+			// The nickname was taken after it had been checked
+			return domain.User{}, []domain.FieldError{nicknameTakenError}, nil
+		}
+		s.logger.Errorf("Error updating profile: %v", err)
+		return domain.User{}, nil, err
+	}
+
+	user, err = s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return domain.User{}, nil, err
+	}
+
+	return user, nil, nil
+}
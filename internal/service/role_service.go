@@ -0,0 +1,451 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type roleManagementRepository interface {
+	CreateRole(ctx context.Context, name string) (int64, error)
+	GetRoleByID(ctx context.Context, id int64) (domain.Role, error)
+	ListRoles(ctx context.Context) ([]domain.Role, error)
+	RenameRole(ctx context.Context, id int64, name string) error
+	DeleteRole(ctx context.Context, id int64) error
+	GrantRoleToUser(ctx context.Context, userID, roleID, grantedBy int64, expiresAt *time.Time) error
+	RemoveRoleFromUser(ctx context.Context, userID, roleID int64) error
+	DeleteExpiredRoleGrants(ctx context.Context) ([]domain.UserRole, error)
+	GetUsersByRole(ctx context.Context, roleID int64) ([]domain.User, error)
+	GetUserIDsWithEffectiveRole(ctx context.Context, roleID int64) ([]int64, error)
+	GetUserRoleGrants(ctx context.Context, userID int64) ([]domain.RoleGrant, error)
+	GetUserRoleNames(ctx context.Context, userID int64) ([]string, error)
+	AddRoleChild(ctx context.Context, parentID, childID int64) error
+	RemoveRoleChild(ctx context.Context, parentID, childID int64) error
+	GetRoleChildren(ctx context.Context, roleID int64) ([]domain.Role, error)
+	CreateRoleChange(ctx context.Context, change domain.RoleChange) error
+	ListRoleChanges(ctx context.Context, limit int) ([]domain.RoleChange, error)
+}
+
+type roleUserRepository interface {
+	GetByID(ctx context.Context, id int64) (domain.User, error)
+	IncrementAuthzVersion(ctx context.Context, userIDs ...int64) error
+}
+
+// RoleService implements administrative management of roles and role assignments
+type RoleService struct {
+	roleRepo    roleManagementRepository
+	userRepo    roleUserRepository
+	uow         unitOfWork
+	invalidator *tokenInvalidator
+	logger      logger.Logger
+}
+
+// NewRoleService creates a role service. When revokeRefreshTokens is set, a role change also revokes
+// the refresh tokens of the affected users, forcing them to log in again.
+func NewRoleService(roleRepo roleManagementRepository, userRepo roleUserRepository, uow unitOfWork, tokenSvc tokenRevoker, revokeRefreshTokens bool, logger logger.Logger) *RoleService {
+	return &RoleService{
+		roleRepo: roleRepo,
+		userRepo: userRepo,
+		uow:      uow,
+		invalidator: &tokenInvalidator{
+			versionRepo:         userRepo,
+			tokenSvc:            tokenSvc,
+			revokeRefreshTokens: revokeRefreshTokens,
+			logger:              logger,
+		},
+		logger: logger,
+	}
+}
+
+// ListRoles retrieves all roles
+func (s *RoleService) ListRoles(ctx context.Context) ([]domain.Role, error) {
+	return s.roleRepo.ListRoles(ctx)
+}
+
+// CreateRole creates a new role on behalf of an admin
+func (s *RoleService) CreateRole(ctx context.Context, actorID int64, req domain.CreateRoleRequest) (domain.Role, []domain.FieldError, error) {
+	name := strings.TrimSpace(req.Name)
+	if fieldErrors := validateRoleName(name); len(fieldErrors) > 0 {
+		return domain.Role{}, fieldErrors, nil
+	}
+
+	var id int64
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		id, err = s.roleRepo.CreateRole(ctx, name)
+		if err != nil {
+			return err
+		}
+
+		return s.roleRepo.CreateRoleChange(ctx, domain.RoleChange{
+			Action:   domain.RoleChangeActions.Created,
+			RoleID:   id,
+			RoleName: name,
+			ActorID:  actorID,
+		})
+	})
+	if err != nil {
+		return domain.Role{}, nil, err
+	}
+
+	role, err := s.roleRepo.GetRoleByID(ctx, id)
+	return role, nil, err
+}
+
+// RenameRole renames an existing role on behalf of an admin
+func (s *RoleService) RenameRole(ctx context.Context, actorID, roleID int64, req domain.RenameRoleRequest) (domain.Role, []domain.FieldError, error) {
+	name := strings.TrimSpace(req.Name)
+	if fieldErrors := validateRoleName(name); len(fieldErrors) > 0 {
+		return domain.Role{}, fieldErrors, nil
+	}
+
+	role, err := s.roleRepo.GetRoleByID(ctx, roleID)
+	if err != nil {
+		return domain.Role{}, nil, err
+	}
+
+	// Default roles are referenced by name throughout the service
+	if domain.IsDefaultRole(role.Name) {
+		return domain.Role{}, nil, domain.ErrProtectedRole
+	}
+
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.roleRepo.RenameRole(ctx, roleID, name); err != nil {
+			return err
+		}
+
+		// Tokens carry role names, so the holders need fresh ones
+		if err := s.invalidateRoleHolderTokens(ctx, roleID); err != nil {
+			return err
+		}
+
+		return s.roleRepo.CreateRoleChange(ctx, domain.RoleChange{
+			Action:   domain.RoleChangeActions.Renamed,
+			RoleID:   roleID,
+			RoleName: name,
+			ActorID:  actorID,
+			Details:  fmt.Sprintf("renamed from %s", role.Name),
+		})
+	})
+	if err != nil {
+		return domain.Role{}, nil, err
+	}
+
+	role, err = s.roleRepo.GetRoleByID(ctx, roleID)
+	return role, nil, err
+}
+
+// DeleteRole deletes a role on behalf of an admin
+func (s *RoleService) DeleteRole(ctx context.Context, actorID, roleID int64) error {
+	role, err := s.roleRepo.GetRoleByID(ctx, roleID)
+	if err != nil {
+		return err
+	}
+
+	if domain.IsDefaultRole(role.Name) {
+		return domain.ErrProtectedRole
+	}
+
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		// Collect the affected users first, their grants are removed together with the role
+		userIDs, err := s.roleRepo.GetUserIDsWithEffectiveRole(ctx, roleID)
+		if err != nil {
+			return err
+		}
+
+		if err := s.roleRepo.DeleteRole(ctx, roleID); err != nil {
+			return err
+		}
+
+		if err := s.invalidator.invalidate(ctx, userIDs...); err != nil {
+			return err
+		}
+
+		return s.roleRepo.CreateRoleChange(ctx, domain.RoleChange{
+			Action:   domain.RoleChangeActions.Deleted,
+			RoleID:   roleID,
+			RoleName: role.Name,
+			ActorID:  actorID,
+		})
+	})
+}
+
+// AssignRole assigns a role to a user on behalf of an admin, optionally for a limited time
+func (s *RoleService) AssignRole(ctx context.Context, actorID, userID, roleID int64, req domain.AssignRoleRequest) error {
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return domain.ErrGrantExpiryInPast
+	}
+
+	role, err := s.roleRepo.GetRoleByID(ctx, roleID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return err
+	}
+
+	var expiresAt *time.Time
+	var details string
+	if req.ExpiresAt != nil {
+		utc := req.ExpiresAt.UTC()
+		expiresAt = &utc
+		details = fmt.Sprintf("expires at %s", utc.Format(time.RFC3339))
+	}
+
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.roleRepo.GrantRoleToUser(ctx, userID, roleID, actorID, expiresAt); err != nil {
+			return err
+		}
+
+		if err := s.invalidator.invalidate(ctx, userID); err != nil {
+			return err
+		}
+
+		return s.roleRepo.CreateRoleChange(ctx, domain.RoleChange{
+			Action:   domain.RoleChangeActions.Assigned,
+			RoleID:   roleID,
+			RoleName: role.Name,
+			UserID:   &userID,
+			ActorID:  actorID,
+			Details:  details,
+		})
+	})
+}
+
+// UnassignRole removes a role from a user on behalf of an admin
+func (s *RoleService) UnassignRole(ctx context.Context, actorID, userID, roleID int64) error {
+	role, err := s.roleRepo.GetRoleByID(ctx, roleID)
+	if err != nil {
+		return err
+	}
+
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.roleRepo.RemoveRoleFromUser(ctx, userID, roleID); err != nil {
+			return err
+		}
+
+		if err := s.invalidator.invalidate(ctx, userID); err != nil {
+			return err
+		}
+
+		return s.roleRepo.CreateRoleChange(ctx, domain.RoleChange{
+			Action:   domain.RoleChangeActions.Unassigned,
+			RoleID:   roleID,
+			RoleName: role.Name,
+			UserID:   &userID,
+			ActorID:  actorID,
+		})
+	})
+}
+
+// ListUsersByRole retrieves all users that have a role assigned
+func (s *RoleService) ListUsersByRole(ctx context.Context, roleID int64) ([]domain.User, error) {
+	if _, err := s.roleRepo.GetRoleByID(ctx, roleID); err != nil {
+		return nil, err
+	}
+
+	return s.roleRepo.GetUsersByRole(ctx, roleID)
+}
+
+// GetUserRoles retrieves the direct and effective roles of a user
+func (s *RoleService) GetUserRoles(ctx context.Context, userID int64) (domain.UserRolesView, error) {
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return domain.UserRolesView{}, err
+	}
+
+	direct, err := s.roleRepo.GetUserRoleGrants(ctx, userID)
+	if err != nil {
+		return domain.UserRolesView{}, err
+	}
+
+	effective, err := s.roleRepo.GetUserRoleNames(ctx, userID)
+	if err != nil {
+		return domain.UserRolesView{}, err
+	}
+
+	return domain.UserRolesView{
+		UserID:    userID,
+		Direct:    direct,
+		Effective: effective,
+	}, nil
+}
+
+// ListRoleChildren retrieves the roles directly implied by a role
+func (s *RoleService) ListRoleChildren(ctx context.Context, roleID int64) ([]domain.Role, error) {
+	if _, err := s.roleRepo.GetRoleByID(ctx, roleID); err != nil {
+		return nil, err
+	}
+
+	return s.roleRepo.GetRoleChildren(ctx, roleID)
+}
+
+// AddRoleChild makes a role imply another role on behalf of an admin
+func (s *RoleService) AddRoleChild(ctx context.Context, actorID, parentID, childID int64) error {
+	parent, err := s.roleRepo.GetRoleByID(ctx, parentID)
+	if err != nil {
+		return err
+	}
+
+	child, err := s.roleRepo.GetRoleByID(ctx, childID)
+	if err != nil {
+		return err
+	}
+
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.roleRepo.AddRoleChild(ctx, parentID, childID); err != nil {
+			return err
+		}
+
+		if err := s.invalidateRoleHolderTokens(ctx, parentID); err != nil {
+			return err
+		}
+
+		return s.roleRepo.CreateRoleChange(ctx, domain.RoleChange{
+			Action:   domain.RoleChangeActions.ChildAdded,
+			RoleID:   parentID,
+			RoleName: parent.Name,
+			ActorID:  actorID,
+			Details:  fmt.Sprintf("child role %s", child.Name),
+		})
+	})
+}
+
+// RemoveRoleChild removes a parent-child relationship between roles on behalf of an admin
+func (s *RoleService) RemoveRoleChild(ctx context.Context, actorID, parentID, childID int64) error {
+	parent, err := s.roleRepo.GetRoleByID(ctx, parentID)
+	if err != nil {
+		return err
+	}
+
+	child, err := s.roleRepo.GetRoleByID(ctx, childID)
+	if err != nil {
+		return err
+	}
+
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.roleRepo.RemoveRoleChild(ctx, parentID, childID); err != nil {
+			return err
+		}
+
+		if err := s.invalidateRoleHolderTokens(ctx, parentID); err != nil {
+			return err
+		}
+
+		return s.roleRepo.CreateRoleChange(ctx, domain.RoleChange{
+			Action:   domain.RoleChangeActions.ChildRemoved,
+			RoleID:   parentID,
+			RoleName: parent.Name,
+			ActorID:  actorID,
+			Details:  fmt.Sprintf("child role %s", child.Name),
+		})
+	})
+}
+
+// ListRoleChanges retrieves the most recent entries of the role change history
+func (s *RoleService) ListRoleChanges(ctx context.Context, limit int) ([]domain.RoleChange, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	return s.roleRepo.ListRoleChanges(ctx, limit)
+}
+
+// PurgeExpiredGrants removes expired role grants and invalidates the tokens of the affected users
+func (s *RoleService) PurgeExpiredGrants(ctx context.Context) error {
+	var grants []domain.UserRole
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		grants, err = s.roleRepo.DeleteExpiredRoleGrants(ctx)
+		if err != nil {
+			return err
+		}
+
+		var userIDs []int64
+		seen := make(map[int64]bool)
+		for _, grant := range grants {
+			userID := grant.UserID
+
+			if !seen[userID] {
+				userIDs = append(userIDs, userID)
+				seen[userID] = true
+			}
+
+			role, err := s.roleRepo.GetRoleByID(ctx, grant.RoleID)
+			if err != nil {
+				return err
+			}
+
+			err = s.roleRepo.CreateRoleChange(ctx, domain.RoleChange{
+				Action:   domain.RoleChangeActions.Expired,
+				RoleID:   grant.RoleID,
+				RoleName: role.Name,
+				UserID:   &userID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return s.invalidator.invalidate(ctx, userIDs...)
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(grants) > 0 {
+		s.logger.Infof("Removed %d expired role grants", len(grants))
+	}
+
+	return nil
+}
+
+// RunExpiredGrantsCleanup periodically purges expired role grants until the context is cancelled
+func (s *RoleService) RunExpiredGrantsCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.PurgeExpiredGrants(ctx); err != nil {
+				s.logger.Errorf("Error purging expired role grants: %v", err)
+			}
+		}
+	}
+}
+
+// invalidateRoleHolderTokens invalidates the tokens of every user whose effective roles include the role
+func (s *RoleService) invalidateRoleHolderTokens(ctx context.Context, roleID int64) error {
+	userIDs, err := s.roleRepo.GetUserIDsWithEffectiveRole(ctx, roleID)
+	if err != nil {
+		return err
+	}
+
+	return s.invalidator.invalidate(ctx, userIDs...)
+}
+
+// validateRoleName validates the name of a role
+func validateRoleName(name string) []domain.FieldError {
+	if name == "" {
+		return []domain.FieldError{{
+			Field:   "name",
+			Message: "Поле пустое",
+		}}
+	}
+
+	if len(name) > 50 {
+		return []domain.FieldError{{
+			Field:   "name",
+			Message: "Название роли не может быть длиннее 50 символов",
+		}}
+	}
+
+	return nil
+}
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/scimfilter"
+)
+
+// scimPath is the target of a SCIM PATCH operation, e.g. emails[type eq "work"].value
+type scimPath struct {
+	attr   string
+	filter scimfilter.Expr // selects elements of a multi-valued attribute
+	sub    string
+}
+
+// parseSCIMPath parses the path of a PATCH operation, stripping the schema URN
+func parseSCIMPath(path string) (scimPath, error) {
+	invalid := domain.NewSCIMError(http.StatusBadRequest, "invalidPath", fmt.Sprintf("invalid path %q", path))
+
+	head, rest := path, ""
+	if i := strings.Index(path, "["); i >= 0 {
+		head, rest = path[:i], path[i:]
+	}
+	if strings.HasPrefix(strings.ToLower(head), "urn:") {
+		head = head[strings.LastIndex(head, ":")+1:]
+	}
+
+	var p scimPath
+	if rest == "" {
+		p.attr, p.sub, _ = strings.Cut(head, ".")
+		if p.attr == "" {
+			return scimPath{}, invalid
+		}
+		return p, nil
+	}
+
+	end := strings.LastIndex(rest, "]")
+	if head == "" || end < 0 {
+		return scimPath{}, invalid
+	}
+
+	filter, err := scimfilter.Parse(rest[1:end])
+	if err != nil {
+		return scimPath{}, invalid
+	}
+
+	p.attr, p.filter = head, filter
+	if sub := rest[end+1:]; sub != "" {
+		if !strings.HasPrefix(sub, ".") || len(sub) == 1 {
+			return scimPath{}, invalid
+		}
+		p.sub = sub[1:]
+	}
+
+	return p, nil
+}
+
+// applySCIMPatch applies a PATCH operation to the JSON form of a resource. Attribute names are matched
+// case-insensitively; adding or replacing a filtered element that does not exist creates it from the filter.
+func applySCIMPatch(resource map[string]interface{}, op domain.SCIMPatchOperation) error {
+	var value interface{}
+	if len(op.Value) > 0 {
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return domain.NewSCIMError(http.StatusBadRequest, "invalidSyntax", "invalid operation value")
+		}
+	}
+
+	switch strings.ToLower(op.Op) {
+	case "add", "replace":
+		add := strings.EqualFold(op.Op, "add")
+
+		if op.Path == "" {
+			// Without a path the value holds the attributes to set
+			attributes, ok := value.(map[string]interface{})
+			if !ok {
+				return domain.NewSCIMError(http.StatusBadRequest, "invalidValue", "operation without a path takes an object")
+			}
+			for name, attrValue := range attributes {
+				path, err := parseSCIMPath(name)
+				if err != nil {
+					return err
+				}
+				setSCIMValue(resource, path, attrValue, add)
+			}
+			return nil
+		}
+
+		path, err := parseSCIMPath(op.Path)
+		if err != nil {
+			return err
+		}
+		setSCIMValue(resource, path, value, add)
+		return nil
+
+	case "remove":
+		if op.Path == "" {
+			return domain.NewSCIMError(http.StatusBadRequest, "noTarget", "remove operation requires a path")
+		}
+
+		path, err := parseSCIMPath(op.Path)
+		if err != nil {
+			return err
+		}
+		removeSCIMValue(resource, path, value)
+		return nil
+	}
+
+	return domain.NewSCIMError(http.StatusBadRequest, "invalidSyntax", fmt.Sprintf("unsupported operation %q", op.Op))
+}
+
+func setSCIMValue(resource map[string]interface{}, path scimPath, value interface{}, add bool) {
+	key := scimKey(resource, path.attr)
+
+	if path.filter == nil {
+		if path.sub != "" {
+			object, ok := resource[key].(map[string]interface{})
+			if !ok {
+				object = map[string]interface{}{}
+				resource[key] = object
+			}
+			object[scimKey(object, path.sub)] = value
+			return
+		}
+
+		// Adding to a multi-valued attribute appends to it
+		if elements, ok := resource[key].([]interface{}); ok && add {
+			if values, ok := value.([]interface{}); ok {
+				resource[key] = append(elements, values...)
+				return
+			}
+		}
+
+		resource[key] = value
+		return
+	}
+
+	elements, _ := resource[key].([]interface{})
+	matched := false
+	for _, element := range elements {
+		object, ok := element.(map[string]interface{})
+		if !ok || !scimMatches(object, path.filter) {
+			continue
+		}
+		matched = true
+		setSCIMElement(object, path.sub, value)
+	}
+
+	if !matched {
+		object := scimFilterValues(path.filter)
+		setSCIMElement(object, path.sub, value)
+		elements = append(elements, object)
+	}
+
+	resource[key] = elements
+}
+
+// setSCIMElement sets a sub-attribute of an element of a multi-valued attribute, or merges the value into it
+func setSCIMElement(object map[string]interface{}, sub string, value interface{}) {
+	if sub != "" {
+		object[scimKey(object, sub)] = value
+		return
+	}
+
+	if values, ok := value.(map[string]interface{}); ok {
+		for name, v := range values {
+			object[scimKey(object, name)] = v
+		}
+	}
+}
+
+func removeSCIMValue(resource map[string]interface{}, path scimPath, value interface{}) {
+	key := scimKey(resource, path.attr)
+
+	if path.filter == nil {
+		if path.sub != "" {
+			if object, ok := resource[key].(map[string]interface{}); ok {
+				delete(object, scimKey(object, path.sub))
+			}
+			return
+		}
+
+		// Azure AD removes members by listing them in the value rather than in a filter
+		if elements, ok := resource[key].([]interface{}); ok {
+			if values, ok := value.([]interface{}); ok && len(values) > 0 {
+				resource[key] = scimWithout(elements, values)
+				return
+			}
+		}
+
+		delete(resource, key)
+		return
+	}
+
+	elements, _ := resource[key].([]interface{})
+	kept := make([]interface{}, 0, len(elements))
+	for _, element := range elements {
+		object, ok := element.(map[string]interface{})
+		if !ok || !scimMatches(object, path.filter) {
+			kept = append(kept, element)
+			continue
+		}
+		if path.sub != "" {
+			delete(object, scimKey(object, path.sub))
+			kept = append(kept, object)
+		}
+	}
+
+	resource[key] = kept
+}
+
+// scimWithout removes the elements whose value sub-attribute is listed in values
+func scimWithout(elements, values []interface{}) []interface{} {
+	removed := make(map[string]bool, len(values))
+	for _, value := range values {
+		if object, ok := value.(map[string]interface{}); ok {
+			removed[fmt.Sprint(object[scimKey(object, "value")])] = true
+		}
+	}
+
+	kept := make([]interface{}, 0, len(elements))
+	for _, element := range elements {
+		object, ok := element.(map[string]interface{})
+		if ok && removed[fmt.Sprint(object[scimKey(object, "value")])] {
+			continue
+		}
+		kept = append(kept, element)
+	}
+
+	return kept
+}
+
+// scimKey returns the key of the object matching the attribute name case-insensitively, or the name if there is none
+func scimKey(object map[string]interface{}, name string) string {
+	if _, ok := object[name]; ok {
+		return name
+	}
+	for key := range object {
+		if strings.EqualFold(key, name) {
+			return key
+		}
+	}
+	return name
+}
+
+// scimMatches reports whether an element of a multi-valued attribute matches a value filter.
+// Only the eq, ne and pr operators are supported; values are compared case-insensitively.
+func scimMatches(object map[string]interface{}, expr scimfilter.Expr) bool {
+	switch expr := expr.(type) {
+	case *scimfilter.Logical:
+		if expr.Op == "and" {
+			return scimMatches(object, expr.Left) && scimMatches(object, expr.Right)
+		}
+		return scimMatches(object, expr.Left) || scimMatches(object, expr.Right)
+
+	case *scimfilter.Not:
+		return !scimMatches(object, expr.Expr)
+
+	case *scimfilter.Comparison:
+		actual, present := object[scimKey(object, expr.Attr)]
+		switch expr.Op {
+		case "pr":
+			return present && actual != nil && actual != ""
+		case "eq":
+			return present && strings.EqualFold(fmt.Sprint(actual), fmt.Sprint(expr.Value))
+		case "ne":
+			return !present || !strings.EqualFold(fmt.Sprint(actual), fmt.Sprint(expr.Value))
+		}
+	}
+
+	return false
+}
+
+// scimFilterValues collects the attributes a value filter requires to be equal to a value,
+// e.g. {"type": "work"} for type eq "work"
+func scimFilterValues(expr scimfilter.Expr) map[string]interface{} {
+	values := map[string]interface{}{}
+
+	switch expr := expr.(type) {
+	case *scimfilter.Logical:
+		if expr.Op == "and" {
+			for name, value := range scimFilterValues(expr.Left) {
+				values[name] = value
+			}
+			for name, value := range scimFilterValues(expr.Right) {
+				values[name] = value
+			}
+		}
+
+	case *scimfilter.Comparison:
+		if expr.Op == "eq" {
+			values[expr.Attr] = expr.Value
+		}
+	}
+
+	return values
+}
+
+// patchSCIMResource applies the operations of a PATCH request to a resource, decoding the result into patched.
+// Booleans sent as strings, as Azure AD does for active, are accepted.
+func patchSCIMResource(resource interface{}, req domain.SCIMPatchRequest, patched interface{}) error {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return err
+	}
+
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+
+	for _, op := range req.Operations {
+		if err := applySCIMPatch(object, op); err != nil {
+			return err
+		}
+	}
+
+	if text, ok := object[scimKey(object, "active")].(string); ok {
+		active, err := strconv.ParseBool(text)
+		if err != nil {
+			return domain.NewSCIMError(http.StatusBadRequest, "invalidValue", "active must be a boolean")
+		}
+		object[scimKey(object, "active")] = active
+	}
+
+	data, err = json.Marshal(object)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, patched); err != nil {
+		return domain.NewSCIMError(http.StatusBadRequest, "invalidValue", "patched resource is invalid")
+	}
+
+	return nil
+}
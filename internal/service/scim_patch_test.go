@@ -0,0 +1,284 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"authmicro/internal/domain"
+)
+
+func TestParseSCIMPath(t *testing.T) {
+	tests := []struct {
+		path      string
+		wantAttr  string
+		wantSub   string
+		wantValue bool // whether the path has a value filter
+		wantErr   bool
+	}{
+		{path: "active", wantAttr: "active"},
+		{path: "name.givenName", wantAttr: "name", wantSub: "givenName"},
+		{path: "urn:ietf:params:scim:schemas:core:2.0:User:name.familyName", wantAttr: "name", wantSub: "familyName"},
+		{path: `members[value eq "7"]`, wantAttr: "members", wantValue: true},
+		{path: `emails[type eq "work"].value`, wantAttr: "emails", wantSub: "value", wantValue: true},
+		{path: "", wantErr: true},
+		{path: `[type eq "work"]`, wantErr: true},
+		{path: `emails[type eq "work"`, wantErr: true},
+		{path: `emails[type eq]`, wantErr: true},
+		{path: `emails[type eq "work"]value`, wantErr: true},
+		{path: `emails[type eq "work"].`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			p, err := parseSCIMPath(tt.path)
+			if tt.wantErr {
+				var scimErr *domain.SCIMError
+				if !errors.As(err, &scimErr) || scimErr.SCIMType != "invalidPath" {
+					t.Fatalf("parseSCIMPath(%q) error = %v, want invalidPath", tt.path, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSCIMPath(%q) error = %v", tt.path, err)
+			}
+			if p.attr != tt.wantAttr || p.sub != tt.wantSub || (p.filter != nil) != tt.wantValue {
+				t.Errorf("parseSCIMPath(%q) = %q, %q, filter %v", tt.path, p.attr, p.sub, p.filter != nil)
+			}
+		})
+	}
+}
+
+func TestApplySCIMPatch(t *testing.T) {
+	group := `{"displayName": "editors", "members": [{"value": "1"}, {"value": "2"}, {"value": "3"}]}`
+	user := `{
+		"userName": "alice@example.com",
+		"active": true,
+		"name": {"givenName": "Alice", "familyName": "Smith"},
+		"emails": [{"type": "work", "value": "alice@example.com", "primary": true}, {"type": "home", "value": "a@home.test"}]
+	}`
+
+	tests := []struct {
+		name     string
+		resource string
+		op       string
+		path     string
+		value    string
+		want     string
+	}{
+		// Members
+		{
+			name:     "add members appends them",
+			resource: group,
+			op:       "add", path: "members", value: `[{"value": "4"}, {"value": "5"}]`,
+			want: `{"displayName": "editors", "members": [{"value": "1"}, {"value": "2"}, {"value": "3"}, {"value": "4"}, {"value": "5"}]}`,
+		},
+		{
+			name:     "add members to a group without members",
+			resource: `{"displayName": "editors"}`,
+			op:       "Add", path: "members", value: `[{"value": "4"}]`,
+			want: `{"displayName": "editors", "members": [{"value": "4"}]}`,
+		},
+		{
+			name:     "replace members",
+			resource: group,
+			op:       "replace", path: "members", value: `[{"value": "9"}]`,
+			want: `{"displayName": "editors", "members": [{"value": "9"}]}`,
+		},
+		{
+			name:     "remove a member by filter",
+			resource: group,
+			op:       "remove", path: `members[value eq "2"]`,
+			want: `{"displayName": "editors", "members": [{"value": "1"}, {"value": "3"}]}`,
+		},
+		{
+			name:     "remove members by filter with or",
+			resource: group,
+			op:       "remove", path: `members[value eq "1" or value eq "3"]`,
+			want: `{"displayName": "editors", "members": [{"value": "2"}]}`,
+		},
+		{
+			name:     "remove members listed in the value",
+			resource: group,
+			op:       "remove", path: "members", value: `[{"value": "1"}, {"value": "3"}, {"value": "8"}]`,
+			want: `{"displayName": "editors", "members": [{"value": "2"}]}`,
+		},
+		{
+			name:     "remove all members",
+			resource: group,
+			op:       "REMOVE", path: "members",
+			want: `{"displayName": "editors"}`,
+		},
+		{
+			name:     "remove a member that is not in the group",
+			resource: group,
+			op:       "remove", path: `members[value eq "8"]`,
+			want: group,
+		},
+
+		// Simple and complex attributes
+		{
+			name:     "replace a simple attribute",
+			resource: user,
+			op:       "replace", path: "active", value: `false`,
+			want: `{"userName": "alice@example.com", "active": false, "name": {"givenName": "Alice", "familyName": "Smith"},
+				"emails": [{"type": "work", "value": "alice@example.com", "primary": true}, {"type": "home", "value": "a@home.test"}]}`,
+		},
+		{
+			name:     "replace a sub-attribute case-insensitively",
+			resource: user,
+			op:       "replace", path: "name.GIVENNAME", value: `"Alicia"`,
+			want: `{"userName": "alice@example.com", "active": true, "name": {"givenName": "Alicia", "familyName": "Smith"},
+				"emails": [{"type": "work", "value": "alice@example.com", "primary": true}, {"type": "home", "value": "a@home.test"}]}`,
+		},
+		{
+			name:     "remove a sub-attribute",
+			resource: user,
+			op:       "remove", path: "name.familyName",
+			want: `{"userName": "alice@example.com", "active": true, "name": {"givenName": "Alice"},
+				"emails": [{"type": "work", "value": "alice@example.com", "primary": true}, {"type": "home", "value": "a@home.test"}]}`,
+		},
+		{
+			name:     "replace without a path",
+			resource: user,
+			op:       "replace", value: `{"Active": false, "name.familyName": "Jones", "urn:ietf:params:scim:schemas:core:2.0:User:nickName": "al"}`,
+			want: `{"userName": "alice@example.com", "active": false, "nickName": "al", "name": {"givenName": "Alice", "familyName": "Jones"},
+				"emails": [{"type": "work", "value": "alice@example.com", "primary": true}, {"type": "home", "value": "a@home.test"}]}`,
+		},
+
+		// Value paths
+		{
+			name:     "replace a sub-attribute of the matching element",
+			resource: user,
+			op:       "replace", path: `emails[type eq "WORK"].value`, value: `"alice@corp.test"`,
+			want: `{"userName": "alice@example.com", "active": true, "name": {"givenName": "Alice", "familyName": "Smith"},
+				"emails": [{"type": "work", "value": "alice@corp.test", "primary": true}, {"type": "home", "value": "a@home.test"}]}`,
+		},
+		{
+			name:     "add a value to an element that does not exist creates it from the filter",
+			resource: user,
+			op:       "add", path: `emails[type eq "other" and primary eq false].value`, value: `"al@other.test"`,
+			want: `{"userName": "alice@example.com", "active": true, "name": {"givenName": "Alice", "familyName": "Smith"},
+				"emails": [{"type": "work", "value": "alice@example.com", "primary": true}, {"type": "home", "value": "a@home.test"},
+					{"type": "other", "primary": false, "value": "al@other.test"}]}`,
+		},
+		{
+			name:     "replace merges an object into the matching element",
+			resource: user,
+			op:       "replace", path: `emails[type eq "home"]`, value: `{"value": "alice@home.test", "display": "Home"}`,
+			want: `{"userName": "alice@example.com", "active": true, "name": {"givenName": "Alice", "familyName": "Smith"},
+				"emails": [{"type": "work", "value": "alice@example.com", "primary": true}, {"type": "home", "value": "alice@home.test", "display": "Home"}]}`,
+		},
+		{
+			name:     "remove a sub-attribute of the matching elements",
+			resource: user,
+			op:       "remove", path: `emails[primary pr].primary`,
+			want: `{"userName": "alice@example.com", "active": true, "name": {"givenName": "Alice", "familyName": "Smith"},
+				"emails": [{"type": "work", "value": "alice@example.com"}, {"type": "home", "value": "a@home.test"}]}`,
+		},
+		{
+			name:     "remove the elements not matching a negated filter",
+			resource: user,
+			op:       "remove", path: `emails[not (type eq "work")]`,
+			want: `{"userName": "alice@example.com", "active": true, "name": {"givenName": "Alice", "familyName": "Smith"},
+				"emails": [{"type": "work", "value": "alice@example.com", "primary": true}]}`,
+		},
+		{
+			name:     "remove the elements matching ne",
+			resource: user,
+			op:       "remove", path: `emails[type ne "home"]`,
+			want: `{"userName": "alice@example.com", "active": true, "name": {"givenName": "Alice", "familyName": "Smith"},
+				"emails": [{"type": "home", "value": "a@home.test"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resource map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.resource), &resource); err != nil {
+				t.Fatalf("invalid resource: %v", err)
+			}
+
+			op := domain.SCIMPatchOperation{Op: tt.op, Path: tt.path}
+			if tt.value != "" {
+				op.Value = json.RawMessage(tt.value)
+			}
+
+			if err := applySCIMPatch(resource, op); err != nil {
+				t.Fatalf("applySCIMPatch() error = %v", err)
+			}
+
+			assertSameJSON(t, resource, tt.want)
+		})
+	}
+}
+
+func TestApplySCIMPatchErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		op       domain.SCIMPatchOperation
+		wantType string
+	}{
+		{"unsupported operation", domain.SCIMPatchOperation{Op: "move", Path: "active"}, "invalidSyntax"},
+		{"invalid value", domain.SCIMPatchOperation{Op: "add", Path: "active", Value: json.RawMessage(`{`)}, "invalidSyntax"},
+		{"remove without a path", domain.SCIMPatchOperation{Op: "remove"}, "noTarget"},
+		{"add without a path takes an object", domain.SCIMPatchOperation{Op: "add", Value: json.RawMessage(`true`)}, "invalidValue"},
+		{"invalid path", domain.SCIMPatchOperation{Op: "replace", Path: `emails[type eq`, Value: json.RawMessage(`1`)}, "invalidPath"},
+		{"invalid path in a value", domain.SCIMPatchOperation{Op: "replace", Value: json.RawMessage(`{"[x]": 1}`)}, "invalidPath"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := applySCIMPatch(map[string]interface{}{}, tt.op)
+
+			var scimErr *domain.SCIMError
+			if !errors.As(err, &scimErr) || scimErr.SCIMType != tt.wantType {
+				t.Fatalf("applySCIMPatch() error = %v, want %s", err, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestPatchSCIMResource(t *testing.T) {
+	type resource struct {
+		UserName string `json:"userName"`
+		Active   bool   `json:"active"`
+	}
+
+	req := domain.SCIMPatchRequest{Operations: []domain.SCIMPatchOperation{
+		{Op: "Replace", Path: "userName", Value: json.RawMessage(`"bob@example.com"`)},
+		{Op: "Replace", Path: "active", Value: json.RawMessage(`"False"`)},
+	}}
+
+	var patched resource
+	if err := patchSCIMResource(resource{UserName: "alice@example.com", Active: true}, req, &patched); err != nil {
+		t.Fatalf("patchSCIMResource() error = %v", err)
+	}
+	if want := (resource{UserName: "bob@example.com"}); patched != want {
+		t.Errorf("patchSCIMResource() = %+v, want %+v", patched, want)
+	}
+
+	req.Operations = []domain.SCIMPatchOperation{{Op: "replace", Path: "active", Value: json.RawMessage(`"maybe"`)}}
+	err := patchSCIMResource(resource{}, req, &patched)
+
+	var scimErr *domain.SCIMError
+	if !errors.As(err, &scimErr) || scimErr.SCIMType != "invalidValue" {
+		t.Errorf("patchSCIMResource() error = %v, want invalidValue", err)
+	}
+}
+
+// assertSameJSON compares a value with the JSON document regardless of formatting and key order
+func assertSameJSON(t *testing.T, got interface{}, want string) {
+	t.Helper()
+
+	var wantValue interface{}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("invalid expected JSON: %v", err)
+	}
+
+	gotJSON, _ := json.Marshal(got)
+	wantJSON, _ := json.Marshal(wantValue)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("got %s\nwant %s", gotJSON, wantJSON)
+	}
+}
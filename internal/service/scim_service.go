@@ -0,0 +1,1003 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"authmicro/configs"
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+	"authmicro/pkg/scimfilter"
+)
+
+type scimClientRepository interface {
+	Create(ctx context.Context, client domain.SCIMClient) (domain.SCIMClient, error)
+	List(ctx context.Context) ([]domain.SCIMClient, error)
+	GetByTokenHash(ctx context.Context, tokenHash string) (domain.SCIMClient, error)
+	Touch(ctx context.Context, id int64, at time.Time) error
+	Delete(ctx context.Context, id int64) error
+}
+
+type scimUserRepository interface {
+	GetByID(ctx context.Context, id int64) (domain.User, error)
+	NicknameExists(ctx context.Context, nickname string) (bool, error)
+	UpdateProfile(ctx context.Context, user domain.User) error
+	SearchUsers(ctx context.Context, filter scimfilter.Expr, offset, limit int) ([]domain.User, int, error)
+	SetExternalID(ctx context.Context, userID int64, externalID *string) error
+	SetAccountStatus(ctx context.Context, userID int64, status string, suspendedUntil *time.Time, reason *string, changedBy int64) error
+	IncrementAuthzVersion(ctx context.Context, userIDs ...int64) error
+	DeleteUser(ctx context.Context, userID int64) error
+	AnonymizeUser(ctx context.Context, userID int64) error
+}
+
+type scimRoleRepository interface {
+	GetRoleByID(ctx context.Context, id int64) (domain.Role, error)
+	GetRoleByName(ctx context.Context, name string) (domain.Role, error)
+	SearchRoles(ctx context.Context, filter scimfilter.Expr, names []string, offset, limit int) ([]domain.Role, int, error)
+	RoleImplies(ctx context.Context, roleID int64, name string) (bool, error)
+	GetUsersByRole(ctx context.Context, roleID int64) ([]domain.User, error)
+	GetRolesByUsers(ctx context.Context, userIDs []int64) (map[int64][]domain.Role, error)
+}
+
+type scimUserRegistrar interface {
+	RegisterImportedUser(ctx context.Context, req domain.RegistrationRequest, dryRun bool) (int64, []domain.FieldError, error)
+	SetVerifiedEmail(ctx context.Context, userID int64, email string) ([]domain.FieldError, error)
+}
+
+type scimRoleManager interface {
+	CreateRole(ctx context.Context, actorID int64, req domain.CreateRoleRequest) (domain.Role, []domain.FieldError, error)
+	RenameRole(ctx context.Context, actorID, roleID int64, req domain.RenameRoleRequest) (domain.Role, []domain.FieldError, error)
+	DeleteRole(ctx context.Context, actorID, roleID int64) error
+	AssignRole(ctx context.Context, actorID, userID, roleID int64, req domain.AssignRoleRequest) error
+	UnassignRole(ctx context.Context, actorID, userID, roleID int64) error
+}
+
+const (
+	scimDefaultCount = 100
+	scimMaxCount     = 200
+	// scimTouchInterval limits how often the last use of a provisioning client is recorded
+	scimTouchInterval = time.Minute
+)
+
+// SCIMService implements SCIM 2.0 provisioning of users and of roles as groups. Provisioning clients act as the
+// system: their changes are not attributed to a user and send no emails. Only the roles listed in the configuration
+// are groups; default roles and roles implying the admin role never are.
+type SCIMService struct {
+	clientRepo  scimClientRepository
+	userRepo    scimUserRepository
+	roleRepo    scimRoleRepository
+	sessionRepo accountSessionRepository
+	registrar   scimUserRegistrar
+	roles       scimRoleManager
+	uow         unitOfWork
+	nicknames   *nicknameChanger
+	config      configs.SCIMConfig
+	anonymize   bool
+	logger      logger.Logger
+}
+
+func NewSCIMService(
+	clientRepo scimClientRepository,
+	userRepo scimUserRepository,
+	roleRepo scimRoleRepository,
+	sessionRepo accountSessionRepository,
+	nicknameRepo nicknameHistoryRepository,
+	registrar scimUserRegistrar,
+	roles scimRoleManager,
+	uow unitOfWork,
+	config configs.SCIMConfig,
+	nicknameConfig configs.NicknameConfig,
+	deletionConfig configs.AccountDeletionConfig,
+	logger logger.Logger,
+) *SCIMService {
+	return &SCIMService{
+		clientRepo:  clientRepo,
+		userRepo:    userRepo,
+		roleRepo:    roleRepo,
+		sessionRepo: sessionRepo,
+		registrar:   registrar,
+		roles:       roles,
+		uow:         uow,
+		nicknames: &nicknameChanger{
+			userRepo:     userRepo,
+			nicknameRepo: nicknameRepo,
+			uow:          uow,
+			config:       nicknameConfig,
+			logger:       logger,
+		},
+		config:    config,
+		anonymize: deletionConfig.Anonymize,
+		logger:    logger,
+	}
+}
+
+// CreateClient registers a provisioning client on behalf of an admin. The bearer token is only returned here;
+// just its hash is stored.
+func (s *SCIMService) CreateClient(ctx context.Context, actorID int64, req domain.CreateSCIMClientRequest) (domain.CreateSCIMClientResponse, []domain.FieldError, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return domain.CreateSCIMClientResponse{}, []domain.FieldError{{
+			Field:   "name",
+			Message: "Поле пустое",
+		}}, nil
+	}
+	if len(name) > 255 {
+		return domain.CreateSCIMClientResponse{}, []domain.FieldError{{
+			Field:   "name",
+			Message: "Название не может быть длиннее 255 символов",
+		}}, nil
+	}
+
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return domain.CreateSCIMClientResponse{}, nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(data)
+
+	var createdBy *int64
+	if actorID != 0 {
+		createdBy = &actorID
+	}
+
+	client, err := s.clientRepo.Create(ctx, domain.SCIMClient{
+		Name:      name,
+		TokenHash: hashSCIMToken(token),
+		CreatedBy: createdBy,
+	})
+	if err != nil {
+		s.logger.Errorf("Error creating SCIM client: %v", err)
+		return domain.CreateSCIMClientResponse{}, nil, err
+	}
+
+	s.logger.Infof("SCIM client %d (%s) created by %d", client.ID, client.Name, actorID)
+
+	return domain.CreateSCIMClientResponse{
+		SCIMClient: client,
+		Token:      token,
+	}, nil, nil
+}
+
+// ListClients retrieves all provisioning clients
+func (s *SCIMService) ListClients(ctx context.Context) ([]domain.SCIMClient, error) {
+	return s.clientRepo.List(ctx)
+}
+
+// DeleteClient deletes a provisioning client, revoking its token. The users and groups it provisioned are kept.
+func (s *SCIMService) DeleteClient(ctx context.Context, clientID int64) error {
+	if err := s.clientRepo.Delete(ctx, clientID); err != nil {
+		return err
+	}
+
+	s.logger.Infof("SCIM client %d deleted", clientID)
+	return nil
+}
+
+// AuthenticateClient finds the provisioning client a bearer token belongs to, failing with
+// domain.ErrSCIMClientNotFound for an unknown token
+func (s *SCIMService) AuthenticateClient(ctx context.Context, token string) (domain.SCIMClient, error) {
+	client, err := s.clientRepo.GetByTokenHash(ctx, hashSCIMToken(token))
+	if err != nil {
+		return domain.SCIMClient{}, err
+	}
+
+	now := time.Now().UTC()
+	if client.LastUsedAt == nil || now.Sub(*client.LastUsedAt) >= scimTouchInterval {
+		if err := s.clientRepo.Touch(ctx, client.ID, now); err != nil {
+			s.logger.Errorf("Error recording SCIM client use: %v", err)
+			// Just log the error and continue
+		}
+	}
+
+	return client, nil
+}
+
+// hashSCIMToken hashes a bearer token of a provisioning client for storage
+func hashSCIMToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ListUsers retrieves a page of the users matching the filter of the request
+func (s *SCIMService) ListUsers(ctx context.Context, req domain.SCIMListRequest) (domain.SCIMListResponse, error) {
+	filter, offset, limit, err := scimQuery(req)
+	if err != nil {
+		return domain.SCIMListResponse{}, err
+	}
+
+	users, total, err := s.userRepo.SearchUsers(ctx, filter, offset, limit)
+	if err != nil {
+		return domain.SCIMListResponse{}, scimFilterError(err)
+	}
+
+	userIDs := make([]int64, 0, len(users))
+	for _, user := range users {
+		userIDs = append(userIDs, user.ID)
+	}
+	roles, err := s.roleRepo.GetRolesByUsers(ctx, userIDs)
+	if err != nil {
+		return domain.SCIMListResponse{}, err
+	}
+
+	resources := make([]domain.SCIMUser, 0, len(users))
+	for _, user := range users {
+		resources = append(resources, s.toSCIMUser(user, roles[user.ID]))
+	}
+
+	return scimListResponse(resources, total, offset, len(resources)), nil
+}
+
+// GetUser retrieves a user
+func (s *SCIMService) GetUser(ctx context.Context, id string) (domain.SCIMUser, error) {
+	user, err := s.findUser(ctx, id)
+	if err != nil {
+		return domain.SCIMUser{}, err
+	}
+
+	return s.scimUser(ctx, user)
+}
+
+// CreateUser provisions a user. The user is validated like an imported one: the email counts as verified and the
+// privacy policy is accepted at the first login. Without a nickName one is derived from the email.
+func (s *SCIMService) CreateUser(ctx context.Context, client domain.SCIMClient, in domain.SCIMUser) (domain.SCIMUser, error) {
+	email := scimEmail(in, "")
+	if email == "" {
+		return domain.SCIMUser{}, domain.NewSCIMError(http.StatusBadRequest, "invalidValue", "userName is required")
+	}
+
+	var user domain.User
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		nickname := in.NickName
+		if nickname == "" {
+			var err error
+			if nickname, err = s.generateNickname(ctx, email); err != nil {
+				return err
+			}
+		}
+
+		req := domain.RegistrationRequest{
+			Nickname: nickname,
+			Email:    email,
+		}
+		if in.Name != nil {
+			req.FirstName = strings.TrimSpace(in.Name.GivenName)
+			req.LastName = strings.TrimSpace(in.Name.FamilyName)
+		}
+
+		userID, fieldErrors, err := s.registrar.RegisterImportedUser(ctx, req, false)
+		if err != nil {
+			return err
+		}
+		if len(fieldErrors) > 0 {
+			return scimValidationError(fieldErrors)
+		}
+
+		if in.ExternalID != "" {
+			if err := s.userRepo.SetExternalID(ctx, userID, &in.ExternalID); err != nil {
+				return err
+			}
+		}
+
+		if user, err = s.userRepo.GetByID(ctx, userID); err != nil {
+			return err
+		}
+
+		if in.Active != nil && !*in.Active {
+			return s.setActive(ctx, client, user, false)
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.SCIMUser{}, s.scimError("Error provisioning user", err)
+	}
+
+	s.logger.Infof("User %d provisioned by SCIM client %d", user.ID, client.ID)
+
+	return s.GetUser(ctx, strconv.FormatInt(user.ID, 10))
+}
+
+// ReplaceUser updates a user with the attributes of the request. Omitted optional attributes (name, nickName,
+// active) are left unchanged.
+func (s *SCIMService) ReplaceUser(ctx context.Context, client domain.SCIMClient, id string, in domain.SCIMUser) (domain.SCIMUser, error) {
+	user, err := s.findUser(ctx, id)
+	if err != nil {
+		return domain.SCIMUser{}, err
+	}
+
+	return s.updateUser(ctx, client, user, in)
+}
+
+// PatchUser applies the operations of a PATCH request to a user
+func (s *SCIMService) PatchUser(ctx context.Context, client domain.SCIMClient, id string, req domain.SCIMPatchRequest) (domain.SCIMUser, error) {
+	user, err := s.findUser(ctx, id)
+	if err != nil {
+		return domain.SCIMUser{}, err
+	}
+
+	current, err := s.scimUser(ctx, user)
+	if err != nil {
+		return domain.SCIMUser{}, err
+	}
+
+	var patched domain.SCIMUser
+	if err := patchSCIMResource(current, req, &patched); err != nil {
+		return domain.SCIMUser{}, err
+	}
+
+	return s.updateUser(ctx, client, user, patched)
+}
+
+// DeleteUser deletes a user, or anonymizes it when deleted accounts are anonymized
+func (s *SCIMService) DeleteUser(ctx context.Context, client domain.SCIMClient, id string) error {
+	user, err := s.findUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if s.anonymize {
+			return s.userRepo.AnonymizeUser(ctx, user.ID)
+		}
+		return s.userRepo.DeleteUser(ctx, user.ID)
+	})
+	if err != nil {
+		return s.scimError("Error deleting provisioned user", err)
+	}
+
+	s.logger.Infof("User %d deleted by SCIM client %d", user.ID, client.ID)
+	return nil
+}
+
+// updateUser changes the email, profile, external ID and status of a user to match a SCIM user
+func (s *SCIMService) updateUser(ctx context.Context, client domain.SCIMClient, user domain.User, in domain.SCIMUser) (domain.SCIMUser, error) {
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		if email := scimEmail(in, user.Email); email != "" && !strings.EqualFold(email, user.Email) {
+			fieldErrors, err := s.registrar.SetVerifiedEmail(ctx, user.ID, email)
+			if err != nil {
+				return err
+			}
+			if len(fieldErrors) > 0 {
+				return scimValidationError(fieldErrors)
+			}
+		}
+
+		updated := user
+		if in.Name != nil {
+			updated.FirstName = strings.TrimSpace(in.Name.GivenName)
+			updated.LastName = strings.TrimSpace(in.Name.FamilyName)
+		}
+		if in.NickName != "" {
+			updated.Nickname = in.NickName
+		}
+
+		var fieldErrors []domain.FieldError
+		fieldErrors = append(fieldErrors, validateName("firstName", updated.FirstName)...)
+		fieldErrors = append(fieldErrors, validateName("lastName", updated.LastName)...)
+		if updated.Nickname != user.Nickname {
+			nicknameErrors, err := s.nicknames.validate(ctx, user.ID, updated.Nickname, false)
+			if err != nil {
+				return err
+			}
+			fieldErrors = append(fieldErrors, nicknameErrors...)
+		}
+		if len(fieldErrors) > 0 {
+			return scimValidationError(fieldErrors)
+		}
+
+		switch {
+		case updated.Nickname != user.Nickname:
+			if err := s.nicknames.save(ctx, updated, user.Nickname, nil); err != nil {
+				if errors.Is(err, domain.ErrNicknameExists) {
+					return scimValidationError([]domain.FieldError{nicknameTakenError})
+				}
+				return err
+			}
+			// Access tokens carry the nickname
+			if err := s.userRepo.IncrementAuthzVersion(ctx, user.ID); err != nil {
+				return err
+			}
+		case updated.FirstName != user.FirstName || updated.LastName != user.LastName:
+			if err := s.userRepo.UpdateProfile(ctx, updated); err != nil {
+				return err
+			}
+		}
+
+		if in.ExternalID != scimExternalID(user) {
+			var externalID *string
+			if in.ExternalID != "" {
+				externalID = &in.ExternalID
+			}
+			if err := s.userRepo.SetExternalID(ctx, user.ID, externalID); err != nil {
+				return err
+			}
+		}
+
+		if in.Active != nil {
+			return s.setActive(ctx, client, user, *in.Active)
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.SCIMUser{}, s.scimError("Error updating provisioned user", err)
+	}
+
+	s.logger.Infof("User %d updated by SCIM client %d", user.ID, client.ID)
+
+	return s.GetUser(ctx, strconv.FormatInt(user.ID, 10))
+}
+
+// setActive reinstates a banned user or bans an active one, revoking all of their sessions. Suspended users count as
+// active; their suspension is left to expire.
+func (s *SCIMService) setActive(ctx context.Context, client domain.SCIMClient, user domain.User, active bool) error {
+	banned := user.Status == domain.AccountStatuses.Banned
+	if active != banned {
+		return nil
+	}
+
+	if active {
+		if err := s.userRepo.SetAccountStatus(ctx, user.ID, domain.AccountStatuses.Active, nil, nil, 0); err != nil {
+			return err
+		}
+		s.logger.Infof("User %d reactivated by SCIM client %d", user.ID, client.ID)
+		return nil
+	}
+
+	reason := fmt.Sprintf("Deactivated by SCIM client %s", client.Name)
+	if err := s.userRepo.SetAccountStatus(ctx, user.ID, domain.AccountStatuses.Banned, nil, &reason, 0); err != nil {
+		return err
+	}
+	if err := s.sessionRepo.DeleteUserTokenSessions(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := s.userRepo.IncrementAuthzVersion(ctx, user.ID); err != nil {
+		return err
+	}
+
+	s.logger.Infof("User %d deactivated by SCIM client %d", user.ID, client.ID)
+	return nil
+}
+
+// nicknameForbiddenChars matches the characters not allowed in nicknames
+var nicknameForbiddenChars = regexp.MustCompile(`[^a-zA-Z0-9]`)
+
+// generateNickname derives an available nickname from the local part of an email, appending a number if it is taken
+func (s *SCIMService) generateNickname(ctx context.Context, email string) (string, error) {
+	local, _, _ := strings.Cut(email, "@")
+	base := nicknameForbiddenChars.ReplaceAllString(local, "")
+	if len(base) > 40 {
+		base = base[:40]
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	for i := 1; i <= 1000; i++ {
+		nickname := base
+		if i > 1 {
+			nickname = base + strconv.Itoa(i)
+		}
+
+		exists, err := s.userRepo.NicknameExists(ctx, nickname)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return nickname, nil
+		}
+	}
+
+	return "", domain.NewSCIMError(http.StatusConflict, "uniqueness", "no available nickName, set one explicitly")
+}
+
+// findUser retrieves a user by its SCIM ID, leaving out anonymized accounts
+func (s *SCIMService) findUser(ctx context.Context, id string) (domain.User, error) {
+	users, _, err := s.userRepo.SearchUsers(ctx, &scimfilter.Comparison{Attr: "id", Op: "eq", Value: id}, 0, 1)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if len(users) == 0 {
+		return domain.User{}, domain.NewSCIMError(http.StatusNotFound, "", fmt.Sprintf("user %s not found", id))
+	}
+
+	return users[0], nil
+}
+
+// scimUser converts a user into a SCIM user, with the roles granted to it directly as groups
+func (s *SCIMService) scimUser(ctx context.Context, user domain.User) (domain.SCIMUser, error) {
+	roles, err := s.roleRepo.GetRolesByUsers(ctx, []int64{user.ID})
+	if err != nil {
+		return domain.SCIMUser{}, err
+	}
+
+	return s.toSCIMUser(user, roles[user.ID]), nil
+}
+
+func (s *SCIMService) toSCIMUser(user domain.User, roles []domain.Role) domain.SCIMUser {
+	id := strconv.FormatInt(user.ID, 10)
+	active := user.Status != domain.AccountStatuses.Banned
+
+	groups := make([]domain.SCIMMember, 0, len(roles))
+	for _, role := range roles {
+		if !s.groupNameAllowed(role.Name) {
+			continue
+		}
+		groups = append(groups, domain.SCIMMember{
+			Value:   strconv.FormatInt(role.ID, 10),
+			Display: role.Name,
+		})
+	}
+
+	return domain.SCIMUser{
+		Schemas:    []string{domain.SCIMSchemas.User},
+		ID:         id,
+		ExternalID: scimExternalID(user),
+		UserName:   user.Email,
+		Name: &domain.SCIMName{
+			GivenName:  user.FirstName,
+			FamilyName: user.LastName,
+		},
+		NickName: user.Nickname,
+		Emails: []domain.SCIMEmail{{
+			Value:   user.Email,
+			Type:    "work",
+			Primary: true,
+		}},
+		Active: &active,
+		Groups: groups,
+		Meta: &domain.SCIMMeta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     s.config.BaseURL + "/Users/" + id,
+		},
+	}
+}
+
+func scimExternalID(user domain.User) string {
+	if user.ExternalID == nil {
+		return ""
+	}
+	return *user.ExternalID
+}
+
+// scimEmail picks the email of a SCIM user: the primary email (or the first one) unless it is the current email,
+// otherwise userName. Clients changing only one of them are thus followed either way.
+func scimEmail(user domain.SCIMUser, current string) string {
+	var primary string
+	for _, email := range user.Emails {
+		if email.Primary || primary == "" {
+			primary = strings.TrimSpace(email.Value)
+		}
+		if email.Primary {
+			break
+		}
+	}
+
+	if primary != "" && !strings.EqualFold(primary, current) {
+		return primary
+	}
+	if userName := strings.TrimSpace(user.UserName); userName != "" {
+		return userName
+	}
+	return primary
+}
+
+// ListGroups retrieves a page of the roles matching the filter of the request
+func (s *SCIMService) ListGroups(ctx context.Context, req domain.SCIMListRequest) (domain.SCIMListResponse, error) {
+	filter, offset, limit, err := scimQuery(req)
+	if err != nil {
+		return domain.SCIMListResponse{}, err
+	}
+
+	names, err := s.provisionableNames(ctx)
+	if err != nil {
+		return domain.SCIMListResponse{}, err
+	}
+
+	roles, total, err := s.roleRepo.SearchRoles(ctx, filter, names, offset, limit)
+	if err != nil {
+		return domain.SCIMListResponse{}, scimFilterError(err)
+	}
+
+	resources := make([]domain.SCIMGroup, 0, len(roles))
+	for _, role := range roles {
+		group, err := s.scimGroup(ctx, role, req.ExcludeMembers)
+		if err != nil {
+			return domain.SCIMListResponse{}, err
+		}
+		resources = append(resources, group)
+	}
+
+	return scimListResponse(resources, total, offset, len(resources)), nil
+}
+
+// GetGroup retrieves a role as a group
+func (s *SCIMService) GetGroup(ctx context.Context, id string, excludeMembers bool) (domain.SCIMGroup, error) {
+	role, err := s.findRole(ctx, id)
+	if err != nil {
+		return domain.SCIMGroup{}, err
+	}
+
+	return s.scimGroup(ctx, role, excludeMembers)
+}
+
+// CreateGroup creates a role and grants it to the members of the group
+func (s *SCIMService) CreateGroup(ctx context.Context, client domain.SCIMClient, in domain.SCIMGroup) (domain.SCIMGroup, error) {
+	if !s.groupNameAllowed(in.DisplayName) {
+		return domain.SCIMGroup{}, groupNotProvisionable(in.DisplayName)
+	}
+
+	var role domain.Role
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		created, fieldErrors, err := s.roles.CreateRole(ctx, 0, domain.CreateRoleRequest{Name: in.DisplayName})
+		if err != nil {
+			return err
+		}
+		if len(fieldErrors) > 0 {
+			return scimValidationError(fieldErrors)
+		}
+		role = created
+
+		return s.setMembers(ctx, role, in.Members)
+	})
+	if err != nil {
+		return domain.SCIMGroup{}, s.scimError("Error provisioning group", err)
+	}
+
+	s.logger.Infof("Role %d (%s) provisioned by SCIM client %d", role.ID, role.Name, client.ID)
+
+	return s.GetGroup(ctx, strconv.FormatInt(role.ID, 10), false)
+}
+
+// ReplaceGroup renames a role and, when members are given, grants and revokes it to match them; omitting members
+// leaves them unchanged
+func (s *SCIMService) ReplaceGroup(ctx context.Context, client domain.SCIMClient, id string, in domain.SCIMGroup) (domain.SCIMGroup, error) {
+	role, err := s.findRole(ctx, id)
+	if err != nil {
+		return domain.SCIMGroup{}, err
+	}
+
+	return s.updateGroup(ctx, client, role, in)
+}
+
+// PatchGroup applies the operations of a PATCH request to a role
+func (s *SCIMService) PatchGroup(ctx context.Context, client domain.SCIMClient, id string, req domain.SCIMPatchRequest) (domain.SCIMGroup, error) {
+	role, err := s.findRole(ctx, id)
+	if err != nil {
+		return domain.SCIMGroup{}, err
+	}
+
+	current, err := s.scimGroup(ctx, role, false)
+	if err != nil {
+		return domain.SCIMGroup{}, err
+	}
+
+	var patched domain.SCIMGroup
+	if err := patchSCIMResource(current, req, &patched); err != nil {
+		return domain.SCIMGroup{}, err
+	}
+	// The patched group lists all of its members; none left means removing everyone
+	if patched.Members == nil {
+		patched.Members = []domain.SCIMMember{}
+	}
+
+	return s.updateGroup(ctx, client, role, patched)
+}
+
+// DeleteGroup deletes a role; default roles cannot be deleted
+func (s *SCIMService) DeleteGroup(ctx context.Context, client domain.SCIMClient, id string) error {
+	role, err := s.findRole(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.roles.DeleteRole(ctx, 0, role.ID); err != nil {
+		return s.scimError("Error deleting provisioned group", err)
+	}
+
+	s.logger.Infof("Role %d (%s) deleted by SCIM client %d", role.ID, role.Name, client.ID)
+	return nil
+}
+
+// updateGroup renames a role and, unless the members are nil, grants and revokes it to match them
+func (s *SCIMService) updateGroup(ctx context.Context, client domain.SCIMClient, role domain.Role, in domain.SCIMGroup) (domain.SCIMGroup, error) {
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		if in.DisplayName != role.Name {
+			if !s.groupNameAllowed(in.DisplayName) {
+				return groupNotProvisionable(in.DisplayName)
+			}
+			_, fieldErrors, err := s.roles.RenameRole(ctx, 0, role.ID, domain.RenameRoleRequest{Name: in.DisplayName})
+			if err != nil {
+				return err
+			}
+			if len(fieldErrors) > 0 {
+				return scimValidationError(fieldErrors)
+			}
+		}
+
+		if in.Members == nil {
+			return nil
+		}
+		return s.setMembers(ctx, role, in.Members)
+	})
+	if err != nil {
+		return domain.SCIMGroup{}, s.scimError("Error updating provisioned group", err)
+	}
+
+	s.logger.Infof("Role %d updated by SCIM client %d", role.ID, client.ID)
+
+	return s.GetGroup(ctx, strconv.FormatInt(role.ID, 10), false)
+}
+
+// setMembers grants a role directly to the members and revokes the direct grants of everyone else
+func (s *SCIMService) setMembers(ctx context.Context, role domain.Role, members []domain.SCIMMember) error {
+	wanted := make(map[int64]bool, len(members))
+	for _, member := range members {
+		user, err := s.findUser(ctx, member.Value)
+		var scimErr *domain.SCIMError
+		if errors.As(err, &scimErr) {
+			return domain.NewSCIMError(http.StatusBadRequest, "invalidValue", fmt.Sprintf("member %s not found", member.Value))
+		}
+		if err != nil {
+			return err
+		}
+		wanted[user.ID] = true
+	}
+
+	current, err := s.roleRepo.GetUsersByRole(ctx, role.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range current {
+		if wanted[user.ID] {
+			delete(wanted, user.ID)
+			continue
+		}
+		if err := s.roles.UnassignRole(ctx, 0, user.ID, role.ID); err != nil && !errors.Is(err, domain.ErrRoleNotGranted) {
+			return err
+		}
+	}
+
+	for userID := range wanted {
+		err := s.roles.AssignRole(ctx, 0, userID, role.ID, domain.AssignRoleRequest{})
+		if err != nil && !errors.Is(err, domain.ErrRoleAlreadyGranted) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findRole retrieves a provisionable role by its SCIM ID; other roles are reported as not found
+func (s *SCIMService) findRole(ctx context.Context, id string) (domain.Role, error) {
+	notFound := domain.NewSCIMError(http.StatusNotFound, "", fmt.Sprintf("group %s not found", id))
+
+	roleID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return domain.Role{}, notFound
+	}
+
+	role, err := s.roleRepo.GetRoleByID(ctx, roleID)
+	if err != nil {
+		if errors.Is(err, domain.ErrRoleNotFound) {
+			return domain.Role{}, notFound
+		}
+		return domain.Role{}, err
+	}
+
+	provisionable, err := s.provisionable(ctx, role)
+	if err != nil {
+		return domain.Role{}, err
+	}
+	if !provisionable {
+		return domain.Role{}, notFound
+	}
+
+	return role, nil
+}
+
+// groupNameAllowed reports whether a role of the name may be a group: it must be listed in the configuration and
+// must not be a default role
+func (s *SCIMService) groupNameAllowed(name string) bool {
+	if domain.IsDefaultRole(name) {
+		return false
+	}
+	for _, group := range s.config.Groups {
+		if group == name {
+			return true
+		}
+	}
+	return false
+}
+
+// provisionable reports whether a role may be managed as a group. Besides being allowed by name, it must not imply
+// the admin role, which an admin may have made one of its children.
+func (s *SCIMService) provisionable(ctx context.Context, role domain.Role) (bool, error) {
+	if !s.groupNameAllowed(role.Name) {
+		return false, nil
+	}
+
+	admin, err := s.roleRepo.RoleImplies(ctx, role.ID, domain.DefaultRoles.Admin)
+	if err != nil {
+		return false, err
+	}
+
+	return !admin, nil
+}
+
+// provisionableNames collects the names of the existing roles that may be managed as groups
+func (s *SCIMService) provisionableNames(ctx context.Context) ([]string, error) {
+	names := make([]string, 0, len(s.config.Groups))
+	for _, name := range s.config.Groups {
+		if !s.groupNameAllowed(name) {
+			continue
+		}
+
+		role, err := s.roleRepo.GetRoleByName(ctx, name)
+		if err != nil {
+			if errors.Is(err, domain.ErrRoleNotFound) {
+				continue
+			}
+			return nil, err
+		}
+
+		provisionable, err := s.provisionable(ctx, role)
+		if err != nil {
+			return nil, err
+		}
+		if provisionable {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+// groupNotProvisionable reports a group the provisioning clients may not create or rename a role to
+func groupNotProvisionable(name string) error {
+	return domain.NewSCIMError(http.StatusForbidden, "", fmt.Sprintf("group %q may not be provisioned", name))
+}
+
+// scimGroup converts a role into a SCIM group whose members are the users it is granted to directly
+func (s *SCIMService) scimGroup(ctx context.Context, role domain.Role, excludeMembers bool) (domain.SCIMGroup, error) {
+	id := strconv.FormatInt(role.ID, 10)
+
+	group := domain.SCIMGroup{
+		Schemas:     []string{domain.SCIMSchemas.Group},
+		ID:          id,
+		DisplayName: role.Name,
+		Meta: &domain.SCIMMeta{
+			ResourceType: "Group",
+			Created:      role.CreatedAt,
+			LastModified: role.UpdatedAt,
+			Location:     s.config.BaseURL + "/Groups/" + id,
+		},
+	}
+
+	if excludeMembers {
+		return group, nil
+	}
+
+	users, err := s.roleRepo.GetUsersByRole(ctx, role.ID)
+	if err != nil {
+		return domain.SCIMGroup{}, err
+	}
+
+	for _, user := range users {
+		group.Members = append(group.Members, domain.SCIMMember{
+			Value:   strconv.FormatInt(user.ID, 10),
+			Display: user.Email,
+		})
+	}
+
+	return group, nil
+}
+
+// scimQuery parses the filter of a listing and converts its 1-based start index and count into an offset and limit
+func scimQuery(req domain.SCIMListRequest) (scimfilter.Expr, int, int, error) {
+	var filter scimfilter.Expr
+	if strings.TrimSpace(req.Filter) != "" {
+		var err error
+		if filter, err = scimfilter.Parse(req.Filter); err != nil {
+			return nil, 0, 0, domain.NewSCIMError(http.StatusBadRequest, "invalidFilter", err.Error())
+		}
+	}
+
+	offset := req.StartIndex - 1
+	if offset < 0 {
+		offset = 0
+	}
+
+	limit := scimDefaultCount
+	if req.Count != nil {
+		limit = *req.Count
+	}
+	if limit < 0 {
+		limit = 0
+	}
+	if limit > scimMaxCount {
+		limit = scimMaxCount
+	}
+
+	return filter, offset, limit, nil
+}
+
+// scimFilterError reports a filter the repositories cannot translate as an invalid filter
+func scimFilterError(err error) error {
+	if errors.Is(err, domain.ErrInvalidSCIMFilter) {
+		return domain.NewSCIMError(http.StatusBadRequest, "invalidFilter", err.Error())
+	}
+	return err
+}
+
+func scimListResponse(resources interface{}, total, offset, count int) domain.SCIMListResponse {
+	return domain.SCIMListResponse{
+		Schemas:      []string{domain.SCIMSchemas.ListResponse},
+		TotalResults: total,
+		StartIndex:   offset + 1,
+		ItemsPerPage: count,
+		Resources:    resources,
+	}
+}
+
+// scimAttributes maps the fields of validation errors to the SCIM attributes they come from
+var scimAttributes = map[string]string{
+	"firstName": "name.givenName",
+	"lastName":  "name.familyName",
+	"nickname":  "nickName",
+	"email":     "userName",
+	"name":      "displayName",
+}
+
+// scimValidationError reports validation errors as a SCIM error: a taken email or nickname as a uniqueness
+// conflict, anything else as an invalid value
+func scimValidationError(fieldErrors []domain.FieldError) error {
+	status, scimType := http.StatusBadRequest, "invalidValue"
+
+	details := make([]string, 0, len(fieldErrors))
+	for _, fe := range fieldErrors {
+		if fe == emailTakenError || fe == nicknameTakenError {
+			status, scimType = http.StatusConflict, "uniqueness"
+		}
+
+		attribute, ok := scimAttributes[fe.Field]
+		if !ok {
+			attribute = fe.Field
+		}
+		details = append(details, attribute+": "+fe.Message)
+	}
+
+	return domain.NewSCIMError(status, scimType, strings.Join(details, "; "))
+}
+
+// scimError converts the domain errors of a provisioning change into SCIM errors, logging unexpected ones
+func (s *SCIMService) scimError(message string, err error) error {
+	var scimErr *domain.SCIMError
+	switch {
+	case errors.As(err, &scimErr):
+		return scimErr
+	case errors.Is(err, domain.ErrEmailExists):
+		return scimValidationError([]domain.FieldError{emailTakenError})
+	case errors.Is(err, domain.ErrNicknameExists):
+		return scimValidationError([]domain.FieldError{nicknameTakenError})
+	case errors.Is(err, domain.ErrRoleExists):
+		return domain.NewSCIMError(http.StatusConflict, "uniqueness", "displayName: "+err.Error())
+	case errors.Is(err, domain.ErrProtectedRole):
+		return domain.NewSCIMError(http.StatusBadRequest, "mutability", err.Error())
+	case errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrRoleNotFound):
+		return domain.NewSCIMError(http.StatusNotFound, "", err.Error())
+	}
+
+	s.logger.Errorf("%s: %v", message, err)
+	return err
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+
+	"authmicro/pkg/logger"
+)
+
+type authzVersionIncrementer interface {
+	IncrementAuthzVersion(ctx context.Context, userIDs ...int64) error
+}
+
+type tokenRevoker interface {
+	RevokeAllUserTokens(ctx context.Context, userID int64) error
+}
+
+// tokenInvalidator invalidates the tokens of users whose roles or memberships changed
+type tokenInvalidator struct {
+	versionRepo         authzVersionIncrementer
+	tokenSvc            tokenRevoker
+	revokeRefreshTokens bool
+	logger              logger.Logger
+}
+
+// invalidate bumps the authorization version of the users, so that access tokens carrying their previous
+// roles are rejected, and optionally revokes their refresh tokens
+func (i *tokenInvalidator) invalidate(ctx context.Context, userIDs ...int64) error {
+	if err := i.versionRepo.IncrementAuthzVersion(ctx, userIDs...); err != nil {
+		i.logger.Errorf("Error incrementing authorization version: %v", err)
+		return err
+	}
+
+	if !i.revokeRefreshTokens {
+		return nil
+	}
+
+	for _, userID := range userIDs {
+		if err := i.tokenSvc.RevokeAllUserTokens(ctx, userID); err != nil {
+			i.logger.Errorf("Error revoking tokens of user %d: %v", userID, err)
+			// Outdated access tokens are already rejected, the user just keeps their sessions
+		}
+	}
+
+	return nil
+}
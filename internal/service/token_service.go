@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -12,22 +13,28 @@ import (
 	"authmicro/internal/domain"
 )
 
+type accountStateRepository interface {
+	GetAccountState(ctx context.Context, userID int64) (domain.AccountState, error)
+}
+
 type TokenService struct {
 	config      configs.JWTConfig
 	sessionRepo sessionRepository
+	versionRepo accountStateRepository
 }
 
-func NewTokenService(config configs.JWTConfig, sessionRepo sessionRepository) *TokenService {
+func NewTokenService(config configs.JWTConfig, sessionRepo sessionRepository, versionRepo accountStateRepository) *TokenService {
 	return &TokenService{
 		config:      config,
 		sessionRepo: sessionRepo,
+		versionRepo: versionRepo,
 	}
 }
 
 // GenerateTokenPair generates a new access and refresh token pair
-func (s *TokenService) GenerateTokenPair(ctx context.Context, user domain.User, roles []string) (domain.TokenPair, error) {
+func (s *TokenService) GenerateTokenPair(ctx context.Context, user domain.User, grants domain.UserGrants, authCtx domain.AuthContext) (domain.TokenPair, error) {
 	// Generate access token
-	accessToken, err := s.generateAccessToken(user, roles)
+	accessToken, err := s.generateAccessToken(user, grants, authCtx)
 	if err != nil {
 		return domain.TokenPair{}, err
 	}
@@ -44,8 +51,15 @@ func (s *TokenService) GenerateTokenPair(ctx context.Context, user domain.User,
 	}, nil
 }
 
-// ValidateToken validates a JWT token and returns the claims
-func (s *TokenService) ValidateToken(tokenString string) (*domain.TokenClaims, error) {
+// GenerateAccessToken generates a standalone access token, e.g. an elevated token after re-authentication
+func (s *TokenService) GenerateAccessToken(ctx context.Context, user domain.User, grants domain.UserGrants, authCtx domain.AuthContext) (string, error) {
+	return s.generateAccessToken(user, grants, authCtx)
+}
+
+// ValidateToken validates a JWT token and returns the claims.
+// Tokens issued before the user's roles last changed are rejected with domain.ErrTokenOutdated, tokens of
+// suspended or banned users with domain.ErrAccountSuspended or domain.ErrAccountBanned.
+func (s *TokenService) ValidateToken(ctx context.Context, tokenString string) (*domain.TokenClaims, error) {
 	// Parse token
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		// Check signing method
@@ -110,25 +124,111 @@ func (s *TokenService) ValidateToken(tokenString string) (*domain.TokenClaims, e
 		return nil, errors.New("invalid iat claim")
 	}
 
+	// Extract permissions; they are only present when embedding is enabled
+	var permissions []string
+	if permissionsInterface, ok := claims["permissions"].([]interface{}); ok {
+		permissions = make([]string, len(permissionsInterface))
+		for i, permission := range permissionsInterface {
+			permissions[i], ok = permission.(string)
+			if !ok {
+				return nil, errors.New("invalid permission in permissions claim")
+			}
+		}
+	}
+
+	// Extract authentication context; tokens issued before step-up support carry none
+	var amr []string
+	if amrInterface, ok := claims["amr"].([]interface{}); ok {
+		amr = make([]string, len(amrInterface))
+		for i, method := range amrInterface {
+			amr[i], ok = method.(string)
+			if !ok {
+				return nil, errors.New("invalid method in amr claim")
+			}
+		}
+	}
+
+	acr, _ := claims["acr"].(string)
+	if acr == "" {
+		acr = domain.AuthContextClasses.Basic
+	}
+
+	authTime, ok := claims["auth_time"].(float64)
+	if !ok {
+		authTime = iat
+	}
+
+	// Extract organization memberships; tokens issued before organizations were introduced carry none
+	var memberships []domain.Membership
+	if membershipsInterface, ok := claims["memberships"].([]interface{}); ok {
+		for _, membershipInterface := range membershipsInterface {
+			membershipClaim, ok := membershipInterface.(map[string]interface{})
+			if !ok {
+				return nil, errors.New("invalid membership in memberships claim")
+			}
+
+			orgID, ok := membershipClaim["orgId"].(float64)
+			if !ok {
+				return nil, errors.New("invalid orgId in memberships claim")
+			}
+
+			membership := domain.Membership{OrganizationID: int64(orgID)}
+			orgRoles, _ := membershipClaim["roles"].([]interface{})
+			for _, role := range orgRoles {
+				roleName, ok := role.(string)
+				if !ok {
+					return nil, errors.New("invalid role in memberships claim")
+				}
+				membership.Roles = append(membership.Roles, roleName)
+			}
+
+			memberships = append(memberships, membership)
+		}
+	}
+
+	// Tokens issued before authorization versioning carry none and are treated as outdated
+	authzVersion, _ := claims["authzVersion"].(float64)
+
+	state, err := s.versionRepo.GetAccountState(ctx, int64(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := state.AccountError(time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	if int64(authzVersion) < state.AuthzVersion {
+		return nil, domain.ErrTokenOutdated
+	}
+
 	// Return token claims
 	return &domain.TokenClaims{
-		UserID:    int64(userID),
-		Email:     email,
-		Nickname:  nickname,
-		Roles:     roles,
-		ExpiresAt: int64(exp),
-		IssuedAt:  int64(iat),
+		UserID:       int64(userID),
+		Email:        email,
+		Nickname:     nickname,
+		Roles:        roles,
+		Permissions:  permissions,
+		AMR:          amr,
+		ACR:          acr,
+		AuthTime:     int64(authTime),
+		AuthzVersion: int64(authzVersion),
+		Memberships:  memberships,
+		ExpiresAt:    int64(exp),
+		IssuedAt:     int64(iat),
 	}, nil
 }
 
 // StoreRefreshToken stores a refresh token in the database
-func (s *TokenService) StoreRefreshToken(ctx context.Context, userID int64, refreshToken, userAgent, ip string) error {
+func (s *TokenService) StoreRefreshToken(ctx context.Context, userID int64, refreshToken, userAgent, ip string, authCtx domain.AuthContext) error {
 	session := domain.TokenSession{
 		ID:           uuid.New().String(),
 		UserID:       userID,
 		RefreshToken: refreshToken,
 		UserAgent:    userAgent,
 		IP:           ip,
+		AMR:          strings.Join(authCtx.Methods, " "),
+		AuthTime:     authCtx.AuthTime,
 		ExpiresAt:    time.Now().UTC().Add(s.config.RefreshTokenExpiration),
 		CreatedAt:    time.Now().UTC(),
 	}
@@ -154,18 +254,31 @@ func (s *TokenService) RevokeAllUserTokens(ctx context.Context, userID int64) er
 }
 
 // generateAccessToken generates a new access token
-func (s *TokenService) generateAccessToken(user domain.User, roles []string) (string, error) {
-	// Set token expiration time
+func (s *TokenService) generateAccessToken(user domain.User, grants domain.UserGrants, authCtx domain.AuthContext) (string, error) {
+	// Set token expiration time, capped by the earliest time-bound role grant
 	expirationTime := time.Now().UTC().Add(s.config.AccessTokenExpiration)
+	if grants.ExpiresAt != nil && grants.ExpiresAt.Before(expirationTime) {
+		expirationTime = *grants.ExpiresAt
+	}
 
 	// Create claims
 	claims := jwt.MapClaims{
-		"userId":   user.ID,
-		"email":    user.Email,
-		"nickname": user.Nickname,
-		"roles":    roles,
-		"exp":      expirationTime.Unix(),
-		"iat":      time.Now().UTC().Unix(),
+		"userId":       user.ID,
+		"email":        user.Email,
+		"nickname":     user.Nickname,
+		"roles":        grants.Roles,
+		"amr":          authCtx.Methods,
+		"acr":          authCtx.Class,
+		"auth_time":    authCtx.AuthTime.Unix(),
+		"authzVersion": user.AuthzVersion,
+		"memberships":  grants.Memberships,
+		"exp":          expirationTime.Unix(),
+		"iat":          time.Now().UTC().Unix(),
+	}
+
+	// Embed effective permissions only when configured, to keep tokens small by default
+	if s.config.EmbedPermissions {
+		claims["permissions"] = grants.Permissions
 	}
 
 	// Create token
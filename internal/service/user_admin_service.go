@@ -0,0 +1,465 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"authmicro/configs"
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+)
+
+type userAdminRepository interface {
+	GetByID(ctx context.Context, id int64) (domain.User, error)
+	NicknameExists(ctx context.Context, nickname string) (bool, error)
+	UpdateProfile(ctx context.Context, user domain.User) error
+	IncrementAuthzVersion(ctx context.Context, userIDs ...int64) error
+	ListUsersUnderReview(ctx context.Context) ([]domain.User, error)
+	ClearReviewReason(ctx context.Context, userID int64) error
+	SetAccountStatus(ctx context.Context, userID int64, status string, suspendedUntil *time.Time, reason *string, changedBy int64) error
+	ListUsers(ctx context.Context, req domain.ListUsersRequest, after *domain.UserCursor, limit int) ([]domain.User, error)
+}
+
+type userAdminRoleRepository interface {
+	GetUserRoleGrants(ctx context.Context, userID int64) ([]domain.RoleGrant, error)
+	GetUserRoleNames(ctx context.Context, userID int64) ([]string, error)
+}
+
+type userAdminSessionRepository interface {
+	accountSessionRepository
+	ListUserTokenSessions(ctx context.Context, userID int64) ([]domain.TokenSession, error)
+}
+
+type accountStatusEmailService interface {
+	SendAccountSuspendedNotice(to string, until *time.Time, reason string) error
+}
+
+type nicknameRepository interface {
+	nicknameHistoryRepository
+	ListNicknameHistory(ctx context.Context, userID int64) ([]domain.NicknameChange, error)
+	ListBlockedNicknames(ctx context.Context) ([]domain.BlockedNickname, error)
+	GetBlockedNickname(ctx context.Context, nickname string) (domain.BlockedNickname, error)
+	BlockNickname(ctx context.Context, nickname domain.BlockedNickname) error
+	UnblockNickname(ctx context.Context, nickname string) error
+}
+
+// UserAdminService implements administrative management of user accounts
+type UserAdminService struct {
+	userRepo     userAdminRepository
+	roleRepo     userAdminRoleRepository
+	nicknameRepo nicknameRepository
+	sessionRepo  userAdminSessionRepository
+	uow          unitOfWork
+	emailSvc     accountStatusEmailService
+	nicknames    *nicknameChanger
+	logger       logger.Logger
+}
+
+func NewUserAdminService(
+	userRepo userAdminRepository,
+	roleRepo userAdminRoleRepository,
+	nicknameRepo nicknameRepository,
+	sessionRepo userAdminSessionRepository,
+	uow unitOfWork,
+	emailSvc accountStatusEmailService,
+	nicknameConfig configs.NicknameConfig,
+	logger logger.Logger,
+) *UserAdminService {
+	return &UserAdminService{
+		userRepo:     userRepo,
+		roleRepo:     roleRepo,
+		nicknameRepo: nicknameRepo,
+		sessionRepo:  sessionRepo,
+		uow:          uow,
+		emailSvc:     emailSvc,
+		nicknames: &nicknameChanger{
+			userRepo:     userRepo,
+			nicknameRepo: nicknameRepo,
+			uow:          uow,
+			config:       nicknameConfig,
+			logger:       logger,
+		},
+		logger: logger,
+	}
+}
+
+// ListUsers retrieves a page of the user directory. The next page is requested with the NextCursor of the page and
+// the same filters and sorting.
+func (s *UserAdminService) ListUsers(ctx context.Context, req domain.ListUsersRequest) (domain.UserPage, []domain.FieldError, error) {
+	if req.Limit <= 0 {
+		req.Limit = 50
+	}
+	if req.Limit > 200 {
+		req.Limit = 200
+	}
+
+	fieldErrors := validateUserFilters(&req)
+
+	var after *domain.UserCursor
+	if req.Cursor != "" {
+		cursor, err := decodeUserCursor(req.Cursor)
+		if err != nil || cursor.Sort != req.Sort || cursor.Order != req.Order {
+			fieldErrors = append(fieldErrors, domain.FieldError{
+				Field:   "cursor",
+				Message: "Неверный курсор",
+			})
+		} else {
+			after = &cursor
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return domain.UserPage{}, fieldErrors, nil
+	}
+
+	// One extra row tells whether there is a next page
+	users, err := s.userRepo.ListUsers(ctx, req, after, req.Limit+1)
+	if err != nil {
+		return domain.UserPage{}, nil, err
+	}
+
+	page := domain.UserPage{Users: users}
+	if page.Users == nil {
+		page.Users = []domain.User{}
+	}
+	if len(users) > req.Limit {
+		page.Users = users[:req.Limit]
+		page.NextCursor = encodeUserCursor(req.Sort, req.Order, page.Users[req.Limit-1])
+	}
+
+	return page, nil, nil
+}
+
+// GetUserDetails retrieves a user together with their roles and active sessions
+func (s *UserAdminService) GetUserDetails(ctx context.Context, userID int64) (domain.UserDetails, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return domain.UserDetails{}, err
+	}
+
+	details := domain.UserDetails{
+		User:     user,
+		Sessions: []domain.TokenSessionData{},
+	}
+
+	if details.Roles, err = s.roleRepo.GetUserRoleGrants(ctx, userID); err != nil {
+		return domain.UserDetails{}, err
+	}
+	if details.EffectiveRoles, err = s.roleRepo.GetUserRoleNames(ctx, userID); err != nil {
+		return domain.UserDetails{}, err
+	}
+
+	sessions, err := s.sessionRepo.ListUserTokenSessions(ctx, userID)
+	if err != nil {
+		return domain.UserDetails{}, err
+	}
+	now := time.Now().UTC()
+	for _, session := range sessions {
+		if session.ExpiresAt.After(now) {
+			details.Sessions = append(details.Sessions, tokenSessionData(session))
+		}
+	}
+
+	return details, nil
+}
+
+// validateUserFilters validates the filters and sorting of a user directory request, defaulting the sorting to the
+// newest users first
+func validateUserFilters(req *domain.ListUsersRequest) []domain.FieldError {
+	if req.Sort == "" {
+		req.Sort = domain.UserSorts.CreatedAt
+	}
+	if req.Order == "" {
+		req.Order = domain.SortOrders.Desc
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	req.Nickname = strings.TrimSpace(req.Nickname)
+	req.Role = strings.TrimSpace(req.Role)
+
+	var fieldErrors []domain.FieldError
+	switch req.Sort {
+	case domain.UserSorts.CreatedAt, domain.UserSorts.Email, domain.UserSorts.Nickname:
+	default:
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "sort",
+			Message: "Сортировка возможна по createdAt, email или nickname",
+		})
+	}
+	if req.Order != domain.SortOrders.Asc && req.Order != domain.SortOrders.Desc {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "order",
+			Message: "Порядок сортировки должен быть asc или desc",
+		})
+	}
+	switch req.Status {
+	case "", domain.AccountStatuses.Active, domain.AccountStatuses.Suspended, domain.AccountStatuses.Banned:
+	default:
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "status",
+			Message: "Статус должен быть active, suspended или banned",
+		})
+	}
+
+	return fieldErrors
+}
+
+// encodeUserCursor builds the cursor of the page following the user
+func encodeUserCursor(sort, order string, user domain.User) string {
+	data, _ := json.Marshal(userCursor(sort, order, user))
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// userCursor returns the position right after the user in a listing sorted by the field in the order
+func userCursor(sort, order string, user domain.User) domain.UserCursor {
+	cursor := domain.UserCursor{Sort: sort, Order: order, ID: user.ID}
+	switch sort {
+	case domain.UserSorts.CreatedAt:
+		cursor.Value = user.CreatedAt.Format(time.RFC3339Nano)
+	case domain.UserSorts.Email:
+		cursor.Value = user.Email
+	case domain.UserSorts.Nickname:
+		cursor.Value = user.Nickname
+	}
+	return cursor
+}
+
+// decodeUserCursor parses a cursor built by encodeUserCursor
+func decodeUserCursor(value string) (domain.UserCursor, error) {
+	var cursor domain.UserCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, err
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, err
+	}
+	if cursor.Sort == domain.UserSorts.CreatedAt {
+		if _, err := time.Parse(time.RFC3339Nano, cursor.Value); err != nil {
+			return cursor, err
+		}
+	}
+
+	return cursor, nil
+}
+
+// ListUsersUnderReview retrieves the users whose registration was flagged for review
+func (s *UserAdminService) ListUsersUnderReview(ctx context.Context) ([]domain.User, error) {
+	return s.userRepo.ListUsersUnderReview(ctx)
+}
+
+// ApproveUser clears the review flag of a user
+func (s *UserAdminService) ApproveUser(ctx context.Context, userID int64) error {
+	return s.userRepo.ClearReviewReason(ctx, userID)
+}
+
+// SuspendUser blocks a user until the given time, revoking all of their sessions, and notifies them by email
+func (s *UserAdminService) SuspendUser(ctx context.Context, actorID, userID int64, req domain.SuspendUserRequest) (domain.User, []domain.FieldError, error) {
+	var fieldErrors []domain.FieldError
+
+	if !req.Until.After(time.Now().UTC()) {
+		fieldErrors = append(fieldErrors, domain.FieldError{
+			Field:   "until",
+			Message: "Дата окончания блокировки должна быть в будущем",
+		})
+	}
+	fieldErrors = append(fieldErrors, validateStatusReason(req.Reason)...)
+
+	if len(fieldErrors) > 0 {
+		return domain.User{}, fieldErrors, nil
+	}
+
+	until := req.Until.UTC()
+	user, err := s.blockUser(ctx, actorID, userID, domain.AccountStatuses.Suspended, &until, strings.TrimSpace(req.Reason))
+	return user, nil, err
+}
+
+// BanUser blocks a user for good, revoking all of their sessions, and notifies them by email
+func (s *UserAdminService) BanUser(ctx context.Context, actorID, userID int64, req domain.BanUserRequest) (domain.User, []domain.FieldError, error) {
+	if fieldErrors := validateStatusReason(req.Reason); len(fieldErrors) > 0 {
+		return domain.User{}, fieldErrors, nil
+	}
+
+	user, err := s.blockUser(ctx, actorID, userID, domain.AccountStatuses.Banned, nil, strings.TrimSpace(req.Reason))
+	return user, nil, err
+}
+
+// ReinstateUser lifts the suspension or ban of a user
+func (s *UserAdminService) ReinstateUser(ctx context.Context, actorID, userID int64) (domain.User, error) {
+	if err := s.userRepo.SetAccountStatus(ctx, userID, domain.AccountStatuses.Active, nil, nil, actorID); err != nil {
+		return domain.User{}, err
+	}
+
+	s.logger.Infof("User %d reinstated by %d", userID, actorID)
+
+	return s.userRepo.GetByID(ctx, userID)
+}
+
+// blockUser suspends or bans a user. The status change, the revocation of the refresh tokens and the invalidation
+// of the access tokens happen at once.
+func (s *UserAdminService) blockUser(ctx context.Context, actorID, userID int64, status string, until *time.Time, reason string) (domain.User, error) {
+	if actorID == userID {
+		return domain.User{}, domain.ErrSelfStatusChange
+	}
+
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.SetAccountStatus(ctx, userID, status, until, &reason, actorID); err != nil {
+			return err
+		}
+
+		if err := s.sessionRepo.DeleteUserTokenSessions(ctx, userID); err != nil {
+			return err
+		}
+
+		return s.userRepo.IncrementAuthzVersion(ctx, userID)
+	})
+	if err != nil {
+		if !errors.Is(err, domain.ErrUserNotFound) {
+			s.logger.Errorf("Error changing account status: %v", err)
+		}
+		return domain.User{}, err
+	}
+
+	s.logger.Infof("User %d %s by %d: %s", userID, status, actorID, reason)
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	if err := s.emailSvc.SendAccountSuspendedNotice(user.Email, until, reason); err != nil {
+		s.logger.Errorf("Error sending account suspension notice: %v", err)
+		// Just log the error and continue
+	}
+
+	return user, nil
+}
+
+// validateStatusReason validates the reason given for suspending or banning a user
+func validateStatusReason(reason string) []domain.FieldError {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return []domain.FieldError{{
+			Field:   "reason",
+			Message: "Поле пустое",
+		}}
+	}
+	if len(reason) > 1000 {
+		return []domain.FieldError{{
+			Field:   "reason",
+			Message: "Причина не может быть длиннее 1000 символов",
+		}}
+	}
+	return nil
+}
+
+// ChangeNickname changes the nickname of a user, e.g. to replace an offensive or impersonating one.
+// The change is recorded in the nickname history and the previous nickname is reserved; access tokens
+// carrying it stop working.
+func (s *UserAdminService) ChangeNickname(ctx context.Context, actorID, userID int64, req domain.ChangeNicknameRequest) (domain.User, []domain.FieldError, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return domain.User{}, nil, err
+	}
+
+	if req.Nickname == user.Nickname {
+		return user, nil, nil
+	}
+
+	fieldErrors, err := s.nicknames.validate(ctx, userID, req.Nickname, false)
+	if err != nil {
+		return domain.User{}, nil, err
+	}
+	if len(fieldErrors) > 0 {
+		return domain.User{}, fieldErrors, nil
+	}
+
+	oldNickname := user.Nickname
+	user.Nickname = req.Nickname
+
+	var actor *int64
+	if actorID != 0 {
+		actor = &actorID
+	}
+
+	if err := s.nicknames.save(ctx, user, oldNickname, actor); err != nil {
+		if errors.Is(err, domain.ErrNicknameExists) {
+			return domain.User{}, []domain.FieldError{nicknameTakenError}, nil
+		}
+		s.logger.Errorf("Error changing nickname: %v", err)
+		return domain.User{}, nil, err
+	}
+
+	if err := s.userRepo.IncrementAuthzVersion(ctx, userID); err != nil {
+		s.logger.Errorf("Error incrementing authorization version: %v", err)
+		return domain.User{}, nil, err
+	}
+
+	user, err = s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return domain.User{}, nil, err
+	}
+
+	return user, nil, nil
+}
+
+// ListNicknameHistory retrieves the nickname changes of a user, newest first
+func (s *UserAdminService) ListNicknameHistory(ctx context.Context, userID int64) ([]domain.NicknameChange, error) {
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return s.nicknameRepo.ListNicknameHistory(ctx, userID)
+}
+
+// ListBlockedNicknames retrieves the nicknames nobody may take
+func (s *UserAdminService) ListBlockedNicknames(ctx context.Context) ([]domain.BlockedNickname, error) {
+	return s.nicknameRepo.ListBlockedNicknames(ctx)
+}
+
+// BlockNickname adds a nickname to the blocked list. Users already holding it keep it until it is changed.
+func (s *UserAdminService) BlockNickname(ctx context.Context, actorID int64, req domain.BlockNicknameRequest) (domain.BlockedNickname, []domain.FieldError, error) {
+	nickname := strings.TrimSpace(req.Nickname)
+	if nickname == "" {
+		return domain.BlockedNickname{}, []domain.FieldError{{
+			Field:   "nickname",
+			Message: "Поле пустое",
+		}}, nil
+	}
+
+	if matched, _ := regexp.MatchString(`^[a-zA-Z0-9]+$`, nickname); !matched {
+		return domain.BlockedNickname{}, []domain.FieldError{{
+			Field:   "nickname",
+			Message: "В nickname используются запрещённые символы",
+		}}, nil
+	}
+
+	blocked := domain.BlockedNickname{
+		Nickname: nickname,
+		Reason:   strings.TrimSpace(req.Reason),
+	}
+	if actorID != 0 {
+		blocked.CreatedBy = &actorID
+	}
+
+	if err := s.nicknameRepo.BlockNickname(ctx, blocked); err != nil {
+		return domain.BlockedNickname{}, nil, err
+	}
+
+	blocked, err := s.nicknameRepo.GetBlockedNickname(ctx, nickname)
+	if err != nil {
+		return domain.BlockedNickname{}, nil, err
+	}
+
+	return blocked, nil, nil
+}
+
+// UnblockNickname removes a nickname from the blocked list
+func (s *UserAdminService) UnblockNickname(ctx context.Context, nickname string) error {
+	return s.nicknameRepo.UnblockNickname(ctx, nickname)
+}
@@ -0,0 +1,428 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"authmicro/internal/domain"
+	"authmicro/pkg/logger"
+	"authmicro/pkg/mailaddr"
+)
+
+// maxImportRows limits the number of users of a single bulk import
+const maxImportRows = 5000
+
+// exportPageSize is the number of users read from the database at a time while exporting
+const exportPageSize = 500
+
+type userImporter interface {
+	RegisterImportedUser(ctx context.Context, req domain.RegistrationRequest, dryRun bool) (int64, []domain.FieldError, error)
+}
+
+type userBulkRepository interface {
+	ListUsers(ctx context.Context, req domain.ListUsersRequest, after *domain.UserCursor, limit int) ([]domain.User, error)
+}
+
+type userBulkRoleRepository interface {
+	GetRoleByName(ctx context.Context, name string) (domain.Role, error)
+	GrantRoleToUser(ctx context.Context, userID, roleID, grantedBy int64, expiresAt *time.Time) error
+	CreateRoleChange(ctx context.Context, change domain.RoleChange) error
+	GetRolesByUsers(ctx context.Context, userIDs []int64) (map[int64][]domain.Role, error)
+}
+
+type welcomeEmailService interface {
+	SendWelcomeEmail(to, name string) error
+}
+
+// UserBulkService implements bulk imports and exports of users by admins
+type UserBulkService struct {
+	importer  userImporter
+	userRepo  userBulkRepository
+	roleRepo  userBulkRoleRepository
+	uow       unitOfWork
+	emailSvc  welcomeEmailService
+	emailNorm mailaddr.Normalizer
+	logger    logger.Logger
+}
+
+func NewUserBulkService(
+	importer userImporter,
+	userRepo userBulkRepository,
+	roleRepo userBulkRoleRepository,
+	uow unitOfWork,
+	emailSvc welcomeEmailService,
+	emailNorm mailaddr.Normalizer,
+	logger logger.Logger,
+) *UserBulkService {
+	return &UserBulkService{
+		importer:  importer,
+		userRepo:  userRepo,
+		roleRepo:  roleRepo,
+		uow:       uow,
+		emailSvc:  emailSvc,
+		emailNorm: emailNorm,
+		logger:    logger,
+	}
+}
+
+// ImportUsers creates the users listed in a CSV or JSON file on behalf of an admin. Every user is validated like a
+// self-service registration and granted the listed roles. Users are only created when all of them are valid, so that
+// a corrected file can simply be imported again; with DryRun they are only validated.
+func (s *UserBulkService) ImportUsers(ctx context.Context, actorID int64, r io.Reader, opts domain.UserImportOptions) (domain.UserImportReport, error) {
+	var users []domain.ImportedUser
+	var err error
+	switch opts.Format {
+	case domain.UserFileFormats.CSV:
+		users, err = parseUsersCSV(r)
+	case domain.UserFileFormats.JSON:
+		users, err = parseUsersJSON(r)
+	default:
+		return domain.UserImportReport{}, domain.ErrInvalidUserFileFormat
+	}
+	if err != nil {
+		return domain.UserImportReport{}, err
+	}
+
+	if len(users) > maxImportRows {
+		return domain.UserImportReport{}, domain.ErrUserImportTooLarge
+	}
+
+	report := domain.UserImportReport{
+		DryRun: opts.DryRun,
+		Total:  len(users),
+		Rows:   make([]domain.UserImportRow, len(users)),
+	}
+
+	roles, err := s.validateImport(ctx, users, report.Rows)
+	if err != nil {
+		return domain.UserImportReport{}, err
+	}
+	report.Failed = failedRows(report.Rows)
+	if report.Failed > 0 || opts.DryRun {
+		return report, nil
+	}
+
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		for i, user := range users {
+			userID, fieldErrors, err := s.importer.RegisterImportedUser(ctx, registrationRequest(user), false)
+			if err != nil {
+				return err
+			}
+			if len(fieldErrors) > 0 {
+				// Taken by a registration since the validation
+				report.Rows[i].Errors = fieldErrors
+				return errImportRowInvalid
+			}
+			report.Rows[i].UserID = userID
+
+			for _, role := range roles[i] {
+				if err := s.grantRole(ctx, actorID, userID, role); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errImportRowInvalid) {
+		for i := range report.Rows {
+			report.Rows[i].UserID = 0
+		}
+		report.Failed = failedRows(report.Rows)
+		return report, nil
+	}
+	if err != nil {
+		s.logger.Errorf("Error importing users: %v", err)
+		return domain.UserImportReport{}, err
+	}
+
+	report.Created = len(users)
+	s.logger.Infof("%d users imported by %d", report.Created, actorID)
+
+	if opts.SendWelcomeEmail {
+		for i, user := range users {
+			if err := s.emailSvc.SendWelcomeEmail(report.Rows[i].Email, strings.TrimSpace(user.FirstName)); err != nil {
+				s.logger.Errorf("Error sending welcome email to user %d: %v", report.Rows[i].UserID, err)
+				// Just log the error and continue
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// failedRows counts the rows of an import report that have errors
+func failedRows(rows []domain.UserImportRow) int {
+	failed := 0
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			failed++
+		}
+	}
+	return failed
+}
+
+// errImportRowInvalid rolls back an import when a user turns out to be invalid while being created
+var errImportRowInvalid = errors.New("invalid import row")
+
+// validateImport validates the users of an import, recording their errors in the rows of the report, and looks up
+// the roles to grant to each of them
+func (s *UserBulkService) validateImport(ctx context.Context, users []domain.ImportedUser, rows []domain.UserImportRow) ([][]domain.Role, error) {
+	roles := make([][]domain.Role, len(users))
+	knownRoles := map[string]*domain.Role{}
+	emails := map[string]int{}
+	nicknames := map[string]int{}
+
+	for i, user := range users {
+		rows[i].Row = i + 1
+		rows[i].Email = s.normalizeEmail(user.Email)
+
+		_, fieldErrors, err := s.importer.RegisterImportedUser(ctx, registrationRequest(user), true)
+		if err != nil {
+			return nil, err
+		}
+
+		// The users of the file are not registered yet, so duplicates within it are looked for here
+		if row, ok := emails[rows[i].Email]; ok && rows[i].Email != "" {
+			fieldErrors = append(fieldErrors, domain.FieldError{
+				Field:   "email",
+				Message: fmt.Sprintf("Электронная почта повторяется в строке %d", row),
+			})
+		} else {
+			emails[rows[i].Email] = rows[i].Row
+		}
+		nickname := strings.TrimSpace(user.Nickname)
+		if row, ok := nicknames[nickname]; ok && nickname != "" {
+			fieldErrors = append(fieldErrors, domain.FieldError{
+				Field:   "nickname",
+				Message: fmt.Sprintf("Nickname повторяется в строке %d", row),
+			})
+		} else {
+			nicknames[nickname] = rows[i].Row
+		}
+
+		granted := map[string]bool{}
+		for _, name := range user.Roles {
+			name = strings.TrimSpace(name)
+			if name == "" || name == domain.DefaultRoles.User || granted[name] {
+				continue
+			}
+			granted[name] = true
+
+			role, ok := knownRoles[name]
+			if !ok {
+				found, err := s.roleRepo.GetRoleByName(ctx, name)
+				if err != nil && !errors.Is(err, domain.ErrRoleNotFound) {
+					return nil, err
+				}
+				if err == nil {
+					role = &found
+				}
+				knownRoles[name] = role
+			}
+
+			if role == nil {
+				fieldErrors = append(fieldErrors, domain.FieldError{
+					Field:   "roles",
+					Message: fmt.Sprintf("Роль %s не существует", name),
+				})
+				continue
+			}
+			roles[i] = append(roles[i], *role)
+		}
+
+		rows[i].Errors = fieldErrors
+	}
+
+	return roles, nil
+}
+
+// grantRole grants a role to an imported user, recording it in the role change history
+func (s *UserBulkService) grantRole(ctx context.Context, actorID, userID int64, role domain.Role) error {
+	if err := s.roleRepo.GrantRoleToUser(ctx, userID, role.ID, actorID, nil); err != nil {
+		return err
+	}
+
+	return s.roleRepo.CreateRoleChange(ctx, domain.RoleChange{
+		Action:   domain.RoleChangeActions.Assigned,
+		RoleID:   role.ID,
+		RoleName: role.Name,
+		UserID:   &userID,
+		ActorID:  actorID,
+		Details:  "bulk import",
+	})
+}
+
+// normalizeEmail normalizes an email address like AuthService does, leaving malformed input as it is
+func (s *UserBulkService) normalizeEmail(email string) string {
+	normalized, err := s.emailNorm.Normalize(email)
+	if err != nil {
+		return strings.TrimSpace(email)
+	}
+	return normalized
+}
+
+// ExportUsers writes the users matching the filters of the request to w as CSV, in the order of the request. The
+// file can be imported again. Validation errors are returned before anything is written; the rows are written and
+// flushed page by page, so a failure can leave the file incomplete.
+func (s *UserBulkService) ExportUsers(ctx context.Context, req domain.ListUsersRequest, w io.Writer) ([]domain.FieldError, error) {
+	if fieldErrors := validateUserFilters(&req); len(fieldErrors) > 0 {
+		return fieldErrors, nil
+	}
+
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"id", "firstName", "lastName", "nickname", "email", "emailVerified", "status", "roles", "createdAt"})
+	if err != nil {
+		return nil, err
+	}
+
+	var after *domain.UserCursor
+	for {
+		users, err := s.userRepo.ListUsers(ctx, req, after, exportPageSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(users) == 0 {
+			break
+		}
+
+		userIDs := make([]int64, 0, len(users))
+		for _, user := range users {
+			userIDs = append(userIDs, user.ID)
+		}
+		roles, err := s.roleRepo.GetRolesByUsers(ctx, userIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now().UTC()
+		for _, user := range users {
+			status := user.Status
+			if user.AccountError(now) == nil {
+				status = domain.AccountStatuses.Active
+			}
+
+			roleNames := make([]string, 0, len(roles[user.ID]))
+			for _, role := range roles[user.ID] {
+				roleNames = append(roleNames, role.Name)
+			}
+
+			err := writer.Write([]string{
+				strconv.FormatInt(user.ID, 10),
+				user.FirstName,
+				user.LastName,
+				user.Nickname,
+				user.Email,
+				strconv.FormatBool(user.EmailVerified),
+				status,
+				strings.Join(roleNames, ";"),
+				user.CreatedAt.Format(time.RFC3339),
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return nil, err
+		}
+		if flusher, ok := w.(interface{ Flush() }); ok {
+			flusher.Flush()
+		}
+
+		if len(users) < exportPageSize {
+			break
+		}
+		cursor := userCursor(req.Sort, req.Order, users[len(users)-1])
+		after = &cursor
+	}
+
+	writer.Flush()
+	return nil, writer.Error()
+}
+
+// registrationRequest converts an imported user to the registration request it is validated and created with
+func registrationRequest(user domain.ImportedUser) domain.RegistrationRequest {
+	return domain.RegistrationRequest{
+		FirstName: strings.TrimSpace(user.FirstName),
+		LastName:  strings.TrimSpace(user.LastName),
+		Nickname:  strings.TrimSpace(user.Nickname),
+		Email:     user.Email,
+	}
+}
+
+// parseUsersCSV reads the users of a CSV import. The header names the columns firstName, lastName, nickname, email and
+// optionally roles, in any order; other columns, such as those of an export, are ignored.
+func parseUsersCSV(r io.Reader) ([]domain.ImportedUser, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidUserFile, err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{"firstName", "lastName", "nickname", "email"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: missing column %s", domain.ErrInvalidUserFile, name)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok {
+			return record[i]
+		}
+		return ""
+	}
+
+	var users []domain.ImportedUser
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", domain.ErrInvalidUserFile, err)
+		}
+
+		user := domain.ImportedUser{
+			FirstName: field(record, "firstName"),
+			LastName:  field(record, "lastName"),
+			Nickname:  field(record, "nickname"),
+			Email:     field(record, "email"),
+		}
+		if roles := field(record, "roles"); roles != "" {
+			user.Roles = strings.Split(roles, ";")
+		}
+		users = append(users, user)
+
+		if len(users) > maxImportRows {
+			return nil, domain.ErrUserImportTooLarge
+		}
+	}
+
+	return users, nil
+}
+
+// parseUsersJSON reads the users of a JSON import, an array of domain.ImportedUser
+func parseUsersJSON(r io.Reader) ([]domain.ImportedUser, error) {
+	var users []domain.ImportedUser
+	if err := json.NewDecoder(r).Decode(&users); err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidUserFile, err)
+	}
+	return users, nil
+}
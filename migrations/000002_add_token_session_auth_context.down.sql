@@ -0,0 +1,2 @@
+ALTER TABLE token_sessions DROP COLUMN IF EXISTS auth_time;
+ALTER TABLE token_sessions DROP COLUMN IF EXISTS amr;
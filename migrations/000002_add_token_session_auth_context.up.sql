@@ -0,0 +1,3 @@
+-- Record how and when the user authenticated for each refresh token session
+ALTER TABLE token_sessions ADD COLUMN IF NOT EXISTS amr TEXT NOT NULL DEFAULT '';
+ALTER TABLE token_sessions ADD COLUMN IF NOT EXISTS auth_time TIMESTAMP NOT NULL DEFAULT NOW();
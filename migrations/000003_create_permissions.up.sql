@@ -0,0 +1,35 @@
+-- Create permissions table
+CREATE TABLE IF NOT EXISTS permissions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Create role_permissions table
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id INTEGER NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_permissions_permission_id ON role_permissions(permission_id);
+
+-- Insert default permissions
+INSERT INTO permissions (name, description, created_at, updated_at)
+VALUES ('users:read', 'Read user accounts', NOW(), NOW()),
+       ('users:write', 'Modify user accounts', NOW(), NOW()),
+       ('sessions:revoke', 'Revoke user sessions', NOW(), NOW()),
+       ('roles:manage', 'Manage roles and permissions', NOW(), NOW())
+ON CONFLICT (name) DO NOTHING;
+
+-- Grant all default permissions to the admin role
+INSERT INTO role_permissions (role_id, permission_id, created_at)
+SELECT r.id, p.id, NOW()
+FROM roles r
+CROSS JOIN permissions p
+WHERE r.name = 'admin'
+  AND p.name IN ('users:read', 'users:write', 'sessions:revoke', 'roles:manage')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_role_change_history_user_id;
+DROP INDEX IF EXISTS idx_role_change_history_role_id;
+
+DROP TABLE IF EXISTS role_change_history;
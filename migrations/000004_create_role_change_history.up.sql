@@ -0,0 +1,14 @@
+-- Create role_change_history table
+CREATE TABLE IF NOT EXISTS role_change_history (
+    id SERIAL PRIMARY KEY,
+    action VARCHAR(20) NOT NULL,
+    role_id INTEGER NOT NULL,
+    role_name VARCHAR(50) NOT NULL,
+    user_id INTEGER,
+    actor_id INTEGER NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_change_history_role_id ON role_change_history(role_id);
+CREATE INDEX IF NOT EXISTS idx_role_change_history_user_id ON role_change_history(user_id);
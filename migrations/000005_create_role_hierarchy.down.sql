@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_role_hierarchy_child_role_id;
+
+DROP TABLE IF EXISTS role_hierarchy;
@@ -0,0 +1,10 @@
+-- Create role_hierarchy table; a user holding the parent role effectively holds the child role
+CREATE TABLE IF NOT EXISTS role_hierarchy (
+    parent_role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    child_role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (parent_role_id, child_role_id),
+    CHECK (parent_role_id <> child_role_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_hierarchy_child_role_id ON role_hierarchy(child_role_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_user_roles_expires_at;
+
+ALTER TABLE user_roles DROP COLUMN IF EXISTS granted_by;
+ALTER TABLE user_roles DROP COLUMN IF EXISTS expires_at;
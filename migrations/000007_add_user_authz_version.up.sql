@@ -0,0 +1,2 @@
+-- Per-user authorization version; access tokens carrying an older version are rejected
+ALTER TABLE users ADD COLUMN IF NOT EXISTS authz_version BIGINT NOT NULL DEFAULT 1;
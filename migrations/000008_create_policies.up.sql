@@ -0,0 +1,13 @@
+-- Attribute-based authorization policies
+CREATE TABLE IF NOT EXISTS policies (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    effect VARCHAR(10) NOT NULL CHECK (effect IN ('allow', 'deny')),
+    action VARCHAR(100) NOT NULL,
+    resource_type VARCHAR(100) NOT NULL DEFAULT '*',
+    condition TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
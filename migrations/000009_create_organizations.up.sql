@@ -0,0 +1,29 @@
+-- Organizations (tenants) and their members
+CREATE TABLE IF NOT EXISTS organizations (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(50) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS memberships (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, organization_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_memberships_organization_id ON memberships(organization_id);
+
+-- Roles granted to a member within an organization
+CREATE TABLE IF NOT EXISTS membership_roles (
+    user_id INTEGER NOT NULL,
+    organization_id INTEGER NOT NULL,
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, organization_id, role_id),
+    FOREIGN KEY (user_id, organization_id) REFERENCES memberships(user_id, organization_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_membership_roles_role_id ON membership_roles(role_id);
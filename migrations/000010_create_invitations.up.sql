@@ -0,0 +1,26 @@
+-- Invitations of people into organizations
+CREATE TABLE IF NOT EXISTS invitations (
+    id SERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    nonce VARCHAR(36) NOT NULL,
+    invited_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP,
+    accepted_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- At most one open invitation per email and organization
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invitations_open_email
+    ON invitations(organization_id, LOWER(email))
+    WHERE accepted_at IS NULL AND revoked_at IS NULL;
+
+-- Roles granted within the organization on acceptance
+CREATE TABLE IF NOT EXISTS invitation_roles (
+    invitation_id INTEGER NOT NULL REFERENCES invitations(id) ON DELETE CASCADE,
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    PRIMARY KEY (invitation_id, role_id)
+);
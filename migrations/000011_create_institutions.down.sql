@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN IF EXISTS institution_id;
+DROP TABLE IF EXISTS institution_domains;
+DROP TABLE IF EXISTS institutions;
@@ -0,0 +1,17 @@
+-- Institutions allowed to register and their email domains.
+-- A domain either matches exactly (msu.ru) or is a wildcard matching any subdomain (*.msu.ru).
+CREATE TABLE IF NOT EXISTS institutions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS institution_domains (
+    domain VARCHAR(255) PRIMARY KEY,
+    institution_id INTEGER NOT NULL REFERENCES institutions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_institution_domains_institution_id ON institution_domains(institution_id);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS institution_id INTEGER REFERENCES institutions(id) ON DELETE SET NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_users_review_reason;
+ALTER TABLE users DROP COLUMN IF EXISTS review_reason;
+ALTER TABLE registration_sessions DROP COLUMN IF EXISTS review_reason;
@@ -0,0 +1,5 @@
+-- Registrations whose email failed the email policy in flag mode are kept for review
+ALTER TABLE registration_sessions ADD COLUMN IF NOT EXISTS review_reason VARCHAR(50);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS review_reason VARCHAR(50);
+
+CREATE INDEX IF NOT EXISTS idx_users_review_reason ON users(id) WHERE review_reason IS NOT NULL;
@@ -0,0 +1,14 @@
+-- Emails are unique regardless of case. Existing addresses are lowercased unless that would merge accounts;
+-- collisions are reported (see cmd/emailmigrate) and the index is left out until they are resolved.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_users_email_lower') THEN
+        IF EXISTS (SELECT 1 FROM users GROUP BY LOWER(TRIM(email)) HAVING COUNT(*) > 1) THEN
+            RAISE WARNING 'users.email has case-insensitive duplicates, run cmd/emailmigrate to resolve them';
+        ELSE
+            UPDATE users SET email = LOWER(TRIM(email)) WHERE email <> LOWER(TRIM(email));
+            CREATE UNIQUE INDEX idx_users_email_lower ON users (LOWER(email));
+        END IF;
+    END IF;
+END
+$$;
@@ -0,0 +1,12 @@
+-- Pending email changes: a code is sent to the new address and a cancel link to the current one.
+-- A user has at most one pending change; requesting another replaces it.
+CREATE TABLE IF NOT EXISTS email_change_sessions (
+    id UUID PRIMARY KEY,
+    user_id INTEGER NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    new_email VARCHAR(255) NOT NULL,
+    code VARCHAR(6) NOT NULL,
+    code_expires TIMESTAMP NOT NULL,
+    cancel_token UUID NOT NULL UNIQUE,
+    review_reason VARCHAR(50),
+    created_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS blocked_nicknames;
+DROP TABLE IF EXISTS nickname_history;
@@ -0,0 +1,21 @@
+-- Nickname changes. The previous nickname stays reserved for other users until reserved_until.
+CREATE TABLE IF NOT EXISTS nickname_history (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    old_nickname VARCHAR(255) NOT NULL,
+    new_nickname VARCHAR(255) NOT NULL,
+    changed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    reserved_until TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_nickname_history_user_id ON nickname_history(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_nickname_history_old_nickname ON nickname_history(old_nickname, reserved_until);
+
+-- Nicknames nobody may take, matched case-insensitively and stored lowercased
+CREATE TABLE IF NOT EXISTS blocked_nicknames (
+    nickname VARCHAR(255) PRIMARY KEY,
+    reason TEXT NOT NULL DEFAULT '',
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL
+);
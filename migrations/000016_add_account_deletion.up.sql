@@ -0,0 +1,6 @@
+-- Accounts pending deletion are deleted or anonymized once deletion_scheduled_at has passed;
+-- anonymized accounts keep their row with deleted_at set
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON users(deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
@@ -0,0 +1,15 @@
+-- Personal data exports, built in the background and downloadable with the emailed token until expires_at
+CREATE TABLE IF NOT EXISTS data_exports (
+    id UUID PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    format VARCHAR(10) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    data BYTEA,
+    download_token UUID NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_data_exports_status ON data_exports(status, created_at);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS consent_sessions;
+DROP TABLE IF EXISTS consents;
+DROP TABLE IF EXISTS legal_documents;
@@ -0,0 +1,37 @@
+-- Versions of the privacy policy and terms. The current version of a kind is the latest published one.
+CREATE TABLE IF NOT EXISTS legal_documents (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(50) NOT NULL,
+    version VARCHAR(50) NOT NULL,
+    url TEXT NOT NULL,
+    published_at TIMESTAMP NOT NULL,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (kind, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_legal_documents_kind_published_at ON legal_documents(kind, published_at);
+
+-- Acceptances of legal document versions by users
+CREATE TABLE IF NOT EXISTS consents (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    document_id INTEGER NOT NULL REFERENCES legal_documents(id) ON DELETE CASCADE,
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    accepted_at TIMESTAMP NOT NULL,
+    UNIQUE (user_id, document_id)
+);
+
+-- Logins and token refreshes held back until the user accepts the current legal documents
+CREATE TABLE IF NOT EXISTS consent_sessions (
+    id UUID PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_session_id UUID,
+    amr TEXT NOT NULL DEFAULT '',
+    auth_time TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_consent_sessions_user_id ON consent_sessions(user_id);
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS external_id;
+DROP TABLE IF EXISTS scim_clients;
@@ -0,0 +1,12 @@
+-- Provisioning clients calling the SCIM API, authenticated by a bearer token of which only the SHA-256 hash is kept
+CREATE TABLE IF NOT EXISTS scim_clients (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP
+);
+
+-- Identifier of a provisioned user in the system of the provisioning client
+ALTER TABLE users ADD COLUMN IF NOT EXISTS external_id VARCHAR(255);
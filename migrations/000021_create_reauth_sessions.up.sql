@@ -0,0 +1,10 @@
+-- Pending re-authentications of signed-in users, kept apart from login codes so that neither can stand in for the other.
+-- A user has at most one pending re-authentication; requesting another replaces it.
+CREATE TABLE IF NOT EXISTS reauth_sessions (
+    id UUID PRIMARY KEY,
+    user_id INTEGER NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    code VARCHAR(6) NOT NULL,
+    code_expires TIMESTAMP NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,2 @@
+ALTER TABLE data_exports DROP COLUMN IF EXISTS attempts;
+ALTER TABLE data_exports DROP COLUMN IF EXISTS claimed_at;
@@ -0,0 +1,4 @@
+-- When a data export was last claimed by a worker and how many times, so that exports left processing by a crashed
+-- worker are claimed again or given up on
+ALTER TABLE data_exports ADD COLUMN IF NOT EXISTS claimed_at TIMESTAMP;
+ALTER TABLE data_exports ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,456 @@
+package expr
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// functionArity lists the supported functions and the number of arguments they take
+var functionArity = map[string]int{
+	"has":        1,
+	"size":       1,
+	"lower":      1,
+	"upper":      1,
+	"startsWith": 2,
+	"endsWith":   2,
+	"contains":   2,
+	"matches":    2,
+}
+
+// Eval evaluates the program against the environment
+func (p *Program) Eval(env map[string]interface{}) (interface{}, error) {
+	return p.root.eval(env)
+}
+
+// EvalBool evaluates the program and requires the result to be a boolean
+func (p *Program) EvalBool(env map[string]interface{}) (bool, error) {
+	value, err := p.Eval(env)
+	if err != nil {
+		return false, err
+	}
+
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression must evaluate to a boolean, got %s", typeName(value))
+	}
+
+	return result, nil
+}
+
+func (n *literalNode) eval(env map[string]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n *identNode) eval(env map[string]interface{}) (interface{}, error) {
+	value, ok := env[n.name]
+	if !ok {
+		return nil, fmt.Errorf("undefined variable %q", n.name)
+	}
+	return normalize(value), nil
+}
+
+func (n *memberNode) eval(env map[string]interface{}) (interface{}, error) {
+	target, err := n.target.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := n.key.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := target.(type) {
+	case map[string]interface{}:
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("map key must be a string, got %s", typeName(key))
+		}
+		value, ok := t[name]
+		if !ok {
+			return nil, fmt.Errorf("no such attribute %q", name)
+		}
+		return normalize(value), nil
+
+	case []interface{}:
+		index, ok := key.(float64)
+		if !ok || index != float64(int(index)) {
+			return nil, fmt.Errorf("list index must be an integer, got %s", typeName(key))
+		}
+		if int(index) < 0 || int(index) >= len(t) {
+			return nil, fmt.Errorf("list index %d out of range", int(index))
+		}
+		return normalize(t[int(index)]), nil
+	}
+
+	return nil, fmt.Errorf("cannot access attributes of %s", typeName(target))
+}
+
+func (n *listNode) eval(env map[string]interface{}) (interface{}, error) {
+	items := make([]interface{}, 0, len(n.items))
+	for _, item := range n.items {
+		value, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+	}
+	return items, nil
+}
+
+func (n *unaryNode) eval(env map[string]interface{}) (interface{}, error) {
+	value, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "!":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator ! expects a boolean, got %s", typeName(value))
+		}
+		return !b, nil
+	default:
+		f, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("operator - expects a number, got %s", typeName(value))
+		}
+		return -f, nil
+	}
+}
+
+func (n *conditionalNode) eval(env map[string]interface{}) (interface{}, error) {
+	cond, err := evalBool(n.cond, env, "?")
+	if err != nil {
+		return nil, err
+	}
+
+	if cond {
+		return n.then.eval(env)
+	}
+	return n.otherwise.eval(env)
+}
+
+func (n *binaryNode) eval(env map[string]interface{}) (interface{}, error) {
+	// Logical operators short-circuit
+	switch n.op {
+	case "&&", "||":
+		left, err := evalBool(n.left, env, n.op)
+		if err != nil {
+			return nil, err
+		}
+		if (n.op == "&&" && !left) || (n.op == "||" && left) {
+			return left, nil
+		}
+		return evalBool(n.right, env, n.op)
+	}
+
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "in":
+		return contains(right, left)
+	case "<", "<=", ">", ">=":
+		return compare(n.op, left, right)
+	case "+":
+		switch l := left.(type) {
+		case string:
+			if r, ok := right.(string); ok {
+				return l + r, nil
+			}
+		case []interface{}:
+			if r, ok := right.([]interface{}); ok {
+				return append(append([]interface{}{}, l...), r...), nil
+			}
+		}
+	}
+
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	if !lok || !rok {
+		return nil, fmt.Errorf("operator %s is not supported for %s and %s", n.op, typeName(left), typeName(right))
+	}
+
+	switch n.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	default:
+		if r == 0 || l != float64(int64(l)) || r != float64(int64(r)) {
+			return nil, fmt.Errorf("operator %% expects non-zero integers")
+		}
+		return float64(int64(l) % int64(r)), nil
+	}
+}
+
+func (n *callNode) eval(env map[string]interface{}) (interface{}, error) {
+	if n.name == "has" {
+		_, err := n.args[0].eval(env)
+		return err == nil, nil
+	}
+
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+
+	switch n.name {
+	case "size":
+		switch v := args[0].(type) {
+		case string:
+			return float64(len([]rune(v))), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		}
+		return nil, fmt.Errorf("size() is not supported for %s", typeName(args[0]))
+
+	case "contains":
+		return contains(args[0], args[1])
+	}
+
+	// The remaining functions operate on strings only
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		s, ok := arg.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s() expects string arguments, got %s", n.name, typeName(arg))
+		}
+		strs[i] = s
+	}
+
+	switch n.name {
+	case "lower":
+		return strings.ToLower(strs[0]), nil
+	case "upper":
+		return strings.ToUpper(strs[0]), nil
+	case "startsWith":
+		return strings.HasPrefix(strs[0], strs[1]), nil
+	case "endsWith":
+		return strings.HasSuffix(strs[0], strs[1]), nil
+	default:
+		re, err := regexp.Compile(strs[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %v", err)
+		}
+		return re.MatchString(strs[0]), nil
+	}
+}
+
+// evalBool evaluates a node that must produce a boolean operand
+func evalBool(n node, env map[string]interface{}, op string) (bool, error) {
+	value, err := n.eval(env)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("operator %s expects booleans, got %s", op, typeName(value))
+	}
+	return b, nil
+}
+
+// equal compares two values structurally
+func equal(left, right interface{}) bool {
+	left, right = normalize(left), normalize(right)
+
+	switch l := left.(type) {
+	case []interface{}:
+		r, ok := right.([]interface{})
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for i := range l {
+			if !equal(l[i], r[i]) {
+				return false
+			}
+		}
+		return true
+
+	case map[string]interface{}:
+		r, ok := right.(map[string]interface{})
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for k, v := range l {
+			rv, ok := r[k]
+			if !ok || !equal(v, rv) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return reflect.DeepEqual(left, right)
+}
+
+// contains reports whether the container holds the element: an item of a list, a key of a map or a substring
+func contains(container, element interface{}) (bool, error) {
+	switch c := container.(type) {
+	case []interface{}:
+		for _, item := range c {
+			if equal(item, element) {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case map[string]interface{}:
+		key, ok := element.(string)
+		if !ok {
+			return false, fmt.Errorf("map key must be a string, got %s", typeName(element))
+		}
+		_, ok = c[key]
+		return ok, nil
+
+	case string:
+		s, ok := element.(string)
+		if !ok {
+			return false, fmt.Errorf("cannot search a string for %s", typeName(element))
+		}
+		return strings.Contains(c, s), nil
+	}
+
+	return false, fmt.Errorf("cannot search %s", typeName(container))
+}
+
+// compare orders two numbers or two strings
+func compare(op string, left, right interface{}) (bool, error) {
+	var cmp int
+
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return false, fmt.Errorf("cannot compare %s and %s", typeName(left), typeName(right))
+		}
+		switch {
+		case l < r:
+			cmp = -1
+		case l > r:
+			cmp = 1
+		}
+
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return false, fmt.Errorf("cannot compare %s and %s", typeName(left), typeName(right))
+		}
+		cmp = strings.Compare(l, r)
+
+	default:
+		return false, fmt.Errorf("cannot compare %s and %s", typeName(left), typeName(right))
+	}
+
+	switch op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+// normalize converts Go values supplied in the environment to the types the evaluator works with:
+// float64 numbers, strings, booleans, nil, []interface{} lists and map[string]interface{} maps
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, bool, string, float64, []interface{}, map[string]interface{}:
+		return v
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, s := range v {
+			items[i] = s
+		}
+		return items
+	case map[string]string:
+		m := make(map[string]interface{}, len(v))
+		for k, s := range v {
+			m[k] = s
+		}
+		return m
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = normalize(rv.Index(i).Interface())
+		}
+		return items
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			m := make(map[string]interface{}, rv.Len())
+			for _, key := range rv.MapKeys() {
+				m[key.String()] = rv.MapIndex(key).Interface()
+			}
+			return m
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	}
+
+	return value
+}
+
+// typeName returns the name of a value's type for error messages
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,258 @@
+package expr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "operators are matched greedily",
+			src:  "a<=b&&!c",
+			want: []string{"a", "<=", "b", "&&", "!", "c"},
+		},
+		{
+			name: "strings with escapes",
+			src:  `'it\'s' "a\tb"`,
+			want: []string{"it's", "a\tb"},
+		},
+		{
+			name: "numbers",
+			src:  "1 2.5",
+			want: []string{"1", "2.5"},
+		},
+		{
+			name:    "unterminated string",
+			src:     "'abc",
+			wantErr: "unterminated string at position 0",
+		},
+		{
+			name:    "invalid number",
+			src:     "1.2.3",
+			wantErr: `invalid number "1.2.3"`,
+		},
+		{
+			name:    "unexpected character",
+			src:     "a & b",
+			wantErr: `unexpected character '&' at position 2`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := tokenize(tt.src)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("tokenize(%q) error = %v, want %q", tt.src, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("tokenize(%q) error = %v", tt.src, err)
+			}
+
+			var got []string
+			for _, tok := range tokens {
+				if tok.kind != tokenEOF {
+					got = append(got, tok.text)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tokenize(%q) = %q, want %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src     string
+		wantErr string
+	}{
+		{"", "unexpected end of expression"},
+		{"1 +", "unexpected end of expression"},
+		{"(1 + 2", `expected ")" at end of expression`},
+		{"[1, 2", `expected "]" at end of expression`},
+		{"a ? b", `expected ":" at end of expression`},
+		{"a b", `unexpected "b" at position 2`},
+		{"a == b == c", `unexpected "==" at position 7`},
+		{"a.1", "expected attribute name at position 2"},
+		{"unknown(a)", `unknown function "unknown"`},
+		{"size(a, b)", "function size expects 1 arguments, got 2"},
+		{"lower()", "function lower expects 1 arguments, got 0"},
+		{"has(a)", "has() expects an attribute access"},
+		{"'abc", "unterminated string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Compile(tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Compile(%q) error = %v, want %q", tt.src, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEval(t *testing.T) {
+	env := map[string]interface{}{
+		"subject": map[string]interface{}{
+			"id":    int64(7),
+			"roles": []string{"admin", "editor"},
+			"email": "Alice@Example.com",
+		},
+		"resource": map[string]interface{}{
+			"type":  "document",
+			"owner": 7,
+			"tags":  []interface{}{"a", "b"},
+		},
+		"n": 10,
+	}
+
+	tests := []struct {
+		src  string
+		want interface{}
+	}{
+		// Precedence
+		{"1 + 2 * 3", 7.0},
+		{"(1 + 2) * 3", 9.0},
+		{"10 - 4 - 3", 3.0},
+		{"12 / 3 / 2", 2.0},
+		{"7 % 4 + 1", 4.0},
+		{"-2 * 3", -6.0},
+		{"1 + 2 == 3", true},
+		{"true || false && false", true},
+		{"(true || false) && false", false},
+		{"!false && false", false},
+		{"!(false && false)", true},
+		{"1 < 2 ? 'yes' : 'no'", "yes"},
+		{"false ? 1 : true ? 2 : 3", 2.0},
+		{"1 + 1 in [2, 3]", true},
+
+		// Attributes and literals
+		{"subject.id", 7.0},
+		{"resource['type']", "document"},
+		{"subject.roles[1]", "editor"},
+		{"resource.owner == subject.id", true},
+		{"null == null", true},
+		{"[1, 'a'] == [1, 'a']", true},
+
+		// Membership and functions
+		{"'admin' in subject.roles", true},
+		{"'type' in resource", true},
+		{"'x' in resource.tags", false},
+		{"has(resource.owner)", true},
+		{"has(resource.missing)", false},
+		{"size(subject.roles) + size('héllo')", 7.0},
+		{"lower(subject.email)", "alice@example.com"},
+		{"upper('a')", "A"},
+		{"startsWith(subject.email, 'Alice')", true},
+		{"endsWith(subject.email, '.org')", false},
+		{"contains(subject.email, '@')", true},
+		{"matches(subject.email, '^[A-Z]')", true},
+		{"'ab' + 'cd'", "abcd"},
+		{"size([1] + [2, 3])", 3.0},
+		{"'b' > 'a' && n >= 10", true},
+
+		// Short-circuiting skips operands that would fail
+		{"false && missing", false},
+		{"true || missing", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			program, err := Compile(tt.src)
+			if err != nil {
+				t.Fatalf("Compile(%q) error = %v", tt.src, err)
+			}
+
+			got, err := program.Eval(env)
+			if err != nil {
+				t.Fatalf("Eval(%q) error = %v", tt.src, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Eval(%q) = %#v, want %#v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	env := map[string]interface{}{
+		"subject": map[string]interface{}{
+			"roles": []string{"admin"},
+		},
+		"n": 1,
+	}
+
+	tests := []struct {
+		src     string
+		wantErr string
+	}{
+		{"missing", `undefined variable "missing"`},
+		{"subject.missing", `no such attribute "missing"`},
+		{"subject[1]", "map key must be a string, got number"},
+		{"subject.roles[5]", "list index 5 out of range"},
+		{"subject.roles[0.5]", "list index must be an integer, got number"},
+		{"n.x", "cannot access attributes of number"},
+		{"!n", "operator ! expects a boolean, got number"},
+		{"-'a'", "operator - expects a number, got string"},
+		{"n && true", "operator && expects booleans, got number"},
+		{"n ? 1 : 2", "operator ? expects booleans, got number"},
+		{"'a' + 1", "operator + is not supported for string and number"},
+		{"[1] - [1]", "operator - is not supported for list and list"},
+		{"1 / 0", "division by zero"},
+		{"1.5 % 1", "operator % expects non-zero integers"},
+		{"1 < 'a'", "cannot compare number and string"},
+		{"true < false", "cannot compare bool and bool"},
+		{"1 in n", "cannot search number"},
+		{"1 in subject", "map key must be a string, got number"},
+		{"1 in 'abc'", "cannot search a string for number"},
+		{"size(n)", "size() is not supported for number"},
+		{"lower(n)", "lower() expects string arguments, got number"},
+		{"matches('a', '(')", "invalid regular expression"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			program, err := Compile(tt.src)
+			if err != nil {
+				t.Fatalf("Compile(%q) error = %v", tt.src, err)
+			}
+
+			_, err = program.Eval(env)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Eval(%q) error = %v, want %q", tt.src, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEvalBool(t *testing.T) {
+	program, err := Compile("1 + 1")
+	if err != nil {
+		t.Fatalf("Compile error = %v", err)
+	}
+
+	_, err = program.EvalBool(nil)
+	if err == nil || !strings.Contains(err.Error(), "expression must evaluate to a boolean, got number") {
+		t.Fatalf("EvalBool error = %v", err)
+	}
+
+	program, err = Compile("1 + 1 == 2")
+	if err != nil {
+		t.Fatalf("Compile error = %v", err)
+	}
+
+	got, err := program.EvalBool(nil)
+	if err != nil || !got {
+		t.Fatalf("EvalBool = %v, %v, want true", got, err)
+	}
+}
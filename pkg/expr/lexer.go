@@ -0,0 +1,121 @@
+// Package expr implements a small CEL-like expression language used by authorization policies.
+//
+// Expressions support literals (numbers, 'strings', true, false, null, [lists]), attribute access
+// (subject.roles, resource["type"]), arithmetic (+ - * / %), comparisons (== != < <= > >=),
+// membership (x in list, "key" in map), logical operators (&& || !), the conditional operator
+// (cond ? a : b) and a few functions: has, size, lower, upper, startsWith, endsWith, contains, matches.
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{}
+	pos   int
+}
+
+// operators lists the supported operators, longest first so that they are matched greedily
+var operators = []string{
+	"==", "!=", "<=", ">=", "&&", "||",
+	"<", ">", "!", "+", "-", "*", "/", "%", "(", ")", "[", "]", ".", ",", "?", ":",
+}
+
+// tokenize splits the source into tokens
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i]), pos: start})
+
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			value, err := strconv.ParseFloat(string(runes[start:i]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", string(runes[start:i]), start)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i]), value: value, pos: start})
+
+		case r == '\'' || r == '"':
+			start := i
+			quote := r
+			i++
+
+			var sb strings.Builder
+			closed := false
+			for i < len(runes) {
+				c := runes[i]
+				if c == quote {
+					closed = true
+					i++
+					break
+				}
+				if c == '\\' && i+1 < len(runes) {
+					i++
+					switch runes[i] {
+					case 'n':
+						sb.WriteRune('\n')
+					case 't':
+						sb.WriteRune('\t')
+					default:
+						sb.WriteRune(runes[i])
+					}
+					i++
+					continue
+				}
+				sb.WriteRune(c)
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: sb.String(), value: sb.String(), pos: start})
+
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+			}
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(runes)}), nil
+}
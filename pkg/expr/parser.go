@@ -0,0 +1,355 @@
+package expr
+
+import (
+	"fmt"
+)
+
+// node is a node of a parsed expression
+type node interface {
+	eval(env map[string]interface{}) (interface{}, error)
+}
+
+type (
+	literalNode struct {
+		value interface{}
+	}
+
+	identNode struct {
+		name string
+	}
+
+	memberNode struct {
+		target node
+		key    node
+	}
+
+	listNode struct {
+		items []node
+	}
+
+	unaryNode struct {
+		op      string
+		operand node
+	}
+
+	binaryNode struct {
+		op          string
+		left, right node
+	}
+
+	conditionalNode struct {
+		cond, then, otherwise node
+	}
+
+	callNode struct {
+		name string
+		args []node
+	}
+)
+
+// Program is a compiled expression
+type Program struct {
+	source string
+	root   node
+}
+
+// Compile parses an expression into a program
+func Compile(src string) (*Program, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+
+	return &Program{source: src, root: root}, nil
+}
+
+// String returns the source of the program
+func (p *Program) String() string {
+	return p.source
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is one of the operators or keywords
+func (p *parser) accept(texts ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokenOperator && tok.kind != tokenIdent {
+		return "", false
+	}
+
+	for _, text := range texts {
+		if tok.text == text {
+			p.next()
+			return text, true
+		}
+	}
+
+	return "", false
+}
+
+func (p *parser) expect(text string) error {
+	if _, ok := p.accept(text); !ok {
+		tok := p.peek()
+		if tok.kind == tokenEOF {
+			return fmt.Errorf("expected %q at end of expression", text)
+		}
+		return fmt.Errorf("expected %q at position %d, got %q", text, tok.pos, tok.text)
+	}
+	return nil
+}
+
+func (p *parser) parseConditional() (node, error) {
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := p.accept("?"); !ok {
+		return cond, nil
+	}
+
+	then, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+
+	otherwise, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+
+	return &conditionalNode{cond: cond, then: then, otherwise: otherwise}, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	return p.parseBinary(p.parseAnd, "||")
+}
+
+func (p *parser) parseAnd() (node, error) {
+	return p.parseBinary(p.parseComparison, "&&")
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.accept("==", "!=", "<", "<=", ">", ">=", "in")
+	if !ok {
+		return left, nil
+	}
+
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	return &binaryNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	return p.parseBinary(p.parseUnary, "*", "/", "%")
+}
+
+// parseBinary parses a left-associative chain of binary operators
+func (p *parser) parseBinary(operand func() (node, error), ops ...string) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return left, nil
+		}
+
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if op, ok := p.accept("!", "-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: op, operand: operand}, nil
+	}
+
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	target, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept("."); ok {
+			tok := p.next()
+			if tok.kind != tokenIdent {
+				return nil, fmt.Errorf("expected attribute name at position %d", tok.pos)
+			}
+			target = &memberNode{target: target, key: &literalNode{value: tok.text}}
+			continue
+		}
+
+		if _, ok := p.accept("["); ok {
+			key, err := p.parseConditional()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			target = &memberNode{target: target, key: key}
+			continue
+		}
+
+		return target, nil
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenNumber, tokenString:
+		return &literalNode{value: tok.value}, nil
+
+	case tokenIdent:
+		switch tok.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+
+		if _, ok := p.accept("("); ok {
+			return p.parseCall(tok)
+		}
+
+		return &identNode{name: tok.text}, nil
+
+	case tokenOperator:
+		switch tok.text {
+		case "(":
+			inner, err := p.parseConditional()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+
+		case "[":
+			list := &listNode{}
+			if _, ok := p.accept("]"); ok {
+				return list, nil
+			}
+			for {
+				item, err := p.parseConditional()
+				if err != nil {
+					return nil, err
+				}
+				list.items = append(list.items, item)
+
+				if _, ok := p.accept(","); ok {
+					continue
+				}
+				if err := p.expect("]"); err != nil {
+					return nil, err
+				}
+				return list, nil
+			}
+		}
+	}
+
+	if tok.kind == tokenEOF {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	arity, ok := functionArity[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at position %d", name.text, name.pos)
+	}
+
+	call := &callNode{name: name.text}
+	if _, ok := p.accept(")"); !ok {
+		for {
+			arg, err := p.parseConditional()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+
+			if _, ok := p.accept(","); ok {
+				continue
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	if len(call.args) != arity {
+		return nil, fmt.Errorf("function %s expects %d arguments, got %d", name.text, arity, len(call.args))
+	}
+
+	// has() checks for the presence of an attribute, so its argument must be an attribute access
+	if name.text == "has" {
+		if _, ok := call.args[0].(*memberNode); !ok {
+			return nil, fmt.Errorf("has() expects an attribute access such as has(resource.owner)")
+		}
+	}
+
+	return call, nil
+}
@@ -0,0 +1,104 @@
+// Package mailaddr normalizes email addresses so that equivalent spellings of an address map to a single account
+package mailaddr
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// ErrInvalidAddress is returned for strings that cannot be normalized as an email address
+var ErrInvalidAddress = errors.New("invalid email address")
+
+// provider describes how a mail provider interprets the local part of its addresses
+type provider struct {
+	// domain is the canonical domain of the provider's aliases
+	domain string
+	// ignoreDots drops dots from the local part (john.doe and johndoe are the same mailbox)
+	ignoreDots bool
+	// dotsAsHyphens treats dots and hyphens in the local part as the same character
+	dotsAsHyphens bool
+	// subaddressing drops the "+tag" suffix of the local part
+	subaddressing bool
+}
+
+var (
+	gmail   = provider{domain: "gmail.com", ignoreDots: true, subaddressing: true}
+	yandex  = provider{domain: "yandex.ru", dotsAsHyphens: true, subaddressing: true}
+	outlook = provider{subaddressing: true}
+	icloud  = provider{domain: "icloud.com", subaddressing: true}
+)
+
+// providers maps the domains with provider-specific rules to their providers
+var providers = map[string]provider{
+	"gmail.com":      gmail,
+	"googlemail.com": gmail,
+	"yandex.ru":      yandex,
+	"yandex.com":     yandex,
+	"yandex.by":      yandex,
+	"yandex.kz":      yandex,
+	"yandex.ua":      yandex,
+	"ya.ru":          yandex,
+	"outlook.com":    outlook,
+	"hotmail.com":    outlook,
+	"live.com":       outlook,
+	"icloud.com":     icloud,
+	"me.com":         icloud,
+	"mac.com":        icloud,
+}
+
+// Normalizer normalizes email addresses
+type Normalizer struct {
+	// ProviderRules applies the dot, hyphen and "+tag" rules of well-known providers, e.g. J.Doe+news@googlemail.com
+	// becomes jdoe@gmail.com. Without it only case, whitespace and the domain encoding are normalized.
+	ProviderRules bool
+}
+
+// Normalize trims and lowercases an address and converts an internationalized domain to its ASCII (punycode) form
+func (n Normalizer) Normalize(address string) (string, error) {
+	address = strings.TrimSpace(address)
+
+	at := strings.LastIndex(address, "@")
+	if at <= 0 || at == len(address)-1 {
+		return "", ErrInvalidAddress
+	}
+
+	local := strings.ToLower(address[:at])
+	domain, err := idna.Lookup.ToASCII(strings.TrimSuffix(address[at+1:], "."))
+	if err != nil {
+		return "", ErrInvalidAddress
+	}
+	domain = strings.ToLower(domain)
+
+	if n.ProviderRules {
+		if p, ok := providers[domain]; ok {
+			local = p.normalizeLocal(local)
+			if p.domain != "" {
+				domain = p.domain
+			}
+		}
+	}
+
+	if local == "" {
+		return "", ErrInvalidAddress
+	}
+
+	return local + "@" + domain, nil
+}
+
+// normalizeLocal applies the provider rules to the local part of an address
+func (p provider) normalizeLocal(local string) string {
+	if p.subaddressing {
+		if plus := strings.Index(local, "+"); plus >= 0 {
+			local = local[:plus]
+		}
+	}
+	if p.ignoreDots {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	if p.dotsAsHyphens {
+		local = strings.ReplaceAll(local, ".", "-")
+	}
+	return local
+}
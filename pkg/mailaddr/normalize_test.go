@@ -0,0 +1,97 @@
+package mailaddr
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name          string
+		address       string
+		providerRules bool
+		want          string
+		wantErr       bool
+	}{
+		// Basic normalization
+		{name: "lowercases and trims", address: "  John.Doe@Example.COM \t", want: "john.doe@example.com"},
+		{name: "drops the trailing dot of the domain", address: "john@example.com.", want: "john@example.com"},
+		{name: "keeps the last @ as the separator", address: `"a@b"@example.com`, want: `"a@b"@example.com`},
+		{name: "internationalized domain", address: "user@Пример.РФ", want: "user@xn--e1afmkfd.xn--p1ai"},
+		{name: "punycode domain is kept", address: "user@xn--e1afmkfd.xn--p1ai", want: "user@xn--e1afmkfd.xn--p1ai"},
+		{name: "non-ASCII local part is kept", address: "Иван@example.com", want: "иван@example.com"},
+
+		// Provider rules are off by default
+		{name: "gmail without provider rules", address: "J.Doe+news@googlemail.com", want: "j.doe+news@googlemail.com"},
+		{name: "yandex without provider rules", address: "ivan.petrov@ya.ru", want: "ivan.petrov@ya.ru"},
+
+		// Gmail
+		{name: "gmail dots", address: "j.o.h.n.doe@gmail.com", providerRules: true, want: "johndoe@gmail.com"},
+		{name: "gmail plus tag", address: "johndoe+news+daily@gmail.com", providerRules: true, want: "johndoe@gmail.com"},
+		{name: "googlemail alias", address: "J.Doe+news@GoogleMail.com", providerRules: true, want: "jdoe@gmail.com"},
+		{name: "gmail dots after the tag are dropped with it", address: "john+a.b@gmail.com", providerRules: true, want: "john@gmail.com"},
+
+		// Yandex
+		{name: "yandex dots become hyphens", address: "ivan.petrov@yandex.ru", providerRules: true, want: "ivan-petrov@yandex.ru"},
+		{name: "yandex hyphens are kept", address: "ivan-petrov@yandex.ru", providerRules: true, want: "ivan-petrov@yandex.ru"},
+		{name: "yandex regional alias", address: "Ivan.Petrov+shop@ya.ru", providerRules: true, want: "ivan-petrov@yandex.ru"},
+		{name: "yandex.com alias", address: "ivan@yandex.com", providerRules: true, want: "ivan@yandex.ru"},
+
+		// Providers with subaddressing only
+		{name: "outlook keeps its domain", address: "john.doe+x@hotmail.com", providerRules: true, want: "john.doe@hotmail.com"},
+		{name: "icloud alias", address: "john.doe+x@me.com", providerRules: true, want: "john.doe@icloud.com"},
+
+		// Unknown providers keep dots and tags
+		{name: "unknown provider", address: "john.doe+x@example.com", providerRules: true, want: "john.doe+x@example.com"},
+		{name: "gmail subdomain is not gmail", address: "j.doe+x@mail.gmail.com", providerRules: true, want: "j.doe+x@mail.gmail.com"},
+
+		// Invalid addresses
+		{name: "empty", address: "", wantErr: true},
+		{name: "no @", address: "john.example.com", wantErr: true},
+		{name: "empty local part", address: "@example.com", wantErr: true},
+		{name: "empty domain", address: "john@", wantErr: true},
+		{name: "invalid domain", address: "john@exa mple.com", wantErr: true},
+		{name: "only a plus tag", address: "+news@gmail.com", providerRules: true, wantErr: true},
+		{name: "only dots", address: "...@gmail.com", providerRules: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalizer{ProviderRules: tt.providerRules}.Normalize(tt.address)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidAddress) {
+					t.Fatalf("Normalize(%q) = %q, %v, want ErrInvalidAddress", tt.address, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Normalize(%q) error = %v", tt.address, err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.address, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeIdempotent(t *testing.T) {
+	n := Normalizer{ProviderRules: true}
+	for _, address := range []string{
+		"J.Doe+news@GoogleMail.com",
+		"Ivan.Petrov+shop@ya.ru",
+		"user@Пример.РФ",
+		"john.doe+x@me.com",
+	} {
+		once, err := n.Normalize(address)
+		if err != nil {
+			t.Fatalf("Normalize(%q) error = %v", address, err)
+		}
+		twice, err := n.Normalize(once)
+		if err != nil {
+			t.Fatalf("Normalize(%q) error = %v", once, err)
+		}
+		if once != twice {
+			t.Errorf("Normalize is not idempotent for %q: %q then %q", address, once, twice)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+// Package scimfilter parses SCIM 2.0 filter expressions (RFC 7644, section 3.4.2.2).
+//
+// Filters compare attribute paths (userName, name.givenName, meta.lastModified, optionally prefixed with the URN of
+// their schema) with the operators eq, ne, co, sw, ew, gt, ge, lt, le and pr, and combine comparisons with and, or,
+// not and parentheses. Values are JSON strings, numbers, true, false and null. Value paths such as
+// emails[type eq "work"] are not supported.
+package scimfilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Operators lists the comparison operators; pr takes no value
+var Operators = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"gt": true, "ge": true, "lt": true, "le": true, "pr": true,
+}
+
+// Expr is a node of a parsed filter: *Logical, *Not or *Comparison
+type Expr interface {
+	isExpr()
+}
+
+// Logical combines two filters with "and" or "or"
+type Logical struct {
+	Op          string
+	Left, Right Expr
+}
+
+// Not negates a filter
+type Not struct {
+	Expr Expr
+}
+
+// Comparison compares an attribute with a value. Attr is the attribute path without its schema URN, Op is lowercase
+// and Value is a string, float64, bool or nil; it is nil for pr.
+type Comparison struct {
+	Attr  string
+	Op    string
+	Value interface{}
+}
+
+func (*Logical) isExpr()    {}
+func (*Not) isExpr()        {}
+func (*Comparison) isExpr() {}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenValue
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{}
+	pos   int
+}
+
+// Parse parses a filter
+func Parse(src string) (Expr, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+
+	return expr, nil
+}
+
+// tokenize splits the filter into words (attribute paths, operators, keywords), values and parentheses
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenOpen, text: "(", pos: i})
+			i++
+
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenClose, text: ")", pos: i})
+			i++
+
+		case r == '"':
+			start := i
+			i++
+			for i < len(runes) && runes[i] != '"' {
+				if runes[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			i++
+
+			var value string
+			if err := json.Unmarshal([]byte(string(runes[start:i])), &value); err != nil {
+				return nil, fmt.Errorf("invalid string at position %d", start)
+			}
+			tokens = append(tokens, token{kind: tokenValue, text: string(runes[start:i]), value: value, pos: start})
+
+		case r == '-' || unicode.IsDigit(r):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || strings.ContainsRune(".eE+-", runes[i])) {
+				i++
+			}
+			value, err := strconv.ParseFloat(string(runes[start:i]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", string(runes[start:i]), start)
+			}
+			tokens = append(tokens, token{kind: tokenValue, text: string(runes[start:i]), value: value, pos: start})
+
+		case unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || strings.ContainsRune("_-.:$", runes[i])) {
+				i++
+			}
+			word := string(runes[start:i])
+			switch word {
+			case "true", "false":
+				tokens = append(tokens, token{kind: tokenValue, text: word, value: word == "true", pos: start})
+			case "null":
+				tokens = append(tokens, token{kind: tokenValue, text: word, pos: start})
+			default:
+				tokens = append(tokens, token{kind: tokenWord, text: word, pos: start})
+			}
+
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// keyword reports whether the next token is the keyword, consuming it if so
+func (p *parser) keyword(word string) bool {
+	tok := p.peek()
+	if tok.kind == tokenWord && strings.EqualFold(tok.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: "or", Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("and") {
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: "and", Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseTerm() (Expr, error) {
+	if p.keyword("not") {
+		if tok := p.next(); tok.kind != tokenOpen {
+			return nil, fmt.Errorf("expected \"(\" after not at position %d", tok.pos)
+		}
+		expr, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Expr: expr}, nil
+	}
+
+	if p.peek().kind == tokenOpen {
+		p.next()
+		return p.parseGroup()
+	}
+
+	attr := p.next()
+	if attr.kind != tokenWord {
+		return nil, fmt.Errorf("expected an attribute at position %d", attr.pos)
+	}
+
+	opTok := p.next()
+	op := strings.ToLower(opTok.text)
+	if opTok.kind != tokenWord || !Operators[op] {
+		return nil, fmt.Errorf("expected an operator at position %d", opTok.pos)
+	}
+
+	comparison := &Comparison{Attr: attributePath(attr.text), Op: op}
+	if op == "pr" {
+		return comparison, nil
+	}
+
+	value := p.next()
+	if value.kind != tokenValue {
+		return nil, fmt.Errorf("expected a value at position %d", value.pos)
+	}
+	comparison.Value = value.value
+
+	return comparison, nil
+}
+
+// parseGroup parses a parenthesized filter after its opening parenthesis
+func (p *parser) parseGroup() (Expr, error) {
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.next(); tok.kind != tokenClose {
+		return nil, fmt.Errorf("expected \")\" at position %d", tok.pos)
+	}
+
+	return expr, nil
+}
+
+// attributePath strips the schema URN from an attribute path, e.g.
+// urn:ietf:params:scim:schemas:core:2.0:User:userName becomes userName
+func attributePath(path string) string {
+	if strings.HasPrefix(strings.ToLower(path), "urn:") {
+		return path[strings.LastIndex(path, ":")+1:]
+	}
+	return path
+}
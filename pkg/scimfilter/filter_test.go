@@ -0,0 +1,106 @@
+package scimfilter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// format renders a parsed filter with explicit parentheses to make its structure visible
+func format(expr Expr) string {
+	switch expr := expr.(type) {
+	case *Logical:
+		return fmt.Sprintf("(%s %s %s)", format(expr.Left), expr.Op, format(expr.Right))
+	case *Not:
+		return fmt.Sprintf("not(%s)", format(expr.Expr))
+	case *Comparison:
+		if expr.Op == "pr" {
+			return expr.Attr + " pr"
+		}
+		return fmt.Sprintf("%s %s %#v", expr.Attr, expr.Op, expr.Value)
+	}
+	return "?"
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		// Values
+		{`userName eq "bjensen"`, `userName eq "bjensen"`},
+		{`title eq "say \"hi\"!"`, `title eq "say \"hi\"!"`},
+		{`age gt 21`, `age gt 21`},
+		{`score le -1.5e2`, `score le -150`},
+		{`active eq true`, `active eq true`},
+		{`active ne false`, `active ne false`},
+		{`externalId eq null`, `externalId eq <nil>`},
+		{`title pr`, `title pr`},
+
+		// Operators and keywords are case-insensitive, attribute names are kept as is
+		{`UserName EQ "x"`, `UserName eq "x"`},
+		{`a eq 1 AND b eq 2 Or c Pr`, `((a eq 1 and b eq 2) or c pr)`},
+		{`NOT (a pr)`, `not(a pr)`},
+
+		// Attribute paths
+		{`name.familyName co "O'Malley"`, `name.familyName co "O'Malley"`},
+		{`meta.lastModified gt "2011-05-13T04:42:34Z"`, `meta.lastModified gt "2011-05-13T04:42:34Z"`},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName sw "J"`, `userName sw "J"`},
+		{`urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber eq "7"`, `employeeNumber eq "7"`},
+
+		// Precedence: not binds tighter than and, and binds tighter than or, both are left-associative
+		{`a pr or b pr and c pr`, `(a pr or (b pr and c pr))`},
+		{`a pr and b pr or c pr`, `((a pr and b pr) or c pr)`},
+		{`a pr and b pr and c pr`, `((a pr and b pr) and c pr)`},
+		{`a pr or b pr or c pr`, `((a pr or b pr) or c pr)`},
+		{`(a pr or b pr) and c pr`, `((a pr or b pr) and c pr)`},
+		{`not (a pr) and b pr`, `(not(a pr) and b pr)`},
+		{`not (a pr or b pr)`, `not((a pr or b pr))`},
+		{`((a eq "x"))`, `a eq "x"`},
+		{`a pr and not (b eq null or not (c pr))`, `(a pr and not((b eq <nil> or not(c pr))))`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			expr, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.src, err)
+			}
+			if got := format(expr); got != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src     string
+		wantErr string
+	}{
+		{``, "expected an attribute at position 0"},
+		{`userName`, "expected an operator at position 8"},
+		{`userName is "x"`, "expected an operator at position 9"},
+		{`userName eq`, "expected a value at position 11"},
+		{`userName eq bjensen`, "expected a value at position 12"},
+		{`userName eq "x" "y"`, `unexpected "\"y\"" at position 16`},
+		{`userName eq "x" and`, "expected an attribute at position 19"},
+		{`"x" eq userName`, "expected an attribute at position 0"},
+		{`(userName pr`, `expected ")" at position 12`},
+		{`userName pr)`, `unexpected ")" at position 11`},
+		{`not userName pr`, `expected "(" after not at position 4`},
+		{`userName eq "x`, "unterminated string at position 12"},
+		{`userName eq "\x"`, "invalid string at position 12"},
+		{`age gt 1-2`, `invalid number "1-2" at position 7`},
+		{`emails[type eq "work"]`, `unexpected character '[' at position 6`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Parse(tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Parse(%q) error = %v, want %q", tt.src, err, tt.wantErr)
+			}
+		})
+	}
+}